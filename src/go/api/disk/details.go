@@ -3,6 +3,8 @@ package disk
 import (
 	"encoding/json"
 	"strings"
+
+	v1 "phenix/types/version/v1"
 )
 
 type Kind uint8
@@ -14,7 +16,22 @@ const (
 	ISOImage
 )
 
-var knownImageExtensions = []string{".qcow2", ".qc2", "_rootfs.tgz", ".hdd", ".iso"} //nolint:gochecknoglobals // global constant
+var knownImageExtensions = []string{".qcow2", ".qc2", "_rootfs.tgz", ".hdd", ".iso", ".raw", ".vmdk", ".vdi", ".vhdx"} //nolint:gochecknoglobals // global constant
+
+//nolint:gochecknoglobals // global constant
+var formatExtensions = map[v1.Format]string{
+	v1.FormatQcow2: ".qcow2",
+	v1.FormatRaw:   ".raw",
+	v1.FormatVmdk:  ".vmdk",
+	v1.FormatVdi:   ".vdi",
+	v1.FormatVhdx:  ".vhdx",
+}
+
+// FormatExtension returns the file extension used for disks in the given
+// format, or an empty string if the format isn't supported.
+func FormatExtension(format v1.Format) string {
+	return formatExtensions[format]
+}
 
 func (k Kind) MarshalJSON() ([]byte, error) {
 	return json.Marshal(k.String())
@@ -7,6 +7,7 @@ import (
 	"strings"
 
 	"phenix/api/experiment"
+	v1 "phenix/types/version/v1"
 	"phenix/util/mm"
 	"phenix/util/mm/mmcli"
 	"phenix/util/plog"
@@ -67,6 +68,18 @@ func (MMDiskFiles) RenameDisk(src, dst string) error {
 	return err
 }
 
+func (MMDiskFiles) ConvertDisk(src, dst string, format v1.Format) error {
+	if _, ok := formatExtensions[format]; !ok {
+		return fmt.Errorf("unsupported disk format %q", format)
+	}
+
+	cmd := mmcli.NewCommand()
+	cmd.Command = fmt.Sprintf("shell qemu-img convert -O %s %s %s", format, src, dst)
+	_, err := mmcli.SingleDataResponse(mmcli.Run(cmd))
+
+	return err
+}
+
 func (MMDiskFiles) DeleteDisk(src string) error {
 	cmd := mmcli.NewCommand()
 	cmd.Command = "shell rm " + src
@@ -3,7 +3,10 @@ Package disk is an implementation of the phenix disk API.
 This API is used for managing existing images/disks used by VMs.
 
 Provides functionality for getting a detailed list of disks.
-Allows for basic operations such as uploading, deleting, renaming, and copying.
+Allows for basic operations such as uploading, deleting, renaming, and copying,
+as well as converting disks between formats and importing VMs from OVAs.
+Records sidecar manifests with the content hash and size of images that are
+built, uploaded, cloned, or committed to, so images modified in place can be detected.
 Also allows for QEMU operations such as rebasing, snapshotting, and committing by wrapping minimega commands.
 
 NOTE: In a mesh, it is assumed that all disks are on the head node.
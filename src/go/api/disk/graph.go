@@ -0,0 +1,362 @@
+package disk
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"phenix/api/experiment"
+	"phenix/api/vm"
+	"phenix/store"
+	"phenix/types"
+	"phenix/util/mm"
+	"phenix/util/mm/mmcli"
+	"phenix/util/plog"
+)
+
+// diskImageExtensions are the extensions of the images included in the
+// backing-file graph. Other known images (e.g., ISOs and container
+// filesystems) can't have backing files.
+var diskImageExtensions = []string{".qcow2", ".qc2", ".hdd"} //nolint:gochecknoglobals // global constant
+
+var ErrIncompleteReferences = errors.New("unable to collect all disk image references")
+
+// GraphImage is a disk image in the backing-file graph of the minimega files
+// directory.
+type GraphImage struct {
+	Path string `json:"path"`
+	Size int64  `json:"size"`
+	// Backing is the image this image is an overlay of, if any.
+	Backing  string   `json:"backing,omitempty"`
+	Children []string `json:"children,omitempty"`
+	// References are the topologies, experiments, snapshot catalogs, and running
+	// VMs that reference the image directly (e.g., "topology/foo").
+	References []string `json:"references,omitempty"`
+	// InUse is true if the image, or an overlay of it, is referenced.
+	InUse bool `json:"inUse"`
+	// Orphan is true for overlays and snapshots that aren't in use. They're
+	// safe to delete.
+	Orphan bool `json:"orphan"`
+	// Error is set if the image's backing chain couldn't be read, in which case
+	// the image is never considered an orphan.
+	Error string `json:"error,omitempty"`
+}
+
+// Graph is the backing-file graph of every disk image in the minimega files
+// directory.
+type Graph struct {
+	Images []GraphImage `json:"images"`
+	// Reclaimable is the number of bytes that would be freed by deleting all
+	// orphaned images.
+	Reclaimable int64 `json:"reclaimable"`
+	// Errors are set for topologies and experiments whose image references
+	// couldn't be collected, in which case images they reference may be
+	// reported as orphans.
+	Errors []string `json:"errors,omitempty"`
+}
+
+// Orphans returns the orphaned images in the graph, ordered so overlays come
+// before the images they're overlays of and can be deleted in order.
+func (g Graph) Orphans() []GraphImage {
+	var (
+		orphans []GraphImage
+		backing = make(map[string]string, len(g.Images))
+		depths  = make(map[string]int)
+	)
+
+	for _, image := range g.Images {
+		backing[image.Path] = image.Backing
+
+		if image.Orphan {
+			orphans = append(orphans, image)
+		}
+	}
+
+	for _, image := range orphans {
+		// Limit the depth in case of a (corrupt) backing chain loop.
+		for b := image.Backing; b != "" && depths[image.Path] < len(g.Images); b = backing[b] {
+			depths[image.Path]++
+		}
+	}
+
+	sort.SliceStable(orphans, func(i, j int) bool { return depths[orphans[i].Path] > depths[orphans[j].Path] })
+
+	return orphans
+}
+
+// BackingGraph builds the backing-file graph of every disk image in the
+// minimega files directory, including experiment files directories, and marks
+// the images referenced by any topology, experiment, snapshot catalog, or
+// running VM.
+func BackingGraph() (Graph, error) {
+	paths, err := diskImages()
+	if err != nil {
+		return Graph{}, err //nolint:exhaustruct // error
+	}
+
+	var (
+		chains = make(map[string][]string)
+		sizes  = make(map[string]int64)
+		errs   = make(map[string]string)
+	)
+
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			continue // skip files removed since the walk
+		}
+
+		sizes[path] = info.Size()
+
+		chain, err := mm.ImageBackingChain("", path)
+		if err != nil {
+			errs[path] = err.Error()
+
+			continue
+		}
+
+		chains[path] = chain
+	}
+
+	refs, refErrs, err := imageReferences()
+	if err != nil {
+		return Graph{}, err //nolint:exhaustruct // error
+	}
+
+	graph := buildGraph(chains, sizes, errs, refs)
+	graph.Errors = refErrs
+
+	return graph, nil
+}
+
+// CollectGarbage deletes the orphaned overlays and snapshots in the minimega
+// files directory. For a dry run, nothing is deleted. It returns the images
+// that were (or would be) deleted and the number of bytes freed. Nothing is
+// deleted if any image references couldn't be collected, since the images they
+// reference would be deleted too; ErrIncompleteReferences is returned instead.
+func CollectGarbage(dryRun bool) ([]GraphImage, int64, error) {
+	graph, err := BackingGraph()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return collectGarbage(graph, dryRun)
+}
+
+func collectGarbage(graph Graph, dryRun bool) ([]GraphImage, int64, error) {
+	orphans := graph.Orphans()
+
+	if len(graph.Errors) > 0 {
+		err := fmt.Errorf("%w: %s", ErrIncompleteReferences, strings.Join(graph.Errors, "; "))
+
+		if dryRun {
+			return orphans, graph.Reclaimable, err
+		}
+
+		return nil, 0, err
+	}
+
+	if dryRun {
+		return orphans, graph.Reclaimable, nil
+	}
+
+	var (
+		deleted []GraphImage
+		freed   int64
+	)
+
+	for _, image := range orphans {
+		if err := DeleteDisk(image.Path); err != nil {
+			return deleted, freed, fmt.Errorf("deleting orphaned image %s: %w", image.Path, err)
+		}
+
+		plog.Info(plog.TypeSystem, "deleted orphaned disk image", "path", image.Path, "size", image.Size)
+
+		deleted = append(deleted, image)
+		freed += image.Size
+	}
+
+	return deleted, freed, nil
+}
+
+// buildGraph builds the backing-file graph from the backing chain of each
+// image, keyed by path. Images are marked in use if they, or any overlay of
+// them, have references.
+func buildGraph(chains map[string][]string, sizes map[string]int64, errs map[string]string, refs map[string][]string) Graph {
+	images := make(map[string]*GraphImage)
+
+	get := func(path string) *GraphImage {
+		image, ok := images[path]
+		if !ok {
+			image = &GraphImage{Path: path, Size: sizes[path]} //nolint:exhaustruct // partial initialization
+			images[path] = image
+		}
+
+		return image
+	}
+
+	for path, msg := range errs {
+		get(path).Error = msg
+	}
+
+	for path, chain := range chains {
+		get(path)
+
+		for i := 1; i < len(chain); i++ {
+			child, parent := get(chain[i-1]), get(chain[i])
+
+			if child.Backing == "" {
+				child.Backing = parent.Path
+				parent.Children = append(parent.Children, child.Path)
+			}
+		}
+	}
+
+	for path, references := range refs {
+		if image, ok := images[path]; ok {
+			image.References = references
+		}
+	}
+
+	// Images that couldn't be inspected are treated as in use, along with
+	// anything they might be overlays of, to be safe.
+	for _, image := range images {
+		if len(image.References) == 0 && image.Error == "" {
+			continue
+		}
+
+		for i := image; i != nil && !i.InUse; i = images[i.Backing] {
+			i.InUse = true
+		}
+	}
+
+	graph := Graph{Images: make([]GraphImage, 0, len(images)), Reclaimable: 0, Errors: nil}
+
+	for _, image := range images {
+		// Only overlays and snapshots are orphans; base images are kept even if
+		// they're not in use. Images outside the files directory (e.g., backing
+		// images elsewhere) weren't walked and are never orphans.
+		_, walked := sizes[image.Path]
+
+		image.Orphan = !image.InUse && image.Backing != "" && walked
+		if image.Orphan {
+			graph.Reclaimable += image.Size
+		}
+
+		sort.Strings(image.Children)
+
+		graph.Images = append(graph.Images, *image)
+	}
+
+	sort.Slice(graph.Images, func(i, j int) bool { return graph.Images[i].Path < graph.Images[j].Path })
+
+	return graph
+}
+
+// imageReferences returns the references to disk images, keyed by absolute
+// path, from all topologies, experiments, snapshot catalogs, and VMs in all
+// minimega namespaces. Topologies and snapshot catalogs that can't be read are
+// skipped and returned as errors so callers know the references are incomplete.
+func imageReferences() (map[string][]string, []string, error) {
+	var (
+		refs = make(map[string][]string)
+		errs []string
+	)
+
+	add := func(image, ref string) {
+		if image == "" {
+			return
+		}
+
+		image = mm.GetMMFullPath(image)
+
+		// Resolve symlinked images (e.g., base images linked into the files
+		// directory) so both the link and its target are referenced.
+		if target, err := filepath.EvalSymlinks(image); err == nil && target != image {
+			refs[target] = append(refs[target], ref)
+		}
+
+		refs[image] = append(refs[image], ref)
+	}
+
+	topologies, err := store.List("Topology")
+	if err != nil {
+		return nil, nil, fmt.Errorf("getting topologies: %w", err)
+	}
+
+	for _, c := range topologies {
+		topo, err := types.DecodeTopologyFromConfig(c)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("decoding topology %s: %v", c.Metadata.Name, err))
+
+			continue
+		}
+
+		for _, node := range topo.Nodes() {
+			for _, drive := range node.Hardware().Drives() {
+				add(drive.Image(), "topology/"+c.Metadata.Name)
+			}
+		}
+	}
+
+	experiments, err := experiment.List()
+	if err != nil {
+		return nil, nil, fmt.Errorf("getting experiments: %w", err)
+	}
+
+	for _, exp := range experiments {
+		name := exp.Metadata.Name
+
+		for _, node := range exp.Spec.Topology().Nodes() {
+			for _, drive := range node.Hardware().Drives() {
+				add(drive.Image(), "experiment/"+name)
+			}
+		}
+
+		snapshots, err := vm.SnapshotCatalog(name, "")
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("getting snapshot catalog for experiment %s: %v", name, err))
+		}
+
+		for _, snapshot := range snapshots {
+			if !snapshot.Missing {
+				add(filepath.Join(exp.FilesDir(), snapshot.File()+".hdd"), fmt.Sprintf("snapshot/%s/%s", name, snapshot.VM))
+			}
+		}
+	}
+
+	// Include VMs in every minimega namespace, not just phenix experiments, so
+	// images used by VMs launched outside of phenix aren't considered orphans.
+	cmd := mmcli.NewCommand()
+	cmd.Command = "namespace"
+	cmd.Columns = []string{"namespace"}
+
+	for _, ns := range mmcli.RunTabular(cmd) {
+		cmd := mmcli.NewNamespacedCommand(ns["namespace"])
+		cmd.Command = "vm info"
+		cmd.Columns = []string{"name", "disks"}
+
+		for _, row := range mmcli.RunTabular(cmd) {
+			// Multiple disks are space-separated, and each diskspec is
+			// comma-separated with the path first.
+			for _, spec := range strings.Fields(row["disks"]) {
+				add(strings.Split(spec, ",")[0], fmt.Sprintf("vm/%s/%s", ns["namespace"], row["name"]))
+			}
+		}
+	}
+
+	return refs, errs, nil
+}
+
+func hasDiskImageExtension(path string) bool {
+	for _, ext := range diskImageExtensions {
+		if strings.HasSuffix(path, ext) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,99 @@
+//nolint:testpackage // testing internals
+package disk
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestBuildGraph(t *testing.T) {
+	chains := map[string][]string{
+		"/images/base.qc2":                {"/images/base.qc2"},
+		"/images/unused-base.qc2":         {"/images/unused-base.qc2"},
+		"/images/overlay.qc2":             {"/images/overlay.qc2", "/images/base.qc2"},
+		"/images/overlay-child.qc2":       {"/images/overlay-child.qc2", "/images/overlay.qc2", "/images/base.qc2"},
+		"/images/orphan.qc2":              {"/images/orphan.qc2", "/images/base.qc2"},
+		"/images/orphan-child.qc2":        {"/images/orphan-child.qc2", "/images/orphan.qc2", "/images/base.qc2"},
+		"/images/exp/files/vm__snap.hdd":  {"/images/exp/files/vm__snap.hdd", "/images/base.qc2"},
+		"/images/old/files/vm__snap.hdd":  {"/images/old/files/vm__snap.hdd", "/other/base.qc2"},
+		"/images/old/files/vm__snap2.hdd": {"/images/old/files/vm__snap2.hdd", "/images/broken.qc2"},
+	}
+
+	sizes := map[string]int64{
+		"/images/base.qc2":                100,
+		"/images/unused-base.qc2":         100,
+		"/images/overlay.qc2":             10,
+		"/images/overlay-child.qc2":       10,
+		"/images/orphan.qc2":              20,
+		"/images/orphan-child.qc2":        5,
+		"/images/exp/files/vm__snap.hdd":  10,
+		"/images/old/files/vm__snap.hdd":  30,
+		"/images/old/files/vm__snap2.hdd": 30,
+		"/images/broken.qc2":              50,
+	}
+
+	errs := map[string]string{"/images/broken.qc2": "corrupt image"}
+
+	refs := map[string][]string{
+		"/images/overlay-child.qc2":      {"topology/foo"},
+		"/images/exp/files/vm__snap.hdd": {"snapshot/exp/vm"},
+	}
+
+	graph := buildGraph(chains, sizes, errs, refs)
+
+	expected := map[string]bool{
+		"/images/orphan.qc2":             true,
+		"/images/orphan-child.qc2":       true,
+		"/images/old/files/vm__snap.hdd": true,
+		// Overlays of images that can't be inspected can still be orphans.
+		"/images/old/files/vm__snap2.hdd": true,
+	}
+
+	for _, image := range graph.Images {
+		if image.Orphan != expected[image.Path] {
+			t.Errorf("expected orphan=%t for %s", expected[image.Path], image.Path)
+		}
+	}
+
+	if graph.Reclaimable != 85 {
+		t.Errorf("expected 85 reclaimable bytes, got %d", graph.Reclaimable)
+	}
+
+	orphans := graph.Orphans()
+
+	if len(orphans) != 4 || orphans[0].Path != "/images/orphan-child.qc2" {
+		t.Errorf("expected overlays to be deleted before their backing images, got %v", orphans)
+	}
+}
+
+func TestCollectGarbageIncompleteReferences(t *testing.T) {
+	orphan := filepath.Join(t.TempDir(), "orphan.qc2")
+
+	if err := os.WriteFile(orphan, []byte("overlay"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	graph := Graph{
+		Images: []GraphImage{
+			{Path: orphan, Size: 7, Backing: "/images/base.qc2", Orphan: true}, //nolint:exhaustruct // partial initialization
+		},
+		Reclaimable: 7,
+		Errors:      []string{"decoding topology foo: invalid"},
+	}
+
+	orphans, _, err := collectGarbage(graph, true)
+	if !errors.Is(err, ErrIncompleteReferences) || len(orphans) != 1 {
+		t.Fatalf("expected dry run to report orphans and incomplete references, got %v (%v)", orphans, err)
+	}
+
+	deleted, _, err := collectGarbage(graph, false)
+	if !errors.Is(err, ErrIncompleteReferences) || len(deleted) != 0 {
+		t.Fatalf("expected nothing deleted with incomplete references, got %v (%v)", deleted, err)
+	}
+
+	if _, err := os.Stat(orphan); err != nil {
+		t.Fatalf("expected orphan to be kept: %v", err)
+	}
+}
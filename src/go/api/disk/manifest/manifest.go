@@ -0,0 +1,184 @@
+// Package manifest records the content hash and size of disk images in sidecar
+// manifests so images modified in place (e.g., a shared base image edited
+// underneath its overlays) can be detected.
+package manifest
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"time"
+)
+
+// Suffix is appended to an image's path to get the path of its manifest.
+const Suffix = ".manifest.json"
+
+var (
+	ErrNoManifest = errors.New("no manifest recorded for image")
+	ErrModified   = errors.New("image modified since its manifest was recorded")
+)
+
+// Manifest is the recorded state of a disk image.
+type Manifest struct {
+	Size     int64     `json:"size"`
+	SHA256   string    `json:"sha256"`
+	ModTime  time.Time `json:"modTime"`
+	Recorded time.Time `json:"recorded"`
+}
+
+// Path returns the path of the manifest for the image at the given path.
+func Path(image string) string {
+	return image + Suffix
+}
+
+// Record hashes the image at the given path and writes its manifest, replacing
+// any existing manifest.
+func Record(image string) (Manifest, error) {
+	var m Manifest
+
+	before, err := os.Stat(image)
+	if err != nil {
+		return m, fmt.Errorf("getting image info: %w", err)
+	}
+
+	sum, err := hash(image)
+	if err != nil {
+		return m, err
+	}
+
+	after, err := os.Stat(image)
+	if err != nil {
+		return m, fmt.Errorf("getting image info: %w", err)
+	}
+
+	if after.Size() != before.Size() || !after.ModTime().Equal(before.ModTime()) {
+		return m, fmt.Errorf("image %s changed while being hashed", image)
+	}
+
+	m = Manifest{
+		Size:     after.Size(),
+		SHA256:   sum,
+		ModTime:  after.ModTime(),
+		Recorded: time.Now(),
+	}
+
+	body, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return m, fmt.Errorf("marshaling manifest: %w", err)
+	}
+
+	// Write to a temporary file first so a partial manifest is never read.
+	tmp := Path(image) + ".tmp"
+
+	if err := os.WriteFile(tmp, body, 0o644); err != nil { //nolint:gosec // manifests are readable like images
+		return m, fmt.Errorf("writing manifest: %w", err)
+	}
+
+	if err := os.Rename(tmp, Path(image)); err != nil {
+		os.Remove(tmp)
+
+		return m, fmt.Errorf("writing manifest: %w", err)
+	}
+
+	return m, nil
+}
+
+// Read returns the manifest for the image at the given path, or ErrNoManifest
+// if one hasn't been recorded.
+func Read(image string) (Manifest, error) {
+	var m Manifest
+
+	body, err := os.ReadFile(Path(image))
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return m, fmt.Errorf("%w: %s", ErrNoManifest, image)
+		}
+
+		return m, fmt.Errorf("reading manifest: %w", err)
+	}
+
+	if err := json.Unmarshal(body, &m); err != nil {
+		return m, fmt.Errorf("parsing manifest for %s: %w", image, err)
+	}
+
+	return m, nil
+}
+
+// Verify checks the image at the given path against its manifest, returning
+// ErrModified if it doesn't match. The size and modification time are always
+// compared. If full is true, the image is also hashed, and an image whose hash
+// still matches isn't considered modified (e.g., if it was only touched).
+func Verify(image string, full bool) (Manifest, error) {
+	m, err := Read(image)
+	if err != nil {
+		return m, err
+	}
+
+	info, err := os.Stat(image)
+	if err != nil {
+		return m, fmt.Errorf("getting image info: %w", err)
+	}
+
+	if info.Size() != m.Size {
+		return m, fmt.Errorf("%w: size changed from %d to %d bytes", ErrModified, m.Size, info.Size())
+	}
+
+	if !full {
+		if !info.ModTime().Equal(m.ModTime) {
+			return m, fmt.Errorf("%w: modified at %s", ErrModified, info.ModTime().Format(time.RFC3339))
+		}
+
+		return m, nil
+	}
+
+	sum, err := hash(image)
+	if err != nil {
+		return m, err
+	}
+
+	if sum != m.SHA256 {
+		return m, fmt.Errorf("%w: SHA-256 changed from %s to %s", ErrModified, m.SHA256, sum)
+	}
+
+	return m, nil
+}
+
+// Move moves the manifest for the image at src, if any, to the image at dst.
+func Move(src, dst string) error {
+	if err := os.Rename(Path(src), Path(dst)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("moving manifest: %w", err)
+	}
+
+	return nil
+}
+
+// Remove removes the manifest for the image at the given path, if any.
+func Remove(image string) error {
+	if err := os.Remove(Path(image)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("removing manifest: %w", err)
+	}
+
+	return nil
+}
+
+func hash(image string) (string, error) {
+	f, err := os.Open(image)
+	if err != nil {
+		return "", fmt.Errorf("opening image: %w", err)
+	}
+
+	defer f.Close()
+
+	h := sha256.New()
+
+	if _, err := io.Copy(h, f); err != nil {
+		return "", fmt.Errorf("hashing image: %w", err)
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
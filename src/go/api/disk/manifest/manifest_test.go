@@ -0,0 +1,113 @@
+package manifest_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"phenix/api/disk/manifest"
+)
+
+func writeImage(t *testing.T, path, body string) {
+	t.Helper()
+
+	if err := os.WriteFile(path, []byte(body), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	image := filepath.Join(t.TempDir(), "base.qc2")
+
+	writeImage(t, image, "base")
+
+	if _, err := manifest.Verify(image, false); !errors.Is(err, manifest.ErrNoManifest) {
+		t.Fatalf("expected no manifest, got %v", err)
+	}
+
+	recorded, err := manifest.Record(image)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if recorded.Size != 4 {
+		t.Fatalf("expected size 4, got %d", recorded.Size)
+	}
+
+	if _, err := manifest.Verify(image, true); err != nil {
+		t.Fatalf("expected unmodified image to verify, got %v", err)
+	}
+
+	// Touching the image only fails the quick check.
+	later := recorded.ModTime.Add(time.Minute)
+
+	if err := os.Chtimes(image, later, later); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := manifest.Verify(image, false); !errors.Is(err, manifest.ErrModified) {
+		t.Fatalf("expected touched image to fail quick check, got %v", err)
+	}
+
+	if _, err := manifest.Verify(image, true); err != nil {
+		t.Fatalf("expected touched image to pass full check, got %v", err)
+	}
+
+	// Same size, different contents.
+	writeImage(t, image, "BASE")
+
+	if _, err := manifest.Verify(image, true); !errors.Is(err, manifest.ErrModified) {
+		t.Fatalf("expected modified image to fail full check, got %v", err)
+	}
+
+	writeImage(t, image, "rebuilt")
+
+	if _, err := manifest.Verify(image, false); !errors.Is(err, manifest.ErrModified) {
+		t.Fatalf("expected resized image to fail quick check, got %v", err)
+	}
+}
+
+func TestMoveAndRemove(t *testing.T) {
+	var (
+		dir = t.TempDir()
+		src = filepath.Join(dir, "src.qc2")
+		dst = filepath.Join(dir, "dst.qc2")
+	)
+
+	writeImage(t, src, "image")
+
+	if _, err := manifest.Record(src); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.Rename(src, dst); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := manifest.Move(src, dst); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := manifest.Verify(dst, true); err != nil {
+		t.Fatalf("expected moved manifest to verify, got %v", err)
+	}
+
+	if err := manifest.Remove(dst); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := manifest.Read(dst); !errors.Is(err, manifest.ErrNoManifest) {
+		t.Fatalf("expected no manifest after remove, got %v", err)
+	}
+
+	// Images without manifests are ignored.
+	if err := manifest.Move(src, dst); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := manifest.Remove(dst); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,245 @@
+package disk
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"phenix/util/common"
+	"phenix/util/plog"
+)
+
+const (
+	// maxNBDDevices is the number of NBD devices requested when loading the nbd
+	// kernel module.
+	maxNBDDevices = 16
+
+	nbdPartitionTimeout = 5 * time.Second
+	nbdCommandTimeout   = 30 * time.Second
+)
+
+var ErrNoMountablePartition = errors.New("no mountable partition found")
+
+// Mutex to keep concurrent mounts from selecting the same free NBD device.
+var nbdMu sync.Mutex //nolint:gochecknoglobals // global lock
+
+// GetLocalMountPath returns the path on the headnode the disk image at the
+// given path is mounted at when mounted offline.
+func GetLocalMountPath(path string) string {
+	name := strings.NewReplacer("/", "_", "..", "_").Replace(strings.TrimPrefix(path, "/"))
+
+	return filepath.Join(common.PhenixBase, "mounts", "disks", name)
+}
+
+// MountDisk mounts the disk image at the given path read-only at the given
+// mount point on the headnode, without booting it. The image is attached to an
+// NBD device using qemu-nbd and the given partition is mounted. If partition is
+// zero, the largest partition with a filesystem that can be mounted is used.
+// Images in use by a VM are shared rather than locked, so they can be inspected
+// while paused or after being shut down.
+func MountDisk(path, mountPoint string, partition int) error {
+	if !filepath.IsAbs(path) {
+		return fmt.Errorf("disk path %s must be absolute", path)
+	}
+
+	if _, err := os.Stat(path); err != nil {
+		return fmt.Errorf("getting disk %s: %w", path, err)
+	}
+
+	if err := os.MkdirAll(mountPoint, 0o750); err != nil {
+		return fmt.Errorf("creating mount point %s: %w", mountPoint, err)
+	}
+
+	// The module may be built in or already loaded, so errors are ignored.
+	_ = run("modprobe", "nbd", fmt.Sprintf("max_part=%d", maxNBDDevices))
+
+	dev, err := attachNBDDevice(path)
+	if err != nil {
+		return err
+	}
+
+	if err := mountNBDPartition(dev, mountPoint, partition); err != nil {
+		_ = run("qemu-nbd", "--disconnect", dev)
+
+		return fmt.Errorf("mounting disk %s: %w", path, err)
+	}
+
+	plog.Info(plog.TypeSystem, "disk mounted offline", "disk", path, "device", dev, "mount", mountPoint)
+
+	return nil
+}
+
+// UnmountDisk unmounts the disk image mounted at the given mount point by
+// MountDisk and detaches it from its NBD device.
+func UnmountDisk(mountPoint string) error {
+	dev, err := mountedDevice(mountPoint)
+	if err != nil {
+		return err
+	}
+
+	if err := run("umount", mountPoint); err != nil {
+		return fmt.Errorf("unmounting %s: %w", mountPoint, err)
+	}
+
+	dev = nbdDevice(dev)
+
+	if err := run("qemu-nbd", "--disconnect", dev); err != nil {
+		return fmt.Errorf("detaching %s: %w", dev, err)
+	}
+
+	_ = os.Remove(mountPoint)
+
+	return nil
+}
+
+// attachNBDDevice attaches the disk image at the given path read-only to a free
+// NBD device, returning the device. Devices are selected and attached while
+// holding nbdMu since a device is only seen as in use once it's attached.
+func attachNBDDevice(path string) (string, error) {
+	nbdMu.Lock()
+	defer nbdMu.Unlock()
+
+	dev, err := freeNBDDevice()
+	if err != nil {
+		return "", err
+	}
+
+	if err := run("qemu-nbd", "--read-only", "--force-share", "--connect="+dev, path); err != nil {
+		return "", fmt.Errorf("attaching disk %s to %s: %w", path, dev, err)
+	}
+
+	return dev, nil
+}
+
+// freeNBDDevice returns the first NBD device not attached to an image.
+func freeNBDDevice() (string, error) {
+	for i := range maxNBDDevices {
+		// Attached devices have a non-zero size.
+		size, err := os.ReadFile(fmt.Sprintf("/sys/block/nbd%d/size", i))
+		if err != nil {
+			continue
+		}
+
+		if strings.TrimSpace(string(size)) == "0" {
+			return fmt.Sprintf("/dev/nbd%d", i), nil
+		}
+	}
+
+	return "", errors.New("no free NBD devices (is the nbd kernel module loaded?)")
+}
+
+// mountNBDPartition mounts the given partition of the given NBD device, or the
+// largest mountable partition if partition is zero. Disks without a partition
+// table are mounted directly.
+func mountNBDPartition(dev, mountPoint string, partition int) error {
+	if partition > 0 {
+		part := fmt.Sprintf("%sp%d", dev, partition)
+
+		if len(waitForPartitions(part)) == 0 {
+			return fmt.Errorf("partition %d not found on %s", partition, dev)
+		}
+
+		return mountReadOnly(part, mountPoint)
+	}
+
+	parts := waitForPartitions(dev + "p*")
+	if len(parts) == 0 {
+		parts = []string{dev}
+	}
+
+	sort.Slice(parts, func(i, j int) bool { return blockSize(parts[i]) > blockSize(parts[j]) })
+
+	for _, part := range parts {
+		if err := mountReadOnly(part, mountPoint); err == nil {
+			return nil
+		}
+	}
+
+	return ErrNoMountablePartition
+}
+
+// waitForPartitions returns the partition devices matching the given pattern.
+// Partitions show up asynchronously after an NBD device is attached, so it
+// polls until at least one matches or nbdPartitionTimeout elapses.
+func waitForPartitions(pattern string) []string {
+	deadline := time.Now().Add(nbdPartitionTimeout)
+
+	for {
+		parts, _ := filepath.Glob(pattern)
+		if len(parts) > 0 || time.Now().After(deadline) {
+			return parts
+		}
+
+		time.Sleep(100 * time.Millisecond) //nolint:mnd // poll interval
+	}
+}
+
+// mountReadOnly mounts the given device read-only. Journal recovery is skipped
+// for filesystems that support it so disks from VMs that weren't shut down
+// cleanly can still be mounted without being modified.
+func mountReadOnly(dev, mountPoint string) error {
+	if err := run("mount", "-o", "ro,noload", dev, mountPoint); err == nil {
+		return nil
+	}
+
+	return run("mount", "-o", "ro", dev, mountPoint)
+}
+
+// mountedDevice returns the device mounted at the given mount point.
+func mountedDevice(mountPoint string) (string, error) {
+	f, err := os.Open("/proc/mounts")
+	if err != nil {
+		return "", fmt.Errorf("reading mounts: %w", err)
+	}
+
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) > 1 && fields[1] == mountPoint {
+			return fields[0], nil
+		}
+	}
+
+	return "", fmt.Errorf("nothing mounted at %s", mountPoint)
+}
+
+// nbdDevice returns the NBD device for the given partition device. Partition
+// devices are named after their NBD device (e.g., /dev/nbd0p1).
+func nbdDevice(part string) string {
+	if idx := strings.LastIndex(part, "p"); idx > len("/dev/nbd") {
+		return part[:idx]
+	}
+
+	return part
+}
+
+func blockSize(dev string) int64 {
+	size, _ := os.ReadFile(filepath.Join("/sys/class/block", filepath.Base(dev), "size"))
+	n, _ := strconv.ParseInt(strings.TrimSpace(string(size)), 10, 64)
+
+	return n
+}
+
+func run(name string, args ...string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), nbdCommandTimeout)
+	defer cancel()
+
+	out, err := exec.CommandContext(ctx, name, args...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("running %s: %w (%s)", name, err, strings.TrimSpace(string(out)))
+	}
+
+	return nil
+}
@@ -0,0 +1,46 @@
+//nolint:testpackage // testing internals
+package disk
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNBDDevice(t *testing.T) {
+	tests := map[string]string{
+		"/dev/nbd0p1":  "/dev/nbd0",
+		"/dev/nbd12p3": "/dev/nbd12",
+		"/dev/nbd3":    "/dev/nbd3",
+	}
+
+	for part, expected := range tests {
+		if got := nbdDevice(part); got != expected {
+			t.Errorf("nbdDevice(%s) = %s, expected %s", part, got, expected)
+		}
+	}
+}
+
+func TestGetLocalMountPath(t *testing.T) {
+	path := GetLocalMountPath("/phenix/images/../foo/bar.qc2")
+
+	if strings.Contains(strings.TrimPrefix(path, "/phenix/mounts/disks/"), "/") {
+		t.Fatalf("expected mount path to be within disks mounts directory, got %s", path)
+	}
+}
+
+func TestWaitForPartitions(t *testing.T) {
+	part := filepath.Join(t.TempDir(), "nbd0p1")
+
+	// Partitions show up some time after the device is attached.
+	go func() {
+		time.Sleep(200 * time.Millisecond)
+		_ = os.WriteFile(part, nil, 0o600)
+	}()
+
+	if parts := waitForPartitions(part); len(parts) != 1 || parts[0] != part {
+		t.Fatalf("expected partition %s, got %v", part, parts)
+	}
+}
@@ -0,0 +1,377 @@
+package disk
+
+import (
+	"archive/tar"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	v1 "phenix/types/version/v1"
+	"phenix/util/mm"
+	"phenix/util/plog"
+)
+
+// OVF resource types used to build a draft topology node (see the DMTF CIM
+// ResourceAllocationSettingData ResourceType values).
+const (
+	ovfResourceCPU      = 3
+	ovfResourceMemory   = 4
+	ovfResourceEthernet = 10
+	ovfResourceDisk     = 17
+)
+
+var ErrInvalidOVF = errors.New("invalid OVF descriptor")
+
+var invalidHostnameChars = regexp.MustCompile(`[^a-zA-Z0-9-]+`) //nolint:gochecknoglobals // global constant
+
+// OVAImport describes a virtual machine imported from an OVA or OVF.
+type OVAImport struct {
+	Name string `json:"name"`
+	// Disks are the paths of the converted disks, in the order they're attached
+	// to the virtual machine.
+	Disks []string `json:"disks"`
+	// Node is a draft topology node for the virtual machine, using the converted
+	// disks and the CPU, memory, and NIC count from the OVF descriptor. Its
+	// interfaces aren't assigned to VLANs.
+	Node *v1.Node `json:"node"`
+}
+
+type ovfEnvelope struct {
+	Files []struct {
+		ID          string `xml:"id,attr"`
+		Href        string `xml:"href,attr"`
+		Compression string `xml:"compression,attr"`
+	} `xml:"References>File"`
+	Disks []struct {
+		ID      string `xml:"diskId,attr"`
+		FileRef string `xml:"fileRef,attr"`
+	} `xml:"DiskSection>Disk"`
+	Systems []ovfSystem `xml:"VirtualSystem"`
+	// Only the first system in a collection is imported.
+	Collection []ovfSystem `xml:"VirtualSystemCollection>VirtualSystem"`
+}
+
+type ovfSystem struct {
+	ID   string `xml:"id,attr"`
+	Name string `xml:"Name"`
+	OS   struct {
+		Type        string `xml:"osType,attr"`
+		Description string `xml:"Description"`
+	} `xml:"OperatingSystemSection"`
+	Items []ovfItem `xml:"VirtualHardwareSection>Item"`
+}
+
+type ovfItem struct {
+	ResourceType    int    `xml:"ResourceType"`
+	VirtualQuantity int64  `xml:"VirtualQuantity"`
+	AllocationUnits string `xml:"AllocationUnits"`
+	HostResource    string `xml:"HostResource"`
+}
+
+// ImportOVA imports the virtual machine in the OVA (or OVF, with its disks in
+// the same directory) at the given path. Its disks are converted to the given
+// format in the minimega files directory and named after the given name, or
+// the virtual machine's name in the OVF descriptor if name is empty.
+func ImportOVA(path, name string, format v1.Format) (OVAImport, error) {
+	var result OVAImport
+
+	if FormatExtension(format) == "" {
+		return result, fmt.Errorf("unsupported disk format %q", format)
+	}
+
+	var (
+		dir = mm.GetMMFilesDirectory()
+		src = filepath.Dir(path)
+	)
+
+	if strings.EqualFold(filepath.Ext(path), ".ova") {
+		tmp, err := os.MkdirTemp(dir, ".import-")
+		if err != nil {
+			return result, fmt.Errorf("creating import directory: %w", err)
+		}
+
+		defer os.RemoveAll(tmp)
+
+		if err := extractOVA(path, tmp); err != nil {
+			return result, err
+		}
+
+		src = tmp
+	}
+
+	ovf, err := findOVF(path, src)
+	if err != nil {
+		return result, err
+	}
+
+	env, system, err := parseOVF(ovf)
+	if err != nil {
+		return result, err
+	}
+
+	if name == "" {
+		name = system.Name
+	}
+
+	if name == "" {
+		name = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+
+	name = strings.Trim(invalidHostnameChars.ReplaceAllString(name, "-"), "-")
+	result.Name = name
+
+	files, err := env.diskFiles(system)
+	if err != nil {
+		return result, err
+	}
+
+	for i, file := range files {
+		dst := filepath.Join(dir, name+FormatExtension(format))
+
+		if len(files) > 1 {
+			dst = filepath.Join(dir, fmt.Sprintf("%s-%d%s", name, i, FormatExtension(format)))
+		}
+
+		if _, err := os.Stat(dst); err == nil {
+			return result, fmt.Errorf("disk %s already exists", dst)
+		}
+
+		if err := ConvertDisk(filepath.Join(src, file), dst, format); err != nil {
+			return result, fmt.Errorf("converting disk %s: %w", file, err)
+		}
+
+		result.Disks = append(result.Disks, dst)
+	}
+
+	result.Node = system.draftNode(name, filepath.Base(path), result.Disks)
+
+	plog.Info(plog.TypeSystem, "imported OVA", "ova", path, "name", name, "disks", result.Disks)
+
+	return result, nil
+}
+
+// extractOVA extracts the files in the OVA (a tar archive) at the given path to
+// the given directory. Directories in the archive are flattened.
+func extractOVA(path, dir string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("opening OVA %s: %w", path, err)
+	}
+
+	defer f.Close()
+
+	tr := tar.NewReader(f)
+
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+
+		if err != nil {
+			return fmt.Errorf("reading OVA %s: %w", path, err)
+		}
+
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		name := filepath.Base(header.Name)
+		if name == "." || name == ".." || name == "/" {
+			continue
+		}
+
+		out, err := os.OpenFile(filepath.Join(dir, name), os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o600)
+		if err != nil {
+			return fmt.Errorf("extracting %s from OVA: %w", name, err)
+		}
+
+		_, err = io.Copy(out, tr) //nolint:gosec // OVA disks are large by design
+
+		if closeErr := out.Close(); err == nil {
+			err = closeErr
+		}
+
+		if err != nil {
+			return fmt.Errorf("extracting %s from OVA: %w", name, err)
+		}
+	}
+}
+
+// findOVF returns the path of the OVF descriptor in the given directory. If
+// path is itself an OVF descriptor, it's returned.
+func findOVF(path, dir string) (string, error) {
+	if strings.EqualFold(filepath.Ext(path), ".ovf") {
+		return path, nil
+	}
+
+	matches, _ := filepath.Glob(filepath.Join(dir, "*.ovf"))
+	if len(matches) == 0 {
+		return "", fmt.Errorf("%w: no OVF descriptor found in %s", ErrInvalidOVF, path)
+	}
+
+	return matches[0], nil
+}
+
+func parseOVF(path string) (ovfEnvelope, ovfSystem, error) {
+	var (
+		env    ovfEnvelope
+		system ovfSystem
+	)
+
+	body, err := os.ReadFile(path)
+	if err != nil {
+		return env, system, fmt.Errorf("reading OVF descriptor: %w", err)
+	}
+
+	if err := xml.Unmarshal(body, &env); err != nil {
+		return env, system, fmt.Errorf("%w: %w", ErrInvalidOVF, err)
+	}
+
+	systems := slices.Concat(env.Systems, env.Collection)
+
+	if len(systems) == 0 {
+		return env, system, fmt.Errorf("%w: no virtual system found", ErrInvalidOVF)
+	}
+
+	return env, systems[0], nil
+}
+
+// diskFiles returns the names of the disk files attached to the given system,
+// in the order they're attached. If the system's hardware doesn't reference any
+// disks, all the disks in the descriptor are used.
+func (e ovfEnvelope) diskFiles(system ovfSystem) ([]string, error) {
+	var (
+		hrefs = make(map[string]string)
+		disks = make(map[string]string)
+		files []string
+		seen  = make(map[string]bool)
+	)
+
+	for _, file := range e.Files {
+		if file.Compression != "" && file.Compression != "identity" {
+			return nil, fmt.Errorf("%w: compressed disk %s not supported", ErrInvalidOVF, file.Href)
+		}
+
+		hrefs[file.ID] = filepath.Base(file.Href)
+	}
+
+	for _, disk := range e.Disks {
+		disks[disk.ID] = hrefs[disk.FileRef]
+	}
+
+	add := func(file string) {
+		if file != "" && !seen[file] {
+			seen[file] = true
+			files = append(files, file)
+		}
+	}
+
+	for _, item := range system.Items {
+		if item.ResourceType != ovfResourceDisk {
+			continue
+		}
+
+		// Host resources reference disks as ovf:/disk/<id> (or files as
+		// ovf:/file/<id> in older descriptors).
+		ref := item.HostResource[strings.LastIndex(item.HostResource, "/")+1:]
+
+		if file, ok := disks[ref]; ok {
+			add(file)
+		} else {
+			add(hrefs[ref])
+		}
+	}
+
+	if len(files) == 0 {
+		for _, disk := range e.Disks {
+			add(disks[disk.ID])
+		}
+	}
+
+	if len(files) == 0 {
+		return nil, fmt.Errorf("%w: no disks found", ErrInvalidOVF)
+	}
+
+	return files, nil
+}
+
+// draftNode returns a topology node for the system using the given disks.
+func (s ovfSystem) draftNode(name, source string, disks []string) *v1.Node {
+	var (
+		vcpus  = 1
+		memory = 512 //nolint:mnd // default memory in MB
+		nics   int
+		osType = "linux"
+	)
+
+	for _, item := range s.Items {
+		switch item.ResourceType {
+		case ovfResourceCPU:
+			vcpus = int(item.VirtualQuantity)
+		case ovfResourceMemory:
+			memory = int(item.VirtualQuantity * ovfAllocationUnits(item.AllocationUnits) >> 20) //nolint:mnd // bytes to MB
+		case ovfResourceEthernet:
+			nics++
+		}
+	}
+
+	if desc := strings.ToLower(s.OS.Type + " " + s.OS.Description); strings.Contains(desc, "win") {
+		osType = "windows"
+	}
+
+	node := &v1.Node{ //nolint:exhaustruct // partial initialization
+		TypeF: "VirtualMachine",
+		GeneralF: &v1.General{ //nolint:exhaustruct // partial initialization
+			HostnameF:    name,
+			DescriptionF: "imported from " + source,
+			VMTypeF:      "kvm",
+		},
+	}
+
+	node.AddHardware(osType, vcpus, memory)
+
+	for _, disk := range disks {
+		node.HardwareF.DrivesF = append(node.HardwareF.DrivesF, &v1.Drive{ImageF: filepath.Base(disk)}) //nolint:exhaustruct // partial initialization
+	}
+
+	for i := range nics {
+		node.AddNetworkInterface("ethernet", fmt.Sprintf("IF%d", i), "")
+	}
+
+	return node
+}
+
+// ovfAllocationUnits returns the number of bytes in the given OVF allocation
+// units (e.g., "byte * 2^20"). Units default to megabytes.
+func ovfAllocationUnits(units string) int64 {
+	units = strings.ToLower(strings.ReplaceAll(units, " ", ""))
+
+	switch units {
+	case "kilobytes", "kb":
+		return 1 << 10 //nolint:mnd // kilobyte
+	case "megabytes", "mb", "":
+		return 1 << 20 //nolint:mnd // megabyte
+	case "gigabytes", "gb":
+		return 1 << 30 //nolint:mnd // gigabyte
+	}
+
+	if exp, ok := strings.CutPrefix(units, "byte*2^"); ok {
+		if n, err := strconv.Atoi(exp); err == nil && n >= 0 && n < 63 {
+			return 1 << n
+		}
+	}
+
+	if units == "byte" {
+		return 1
+	}
+
+	return 1 << 20 //nolint:mnd // megabyte
+}
@@ -0,0 +1,101 @@
+//nolint:testpackage // testing internals
+package disk
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const testOVF = `<?xml version="1.0" encoding="UTF-8"?>
+<Envelope xmlns="http://schemas.dmtf.org/ovf/envelope/1" xmlns:ovf="http://schemas.dmtf.org/ovf/envelope/1"
+  xmlns:rasd="http://schemas.dmtf.org/wbem/wscim/1/cim-schema/2/CIM_ResourceAllocationSettingData"
+  xmlns:vmw="http://www.vmware.com/schema/ovf">
+  <References>
+    <File ovf:id="file1" ovf:href="win10-disk1.vmdk"/>
+    <File ovf:id="file2" ovf:href="win10-disk2.vmdk"/>
+  </References>
+  <DiskSection>
+    <Disk ovf:diskId="vmdisk1" ovf:fileRef="file1" ovf:capacity="64"/>
+    <Disk ovf:diskId="vmdisk2" ovf:fileRef="file2" ovf:capacity="32"/>
+  </DiskSection>
+  <VirtualSystem ovf:id="win10">
+    <Name>Win 10 Workstation</Name>
+    <OperatingSystemSection ovf:id="103" vmw:osType="windows9_64Guest">
+      <Description>Microsoft Windows 10 (64-bit)</Description>
+    </OperatingSystemSection>
+    <VirtualHardwareSection>
+      <Item><rasd:ResourceType>3</rasd:ResourceType><rasd:VirtualQuantity>4</rasd:VirtualQuantity></Item>
+      <Item>
+        <rasd:AllocationUnits>byte * 2^30</rasd:AllocationUnits>
+        <rasd:ResourceType>4</rasd:ResourceType>
+        <rasd:VirtualQuantity>8</rasd:VirtualQuantity>
+      </Item>
+      <Item><rasd:HostResource>ovf:/disk/vmdisk2</rasd:HostResource><rasd:ResourceType>17</rasd:ResourceType></Item>
+      <Item><rasd:HostResource>ovf:/disk/vmdisk1</rasd:HostResource><rasd:ResourceType>17</rasd:ResourceType></Item>
+      <Item><rasd:ResourceType>10</rasd:ResourceType></Item>
+      <Item><rasd:ResourceType>10</rasd:ResourceType></Item>
+      <Item><rasd:ResourceType>10</rasd:ResourceType></Item>
+    </VirtualHardwareSection>
+  </VirtualSystem>
+</Envelope>`
+
+func TestParseOVF(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "win10.ovf")
+
+	if err := os.WriteFile(path, []byte(testOVF), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	env, system, err := parseOVF(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files, err := env.diskFiles(system)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(files) != 2 || files[0] != "win10-disk2.vmdk" || files[1] != "win10-disk1.vmdk" {
+		t.Fatalf("expected disks in attached order, got %v", files)
+	}
+
+	node := system.draftNode("win10", "win10.ova", []string{"/phenix/images/win10-0.qcow2", "/phenix/images/win10-1.qcow2"})
+
+	if node.Hardware().VCPU() != 4 {
+		t.Errorf("expected 4 vcpus, got %d", node.Hardware().VCPU())
+	}
+
+	if node.Hardware().Memory() != 8192 {
+		t.Errorf("expected 8192 MB memory, got %d", node.Hardware().Memory())
+	}
+
+	if node.Hardware().OSType() != "windows" {
+		t.Errorf("expected windows OS type, got %s", node.Hardware().OSType())
+	}
+
+	if len(node.Hardware().Drives()) != 2 || node.Hardware().Drives()[0].Image() != "win10-0.qcow2" {
+		t.Errorf("unexpected drives %v", node.Hardware().Drives())
+	}
+
+	if len(node.Network().Interfaces()) != 3 {
+		t.Errorf("expected 3 interfaces, got %d", len(node.Network().Interfaces()))
+	}
+}
+
+func TestOVFAllocationUnits(t *testing.T) {
+	tests := map[string]int64{
+		"byte * 2^20": 1 << 20,
+		"byte * 2^30": 1 << 30,
+		"MegaBytes":   1 << 20,
+		"":            1 << 20,
+		"byte":        1,
+	}
+
+	for units, expected := range tests {
+		if got := ovfAllocationUnits(units); got != expected {
+			t.Errorf("ovfAllocationUnits(%q) = %d, expected %d", units, got, expected)
+		}
+	}
+}
@@ -1,5 +1,12 @@
 package disk
 
+import (
+	"phenix/api/disk/manifest"
+	v1 "phenix/types/version/v1"
+	"phenix/util/mm"
+	"phenix/util/plog"
+)
+
 // DiskFiles defines disk API functions
 // all path, src, dst arguments should be either absolute paths or relative paths from the mm files directory.
 type DiskFiles interface {
@@ -32,6 +39,9 @@ type DiskFiles interface {
 	// renames `src` to `dst`. This is equivalent to a shell `mv`.
 	// Note that if this image backs others, they will need to be rebased to the new name (can use unsafe)
 	RenameDisk(src, dst string) error
+	// converts the disk at `src` to a new image at `dst` in the given format
+	// (e.g., to import a vmdk from another hypervisor as a qcow2).
+	ConvertDisk(src, dst string, format v1.Format) error
 	// deletes `src`. This is equivalent to a shell `rm`.
 	// Note that if this image backs others, they will become invalid
 	DeleteDisk(src string) error
@@ -47,8 +57,28 @@ func GetImage(path string) (Details, error) {
 	return DefaultDiskFiles.GetImage(path)
 }
 
+// CommitDisk commits the disk at path to its backing image and records a new
+// manifest for the backing image, since its contents are changed on purpose.
+// The commit has already happened if the manifest can't be recorded, so the
+// error is logged rather than returned.
 func CommitDisk(path string) error {
-	return DefaultDiskFiles.CommitDisk(path)
+	var backing string
+
+	if chain, err := mm.ImageBackingChain("", mm.GetMMFullPath(path)); err == nil && len(chain) > 1 {
+		backing = chain[1]
+	}
+
+	if err := DefaultDiskFiles.CommitDisk(path); err != nil {
+		return err
+	}
+
+	if backing != "" {
+		if _, err := manifest.Record(backing); err != nil {
+			plog.Warn(plog.TypeSystem, "recording manifest for committed disk image", "image", backing, "err", err)
+		}
+	}
+
+	return nil
 }
 
 func SnapshotDisk(src, dst string) error {
@@ -63,14 +93,46 @@ func ResizeDisk(src, size string) error {
 	return DefaultDiskFiles.ResizeDisk(src, size)
 }
 
+// CloneDisk copies the disk at src to dst and records a manifest for the copy.
+// Errors recording the manifest are logged rather than returned.
 func CloneDisk(src, dst string) error {
-	return DefaultDiskFiles.CloneDisk(src, dst)
+	if err := DefaultDiskFiles.CloneDisk(src, dst); err != nil {
+		return err
+	}
+
+	if _, err := manifest.Record(mm.GetMMFullPath(dst)); err != nil {
+		plog.Warn(plog.TypeSystem, "recording manifest for cloned disk image", "image", dst, "err", err)
+	}
+
+	return nil
 }
 
+// RenameDisk renames the disk at src to dst, along with its manifest.
 func RenameDisk(src, dst string) error {
-	return DefaultDiskFiles.RenameDisk(src, dst)
+	if err := DefaultDiskFiles.RenameDisk(src, dst); err != nil {
+		return err
+	}
+
+	if err := manifest.Move(mm.GetMMFullPath(src), mm.GetMMFullPath(dst)); err != nil {
+		plog.Warn(plog.TypeSystem, "renaming disk image manifest", "src", src, "dst", dst, "err", err)
+	}
+
+	return nil
+}
+
+func ConvertDisk(src, dst string, format v1.Format) error {
+	return DefaultDiskFiles.ConvertDisk(src, dst, format)
 }
 
+// DeleteDisk deletes the disk at src, along with its manifest.
 func DeleteDisk(src string) error {
-	return DefaultDiskFiles.DeleteDisk(src)
+	if err := DefaultDiskFiles.DeleteDisk(src); err != nil {
+		return err
+	}
+
+	if err := manifest.Remove(mm.GetMMFullPath(src)); err != nil {
+		plog.Warn(plog.TypeSystem, "deleting disk image manifest", "src", src, "err", err)
+	}
+
+	return nil
 }
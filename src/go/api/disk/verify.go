@@ -0,0 +1,114 @@
+package disk
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"strings"
+
+	"phenix/api/disk/manifest"
+	"phenix/util/mm"
+)
+
+// Image verification statuses.
+const (
+	VerifyOK         = "ok"
+	VerifyModified   = "modified"
+	VerifyUnrecorded = "unrecorded"
+	VerifyRecorded   = "recorded"
+	VerifyError      = "error"
+)
+
+// ImageVerification is the result of verifying a disk image against its
+// manifest.
+type ImageVerification struct {
+	Path    string `json:"path"`
+	Status  string `json:"status"`
+	SHA256  string `json:"sha256,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+// VerifyImages verifies the disk images at the given paths against their
+// manifests, or every disk image in the minimega files directory if no paths
+// are given. If full is true, images are rehashed rather than only having their
+// size and modification time compared. If record is true, manifests are
+// recorded for images that don't have one.
+func VerifyImages(paths []string, full, record bool) ([]ImageVerification, error) {
+	if len(paths) == 0 {
+		var err error
+
+		if paths, err = diskImages(); err != nil {
+			return nil, err
+		}
+	}
+
+	results := make([]ImageVerification, 0, len(paths))
+
+	for _, path := range paths {
+		path = mm.GetMMFullPath(path)
+
+		result := ImageVerification{Path: path, Status: VerifyOK} //nolint:exhaustruct // partial initialization
+
+		m, err := manifest.Verify(path, full)
+
+		switch {
+		case errors.Is(err, manifest.ErrNoManifest) && record:
+			if m, err = manifest.Record(path); err != nil {
+				result.Status = VerifyError
+				result.Message = err.Error()
+			} else {
+				result.Status = VerifyRecorded
+			}
+		case errors.Is(err, manifest.ErrNoManifest):
+			result.Status = VerifyUnrecorded
+		case errors.Is(err, manifest.ErrModified):
+			result.Status = VerifyModified
+			result.Message = err.Error()
+		case err != nil:
+			result.Status = VerifyError
+			result.Message = err.Error()
+		}
+
+		result.SHA256 = m.SHA256
+
+		results = append(results, result)
+	}
+
+	return results, nil
+}
+
+// diskImages returns the paths of the disk images in the minimega files
+// directory, including experiment files directories.
+func diskImages() ([]string, error) {
+	var (
+		root  = mm.GetMMFilesDirectory()
+		paths []string
+	)
+
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil //nolint:nilerr // skip unreadable paths
+		}
+
+		// Skip hidden files and directories (e.g., in-progress uploads).
+		if strings.HasPrefix(d.Name(), ".") && path != root {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+
+			return nil
+		}
+
+		if !d.IsDir() && hasDiskImageExtension(path) {
+			paths = append(paths, path)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("walking files directory %s: %w", root, err)
+	}
+
+	return paths, nil
+}
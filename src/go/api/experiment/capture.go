@@ -0,0 +1,184 @@
+package experiment
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"phenix/util/common"
+	"phenix/util/file"
+	"phenix/util/mm"
+	"phenix/util/pcap"
+	"phenix/util/plog"
+)
+
+// JournalCaptureStarted is the message of the journal event recorded when a VM
+// packet capture is started. The event's data includes the capture's interface,
+// VLAN, and file name, which are used to describe each capture when merging.
+const JournalCaptureStarted = "VM capture started"
+
+var (
+	ErrNoCapturesToMerge = errors.New("no packet captures to merge")
+
+	// Subnet captures are named <vm>_<iface>_<timestamp>.pcap.
+	subnetCaptureRegex = regexp.MustCompile(`^(.+)_(\d+)_\d{8}_\d{4}\.pcap\d*$`)
+)
+
+// captureSource describes where a capture file was recorded.
+type captureSource struct {
+	vm    string
+	iface int
+	vlan  string
+}
+
+// MergeCaptures merges the packet captures recorded for the experiment with
+// the given name into a single time-ordered PCAPNG file written to w. Each
+// capture is written as its own interface, named and described using the VM,
+// interface, and VLAN it was recorded on. Captures still in progress are not
+// included. It returns the number of packets written.
+func MergeCaptures(name string, w io.Writer, opts ...MergeCapturesOption) (int, error) {
+	o := newMergeCapturesOptions(opts...)
+
+	files, err := file.GetExperimentFiles(name, "")
+	if err != nil {
+		return 0, fmt.Errorf("getting list of experiment files: %w", err)
+	}
+
+	active := make(map[string]struct{})
+
+	for _, c := range mm.GetExperimentCaptures(mm.NS(name)) {
+		active[filepath.Base(c.Filepath)] = struct{}{}
+	}
+
+	recorded, err := recordedCaptures(name)
+	if err != nil {
+		return 0, err
+	}
+
+	// Sort for a stable interface order in the merged capture.
+	sort.Slice(files, func(i, j int) bool { return files[i].Path < files[j].Path })
+
+	var (
+		headnode = mm.Headnode()
+		sources  []pcap.Source
+	)
+
+	for _, f := range files {
+		if !file.IsPacketCapture(f.Name) {
+			continue
+		}
+
+		if _, ok := active[captureBaseName(f.Name)]; ok {
+			plog.Warn(plog.TypeSystem, "skipping capture still in progress", "exp", name, "file", f.Path)
+
+			continue
+		}
+
+		src, known := recorded[captureBaseName(f.Name)]
+		if !known {
+			src, known = parseSubnetCaptureName(f.Name)
+		}
+
+		if o.vlan != "" && (!known || !strings.EqualFold(src.vlan, o.vlan)) {
+			continue
+		}
+
+		// Captures may have been recorded on any cluster host, so make sure the
+		// file is available on the headnode.
+		_ = file.CopyFile(fmt.Sprintf("%s/files/%s", name, f.Path), headnode, nil)
+
+		r, err := os.Open(fmt.Sprintf("%s/images/%s/files/%s", common.PhenixBase, name, f.Path))
+		if err != nil {
+			return 0, fmt.Errorf("opening capture file %s: %w", f.Path, err)
+		}
+
+		// Files are read while merging, so they're closed once merged.
+		defer r.Close()
+
+		reader, err := pcap.NewReader(r)
+		if err != nil {
+			plog.Warn(plog.TypeSystem, "skipping unreadable capture", "exp", name, "file", f.Path, "err", err)
+
+			continue
+		}
+
+		source := pcap.Source{Name: f.Name, Description: f.Path, Reader: reader}
+
+		if known {
+			source.Name = fmt.Sprintf("%s:%d", src.vm, src.iface)
+
+			if src.vlan != "" {
+				source.Description = fmt.Sprintf("VLAN %s (%s)", src.vlan, f.Path)
+			}
+		}
+
+		sources = append(sources, source)
+	}
+
+	if len(sources) == 0 {
+		return 0, fmt.Errorf("experiment %s: %w", name, ErrNoCapturesToMerge)
+	}
+
+	count, err := pcap.Merge(w, sources, pcap.Window{From: o.from, To: o.to})
+	if err != nil {
+		return count, fmt.Errorf("merging captures for experiment %s: %w", name, err)
+	}
+
+	return count, nil
+}
+
+// recordedCaptures returns the source of each capture recorded in the journal
+// for the experiment with the given name, keyed by capture file name.
+func recordedCaptures(name string) (map[string]captureSource, error) {
+	entries, err := Journal(name, time.Time{})
+	if err != nil {
+		return nil, fmt.Errorf("getting journal for experiment %s: %w", name, err)
+	}
+
+	recorded := make(map[string]captureSource)
+
+	for _, entry := range entries {
+		if entry.Source != JournalSourceSystem || entry.Message != JournalCaptureStarted {
+			continue
+		}
+
+		f, _ := entry.Data["file"].(string)
+		if f == "" {
+			continue
+		}
+
+		// Data decoded from the journal uses float64 for numbers.
+		iface, _ := entry.Data["interface"].(float64)
+		vlan, _ := entry.Data["vlan"].(string)
+
+		recorded[f] = captureSource{vm: entry.VM, iface: int(iface), vlan: vlan}
+	}
+
+	return recorded, nil
+}
+
+// parseSubnetCaptureName parses the VM and interface from the name of a capture
+// started for a subnet. The VLAN is unknown.
+func parseSubnetCaptureName(name string) (captureSource, bool) {
+	match := subnetCaptureRegex.FindStringSubmatch(name)
+	if match == nil {
+		return captureSource{}, false //nolint:exhaustruct // unknown source
+	}
+
+	iface, _ := strconv.Atoi(match[2])
+
+	return captureSource{vm: match[1], iface: iface, vlan: ""}, true
+}
+
+// captureBaseName returns the capture file name without any ring buffer
+// number suffix (e.g., foo.pcap3 -> foo.pcap).
+func captureBaseName(name string) string {
+	return strings.TrimRight(name, "0123456789")
+}
@@ -0,0 +1,27 @@
+//nolint:testpackage // testing internals
+package experiment
+
+import "testing"
+
+func TestParseSubnetCaptureName(t *testing.T) {
+	src, ok := parseSubnetCaptureName("host_a_1_20240601_1230.pcap")
+	if !ok || src.vm != "host_a" || src.iface != 1 {
+		t.Fatalf("unexpected capture source %+v", src)
+	}
+
+	if _, ok := parseSubnetCaptureName("custom.pcap"); ok {
+		t.Fatal("expected custom capture name to not be parsed")
+	}
+}
+
+func TestCaptureBaseName(t *testing.T) {
+	for name, expected := range map[string]string{
+		"foo.pcap":   "foo.pcap",
+		"foo.pcap3":  "foo.pcap",
+		"foo.pcap12": "foo.pcap",
+	} {
+		if got := captureBaseName(name); got != expected {
+			t.Fatalf("expected base name %s for %s, got %s", expected, name, got)
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package experiment
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"phenix/util/mm"
+	"phenix/util/notes"
+)
+
+var ErrInvalidClockTime = errors.New("invalid clock time")
+
+// clockActiveTimeout is how long to wait for a VM's C2 agent to be active
+// before skipping the VM when shifting the experiment clock.
+const clockActiveTimeout = 5 * time.Second
+
+// SetClock shifts the virtual time of every VM in the given running experiment.
+// The given value can either be an RFC3339 timestamp to set the virtual time
+// to, or a duration (e.g., "-8760h") to offset the virtual time from real time
+// by. Guest clocks are shifted relative to their current time via miniccc so
+// VMs stay consistent with each other regardless of command delivery delays.
+// VMs without an active C2 agent are skipped with a warning. It returns the new
+// virtual time of the experiment.
+func SetClock(ctx context.Context, name, value string) (time.Time, error) {
+	exp, err := Get(name)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("getting experiment %s: %w", name, err)
+	}
+
+	if !exp.Running() {
+		return time.Time{}, fmt.Errorf("experiment %s is not running", name)
+	}
+
+	now := time.Now()
+
+	offset, err := parseClockOffset(value, now)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	delta := offset - exp.Status.ClockOffset()
+
+	for _, node := range exp.Spec.Topology().BootableNodes() {
+		if node.External() {
+			continue
+		}
+
+		host := node.General().Hostname()
+		opts := []mm.C2Option{mm.C2NS(name), mm.C2VM(host), mm.C2Context(ctx)}
+
+		if err := mm.IsC2ClientActive(append(opts, mm.C2Timeout(clockActiveTimeout))...); err != nil {
+			notes.AddWarnings(ctx, false, fmt.Errorf("skipping clock shift for VM %s: %w", host, err))
+
+			continue
+		}
+
+		opts = append(
+			opts,
+			mm.C2SkipActiveClientCheck(true),
+			mm.C2Command(clockShiftCommand(node.Hardware().OSType(), delta)),
+		)
+
+		if _, err := mm.ExecC2Command(opts...); err != nil {
+			notes.AddWarnings(ctx, false, fmt.Errorf("shifting clock for VM %s: %w", host, err))
+		}
+	}
+
+	exp.Status.SetClockOffset(offset)
+
+	if err := exp.WriteToStore(true); err != nil {
+		return time.Time{}, fmt.Errorf("saving clock offset for experiment %s: %w", name, err)
+	}
+
+	RecordEvent(name, "", "experiment clock set", map[string]any{"offset": offset.String()})
+
+	return now.Add(offset), nil
+}
+
+// parseClockOffset parses the given value as either an RFC3339 timestamp or a
+// duration, returning the offset of the resulting virtual time from now.
+func parseClockOffset(value string, now time.Time) (time.Duration, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t.Sub(now).Round(time.Second), nil
+	}
+
+	if d, err := time.ParseDuration(value); err == nil {
+		return d, nil
+	}
+
+	return 0, fmt.Errorf(
+		"%w: %s (must be an RFC3339 timestamp or a duration)",
+		ErrInvalidClockTime,
+		value,
+	)
+}
+
+// clockShiftCommand returns the command to execute via C2 to shift a VM's
+// clock by the given delta. Commands are run via PowerShell on Windows hosts
+// and via `sh` everywhere else.
+func clockShiftCommand(osType string, delta time.Duration) string {
+	seconds := int64(delta.Round(time.Second) / time.Second)
+
+	if strings.EqualFold(osType, "windows") {
+		return fmt.Sprintf(
+			`powershell -NoProfile -Command "Set-Date -Adjust ([TimeSpan]::FromSeconds(%d)) | Out-Null"`,
+			seconds,
+		)
+	}
+
+	return fmt.Sprintf(`sh -c 'date -u -s "@$(($(date +%%s) + %d))" && hwclock -w'`, seconds)
+}
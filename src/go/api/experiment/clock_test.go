@@ -0,0 +1,39 @@
+//nolint:testpackage // testing internals
+package experiment
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseClockOffset(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	offset, err := parseClockOffset("2024-05-31T12:00:00Z", now)
+	if err != nil || offset != -24*time.Hour {
+		t.Fatalf("expected -24h offset from timestamp, got %v (%v)", offset, err)
+	}
+
+	offset, err = parseClockOffset("90m", now)
+	if err != nil || offset != 90*time.Minute {
+		t.Fatalf("expected 90m offset from duration, got %v (%v)", offset, err)
+	}
+
+	if _, err := parseClockOffset("yesterday", now); !errors.Is(err, ErrInvalidClockTime) {
+		t.Fatalf("expected invalid clock time error, got %v", err)
+	}
+}
+
+func TestClockShiftCommand(t *testing.T) {
+	cmd := clockShiftCommand("linux", -36*time.Hour)
+	if !strings.Contains(cmd, "date -u -s") || !strings.Contains(cmd, "+ -129600") {
+		t.Fatalf("unexpected Linux clock shift command: %s", cmd)
+	}
+
+	cmd = clockShiftCommand("Windows", 90*time.Second)
+	if !strings.Contains(cmd, "Set-Date -Adjust") || !strings.Contains(cmd, "FromSeconds(90)") {
+		t.Fatalf("unexpected Windows clock shift command: %s", cmd)
+	}
+}
@@ -532,6 +532,18 @@ func Start(ctx context.Context, opts ...StartOption) error {
 		return fmt.Errorf("applying apps to experiment: %w", err)
 	}
 
+	// Run pre-flight checks after pre-start apps have been applied since they
+	// can modify the topology (and schedule) of the experiment.
+	if !o.dryrun && !o.skipPreflight {
+		report := preflight(exp, PreflightWithVerifyImages(o.verifyImages))
+
+		notes.AddWarnings(ctx, false, report.Warnings()...)
+
+		if err := report.Err(); err != nil {
+			return err
+		}
+	}
+
 	var (
 		mmScript = fmt.Sprintf("%s/mm_files/%s.mm", exp.Spec.BaseDir(), exp.Spec.ExperimentName())
 		ccScript = fmt.Sprintf(
@@ -546,6 +558,15 @@ func Start(ctx context.Context, opts ...StartOption) error {
 		return fmt.Errorf("generating minimega script: %w", err)
 	}
 
+	// Track the difference between the virtual time VMs are launched with and
+	// real time so the experiment clock can be shifted consistently at runtime.
+	if clock := exp.Spec.Clock(); clock.Enabled() {
+		now := time.Now()
+		exp.Status.SetClockOffset(clock.Time(now).Sub(now).Round(time.Second))
+	} else {
+		exp.Status.SetClockOffset(0)
+	}
+
 	if exp.Spec.Topology().HasCommands() {
 		err = tmpl.CreateFileFromTemplate(
 			"minimega_cc_script.tmpl",
@@ -560,6 +581,7 @@ func Start(ctx context.Context, opts ...StartOption) error {
 	var (
 		delays = make(map[string]time.Duration)
 		c2s    = make(map[string]map[string]bool)
+		deps   = make(map[string]vmDependencies)
 	)
 
 	if o.dryrun {
@@ -645,6 +667,31 @@ func Start(ctx context.Context, opts ...StartOption) error {
 				}
 			}
 
+			if after := node.Delay().After(); len(after) > 0 {
+				hosts := make(map[string]string)
+
+				for _, other := range after {
+					if dep := exp.Spec.Topology().FindNodeByName(other); dep != nil {
+						hosts[other] = dep.Hardware().OSType()
+					}
+				}
+
+				if len(hosts) > 0 {
+					deps[hostname] = vmDependencies{hosts: hosts, probe: node.Delay().Probe()}
+					notes.AddInfo(
+						ctx,
+						true,
+						fmt.Sprintf(
+							"VM %s delayed - will be started after %v are ready",
+							hostname,
+							after,
+						),
+					)
+
+					continue
+				}
+			}
+
 			if d := node.Delay().Timer(); d != 0 {
 				delays[hostname] = d
 
@@ -706,6 +753,19 @@ func Start(ctx context.Context, opts ...StartOption) error {
 			}
 		}
 
+		// Link properties are applied to VM taps, which don't exist until the VMs
+		// are launched. Failing to apply them shouldn't fail the experiment.
+		for _, node := range bootable {
+			if err := ApplyLinkProperties(exp.Spec.ExperimentName(), node); err != nil {
+				merr := &multierror.Error{} //nolint:exhaustruct // library struct
+				if errors.As(err, &merr) {
+					notes.AddWarnings(ctx, false, merr.Errors...)
+				} else {
+					notes.AddWarnings(ctx, false, err)
+				}
+			}
+		}
+
 		schedule := make(map[string]string)
 
 		for _, vm := range mm.GetVMInfo(mm.NS(exp.Spec.ExperimentName())) {
@@ -753,7 +813,7 @@ func Start(ctx context.Context, opts ...StartOption) error {
 				}
 			}
 
-			err = handleDelayedVMs(ctx, exp.Spec.ExperimentName(), delays, c2s)
+			err = handleDelayedVMs(ctx, exp.Spec.ExperimentName(), delays, c2s, deps)
 			if err != nil {
 				errors := multierror.Append(nil, fmt.Errorf("handling delayed VMs: %w", err))
 
@@ -812,7 +872,7 @@ func Start(ctx context.Context, opts ...StartOption) error {
 					}
 				}
 
-				err = handleDelayedVMs(ctx, exp.Spec.ExperimentName(), delays, c2s)
+				err = handleDelayedVMs(ctx, exp.Spec.ExperimentName(), delays, c2s, deps)
 				if err != nil {
 					o.errChan <- fmt.Errorf("handling delayed VMs: %w", err)
 
@@ -861,6 +921,12 @@ func Start(ctx context.Context, opts ...StartOption) error {
 		hook("start", o.name)
 	}
 
+	if o.dryrun {
+		RecordEvent(o.name, "", "experiment started", map[string]any{"dryrun": true})
+	} else {
+		RecordEvent(o.name, "", "experiment started", nil)
+	}
+
 	return nil
 }
 
@@ -906,6 +972,7 @@ func Stop(name string) error {
 	}
 
 	exp.Status.SetStartTime("")
+	exp.Status.SetClockOffset(0)
 
 	c.Spec = structs.MapDefaultCase(exp.Spec, structs.CASESNAKE)
 	c.Status = structs.MapDefaultCase(exp.Status, structs.CASESNAKE)
@@ -919,6 +986,10 @@ func Stop(name string) error {
 		hook("stop", name)
 	}
 
+	if errors == nil {
+		RecordEvent(name, "", "experiment stopped", nil)
+	}
+
 	return errors
 }
 
@@ -1069,6 +1140,12 @@ func TriggerRunning(ctx context.Context, name string, apps ...string) error {
 		return fmt.Errorf("triggering apps for experiment: %w", err)
 	}
 
+	if len(apps) > 0 {
+		RecordEvent(name, "", "running stage triggered", map[string]any{"apps": apps})
+	} else {
+		RecordEvent(name, "", "running stage triggered", nil)
+	}
+
 	return nil
 }
 
@@ -1210,8 +1287,9 @@ func handleDelayedVMs(
 	ns string,
 	delays map[string]time.Duration,
 	c2s map[string]map[string]bool,
+	deps map[string]vmDependencies,
 ) error {
-	if len(delays) == 0 && len(c2s) == 0 {
+	if len(delays) == 0 && len(c2s) == 0 && len(deps) == 0 {
 		return nil
 	}
 
@@ -1219,7 +1297,7 @@ func handleDelayedVMs(
 
 	var (
 		wg      sync.WaitGroup
-		errChan = make(chan error, len(delays)+len(c2s))
+		errChan = make(chan error, len(delays)+len(c2s)+len(deps))
 	)
 
 	for host, delay := range delays {
@@ -1244,6 +1322,17 @@ func handleDelayedVMs(
 		}(host, others)
 	}
 
+	for host, d := range deps {
+		wg.Add(1)
+
+		go func(host string, d vmDependencies) {
+			defer wg.Done()
+			if err := waitForDependencies(ctx, ns, host, d); err != nil {
+				errChan <- err
+			}
+		}(host, d)
+	}
+
 	wg.Wait()
 	close(errChan)
 
@@ -1274,17 +1363,25 @@ func handleDelayedVMs(
 	return nil
 }
 
+func startDelayedVM(ns, host string) error {
+	cmd := mmcli.NewNamespacedCommand(ns)
+	cmd.Command = "vm start " + host
+
+	err := mmcli.ErrorResponse(mmcli.Run(cmd))
+	if err != nil {
+		return NewDelayedVMError(host, err, "starting VM %s", host)
+	}
+
+	return nil
+}
+
 func waitForTimeDelay(ctx context.Context, ns, host string, delay time.Duration) error {
 	select {
 	case <-ctx.Done():
 		return ctx.Err()
 	case <-time.After(delay):
-		cmd := mmcli.NewNamespacedCommand(ns)
-		cmd.Command = "vm start " + host
-
-		err := mmcli.ErrorResponse(mmcli.Run(cmd))
-		if err != nil {
-			return NewDelayedVMError(host, err, "starting VM %s", host)
+		if err := startDelayedVM(ns, host); err != nil {
+			return err
 		}
 
 		notes.AddInfo(ctx, true, fmt.Sprintf("Time delayed VM %s started", host))
@@ -1328,12 +1425,8 @@ func waitForC2(
 			}
 
 			if done {
-				cmd := mmcli.NewNamespacedCommand(ns)
-				cmd.Command = "vm start " + host
-
-				err := mmcli.ErrorResponse(mmcli.Run(cmd))
-				if err != nil {
-					return NewDelayedVMError(host, err, "starting VM %s", host)
+				if err := startDelayedVM(ns, host); err != nil {
+					return err
 				}
 
 				notes.AddInfo(ctx, true, fmt.Sprintf("C2 delayed VM %s started", host))
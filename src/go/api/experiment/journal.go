@@ -0,0 +1,259 @@
+package experiment
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"phenix/util/plog"
+)
+
+const journalFileName = "journal.jsonl"
+
+// Sources used to identify who or what added an entry to an experiment journal.
+const (
+	JournalSourceUser   = "user"
+	JournalSourceApp    = "app"
+	JournalSourceSystem = "system"
+)
+
+var ErrInvalidJournalFormat = errors.New("invalid journal export format")
+
+// Mutex to protect concurrent appends to experiment journal files.
+var journalMu sync.Mutex //nolint:gochecknoglobals // global lock
+
+// JournalEntry is a single timestamped annotation in an experiment journal.
+// Entries are either added explicitly by users and apps or automatically by
+// phenix for experiment lifecycle events and VM operations.
+type JournalEntry struct {
+	Timestamp time.Time      `json:"timestamp"`
+	Source    string         `json:"source"`
+	Author    string         `json:"author,omitempty"`
+	VM        string         `json:"vm,omitempty"`
+	Message   string         `json:"message"`
+	Data      map[string]any `json:"data,omitempty"`
+}
+
+// AddJournalEntry appends the given entry to the journal for the experiment
+// with the given name. If the entry's timestamp is not set it will be set to
+// the current time, and if the entry's source is not set it will default to
+// `user`. It returns the entry as it was written to the journal.
+func AddJournalEntry(name string, entry JournalEntry) (JournalEntry, error) {
+	if strings.TrimSpace(entry.Message) == "" && len(entry.Data) == 0 {
+		return entry, errors.New("journal entry must include a message or data")
+	}
+
+	exp, err := Get(name)
+	if err != nil {
+		return entry, fmt.Errorf("getting experiment %s: %w", name, err)
+	}
+
+	if entry.Timestamp.IsZero() {
+		entry.Timestamp = time.Now().UTC()
+	}
+
+	if entry.Source == "" {
+		entry.Source = JournalSourceUser
+	}
+
+	if err := appendJournalEntry(journalPath(exp.Spec.BaseDir()), entry); err != nil {
+		return entry, fmt.Errorf("writing journal entry for experiment %s: %w", name, err)
+	}
+
+	return entry, nil
+}
+
+// Journal returns the journal entries for the experiment with the given name,
+// sorted by timestamp. If `since` is not the zero time, only entries recorded
+// after it are returned.
+func Journal(name string, since time.Time) ([]JournalEntry, error) {
+	exp, err := Get(name)
+	if err != nil {
+		return nil, fmt.Errorf("getting experiment %s: %w", name, err)
+	}
+
+	entries, err := readJournal(journalPath(exp.Spec.BaseDir()))
+	if err != nil {
+		return nil, fmt.Errorf("reading journal for experiment %s: %w", name, err)
+	}
+
+	if since.IsZero() {
+		return entries, nil
+	}
+
+	filtered := make([]JournalEntry, 0, len(entries))
+
+	for _, entry := range entries {
+		if entry.Timestamp.After(since) {
+			filtered = append(filtered, entry)
+		}
+	}
+
+	return filtered, nil
+}
+
+// RecordEvent adds a system entry to the journal for the experiment with the
+// given name. It's used to automatically track lifecycle events and VM
+// operations, so any errors are logged rather than returned to avoid failing
+// the operation being recorded. The `vm` argument can be an empty string if
+// the event is not specific to a VM.
+func RecordEvent(name, vm, msg string, data map[string]any) {
+	entry := JournalEntry{ //nolint:exhaustruct // partial initialization
+		Source:  JournalSourceSystem,
+		VM:      vm,
+		Message: msg,
+		Data:    data,
+	}
+
+	if _, err := AddJournalEntry(name, entry); err != nil {
+		plog.Warn(plog.TypeSystem, "recording experiment journal event", "exp", name, "err", err)
+	}
+}
+
+// ExportJournal writes the given journal entries to the given writer using the
+// given format. Supported formats are `json` and `csv`.
+func ExportJournal(w io.Writer, entries []JournalEntry, format string) error {
+	switch strings.ToLower(format) {
+	case "", "json":
+		if entries == nil {
+			entries = []JournalEntry{}
+		}
+
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+
+		if err := enc.Encode(entries); err != nil {
+			return fmt.Errorf("encoding journal as JSON: %w", err)
+		}
+
+		return nil
+	case "csv":
+		cw := csv.NewWriter(w)
+
+		_ = cw.Write([]string{"timestamp", "source", "author", "vm", "message", "data"})
+
+		for _, entry := range entries {
+			var data string
+
+			if len(entry.Data) > 0 {
+				body, err := json.Marshal(entry.Data)
+				if err != nil {
+					return fmt.Errorf("encoding journal entry data: %w", err)
+				}
+
+				data = string(body)
+			}
+
+			_ = cw.Write([]string{
+				entry.Timestamp.Format(time.RFC3339Nano),
+				entry.Source,
+				entry.Author,
+				entry.VM,
+				entry.Message,
+				data,
+			})
+		}
+
+		cw.Flush()
+
+		if err := cw.Error(); err != nil {
+			return fmt.Errorf("encoding journal as CSV: %w", err)
+		}
+
+		return nil
+	default:
+		return fmt.Errorf("%w: %s", ErrInvalidJournalFormat, format)
+	}
+}
+
+func journalPath(baseDir string) string {
+	return filepath.Join(baseDir, journalFileName)
+}
+
+func appendJournalEntry(path string, entry JournalEntry) error {
+	body, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("encoding journal entry: %w", err)
+	}
+
+	journalMu.Lock()
+	defer journalMu.Unlock()
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+		return fmt.Errorf("creating journal directory: %w", err)
+	}
+
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o640) //nolint:gosec // path built from experiment base dir
+	if err != nil {
+		return fmt.Errorf("opening journal file: %w", err)
+	}
+
+	defer f.Close()
+
+	if _, err := f.Write(append(body, '\n')); err != nil {
+		return fmt.Errorf("writing journal file: %w", err)
+	}
+
+	return nil
+}
+
+func readJournal(path string) ([]JournalEntry, error) {
+	journalMu.Lock()
+	defer journalMu.Unlock()
+
+	f, err := os.Open(path) //nolint:gosec // path built from experiment base dir
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return []JournalEntry{}, nil
+		}
+
+		return nil, fmt.Errorf("opening journal file: %w", err)
+	}
+
+	defer f.Close()
+
+	var (
+		entries = []JournalEntry{}
+		scanner = bufio.NewScanner(f)
+	)
+
+	// Structured entries can get long, so allow lines up to 1MB.
+	scanner.Buffer(make([]byte, 0, bufio.MaxScanTokenSize), 1<<20)
+
+	for scanner.Scan() {
+		line := scanner.Bytes()
+
+		if len(line) == 0 {
+			continue
+		}
+
+		var entry JournalEntry
+
+		if err := json.Unmarshal(line, &entry); err != nil {
+			plog.Warn(plog.TypeSystem, "skipping malformed journal entry", "path", path, "err", err)
+
+			continue
+		}
+
+		entries = append(entries, entry)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("scanning journal file: %w", err)
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].Timestamp.Before(entries[j].Timestamp)
+	})
+
+	return entries, nil
+}
@@ -0,0 +1,116 @@
+//nolint:testpackage // testing internals
+package experiment
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestJournalRoundTrip(t *testing.T) {
+	path := journalPath(filepath.Join(t.TempDir(), "exp"))
+
+	entries, err := readJournal(path)
+	if err != nil {
+		t.Fatalf("reading missing journal: %v", err)
+	}
+
+	if len(entries) != 0 {
+		t.Fatalf("expected empty journal, got %d entries", len(entries))
+	}
+
+	now := time.Now().UTC()
+
+	// Written out of order to verify entries are sorted when read.
+	written := []JournalEntry{
+		{Timestamp: now.Add(time.Minute), Source: JournalSourceSystem, Message: "experiment started"},
+		{Timestamp: now, Source: JournalSourceUser, Author: "alice", Message: "baseline", Data: map[string]any{"run": "1"}},
+	}
+
+	for _, entry := range written {
+		if err := appendJournalEntry(path, entry); err != nil {
+			t.Fatalf("appending journal entry: %v", err)
+		}
+	}
+
+	// Malformed lines should be skipped rather than failing the read.
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0o640)
+	if err != nil {
+		t.Fatalf("opening journal: %v", err)
+	}
+
+	_, _ = f.WriteString("not json\n")
+	_ = f.Close()
+
+	entries, err = readJournal(path)
+	if err != nil {
+		t.Fatalf("reading journal: %v", err)
+	}
+
+	if len(entries) != 2 {
+		t.Fatalf("expected 2 entries, got %d", len(entries))
+	}
+
+	if entries[0].Message != "baseline" || entries[1].Message != "experiment started" {
+		t.Fatalf("entries not sorted by timestamp: %+v", entries)
+	}
+
+	if entries[0].Data["run"] != "1" {
+		t.Fatalf("expected structured data to round trip, got %+v", entries[0].Data)
+	}
+}
+
+func TestExportJournal(t *testing.T) {
+	ts := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	entries := []JournalEntry{
+		{Timestamp: ts, Source: JournalSourceUser, Author: "alice", VM: "host-01", Message: "hello, world", Data: map[string]any{"k": "v"}},
+	}
+
+	var buf bytes.Buffer
+
+	if err := ExportJournal(&buf, entries, "csv"); err != nil {
+		t.Fatalf("exporting CSV: %v", err)
+	}
+
+	records, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatalf("parsing CSV: %v", err)
+	}
+
+	if len(records) != 2 {
+		t.Fatalf("expected header and 1 record, got %d", len(records))
+	}
+
+	want := []string{"2024-01-02T03:04:05Z", "user", "alice", "host-01", "hello, world", `{"k":"v"}`}
+	for i, v := range want {
+		if records[1][i] != v {
+			t.Errorf("column %d: expected %q, got %q", i, v, records[1][i])
+		}
+	}
+
+	buf.Reset()
+
+	if err := ExportJournal(&buf, nil, "json"); err != nil {
+		t.Fatalf("exporting JSON: %v", err)
+	}
+
+	var decoded []JournalEntry
+
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatalf("parsing JSON: %v", err)
+	}
+
+	if decoded == nil || len(decoded) != 0 {
+		t.Fatalf("expected empty JSON array, got %s", buf.String())
+	}
+
+	if err := ExportJournal(&buf, entries, "xml"); !errors.Is(err, ErrInvalidJournalFormat) {
+		t.Fatalf("expected invalid format error, got %v", err)
+	}
+}
@@ -0,0 +1,53 @@
+package experiment
+
+import (
+	"fmt"
+
+	"github.com/hashicorp/go-multierror"
+
+	ifaces "phenix/types/interfaces"
+	"phenix/util/mm"
+)
+
+// LinkImpairment returns the impairment used to emulate the given link
+// properties.
+func LinkImpairment(link ifaces.NodeNetworkLink) mm.Impairment {
+	return mm.Impairment{
+		Delay:      link.Delay(),
+		Jitter:     link.Jitter(),
+		Loss:       link.Loss(),
+		Corruption: link.Corruption(),
+		Reordering: link.Reordering(),
+		Rate:       link.Bandwidth(),
+	}
+}
+
+// ApplyLinkProperties applies the link properties configured for each
+// interface of the given node to the node's VM in the given running experiment.
+// Interfaces without link properties are left as-is.
+func ApplyLinkProperties(expName string, node ifaces.NodeSpec) error {
+	if node.External() || node.Network() == nil {
+		return nil
+	}
+
+	var (
+		hostname = node.General().Hostname()
+		errs     error
+	)
+
+	for idx, iface := range node.Network().Interfaces() {
+		link := iface.Link()
+		if link == nil {
+			continue
+		}
+
+		if err := mm.ImpairVMInterface(expName, hostname, idx, LinkImpairment(link)); err != nil {
+			errs = multierror.Append(
+				errs,
+				fmt.Errorf("applying link properties to interface %s of VM %s: %w", iface.Name(), hostname, err),
+			)
+		}
+	}
+
+	return errs
+}
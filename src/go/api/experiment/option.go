@@ -1,6 +1,8 @@
 package experiment
 
 import (
+	"time"
+
 	ifaces "phenix/types/interfaces"
 	"phenix/util/common"
 )
@@ -218,6 +220,13 @@ type startOptions struct {
 	// Option to treat all errors generated by minimega as warnings when launching
 	// an experiment.
 	mmErrAsWarn bool
+
+	// Option to skip pre-flight checks when launching an experiment.
+	skipPreflight bool
+
+	// Option to verify the integrity of the backing images of VM disks during
+	// pre-flight checks.
+	verifyImages bool
 }
 
 func newStartOptions(opts ...StartOption) startOptions {
@@ -265,3 +274,75 @@ func StartWithMMErrorsAsWarnings(w bool) StartOption {
 		o.mmErrAsWarn = w
 	}
 }
+
+func StartWithSkipPreflight(s bool) StartOption {
+	return func(o *startOptions) {
+		o.skipPreflight = s
+	}
+}
+
+func StartWithVerifyImages(v bool) StartOption {
+	return func(o *startOptions) {
+		o.verifyImages = v
+	}
+}
+
+type PreflightOption func(*preflightOptions)
+
+type preflightOptions struct {
+	verifyImages bool
+}
+
+func newPreflightOptions(opts ...PreflightOption) preflightOptions {
+	var o preflightOptions
+
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	return o
+}
+
+// PreflightWithVerifyImages enables checking the backing images of VM disks
+// for modifications made underneath their overlays.
+func PreflightWithVerifyImages(v bool) PreflightOption {
+	return func(o *preflightOptions) {
+		o.verifyImages = v
+	}
+}
+
+type MergeCapturesOption func(*mergeCapturesOptions)
+
+type mergeCapturesOptions struct {
+	vlan string
+	from time.Time
+	to   time.Time
+}
+
+func newMergeCapturesOptions(opts ...MergeCapturesOption) mergeCapturesOptions {
+	var o mergeCapturesOptions
+
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	return o
+}
+
+func MergeCapturesWithVLAN(v string) MergeCapturesOption {
+	return func(o *mergeCapturesOptions) {
+		o.vlan = v
+	}
+}
+
+func MergeCapturesFrom(t time.Time) MergeCapturesOption {
+	return func(o *mergeCapturesOptions) {
+		o.from = t
+	}
+}
+
+func MergeCapturesTo(t time.Time) MergeCapturesOption {
+	return func(o *mergeCapturesOptions) {
+		o.to = t
+	}
+}
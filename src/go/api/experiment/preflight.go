@@ -0,0 +1,558 @@
+package experiment
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/go-multierror"
+
+	"phenix/api/disk/manifest"
+	"phenix/app"
+	"phenix/types"
+	"phenix/util/common"
+	"phenix/util/mm"
+)
+
+// Pre-flight check categories.
+const (
+	PreflightCheckImages    = "images"
+	PreflightCheckMemory    = "memory"
+	PreflightCheckVLANs     = "vlans"
+	PreflightCheckBridges   = "bridges"
+	PreflightCheckBinaries  = "binaries"
+	PreflightCheckApps      = "apps"
+	PreflightCheckIntegrity = "integrity"
+)
+
+// Pre-flight check statuses.
+const (
+	PreflightPass = "pass"
+	PreflightWarn = "warn"
+	PreflightFail = "fail"
+)
+
+var ErrPreflightFailed = errors.New("pre-flight checks failed")
+
+// requiredBinaries are the executables required on each cluster host VMs will
+// be launched on.
+var requiredBinaries = []string{"qemu-img", "ip", "ovs-vsctl"} //nolint:gochecknoglobals // global constant
+
+// PreflightCheck is the result of a single pre-flight check. Host is empty for
+// checks that are not specific to a cluster host.
+type PreflightCheck struct {
+	Check   string `json:"check"`
+	Host    string `json:"host,omitempty"`
+	Target  string `json:"target"`
+	Status  string `json:"status"`
+	Message string `json:"message,omitempty"`
+}
+
+// PreflightReport is the aggregated result of all the pre-flight checks run
+// for an experiment.
+type PreflightReport struct {
+	Experiment string           `json:"experiment"`
+	Checks     []PreflightCheck `json:"checks"`
+}
+
+// Failed returns true if any of the checks in the report failed.
+func (r PreflightReport) Failed() bool {
+	for _, c := range r.Checks {
+		if c.Status == PreflightFail {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Err returns all the failed checks in the report as a single error, or nil if
+// no checks failed.
+func (r PreflightReport) Err() error {
+	var errs error
+
+	for _, c := range r.Checks {
+		if c.Status == PreflightFail {
+			errs = multierror.Append(errs, c)
+		}
+	}
+
+	if errs == nil {
+		return nil
+	}
+
+	return fmt.Errorf("%w: %w", ErrPreflightFailed, errs)
+}
+
+// Warnings returns all the checks in the report with a warning status as
+// errors, suitable for adding to notes.
+func (r PreflightReport) Warnings() []error {
+	var warnings []error
+
+	for _, c := range r.Checks {
+		if c.Status == PreflightWarn {
+			warnings = append(warnings, c)
+		}
+	}
+
+	return warnings
+}
+
+func (c PreflightCheck) Error() string {
+	if c.Host == "" {
+		return fmt.Sprintf("%s %s: %s", c.Check, c.Target, c.Message)
+	}
+
+	return fmt.Sprintf("%s %s on %s: %s", c.Check, c.Target, c.Host, c.Message)
+}
+
+// Preflight runs pre-flight checks for the experiment with the given name,
+// verifying the cluster is able to run the experiment before anything is
+// launched. It returns an aggregated report of all the checks run. Failed
+// checks are included in the report rather than returned as an error.
+func Preflight(name string, opts ...PreflightOption) (PreflightReport, error) {
+	exp, err := Get(name)
+	if err != nil {
+		return PreflightReport{}, fmt.Errorf("getting experiment %s: %w", name, err)
+	}
+
+	if exp.Running() {
+		return PreflightReport{}, fmt.Errorf("experiment %s is already running", name)
+	}
+
+	return preflight(exp, opts...), nil
+}
+
+func preflight(exp *types.Experiment, opts ...PreflightOption) PreflightReport {
+	o := newPreflightOptions(opts...)
+
+	report := PreflightReport{Experiment: exp.Metadata.Name, Checks: nil}
+
+	var (
+		head     = mm.Headnode()
+		schedule = exp.Spec.Schedules()
+		hosts    = make(map[string]struct{})
+	)
+
+	// VMs that haven't been scheduled will be placed by minimega, so host
+	// specific checks for them are run against the headnode.
+	hostFor := func(vm string) string {
+		if host := schedule[vm]; host != "" {
+			return host
+		}
+
+		return head
+	}
+
+	for _, node := range exp.Spec.Topology().BootableNodes() {
+		if node.External() {
+			continue
+		}
+
+		hosts[hostFor(node.General().Hostname())] = struct{}{}
+	}
+
+	sorted := make([]string, 0, len(hosts))
+	for host := range hosts {
+		sorted = append(sorted, host)
+	}
+
+	sort.Strings(sorted)
+
+	report.Checks = append(report.Checks, preflightImages(exp, head, hostFor)...)
+	report.Checks = append(report.Checks, preflightMemory(exp, schedule)...)
+	report.Checks = append(report.Checks, preflightVLANs(exp)...)
+	report.Checks = append(report.Checks, preflightBridges(exp, sorted)...)
+	report.Checks = append(report.Checks, preflightBinaries(sorted)...)
+	report.Checks = append(report.Checks, preflightApps(exp)...)
+
+	if o.verifyImages {
+		report.Checks = append(report.Checks, preflightIntegrity(exp, head)...)
+	}
+
+	return report
+}
+
+func preflightImages(exp *types.Experiment, head string, hostFor func(string) string) []PreflightCheck {
+	type key struct{ host, image string }
+
+	var (
+		checks []PreflightCheck
+		seen   = make(map[key]struct{})
+	)
+
+	for _, node := range exp.Spec.Topology().BootableNodes() {
+		if node.External() {
+			continue
+		}
+
+		host := hostFor(node.General().Hostname())
+
+		for _, drive := range node.Hardware().Drives() {
+			image := mm.GetMMFullPath(drive.Image())
+
+			if _, ok := seen[key{host, image}]; ok {
+				continue
+			}
+
+			seen[key{host, image}] = struct{}{}
+
+			check := PreflightCheck{
+				Check:   PreflightCheckImages,
+				Host:    host,
+				Target:  image,
+				Status:  PreflightPass,
+				Message: "",
+			}
+
+			switch {
+			case fileExistsOnHost(host, image):
+			case host != head && fileExistsOnHost(head, image):
+				check.Status = PreflightWarn
+				check.Message = "image missing on host but will be transferred from the headnode"
+			default:
+				check.Status = PreflightFail
+				check.Message = "image does not exist"
+			}
+
+			checks = append(checks, check)
+		}
+	}
+
+	return checks
+}
+
+// preflightMemory checks that each compute node has enough free memory for the
+// VMs scheduled on it. VMs that haven't been scheduled are skipped since
+// minimega places them based on load when the experiment starts.
+func preflightMemory(exp *types.Experiment, schedule map[string]string) []PreflightCheck {
+	cluster, err := mm.GetClusterHosts(false)
+	if err != nil {
+		return []PreflightCheck{{
+			Check:   PreflightCheckMemory,
+			Target:  "cluster",
+			Status:  PreflightFail,
+			Message: fmt.Sprintf("unable to get cluster hosts: %v", err),
+		}}
+	}
+
+	required := make(map[string]int)
+
+	for _, node := range exp.Spec.Topology().BootableNodes() {
+		if node.External() {
+			continue
+		}
+
+		host := schedule[node.General().Hostname()]
+		if host == "" {
+			continue
+		}
+
+		required[host] += node.Hardware().Memory()
+	}
+
+	names := make([]string, 0, len(required))
+	for host := range required {
+		names = append(names, host)
+	}
+
+	sort.Strings(names)
+
+	checks := make([]PreflightCheck, 0, len(names))
+
+	for _, name := range names {
+		check := PreflightCheck{
+			Check:   PreflightCheckMemory,
+			Host:    name,
+			Target:  fmt.Sprintf("%d MB", required[name]),
+			Status:  PreflightPass,
+			Message: "",
+		}
+
+		host := cluster.FindHostByName(name)
+		if host == nil {
+			check.Status = PreflightFail
+			check.Message = "host not found in cluster"
+		} else if free := host.MemTotal - host.MemUsed; required[name] > free {
+			check.Status = PreflightFail
+			check.Message = fmt.Sprintf("only %d MB of memory free", free)
+		}
+
+		checks = append(checks, check)
+	}
+
+	return checks
+}
+
+func preflightVLANs(exp *types.Experiment) []PreflightCheck {
+	aliases := exp.Spec.VLANs().Aliases()
+	if len(aliases) == 0 {
+		return nil
+	}
+
+	// Map VLAN IDs in use by other running experiments to the experiment using
+	// them so conflicts can be reported.
+	inUse := make(map[int]string)
+
+	exps, err := List()
+	if err != nil {
+		return []PreflightCheck{{
+			Check:   PreflightCheckVLANs,
+			Target:  "experiments",
+			Status:  PreflightFail,
+			Message: fmt.Sprintf("unable to list experiments: %v", err),
+		}}
+	}
+
+	for _, other := range exps {
+		if other.Metadata.Name == exp.Metadata.Name || !other.Running() {
+			continue
+		}
+
+		for _, id := range other.Status.VLANs() {
+			if id != 0 {
+				inUse[id] = other.Metadata.Name
+			}
+		}
+	}
+
+	names := make([]string, 0, len(aliases))
+	for alias := range aliases {
+		names = append(names, alias)
+	}
+
+	sort.Strings(names)
+
+	var (
+		checks = make([]PreflightCheck, 0, len(names))
+		seen   = make(map[int]string)
+	)
+
+	for _, alias := range names {
+		id := aliases[alias]
+
+		check := PreflightCheck{
+			Check:   PreflightCheckVLANs,
+			Target:  fmt.Sprintf("%s (%d)", alias, id),
+			Status:  PreflightPass,
+			Message: "",
+		}
+
+		// An ID of 0 means the VLAN ID will be allocated when the experiment
+		// starts, so it can't conflict with anything yet.
+		if id == 0 {
+			check.Message = "VLAN ID allocated automatically"
+
+			checks = append(checks, check)
+
+			continue
+		}
+
+		if other, ok := inUse[id]; ok {
+			check.Status = PreflightFail
+			check.Message = "VLAN ID in use by experiment " + other
+		} else if other, ok := seen[id]; ok {
+			check.Status = PreflightFail
+			check.Message = "VLAN ID also assigned to alias " + other
+		}
+
+		seen[id] = alias
+
+		checks = append(checks, check)
+	}
+
+	return checks
+}
+
+func preflightBridges(exp *types.Experiment, hosts []string) []PreflightCheck {
+	bridges := []string{exp.Spec.DefaultBridge()}
+
+	for _, node := range exp.Spec.Topology().BootableNodes() {
+		if node.External() || node.Network() == nil {
+			continue
+		}
+
+		for _, iface := range node.Network().Interfaces() {
+			if br := iface.Bridge(); br != "" && !slices.Contains(bridges, br) {
+				bridges = append(bridges, br)
+			}
+		}
+	}
+
+	var checks []PreflightCheck
+
+	for _, host := range hosts {
+		for _, br := range bridges {
+			check := PreflightCheck{
+				Check:   PreflightCheckBridges,
+				Host:    host,
+				Target:  br,
+				Status:  PreflightPass,
+				Message: "",
+			}
+
+			if err := mm.MeshShell(host, "ovs-vsctl br-exists "+br); err != nil {
+				// minimega creates missing bridges when VMs are launched, so a missing
+				// bridge is only fatal if bridges are expected to be managed manually.
+				check.Status = PreflightWarn
+				check.Message = "bridge does not exist and will be created by minimega"
+
+				if common.BridgeMode == common.BridgeModeAuto {
+					check.Status = PreflightPass
+					check.Message = "bridge will be created automatically"
+				}
+			}
+
+			checks = append(checks, check)
+		}
+	}
+
+	return checks
+}
+
+func preflightBinaries(hosts []string) []PreflightCheck {
+	var checks []PreflightCheck
+
+	for _, host := range hosts {
+		for _, bin := range requiredBinaries {
+			check := PreflightCheck{
+				Check:   PreflightCheckBinaries,
+				Host:    host,
+				Target:  bin,
+				Status:  PreflightPass,
+				Message: "",
+			}
+
+			if resp, err := mm.MeshShellResponse(host, "which "+bin); err != nil || resp == "" {
+				check.Status = PreflightFail
+				check.Message = "binary not found in PATH"
+			}
+
+			checks = append(checks, check)
+		}
+	}
+
+	return checks
+}
+
+func preflightApps(exp *types.Experiment) []PreflightCheck {
+	var (
+		checks    []PreflightCheck
+		available = append(app.List(), app.DefaultApps()...)
+	)
+
+	for _, a := range exp.Apps() {
+		if a.Disabled() {
+			continue
+		}
+
+		check := PreflightCheck{
+			Check:   PreflightCheckApps,
+			Target:  a.Name(),
+			Status:  PreflightPass,
+			Message: "",
+		}
+
+		if !slices.Contains(available, a.Name()) {
+			check.Status = PreflightFail
+			check.Message = fmt.Sprintf("app not found (expected %s%s in PATH)", app.UserAppPrefix, a.Name())
+		}
+
+		checks = append(checks, check)
+	}
+
+	return checks
+}
+
+// preflightIntegrity checks the backing images of each VM disk on the headnode
+// (where minimega expects disks to be) for modifications made underneath their
+// overlays. A backing image that no longer matches its recorded manifest fails,
+// and one modified after an overlay of it was last written is a warning since
+// the overlay may be corrupt.
+func preflightIntegrity(exp *types.Experiment, head string) []PreflightCheck {
+	var (
+		checks []PreflightCheck
+		seen   = make(map[string]struct{})
+	)
+
+	for _, node := range exp.Spec.Topology().BootableNodes() {
+		if node.External() {
+			continue
+		}
+
+		for _, drive := range node.Hardware().Drives() {
+			image := mm.GetMMFullPath(drive.Image())
+
+			// Missing images are already reported by the images check.
+			chain, err := mm.ImageBackingChain(head, image)
+			if err != nil {
+				continue
+			}
+
+			for i := 1; i < len(chain); i++ {
+				overlay, backing := chain[i-1], chain[i]
+
+				if _, ok := seen[backing]; ok {
+					continue
+				}
+
+				seen[backing] = struct{}{}
+
+				check := PreflightCheck{
+					Check:   PreflightCheckIntegrity,
+					Host:    head,
+					Target:  backing,
+					Status:  PreflightPass,
+					Message: "",
+				}
+
+				_, err := manifest.Verify(backing, false)
+
+				switch {
+				case errors.Is(err, manifest.ErrModified):
+					check.Status = PreflightFail
+					check.Message = fmt.Sprintf("backing image of %s: %v", overlay, err)
+				case err != nil && !errors.Is(err, manifest.ErrNoManifest):
+					check.Status = PreflightWarn
+					check.Message = fmt.Sprintf("unable to verify backing image: %v", err)
+				case modifiedAfter(backing, overlay):
+					check.Status = PreflightWarn
+					check.Message = "backing image modified after its overlay " + overlay + " was last written"
+				case err != nil:
+					check.Message = "no manifest recorded for backing image"
+				}
+
+				checks = append(checks, check)
+			}
+		}
+	}
+
+	return checks
+}
+
+// modifiedAfter returns true if the file at path a was modified after the file
+// at path b.
+func modifiedAfter(a, b string) bool {
+	aInfo, err := os.Stat(a)
+	if err != nil {
+		return false
+	}
+
+	bInfo, err := os.Stat(b)
+	if err != nil {
+		return false
+	}
+
+	return aInfo.ModTime().After(bInfo.ModTime())
+}
+
+func fileExistsOnHost(host, path string) bool {
+	path = strings.ReplaceAll(path, "'", `'"'"'`)
+
+	resp, err := mm.MeshShellResponse(host, fmt.Sprintf("stat -c present -- '%s'", path))
+
+	return err == nil && resp == "present"
+}
@@ -0,0 +1,345 @@
+//nolint:testpackage // testing internals
+package experiment
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+
+	"phenix/api/disk/manifest"
+	"phenix/store"
+	"phenix/types"
+	v1 "phenix/types/version/v1"
+	"phenix/util/mm"
+)
+
+// preflightMM is a test double for mm.MM that serves cluster host details and
+// responds to shell commands based on which files and binaries exist on each
+// host.
+type preflightMM struct {
+	mm.MM
+
+	hosts  mm.Hosts
+	files  map[string][]string
+	chains map[string][]string
+}
+
+func (preflightMM) Headnode() string {
+	return "head"
+}
+
+func (m preflightMM) GetClusterHosts(bool) (mm.Hosts, error) {
+	return m.hosts, nil
+}
+
+func (m preflightMM) MeshShellResponse(host, cmd string) (string, error) {
+	if strings.HasPrefix(cmd, "qemu-img info") {
+		for image, chain := range m.chains {
+			if strings.Contains(cmd, " "+image+" ") {
+				var resp []map[string]string
+
+				for _, f := range chain {
+					resp = append(resp, map[string]string{"filename": f})
+				}
+
+				body, _ := json.Marshal(resp)
+
+				return string(body), nil
+			}
+		}
+
+		return "", errors.New("not found")
+	}
+
+	for _, f := range m.files[host] {
+		if strings.HasSuffix(cmd, " "+f) || strings.HasSuffix(cmd, " '"+f+"'") {
+			if strings.HasPrefix(cmd, "stat") {
+				return "present", nil
+			}
+
+			return "/usr/bin/" + f, nil
+		}
+	}
+
+	return "", errors.New("not found")
+}
+
+func preflightExperiment(schedules map[string]string, nodes ...*v1.Node) *types.Experiment {
+	return &types.Experiment{ //nolint:exhaustruct // partial initialization
+		Metadata: store.ConfigMetadata{Name: "test"}, //nolint:exhaustruct // partial initialization
+		Spec: &v1.ExperimentSpec{ //nolint:exhaustruct // partial initialization
+			TopologyF:  &v1.TopologySpec{NodesF: nodes}, //nolint:exhaustruct // partial initialization
+			SchedulesF: schedules,
+		},
+	}
+}
+
+func preflightNode(hostname, image string, memory int) *v1.Node {
+	return &v1.Node{ //nolint:exhaustruct // partial initialization
+		GeneralF: &v1.General{HostnameF: hostname}, //nolint:exhaustruct // partial initialization
+		HardwareF: &v1.Hardware{ //nolint:exhaustruct // partial initialization
+			MemoryF: memory,
+			DrivesF: []*v1.Drive{{ImageF: image}}, //nolint:exhaustruct // partial initialization
+		},
+	}
+}
+
+func installPreflightMM(t *testing.T, fake preflightMM) {
+	t.Helper()
+
+	original := mm.DefaultMM
+	t.Cleanup(func() { mm.DefaultMM = original }) //nolint:reassign // restore test double
+
+	mm.DefaultMM = fake //nolint:reassign // install test double
+}
+
+func TestPreflightImages(t *testing.T) {
+	installPreflightMM(t, preflightMM{ //nolint:exhaustruct // partial initialization
+		files: map[string][]string{
+			"head":    {"/images/base.qc2", "/images/shared.qc2"},
+			"compute": {"/images/base.qc2"},
+		},
+	})
+
+	exp := preflightExperiment(
+		map[string]string{"a": "compute", "b": "compute", "c": "compute"},
+		preflightNode("a", "/images/base.qc2", 0),
+		preflightNode("b", "/images/shared.qc2", 0),
+		preflightNode("c", "/images/missing.qc2", 0),
+		preflightNode("d", "/images/base.qc2", 0),
+	)
+
+	hostFor := func(vm string) string {
+		if host := exp.Spec.Schedules()[vm]; host != "" {
+			return host
+		}
+
+		return "head"
+	}
+
+	checks := preflightImages(exp, "head", hostFor)
+
+	want := map[string]string{
+		"compute /images/base.qc2":    PreflightPass,
+		"compute /images/shared.qc2":  PreflightWarn,
+		"compute /images/missing.qc2": PreflightFail,
+		"head /images/base.qc2":       PreflightPass,
+	}
+
+	if len(checks) != len(want) {
+		t.Fatalf("expected %d checks, got %d: %+v", len(want), len(checks), checks)
+	}
+
+	for _, c := range checks {
+		key := c.Host + " " + c.Target
+		if want[key] != c.Status {
+			t.Errorf("%s: expected status %q, got %q", key, want[key], c.Status)
+		}
+	}
+}
+
+func TestPreflightIntegrity(t *testing.T) {
+	var (
+		dir      = t.TempDir()
+		base     = filepath.Join(dir, "base.qc2")
+		modified = filepath.Join(dir, "modified.qc2")
+		touched  = filepath.Join(dir, "touched.qc2")
+		overlay  = filepath.Join(dir, "overlay.qc2")
+		now      = time.Now()
+	)
+
+	for _, f := range []string{base, modified, touched, overlay} {
+		if err := os.WriteFile(f, []byte("image"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, f := range []string{base, modified} {
+		if _, err := manifest.Record(f); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := os.WriteFile(modified, []byte("edited"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	// The overlay was last written before its backing image was touched.
+	if err := os.Chtimes(overlay, now.Add(-time.Hour), now.Add(-time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+
+	installPreflightMM(t, preflightMM{ //nolint:exhaustruct // partial initialization
+		chains: map[string][]string{
+			"/images/a.qc2": {"/images/a.qc2", base},
+			"/images/b.qc2": {"/images/b.qc2", modified},
+			"/images/c.qc2": {overlay, touched},
+		},
+	})
+
+	exp := preflightExperiment(
+		nil,
+		preflightNode("a", "/images/a.qc2", 0),
+		preflightNode("b", "/images/b.qc2", 0),
+		preflightNode("c", "/images/c.qc2", 0),
+		preflightNode("d", "/images/missing.qc2", 0),
+	)
+
+	checks := preflightIntegrity(exp, "head")
+
+	want := map[string]string{
+		base:     PreflightPass,
+		modified: PreflightFail,
+		touched:  PreflightWarn,
+	}
+
+	if len(checks) != len(want) {
+		t.Fatalf("expected %d checks, got %d: %+v", len(want), len(checks), checks)
+	}
+
+	for _, c := range checks {
+		if want[c.Target] != c.Status {
+			t.Errorf("%s: expected status %q, got %q (%s)", c.Target, want[c.Target], c.Status, c.Message)
+		}
+	}
+}
+
+func TestPreflightMemory(t *testing.T) {
+	installPreflightMM(t, preflightMM{ //nolint:exhaustruct // partial initialization
+		hosts: mm.Hosts{
+			{Name: "compute1", MemTotal: 8192, MemUsed: 1024},
+			{Name: "compute2", MemTotal: 8192, MemUsed: 6144},
+		},
+	})
+
+	exp := preflightExperiment(
+		map[string]string{"a": "compute1", "b": "compute2", "c": "compute3"},
+		preflightNode("a", "base.qc2", 4096),
+		preflightNode("b", "base.qc2", 4096),
+		preflightNode("c", "base.qc2", 1024),
+		preflightNode("unscheduled", "base.qc2", 65536),
+	)
+
+	checks := preflightMemory(exp, exp.Spec.Schedules())
+
+	want := map[string]string{
+		"compute1": PreflightPass,
+		"compute2": PreflightFail,
+		"compute3": PreflightFail,
+	}
+
+	if len(checks) != len(want) {
+		t.Fatalf("expected %d checks, got %d: %+v", len(want), len(checks), checks)
+	}
+
+	for _, c := range checks {
+		if want[c.Host] != c.Status {
+			t.Errorf("%s: expected status %q, got %q (%s)", c.Host, want[c.Host], c.Status, c.Message)
+		}
+	}
+}
+
+func TestPreflightBinaries(t *testing.T) {
+	installPreflightMM(t, preflightMM{ //nolint:exhaustruct // partial initialization
+		files: map[string][]string{"compute": {"qemu-img", "ovs-vsctl"}},
+	})
+
+	checks := preflightBinaries([]string{"compute"})
+
+	for _, c := range checks {
+		want := PreflightPass
+		if c.Target == "ip" {
+			want = PreflightFail
+		}
+
+		if c.Status != want {
+			t.Errorf("%s: expected status %q, got %q", c.Target, want, c.Status)
+		}
+	}
+}
+
+func TestPreflightVLANs(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	original := store.DefaultStore
+	t.Cleanup(func() { store.DefaultStore = original }) //nolint:reassign // restore test double
+
+	m := store.NewMockStore(ctrl)
+	m.EXPECT().List(gomock.Eq("Experiment")).Return(store.Configs{
+		{
+			Version:  "phenix.sandia.gov/v1",
+			Kind:     "Experiment",
+			Metadata: store.ConfigMetadata{Name: "other"}, //nolint:exhaustruct // partial initialization
+			Spec:     map[string]any{},
+			Status: map[string]any{
+				"startTime": time.Now().Format(time.RFC3339),
+				"vlans":     map[string]any{"EXP": 100, "AUTO": 0},
+			},
+		},
+	}, nil)
+
+	store.DefaultStore = m //nolint:reassign // install test double
+
+	// Aliases with an ID of 0 are allocated at start, as set by Spec.Init.
+	exp := preflightExperiment(nil)
+	exp.Spec.(*v1.ExperimentSpec).VLANsF = &v1.VLANSpec{ //nolint:exhaustruct,forcetypeassert // partial initialization
+		AliasesF: map[string]int{"auto1": 0, "auto2": 0, "used": 100, "dup1": 200, "dup2": 200},
+	}
+
+	checks := preflightVLANs(exp)
+
+	want := map[string]string{
+		"auto1 (0)":  PreflightPass,
+		"auto2 (0)":  PreflightPass,
+		"used (100)": PreflightFail,
+		"dup1 (200)": PreflightPass,
+		"dup2 (200)": PreflightFail,
+	}
+
+	if len(checks) != len(want) {
+		t.Fatalf("expected %d checks, got %d: %+v", len(want), len(checks), checks)
+	}
+
+	for _, c := range checks {
+		if want[c.Target] != c.Status {
+			t.Errorf("%s: expected status %q, got %q (%s)", c.Target, want[c.Target], c.Status, c.Message)
+		}
+	}
+}
+
+func TestPreflightReportErr(t *testing.T) {
+	report := PreflightReport{
+		Experiment: "test",
+		Checks: []PreflightCheck{
+			{Check: PreflightCheckImages, Host: "compute", Target: "base.qc2", Status: PreflightPass},
+			{Check: PreflightCheckBridges, Host: "compute", Target: "phenix", Status: PreflightWarn, Message: "bridge does not exist"},
+		},
+	}
+
+	if report.Failed() || report.Err() != nil {
+		t.Fatalf("expected report without failures to pass, got %v", report.Err())
+	}
+
+	if len(report.Warnings()) != 1 {
+		t.Fatalf("expected 1 warning, got %d", len(report.Warnings()))
+	}
+
+	report.Checks = append(report.Checks, PreflightCheck{
+		Check: PreflightCheckApps, Target: "foo", Status: PreflightFail, Message: "app not found",
+	})
+
+	err := report.Err()
+	if !report.Failed() || !errors.Is(err, ErrPreflightFailed) {
+		t.Fatalf("expected pre-flight failure, got %v", err)
+	}
+
+	if !strings.Contains(err.Error(), "apps foo: app not found") {
+		t.Fatalf("expected error to describe failed check, got %v", err)
+	}
+}
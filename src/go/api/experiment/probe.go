@@ -0,0 +1,204 @@
+package experiment
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	ifaces "phenix/types/interfaces"
+	"phenix/util/mm"
+	"phenix/util/notes"
+	"phenix/util/pubsub"
+)
+
+const (
+	// probeReadyMarker is written to STDOUT by command and file probes when the
+	// probe succeeds, since miniccc does not report command exit codes.
+	probeReadyMarker = "phenix-probe-ready"
+
+	probeExecTimeout = 30 * time.Second
+	probeConnWait    = 5 * time.Second
+
+	// defaultProbeTimeout bounds how long a delayed VM waits on its dependencies
+	// when no probe timeout is configured so experiment start can't hang forever.
+	defaultProbeTimeout = 30 * time.Minute
+)
+
+var ErrDelayDependenciesNotReady = errors.New("delay dependencies not ready")
+
+// vmDependencies tracks the hosts a delayed VM is waiting on (mapped to each
+// host's OS type) and the optional probe used to determine when they're ready.
+// Without a probe, a host is considered ready once its C2 agent is active.
+type vmDependencies struct {
+	hosts map[string]string
+	probe ifaces.NodeReadinessProbe
+}
+
+func waitForDependencies(ctx context.Context, ns, host string, deps vmDependencies) error {
+	interval := c2CheckInterval
+
+	if deps.probe != nil && deps.probe.Interval() > 0 {
+		interval = deps.probe.Interval()
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	timeout := defaultProbeTimeout
+
+	if deps.probe != nil && deps.probe.Timeout() > 0 {
+		timeout = deps.probe.Timeout()
+	}
+
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+
+	ready := make(map[string]bool)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-deadline.C:
+			var pending []string
+
+			for other := range deps.hosts {
+				if !ready[other] {
+					pending = append(pending, other)
+				}
+			}
+
+			slices.Sort(pending)
+
+			return NewDelayedVMError(
+				host, ErrDelayDependenciesNotReady, "waiting on %v to start VM %s", pending, host,
+			)
+		case <-ticker.C:
+			for other, osType := range deps.hosts {
+				if ready[other] {
+					continue
+				}
+
+				if dependencyReady(ctx, ns, other, osType, deps.probe) {
+					ready[other] = true
+
+					notes.AddInfo(
+						ctx,
+						true,
+						fmt.Sprintf("VM %s ready for delayed VM %s", other, host),
+					)
+				}
+			}
+
+			if len(ready) < len(deps.hosts) {
+				continue
+			}
+
+			if err := startDelayedVM(ns, host); err != nil {
+				return err
+			}
+
+			notes.AddInfo(ctx, true, fmt.Sprintf("Dependency delayed VM %s started", host))
+			pubsub.Publish("delayed-start", fmt.Sprintf("%s/%s", ns, host))
+
+			return nil
+		}
+	}
+}
+
+// dependencyReady returns true if the C2 agent for the given host is active
+// and, if a probe is provided, the probe succeeds when run on the host.
+func dependencyReady(
+	ctx context.Context,
+	ns, host, osType string,
+	probe ifaces.NodeReadinessProbe,
+) bool {
+	opts := []mm.C2Option{mm.C2NS(ns), mm.C2VM(host), mm.C2Context(ctx)}
+
+	if probe != nil && probe.UseUUID() {
+		opts = append(opts, mm.C2IDClientsByUUID())
+	}
+
+	if mm.IsC2ClientActive(append(opts, mm.C2Timeout(1*time.Second))...) != nil {
+		return false
+	}
+
+	if probe == nil {
+		return true
+	}
+
+	opts = append(
+		opts,
+		mm.C2SkipActiveClientCheck(true),
+		mm.C2Timeout(probeExecTimeout),
+		mm.C2Wait(),
+	)
+
+	if probe.Port() != 0 {
+		test := fmt.Sprintf("tcp %s %d wait %v", probe.Address(), probe.Port(), probeConnWait)
+
+		id, err := mm.ExecC2Command(append(opts, mm.C2TestConn(test))...)
+		if err != nil {
+			return false
+		}
+
+		resp, err := mm.GetC2Response(mm.C2NS(ns), mm.C2VM(host), mm.C2CommandID(id))
+		if err != nil {
+			return false
+		}
+
+		return resp != "" && !strings.Contains(resp, "fail")
+	}
+
+	id, err := mm.ExecC2Command(append(opts, mm.C2Command(probeCommand(osType, probe)))...)
+	if err != nil {
+		return false
+	}
+
+	resp, err := mm.GetC2Response(
+		mm.C2NS(ns),
+		mm.C2VM(host),
+		mm.C2CommandID(id),
+		mm.C2ResponseTypeStdout(),
+	)
+	if err != nil {
+		return false
+	}
+
+	return strings.Contains(resp, probeReadyMarker)
+}
+
+// probeCommand returns the command to execute via C2 for command and file
+// probes. The command prints probeReadyMarker to STDOUT only if the probe
+// command exits successfully or the file exists. Commands are run via
+// PowerShell on Windows hosts and via `sh` everywhere else.
+func probeCommand(osType string, probe ifaces.NodeReadinessProbe) string {
+	windows := strings.EqualFold(osType, "windows")
+
+	if path := probe.File(); path != "" {
+		if windows {
+			path = strings.ReplaceAll(path, "'", "''")
+
+			return mm.PowerShellCommand(
+				fmt.Sprintf("if (Test-Path -LiteralPath '%s') { '%s' }", path, probeReadyMarker),
+			)
+		}
+
+		path = strings.ReplaceAll(path, "'", `'"'"'`)
+
+		return fmt.Sprintf("stat -c %s -- '%s'", probeReadyMarker, path)
+	}
+
+	command := probe.Command()
+
+	if windows {
+		return mm.PowerShellCommand(fmt.Sprintf("%s; if ($?) { '%s' }", command, probeReadyMarker))
+	}
+
+	command = strings.ReplaceAll(command, "'", `'"'"'`)
+
+	return fmt.Sprintf("sh -c '%s && echo %s'", command, probeReadyMarker)
+}
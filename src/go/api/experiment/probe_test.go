@@ -0,0 +1,160 @@
+//nolint:testpackage // testing internals
+package experiment
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+
+	ifaces "phenix/types/interfaces"
+	v1 "phenix/types/version/v1"
+	"phenix/util/mm"
+)
+
+// probeMM is a test double for mm.MM that reports whether C2 clients are
+// active and returns a canned response for any C2 command executed.
+type probeMM struct {
+	mm.MM
+
+	mu       sync.Mutex
+	active   bool
+	response string
+	execs    int
+}
+
+func (m *probeMM) IsC2ClientActive(...mm.C2Option) error {
+	if !m.active {
+		return mm.ErrC2ClientNotActive
+	}
+
+	return nil
+}
+
+func (m *probeMM) ExecC2Command(...mm.C2Option) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.execs++
+
+	return "1", nil
+}
+
+func (m *probeMM) GetC2Response(...mm.C2Option) (string, error) {
+	return m.response, nil
+}
+
+func installProbeMM(t *testing.T, fake *probeMM) {
+	t.Helper()
+
+	original := mm.DefaultMM
+	t.Cleanup(func() { mm.DefaultMM = original }) //nolint:reassign // restore test double
+
+	mm.DefaultMM = fake //nolint:reassign // install test double
+}
+
+func TestDependencyReady(t *testing.T) {
+	tests := []struct {
+		name     string
+		active   bool
+		probe    *v1.ReadinessProbe
+		response string
+		want     bool
+		execs    int
+	}{
+		{name: "C2 not active", active: false, probe: &v1.ReadinessProbe{PortF: 389}, want: false, execs: 0},
+		{name: "C2 active without probe", active: true, want: true, execs: 0},
+		{name: "port open", active: true, probe: &v1.ReadinessProbe{PortF: 389}, response: "tcp 127.0.0.1:389 success", want: true, execs: 1},
+		{name: "port closed", active: true, probe: &v1.ReadinessProbe{PortF: 389}, response: "tcp 127.0.0.1:389 fail", want: false, execs: 1},
+		{name: "file exists", active: true, probe: &v1.ReadinessProbe{FileF: "/ready"}, response: probeReadyMarker, want: true, execs: 1},
+		{name: "command failed", active: true, probe: &v1.ReadinessProbe{CommandF: "false"}, response: "", want: false, execs: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := &probeMM{active: tt.active, response: tt.response}
+			installProbeMM(t, fake)
+
+			// Avoid passing a typed nil pointer as the probe interface.
+			var probe ifaces.NodeReadinessProbe
+			if tt.probe != nil {
+				probe = tt.probe
+			}
+
+			got := dependencyReady(context.Background(), "test-ns", "dc", "linux", probe)
+			if got != tt.want {
+				t.Fatalf("expected ready=%v, got %v", tt.want, got)
+			}
+
+			if fake.execs != tt.execs {
+				t.Fatalf("expected %d C2 command(s), got %d", tt.execs, fake.execs)
+			}
+		})
+	}
+}
+
+func TestWaitForDependenciesTimeout(t *testing.T) {
+	installProbeMM(t, &probeMM{active: false})
+
+	deps := vmDependencies{
+		hosts: map[string]string{"dc": "windows"},
+		probe: &v1.ReadinessProbe{PortF: 389, IntervalF: "10ms", TimeoutF: "50ms"},
+	}
+
+	err := waitForDependencies(context.Background(), "test-ns", "member", deps)
+	if !errors.Is(err, ErrDelayDependenciesNotReady) {
+		t.Fatalf("expected dependencies not ready error, got %v", err)
+	}
+
+	var delayErr DelayedVMError
+	if !errors.As(err, &delayErr) || delayErr.VM != "member" {
+		t.Fatalf("expected DelayedVMError for VM member, got %v", err)
+	}
+
+	if !strings.Contains(err.Error(), "[dc]") {
+		t.Fatalf("expected error to name pending dependency, got %v", err)
+	}
+}
+
+func TestProbeCommand(t *testing.T) {
+	tests := []struct {
+		name   string
+		osType string
+		probe  *v1.ReadinessProbe
+		want   string
+	}{
+		{
+			name:   "linux command",
+			osType: "linux",
+			probe:  &v1.ReadinessProbe{CommandF: "grep -q 'ok' /tmp/status"},
+			want:   `sh -c 'grep -q '"'"'ok'"'"' /tmp/status && echo phenix-probe-ready'`,
+		},
+		{
+			name:   "linux file",
+			osType: "linux",
+			probe:  &v1.ReadinessProbe{FileF: "/etc/ready"},
+			want:   "stat -c phenix-probe-ready -- '/etc/ready'",
+		},
+		{
+			name:   "windows command",
+			osType: "Windows",
+			probe:  &v1.ReadinessProbe{CommandF: "nltest /dsgetdc:example.com"},
+			want:   mm.PowerShellCommand(`nltest /dsgetdc:example.com; if ($?) { 'phenix-probe-ready' }`),
+		},
+		{
+			name:   "windows file",
+			osType: "windows",
+			probe:  &v1.ReadinessProbe{FileF: `C:\it's ready.txt`},
+			want:   mm.PowerShellCommand(`if (Test-Path -LiteralPath 'C:\it''s ready.txt') { 'phenix-probe-ready' }`),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := probeCommand(tt.osType, tt.probe); got != tt.want {
+				t.Fatalf("expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
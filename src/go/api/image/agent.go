@@ -0,0 +1,380 @@
+package image
+
+import (
+	"debug/elf"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/go-multierror"
+
+	"phenix/api/disk"
+)
+
+// Linux init systems the miniccc agent can be injected for.
+const (
+	InitSystemd = "systemd"
+	InitSysV    = "sysv"
+	InitOpenRC  = "openrc"
+)
+
+// Agent check statuses.
+const (
+	AgentPass = "pass"
+	AgentWarn = "warn"
+	AgentFail = "fail"
+)
+
+// minicccSerialPort is the virtio-serial port miniccc connects to minimega
+// over.
+const minicccSerialPort = "/dev/virtio-ports/cc"
+
+// maxSymlinkDepth is how many symlinks are followed when resolving paths in a
+// mounted image.
+const maxSymlinkDepth = 16
+
+var ErrAgentNotConfigured = errors.New("miniccc agent not configured")
+
+// minicccPaths are where miniccc is installed in images, by inject-miniccc and
+// inject-miniexe or by the phenix base image script, respectively.
+var minicccPaths = []string{"/usr/local/bin/miniccc", "/opt/minimega/bin/miniccc"} //nolint:gochecknoglobals // global constant
+
+// AgentCheck is the result of a single miniccc agent check.
+type AgentCheck struct {
+	Check   string `json:"check"`
+	Status  string `json:"status"`
+	Message string `json:"message,omitempty"`
+}
+
+func (c AgentCheck) Error() string {
+	return fmt.Sprintf("%s: %s", c.Check, c.Message)
+}
+
+// AgentReport is the result of verifying the miniccc agent is configured in a
+// disk image.
+type AgentReport struct {
+	Disk       string       `json:"disk"`
+	InitSystem string       `json:"initSystem"`
+	Checks     []AgentCheck `json:"checks"`
+}
+
+// Err returns an error wrapping ErrAgentNotConfigured and each failed check,
+// or nil if no checks failed.
+func (r AgentReport) Err() error {
+	var errs error
+
+	for _, c := range r.Checks {
+		if c.Status == AgentFail {
+			errs = multierror.Append(errs, c)
+		}
+	}
+
+	if errs == nil {
+		return nil
+	}
+
+	return fmt.Errorf("%w: %w", ErrAgentNotConfigured, errs)
+}
+
+// InjectMiniccc injects the miniccc executable at the given path and a service
+// to start it at boot for the given init system (systemd, sysv, or openrc) into
+// the given partition of an existing Linux disk image. Relative image paths are
+// resolved against the minimega files directory. If partition is zero, the
+// partition included in the disk path (e.g., /phenix/images/foo.qc2:2) or
+// partition 1 is used.
+func InjectMiniccc(exe, image string, partition int, init string) error {
+	if base := path.Base(exe); base != miniccc {
+		return fmt.Errorf("expected path to the %s executable, got %s", miniccc, base)
+	}
+
+	if _, err := os.Stat(exe); err != nil {
+		return fmt.Errorf("%w: %w", ErrMinicccNotFound, err)
+	}
+
+	switch init {
+	case InitSystemd, InitOpenRC:
+	case InitSysV:
+		init = "sysinitv"
+	default:
+		return fmt.Errorf("unknown init system %s (expected %s, %s, or %s)", init, InitSystemd, InitSysV, InitOpenRC)
+	}
+
+	image, partition, err := minicccDisk(image, partition)
+	if err != nil {
+		return err
+	}
+
+	return InjectMiniExe(exe, fmt.Sprintf("%s:%d", image, partition), init)
+}
+
+// VerifyMiniccc mounts the given partition of the given Linux disk image
+// read-only on the headnode and checks the miniccc agent is installed and
+// configured to start at boot for the image's init system. The image and
+// partition default the same way they do for InjectMiniccc.
+func VerifyMiniccc(image string, partition int) (AgentReport, error) {
+	image, partition, err := minicccDisk(image, partition)
+	if err != nil {
+		return AgentReport{}, err //nolint:exhaustruct // error
+	}
+
+	mnt, err := os.MkdirTemp("", "phenix-miniccc-")
+	if err != nil {
+		return AgentReport{}, fmt.Errorf("creating mount point: %w", err) //nolint:exhaustruct // error
+	}
+
+	if err := disk.MountDisk(image, mnt, partition); err != nil {
+		_ = os.Remove(mnt)
+
+		return AgentReport{}, fmt.Errorf("mounting disk: %w", err) //nolint:exhaustruct // error
+	}
+
+	defer func() { _ = disk.UnmountDisk(mnt) }()
+
+	report := verifyMinicccRoot(mnt)
+	report.Disk = image
+
+	return report, nil
+}
+
+// minicccDisk returns the path of the given disk image, resolved against the
+// minimega files directory if relative (see ResolveImagePath), and the
+// partition to inject into or verify. If partition is zero, the partition
+// included in the disk path (e.g., foo.qc2:2) or partition 1 is used.
+func minicccDisk(image string, partition int) (string, int, error) {
+	image, part, found := strings.Cut(image, ":")
+
+	if partition == 0 && found {
+		var err error
+
+		if partition, err = strconv.Atoi(part); err != nil || partition < 1 {
+			return "", 0, fmt.Errorf("invalid partition %q in disk path", part)
+		}
+	}
+
+	if partition == 0 {
+		partition = 1
+	}
+
+	return ResolveImagePath(image), partition, nil
+}
+
+// verifyMinicccRoot checks the miniccc agent is configured in the Linux root
+// filesystem at the given path.
+func verifyMinicccRoot(root string) AgentReport {
+	var report AgentReport
+
+	exe, check := checkMinicccBinary(root)
+	report.Checks = append(report.Checks, check)
+
+	init, check := checkInitSystem(root)
+	report.InitSystem = init
+	report.Checks = append(report.Checks, check)
+
+	service, check := checkMinicccService(root, init)
+	report.Checks = append(report.Checks, check)
+
+	if service != "" {
+		report.Checks = append(report.Checks, checkMinicccCommand(root, service, exe))
+	}
+
+	return report
+}
+
+// checkMinicccBinary returns the path in the image of the installed miniccc
+// executable, if any.
+func checkMinicccBinary(root string) (string, AgentCheck) {
+	check := AgentCheck{Check: "binary"} //nolint:exhaustruct // partial initialization
+
+	for _, p := range minicccPaths {
+		info, err := os.Stat(resolve(root, p))
+		if err != nil {
+			continue
+		}
+
+		switch {
+		case !info.Mode().IsRegular():
+			check.Status, check.Message = AgentFail, p+" is not a regular file"
+		case info.Mode().Perm()&0o111 == 0:
+			check.Status, check.Message = AgentFail, p+" is not executable"
+		default:
+			f, err := elf.Open(resolve(root, p))
+			if err != nil {
+				check.Status, check.Message = AgentFail, p+" is not a Linux executable"
+
+				break
+			}
+
+			_ = f.Close()
+
+			check.Status = AgentPass
+			check.Message = fmt.Sprintf("%s (%s)", p, strings.TrimPrefix(f.Machine.String(), "EM_"))
+		}
+
+		return p, check
+	}
+
+	check.Status = AgentFail
+	check.Message = "miniccc not found at " + strings.Join(minicccPaths, " or ")
+
+	return "", check
+}
+
+// checkInitSystem returns the init system the image boots with. An empty
+// string is returned if it can't be determined.
+func checkInitSystem(root string) (string, AgentCheck) {
+	check := AgentCheck{Check: "init", Status: AgentPass} //nolint:exhaustruct // partial initialization
+
+	var init string
+
+	switch {
+	case strings.Contains(resolve(root, "/sbin/init"), "systemd"):
+		init = InitSystemd
+	case exists(root, "/sbin/openrc-run") || exists(root, "/sbin/openrc"):
+		init = InitOpenRC
+	case exists(root, "/etc/inittab") || exists(root, "/etc/init.d"):
+		init = InitSysV
+	}
+
+	if init == "" {
+		check.Status, check.Message = AgentWarn, "unable to determine init system"
+	} else {
+		check.Message = init
+	}
+
+	return init, check
+}
+
+// checkMinicccService returns the path in the image of the miniccc service
+// enabled for the given init system, if any. If init is empty, services for
+// any init system are accepted.
+func checkMinicccService(root, init string) (string, AgentCheck) {
+	check := AgentCheck{Check: "service"} //nolint:exhaustruct // partial initialization
+
+	services := map[string]func() (string, bool){
+		InitSystemd: func() (string, bool) {
+			for _, dir := range []string{"/etc/systemd/system", "/lib/systemd/system", "/usr/lib/systemd/system"} {
+				if unit := dir + "/miniccc.service"; exists(root, unit) {
+					return unit, glob(root, "/etc/systemd/system/*.wants/miniccc.service")
+				}
+			}
+
+			return "", false
+		},
+		InitSysV: func() (string, bool) {
+			if exists(root, "/etc/init.d/miniccc") && !exists(root, "/sbin/openrc-run") {
+				return "/etc/init.d/miniccc", glob(root, "/etc/rc[2345].d/S*miniccc")
+			}
+
+			return "", false
+		},
+		InitOpenRC: func() (string, bool) {
+			if exists(root, "/etc/init.d/miniccc") {
+				return "/etc/init.d/miniccc", glob(root, "/etc/runlevels/*/miniccc")
+			}
+
+			return "", false
+		},
+	}
+
+	for _, candidate := range []string{InitSystemd, InitSysV, InitOpenRC} {
+		if init != "" && candidate != init {
+			continue
+		}
+
+		service, enabled := services[candidate]()
+
+		switch {
+		case service == "":
+			continue
+		case !enabled:
+			check.Status, check.Message = AgentFail, fmt.Sprintf("%s service %s is not enabled", candidate, service)
+		default:
+			check.Status, check.Message = AgentPass, fmt.Sprintf("%s service %s", candidate, service)
+		}
+
+		return service, check
+	}
+
+	check.Status = AgentFail
+	check.Message = "no miniccc service installed"
+
+	// Point out services installed for the wrong init system.
+	for _, candidate := range []string{InitSystemd, InitSysV, InitOpenRC} {
+		if service, _ := services[candidate](); service != "" {
+			check.Message = fmt.Sprintf("no %s miniccc service installed (found %s service %s)", init, candidate, service)
+
+			break
+		}
+	}
+
+	return "", check
+}
+
+// checkMinicccCommand checks the given service starts the installed miniccc
+// executable and connects it to minimega over its serial port.
+func checkMinicccCommand(root, service, exe string) AgentCheck {
+	check := AgentCheck{Check: "command", Status: AgentPass} //nolint:exhaustruct // partial initialization
+
+	body, err := os.ReadFile(resolve(root, service))
+	if err != nil {
+		check.Status, check.Message = AgentFail, fmt.Sprintf("unable to read %s: %v", service, err)
+
+		return check
+	}
+
+	// Init scripts may refer to the executable by its directory and name
+	// separately (e.g., /usr/local/bin/$PROG).
+	starts := strings.Contains(string(body), exe) ||
+		strings.Contains(string(body), path.Dir(exe)+"/") && strings.Contains(string(body), miniccc)
+
+	switch {
+	case exe != "" && !starts:
+		check.Status, check.Message = AgentFail, fmt.Sprintf("%s does not start %s", service, exe)
+	case !strings.Contains(string(body), minicccSerialPort):
+		check.Status, check.Message = AgentWarn, fmt.Sprintf("%s does not connect miniccc to %s", service, minicccSerialPort)
+	default:
+		check.Message = "miniccc connects over " + minicccSerialPort
+	}
+
+	return check
+}
+
+// resolve returns the path on the host of the given path in the image mounted
+// at root, following symlinks in the image relative to root rather than the
+// host's root.
+func resolve(root, p string) string {
+	for range maxSymlinkDepth {
+		full := filepath.Join(root, p)
+
+		target, err := os.Readlink(full)
+		if err != nil {
+			return full
+		}
+
+		if filepath.IsAbs(target) {
+			p = target
+		} else {
+			p = filepath.Join(filepath.Dir(p), target)
+		}
+	}
+
+	return filepath.Join(root, p)
+}
+
+func exists(root, p string) bool {
+	_, err := os.Lstat(resolve(root, p))
+
+	return err == nil
+}
+
+// glob returns true if any paths in the image mounted at root match the given
+// pattern.
+func glob(root, pattern string) bool {
+	matches, _ := filepath.Glob(filepath.Join(root, pattern))
+
+	return len(matches) > 0
+}
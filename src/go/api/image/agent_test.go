@@ -0,0 +1,216 @@
+//nolint:testpackage // testing internals
+package image
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"phenix/tmpl"
+	"phenix/util/mm"
+)
+
+// fakeRoot creates a Linux root filesystem with the given files, where the
+// value is either the file's contents or, if prefixed with "->", the target of
+// a symlink.
+func fakeRoot(t *testing.T, files map[string]string) string {
+	t.Helper()
+
+	root := t.TempDir()
+
+	for name, content := range files {
+		p := filepath.Join(root, name)
+
+		if err := os.MkdirAll(filepath.Dir(p), 0o750); err != nil {
+			t.Fatal(err)
+		}
+
+		var err error
+
+		if target, ok := strings.CutPrefix(content, "->"); ok {
+			err = os.Symlink(target, p)
+		} else {
+			err = os.WriteFile(p, []byte(content), 0o750) //nolint:gosec // scripts and executables
+		}
+
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return root
+}
+
+// fakeMiniccc returns the contents of an ELF executable to stand in for
+// miniccc.
+func fakeMiniccc(t *testing.T) string {
+	t.Helper()
+
+	exe, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	body, err := os.ReadFile(exe) //nolint:gosec // test binary
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return string(body)
+}
+
+func agentChecks(report AgentReport) map[string]AgentCheck {
+	checks := make(map[string]AgentCheck)
+
+	for _, c := range report.Checks {
+		checks[c.Check] = c
+	}
+
+	return checks
+}
+
+func TestVerifyMinicccSystemd(t *testing.T) {
+	root := fakeRoot(t, map[string]string{
+		"/usr/local/bin/miniccc":              fakeMiniccc(t),
+		"/lib/systemd/systemd":                "",
+		"/sbin/init":                          "->/lib/systemd/systemd",
+		"/etc/systemd/system/miniccc.service": "[Service]\nExecStart=/usr/local/bin/miniccc -serial /dev/virtio-ports/cc\n",
+		"/etc/systemd/system/multi-user.target.wants/miniccc.service": "->../miniccc.service",
+	})
+
+	report := verifyMinicccRoot(root)
+
+	if err := report.Err(); err != nil {
+		t.Fatalf("expected agent to be configured, got %v", err)
+	}
+
+	if report.InitSystem != InitSystemd {
+		t.Errorf("expected systemd init system, got %s", report.InitSystem)
+	}
+
+	if c := agentChecks(report)["command"]; c.Status != AgentPass {
+		t.Errorf("expected command check to pass, got %+v", c)
+	}
+}
+
+func TestVerifyMinicccSysV(t *testing.T) {
+	dir := t.TempDir()
+
+	if err := os.MkdirAll(dir+"/miniccc", 0o750); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := tmpl.RestoreAsset(dir, "miniccc/miniccc.init"); err != nil {
+		t.Fatal(err)
+	}
+
+	script, err := os.ReadFile(dir + "/miniccc/miniccc.init")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	root := fakeRoot(t, map[string]string{
+		"/usr/local/bin/miniccc": fakeMiniccc(t),
+		"/sbin/init":             "",
+		"/etc/inittab":           "",
+		"/etc/init.d/miniccc":    string(script),
+	})
+
+	// Installed but not enabled.
+	checks := agentChecks(verifyMinicccRoot(root))
+
+	if c := checks["service"]; c.Status != AgentFail || !strings.Contains(c.Message, "not enabled") {
+		t.Errorf("expected service not enabled, got %+v", c)
+	}
+
+	if err := os.MkdirAll(root+"/etc/rc5.d", 0o750); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.Symlink("../init.d/miniccc", root+"/etc/rc5.d/S99-miniccc"); err != nil {
+		t.Fatal(err)
+	}
+
+	report := verifyMinicccRoot(root)
+
+	if err := report.Err(); err != nil {
+		t.Fatalf("expected agent to be configured, got %v", err)
+	}
+
+	if report.InitSystem != InitSysV {
+		t.Errorf("expected sysv init system, got %s", report.InitSystem)
+	}
+}
+
+func TestVerifyMinicccMissing(t *testing.T) {
+	root := fakeRoot(t, map[string]string{
+		"/sbin/openrc-run":                    "",
+		"/usr/local/bin/miniccc":              "#!/bin/sh\n",
+		"/etc/systemd/system/miniccc.service": "",
+	})
+
+	report := verifyMinicccRoot(root)
+
+	if err := report.Err(); !errors.Is(err, ErrAgentNotConfigured) {
+		t.Fatalf("expected agent not configured, got %v", err)
+	}
+
+	checks := agentChecks(report)
+
+	if c := checks["binary"]; c.Status != AgentFail || !strings.Contains(c.Message, "not a Linux executable") {
+		t.Errorf("expected binary check to fail, got %+v", c)
+	}
+
+	if c := checks["service"]; !strings.Contains(c.Message, "found systemd service") {
+		t.Errorf("expected service for wrong init system to be reported, got %+v", c)
+	}
+}
+
+func TestInjectMinicccValidation(t *testing.T) {
+	if err := InjectMiniccc("/opt/minimega/bin/protonuke", "foo.qc2", 1, InitSystemd); err == nil {
+		t.Error("expected error for non-miniccc executable")
+	}
+
+	if err := InjectMiniccc("/nonexistent/miniccc", "foo.qc2", 1, InitSystemd); !errors.Is(err, ErrMinicccNotFound) {
+		t.Errorf("expected miniccc not found, got %v", err)
+	}
+
+	exe := fakeRoot(t, map[string]string{"/miniccc": ""}) + "/miniccc"
+
+	if err := InjectMiniccc(exe, "foo.qc2", 1, "upstart"); err == nil {
+		t.Error("expected error for unknown init system")
+	}
+}
+
+func TestMinicccDisk(t *testing.T) {
+	files := mm.GetMMFilesDirectory()
+
+	tests := []struct {
+		image     string
+		partition int
+		path      string
+		want      int
+	}{
+		{"foo.qc2", 0, filepath.Join(files, "foo.qc2"), 1},
+		{"foo.qc2:2", 0, filepath.Join(files, "foo.qc2"), 2},
+		{"/images/foo.qc2:2", 3, "/images/foo.qc2", 3},
+		{"/images/foo.qc2", 0, "/images/foo.qc2", 1},
+	}
+
+	for _, tc := range tests {
+		path, partition, err := minicccDisk(tc.image, tc.partition)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", tc.image, err)
+		}
+
+		if path != tc.path || partition != tc.want {
+			t.Errorf("%s: expected %s partition %d, got %s partition %d", tc.image, tc.path, tc.want, path, partition)
+		}
+	}
+
+	if _, _, err := minicccDisk("foo.qc2:boot", 0); err == nil {
+		t.Error("expected error for invalid partition in disk path")
+	}
+}
@@ -5,6 +5,36 @@ const PostbuildAptCleanup = `
 apt clean || apt-get clean || echo "unable to clean apt cache"
 `
 
+const PostbuildDnfCleanup = `
+# --------------------------------------------------- Cleanup ----------------------------------------------------
+dnf clean all || echo "unable to clean dnf cache"
+truncate -s 0 /etc/machine-id
+`
+
+const PostbuildRPMNoRootPasswd = `
+# ---------------------------------------------- No Root Password ------------------------------------------------
+mkdir -p /etc/ssh/sshd_config.d
+cat > /etc/ssh/sshd_config.d/00-phenix.conf <<EOF
+PermitRootLogin yes
+PermitEmptyPasswords yes
+EOF
+passwd -d root
+`
+
+const PostbuildSELinuxPermissive = `
+# -------------------------------------------------- SELinux -----------------------------------------------------
+# Files created outside of the image's policy aren't labeled, so relabel them now
+# and don't enforce the policy. VMs boot from snapshot overlays, so a first boot
+# relabel (/.autorelabel) would never be cleared and would run on every boot.
+if [ -f /etc/selinux/config ]; then
+  sed -i 's/^SELINUX=.*/SELINUX=permissive/' /etc/selinux/config
+  . /etc/selinux/config
+  setfiles -F -e /proc -e /sys -e /dev /etc/selinux/${SELINUXTYPE}/contexts/files/file_contexts / || echo "unable to relabel files"
+else
+  echo "SELinux not installed"
+fi
+`
+
 const PostbuildNoRootPasswd = `
 # ---------------------------------------------- No Root Password ------------------------------------------------
 sed -i 's/nullok_secure/nullok/' /etc/pam.d/common-auth
@@ -160,6 +190,60 @@ var DefaultPackages = []string{ //nolint:gochecknoglobals // global constant
 	"wget",
 }
 
+var RPMDefaultPackages = []string{ //nolint:gochecknoglobals // global constant
+	"curl",
+	"ethtool",
+	"nmap-ncat",
+	"net-tools",
+	"openssh-clients",
+	"openssh-server",
+	"rsync",
+	"tcpdump",
+	"tmux",
+	"vim-enhanced",
+	"wget",
+}
+
+var RPMPackages = []string{ //nolint:gochecknoglobals // global constant
+	"dnf",
+	"dracut",
+	"e2fsprogs",
+	"grub2-pc",
+	"grub2-tools",
+	"iproute",
+	"iputils",
+	"kernel",
+	"NetworkManager",
+	"passwd",
+	"policycoreutils",
+	"systemd",
+}
+
+// RPMReleasePackages are the packages providing the repository configuration
+// for each RPM distribution.
+var RPMReleasePackages = map[string]string{ //nolint:gochecknoglobals // global constant
+	distroRocky:  "rocky-release",
+	distroAlma:   "almalinux-release",
+	distroFedora: "fedora-release",
+}
+
+var ELComponents = []string{ //nolint:gochecknoglobals // global constant
+	"BaseOS",
+	"AppStream",
+}
+
+var FedoraComponents = []string{ //nolint:gochecknoglobals // global constant
+	"releases",
+	"updates",
+}
+
+// debianReleases and kaliReleases are the Debian and Kali releases that can be
+// built. Any other release is assumed to be an Ubuntu release.
+var (
+	debianReleases = []string{"jessie", "stretch", "buster", "bullseye", "bookworm"}                  //nolint:gochecknoglobals // global constant
+	kaliReleases   = []string{"kali-dev", "kali-rolling", "kali-last-snapshot", "kali-bleeding-edge"} //nolint:gochecknoglobals // global constant
+)
+
 var DebianComponents = []string{ //nolint:gochecknoglobals // global constant
 	"main",
 	"restricted",
@@ -13,10 +13,14 @@ import (
 	"path"
 	"slices"
 	"strings"
+	"sync"
+	"syscall"
+	"time"
 
 	"github.com/activeshadow/structs"
 	"github.com/mitchellh/mapstructure"
 
+	"phenix/api/disk/manifest"
 	"phenix/store"
 	"phenix/tmpl"
 	"phenix/types"
@@ -35,6 +39,10 @@ var (
 	ErrProtonukeNotFound = errors.New("protonuke executable not found")
 )
 
+// buildCancelDelay is how long canceled builds are given to exit before being
+// killed.
+const buildCancelDelay = 30 * time.Second
+
 const miniccc = "miniccc"
 const protonuke = "protonuke"
 
@@ -43,17 +51,22 @@ const protonuke = "protonuke"
 // value, specific constants will be included during the create sub-command.
 // The values are passed from the `constants.go` file. An error will be
 // returned if the variant value is not valid (acceptable values are `minbase`
-// or `mingui`).
+// or `mingui`, or `rpm-minbase` for Rocky, Alma, and Fedora releases).
 func SetupImage(img *v1.Image) error { //nolint:funlen // complex logic
-	debian := []string{"jessie", "stretch", "buster", "bullseye", "bookworm"}
-	kali := []string{"kali-dev", "kali-rolling", "kali-last-snapshot", "kali-bleeding-edge"}
+	if isRPMVariant(img.Variant) {
+		if err := setupRPMImage(img); err != nil {
+			return err
+		}
+
+		return addScriptPathsToImage(img)
+	}
 
 	// If mirror is the default value, make sure it is correct based on the Release
 	if img.Mirror == "http://us.archive.ubuntu.com/ubuntu" {
 		switch {
-		case slices.Contains(debian, img.Release):
+		case slices.Contains(debianReleases, img.Release):
 			img.Mirror = "http://ftp.us.debian.org/debian"
-		case slices.Contains(kali, img.Release):
+		case slices.Contains(kaliReleases, img.Release):
 			img.Mirror = "http://http.kali.org/kali"
 		}
 	}
@@ -61,7 +74,7 @@ func SetupImage(img *v1.Image) error { //nolint:funlen // complex logic
 	// If not specified, set default package components
 	if len(img.Components) == 0 {
 		switch {
-		case slices.Contains(kali, img.Release):
+		case slices.Contains(kaliReleases, img.Release):
 			img.Components = append(img.Components, KaliComponents...)
 		default:
 			img.Components = append(img.Components, DebianComponents...)
@@ -77,20 +90,20 @@ func SetupImage(img *v1.Image) error { //nolint:funlen // complex logic
 	switch img.Variant {
 	case "minbase":
 		switch {
-		case slices.Contains(kali, img.Release):
+		case slices.Contains(kaliReleases, img.Release):
 			img.Packages = append(img.Packages, KaliPackages...)
-		case slices.Contains(debian, img.Release):
+		case slices.Contains(debianReleases, img.Release):
 			img.Packages = append(img.Packages, DebianPackages...)
 		default: // "xenial", "bionic", "focal", "jammy", "noble" ...
 			img.Packages = append(img.Packages, UbuntuPackages...)
 		}
 	case "mingui":
 		switch {
-		case slices.Contains(kali, img.Release):
+		case slices.Contains(kaliReleases, img.Release):
 			img.Packages = append(img.Packages, KaliPackages...)
 			img.Packages = append(img.Packages, KaliMinGUIPackages...)
 			_ = addScriptToImage(img, "POSTBUILD_KALI_GUI", PostbuildKaliGUI)
-		case slices.Contains(debian, img.Release):
+		case slices.Contains(debianReleases, img.Release):
 			img.Packages = append(img.Packages, DebianPackages...)
 			img.Packages = append(img.Packages, DebianMinGUIPackages...)
 			_ = addScriptToImage(img, "POSTBUILD_GUI", PostbuildGUI)
@@ -114,18 +127,29 @@ func SetupImage(img *v1.Image) error { //nolint:funlen // complex logic
 		return fmt.Errorf("variant %s is not implemented", img.Variant)
 	}
 
-	if len(img.ScriptPaths) > 0 {
-		for _, p := range img.ScriptPaths {
-			err := addScriptToImage(img, p, "")
-			if err != nil {
-				return fmt.Errorf("adding script %s to image config: %w", p, err)
-			}
+	return addScriptPathsToImage(img)
+}
+
+// addScriptPathsToImage adds the scripts at the image's script paths to the
+// image.
+func addScriptPathsToImage(img *v1.Image) error {
+	for _, p := range img.ScriptPaths {
+		err := addScriptToImage(img, p, "")
+		if err != nil {
+			return fmt.Errorf("adding script %s to image config: %w", p, err)
 		}
 	}
 
 	return nil
 }
 
+// vmdbBuild is the data used to generate the vmdb.tmpl build configuration.
+type vmdbBuild struct {
+	v1.Image
+
+	PackageList string
+}
+
 // Create collects image values from user input at command line, creates an
 // image configuration, and then persists it to the store. SetupImage is used
 // to set default packages and constants. This sub-command requires an image
@@ -213,6 +237,11 @@ func CreateFromConfig(name, saveas string, overlays, packages, scripts []string)
 // application is in the `$PATH`. Any errors encountered will be returned during
 // the process of getting an existing image configuration, decoding it,
 // generating the `vmdb` verbosconfiguration file, or executing the `vmdb` command.
+// Images with an RPM variant (e.g., `rpm-minbase`) are instead built by a
+// generated shell script that bootstraps the release with `dnf --installroot`,
+// which expects `dnf` to be in the `$PATH`. Once built, a build manifest
+// listing the packages installed in the image is written next to it (see
+// ReadBuildManifest).
 func Build(
 	ctx context.Context,
 	name string,
@@ -220,10 +249,27 @@ func Build(
 	cache bool,
 	dryrun bool,
 	output string,
+) error {
+	return BuildTo(ctx, os.Stdout, name, verbosity, cache, dryrun, output)
+}
+
+// BuildTo is like Build, but writes the output of the build to the given writer
+// instead of stdout. The build is stopped if the given context is canceled.
+func BuildTo( //nolint:funlen // complex logic
+	ctx context.Context,
+	w io.Writer,
+	name string,
+	verbosity int,
+	cache bool,
+	dryrun bool,
+	output string,
 ) error {
 	var (
-		img      v1.Image
-		filename string
+		img        v1.Image
+		filename   string
+		configured bool
+		builder    = "vmdb2"
+		args       []string
 	)
 
 	if strings.Contains(name, ".vmdb") {
@@ -243,68 +289,120 @@ func Build(
 		}
 
 		img.Cache = cache
+		configured = true
 
 		// The Kali package repos use `kali-rolling` as the release name.
 		if img.Release == "kali" {
 			img.Release = "kali-rolling"
 		}
 
-		filename = output + "/" + name + ".vmdb"
+		if isRPMVariant(img.Variant) {
+			build, err := newRPMBuild(img, name, output, verbosity)
+			if err != nil {
+				return fmt.Errorf("setting up RPM build: %w", err)
+			}
 
-		err = tmpl.CreateFileFromTemplate("vmdb.tmpl", img, filename)
-		if err != nil {
-			return fmt.Errorf("generate vmdb config from template: %w", err)
+			filename = output + "/" + name + ".sh"
+
+			err = tmpl.CreateFileFromTemplate("rpm.tmpl", build, filename)
+			if err != nil {
+				return fmt.Errorf("generate build script from template: %w", err)
+			}
+
+			builder = "bash"
+			args = []string{filename}
+		} else {
+			filename = output + "/" + name + ".vmdb"
+
+			build := vmdbBuild{Image: img, PackageList: output + "/" + name + packageListSuffix}
+
+			err = tmpl.CreateFileFromTemplate("vmdb.tmpl", build, filename)
+			if err != nil {
+				return fmt.Errorf("generate vmdb config from template: %w", err)
+			}
 		}
 	}
 
-	if !dryrun && !shell.CommandExists("vmdb2") {
-		return errors.New("vmdb2 app does not exist in your path")
-	}
+	if builder == "vmdb2" {
+		if !dryrun && !shell.CommandExists("vmdb2") {
+			return errors.New("vmdb2 app does not exist in your path")
+		}
 
-	args := []string{
-		filename,
-		"--output", output + "/" + name,
-		"--rootfs-tarball", output + "/" + name + ".tar",
-	}
+		args = []string{
+			filename,
+			"--output", output + "/" + name,
+			"--rootfs-tarball", output + "/" + name + ".tar",
+		}
 
-	if verbosity >= VVerbose {
-		args = append(args, "-v")
-	}
+		if verbosity >= VVerbose {
+			args = append(args, "-v")
+		}
 
-	if verbosity >= VVVerbose {
-		args = append(args, "--log", output+"/"+name+".log")
+		if verbosity >= VVVerbose {
+			args = append(args, "--log", output+"/"+name+".log")
+		}
+	} else if !dryrun && !shell.CommandExists("dnf") {
+		return errors.New("dnf app does not exist in your path")
 	}
 
 	if dryrun {
-		fmt.Printf("DRY RUN: vmdb2 %s\n", strings.Join(args, " ")) //nolint:forbidigo // CLI output
+		fmt.Fprintf(w, "DRY RUN: %s %s\n", builder, strings.Join(args, " "))
 	} else {
-		cmd := exec.CommandContext(ctx, "vmdb2", args...)
+		cmd := exec.CommandContext(ctx, builder, args...)
+
+		// Give builders a chance to clean up (e.g., unmount the image) when
+		// canceled before they're killed.
+		cmd.Cancel = func() error { return cmd.Process.Signal(syscall.SIGTERM) }
+		cmd.WaitDelay = buildCancelDelay
 
 		stdout, _ := cmd.StdoutPipe()
 		stderr, _ := cmd.StderrPipe()
 
 		err := cmd.Start()
 		if err != nil {
-			return fmt.Errorf("starting vmdb2 command: %w", err)
+			return fmt.Errorf("starting %s command: %w", builder, err)
 		}
 
-		go func() {
-			scanner := bufio.NewScanner(stdout)
-			for scanner.Scan() {
-				fmt.Println(scanner.Text()) //nolint:forbidigo // CLI output
-			}
-		}()
+		var (
+			wg sync.WaitGroup
+			mu sync.Mutex
+		)
 
-		go func() {
-			scanner := bufio.NewScanner(stderr)
-			for scanner.Scan() {
-				fmt.Println(scanner.Text()) //nolint:forbidigo // CLI output
-			}
-		}()
+		for _, r := range []io.Reader{stdout, stderr} {
+			wg.Add(1)
+
+			go func() {
+				defer wg.Done()
+
+				scanner := bufio.NewScanner(r)
+				for scanner.Scan() {
+					mu.Lock()
+					fmt.Fprintln(w, scanner.Text())
+					mu.Unlock()
+				}
+			}()
+		}
+
+		// All output must be read before waiting on the command.
+		wg.Wait()
 
 		err = cmd.Wait()
 		if err != nil {
-			return fmt.Errorf("building image with vmdb2: %w", err)
+			return fmt.Errorf("building image with %s: %w", builder, err)
+		}
+
+		// Record the built image's manifest so later modifications to it (e.g.,
+		// while it's backing overlays) can be detected.
+		sum, err := manifest.Record(output + "/" + name)
+		if err != nil {
+			return fmt.Errorf("recording manifest for built image: %w", err)
+		}
+
+		// Images built directly from vmdb files have no configuration to describe.
+		if configured {
+			if err := writeBuildManifest(img, name, output+"/"+name, sum); err != nil {
+				return fmt.Errorf("writing build manifest for built image: %w", err)
+			}
 		}
 	}
 
@@ -338,6 +436,27 @@ func List() ([]types.Image, error) {
 	return images, nil
 }
 
+// Get retrieves the named image configuration from the store. It will return
+// any errors encountered while getting or decoding the configuration.
+func Get(name string) (types.Image, error) {
+	c, err := store.NewConfig("image/" + name)
+	if err != nil {
+		return types.Image{}, fmt.Errorf("creating new image config for %s: %w", name, err) //nolint:exhaustruct // error
+	}
+
+	if err = store.Get(c); err != nil {
+		return types.Image{}, fmt.Errorf("getting image config %s from store: %w", name, err) //nolint:exhaustruct // error
+	}
+
+	spec := new(v1.Image)
+
+	if err = mapstructure.Decode(c.Spec, spec); err != nil {
+		return types.Image{}, fmt.Errorf("decoding image spec: %w", err) //nolint:exhaustruct // error
+	}
+
+	return types.Image{Metadata: c.Metadata, Spec: spec}, nil
+}
+
 // Update retrieves the named image configuration file from the store and will
 // update scripts. First, it will verify the script is present on disk. If so,
 // it will remove the existing script from the configuration file and update the
@@ -629,6 +748,31 @@ func InjectMiniExe(exe, disk, svc string) error { //nolint:funlen // complex log
 				tmp + fmt.Sprintf("/%s/symlinks/S99-%s:/etc/rc5.d/S99-%s", base, base, base),
 				exe + ":/usr/local/bin/" + base,
 			}
+		case "openrc":
+			if base != miniccc {
+				return fmt.Errorf("openrc is only supported for %s", miniccc)
+			}
+
+			err = tmpl.RestoreAsset(tmp, fmt.Sprintf("%s/%s.openrc", base, base))
+			if err != nil {
+				return fmt.Errorf("restoring %s openrc service for Linux: %w", base, err)
+			}
+
+			_ = os.Chmod(tmp+fmt.Sprintf("/%s/%s.openrc", base, base), 0o750) //nolint:gosec // init script must be executable
+
+			err = os.Symlink(
+				"/etc/init.d/"+base,
+				tmp+fmt.Sprintf("/%s/symlinks/%s", base, base),
+			)
+			if err != nil {
+				return fmt.Errorf("generating openrc service link for Linux: %w", err)
+			}
+
+			injects = []string{
+				tmp + fmt.Sprintf("/%s/%s.openrc:/etc/init.d/%s", base, base, base),
+				tmp + fmt.Sprintf("/%s/symlinks/%s:/etc/runlevels/default/%s", base, base, base),
+				exe + ":/usr/local/bin/" + base,
+			}
 		default:
 			return fmt.Errorf("unknown service %s specified", svc)
 		}
@@ -0,0 +1,364 @@
+package image
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"phenix/types"
+	v1 "phenix/types/version/v1"
+	"phenix/util/mm"
+	"phenix/util/plog"
+)
+
+// Build job statuses.
+const (
+	BuildRunning   = "running"
+	BuildSucceeded = "succeeded"
+	BuildFailed    = "failed"
+	BuildCanceled  = "canceled"
+)
+
+const (
+	// buildLogLimit is the amount of output kept for each build job.
+	buildLogLimit = 8 << 20 //nolint:mnd // 8 MiB
+	// buildClientBuffer is the number of chunks of output buffered for each
+	// client following a build job before it's considered too slow and dropped.
+	buildClientBuffer = 1024
+	// buildJobRetention is how long finished build jobs are kept.
+	buildJobRetention = 24 * time.Hour
+	// buildStagingDir is the directory, relative to the minimega files
+	// directory, images are built in before being moved into place. It's kept
+	// in the files directory so built images can be renamed into place.
+	buildStagingDir = ".builds"
+)
+
+var (
+	ErrBuildNotFound = errors.New("build job not found")
+	ErrBuildRunning  = errors.New("image is already being built")
+	ErrBuildFinished = errors.New("build job already finished")
+	ErrImageExists   = errors.New("image already exists")
+)
+
+var (
+	buildJobs   = make(map[string]*buildJob) //nolint:gochecknoglobals // global state
+	buildJobsMu sync.Mutex                   //nolint:gochecknoglobals // global state
+
+	// buildImage builds images for build jobs (replaced in tests).
+	buildImage = BuildTo //nolint:gochecknoglobals // global state
+	// buildFilesDir returns the directory built images are saved to (replaced
+	// in tests).
+	buildFilesDir = mm.GetMMFilesDirectory //nolint:gochecknoglobals // global state
+)
+
+// BuildJob is an image build running in the background.
+type BuildJob struct {
+	ID       string    `json:"id"`
+	Image    string    `json:"image"`
+	User     string    `json:"user"`
+	Status   string    `json:"status"`
+	Path     string    `json:"path"`
+	Started  time.Time `json:"started"`
+	Finished time.Time `json:"finished,omitzero"`
+	Error    string    `json:"error,omitempty"`
+}
+
+type buildJob struct {
+	mu      sync.Mutex
+	job     BuildJob
+	log     []byte
+	clients map[chan []byte]struct{}
+	cancel  context.CancelFunc
+}
+
+// StartBuild builds the given image configuration in the background, saving
+// it to the minimega files directory with an extension for its format. The
+// image is built in a staging directory and only moved into place, along with
+// its manifests, if the build succeeds. Only one build of an image can run at a
+// time. It returns ErrImageExists if the image already exists, unless overwrite
+// is set.
+func StartBuild(img types.Image, user string, verbosity int, cache, overwrite bool) (BuildJob, error) {
+	name := img.Metadata.Name
+
+	buildJobsMu.Lock()
+	defer buildJobsMu.Unlock()
+
+	pruneBuildJobs()
+
+	for _, b := range buildJobs {
+		if j := b.snapshot(); j.Image == name && j.Status == BuildRunning {
+			return BuildJob{}, fmt.Errorf("%w: %s (job %s)", ErrBuildRunning, name, j.ID) //nolint:exhaustruct // error
+		}
+	}
+
+	id := make([]byte, 8) //nolint:mnd // 64-bit ID
+
+	if _, err := rand.Read(id); err != nil {
+		return BuildJob{}, fmt.Errorf("generating build job ID: %w", err) //nolint:exhaustruct // error
+	}
+
+	var (
+		ctx, cancel = context.WithCancel(context.Background())
+		files       = buildFilesDir()
+		staging     = filepath.Join(files, buildStagingDir, name)
+	)
+
+	var format v1.Format
+	if img.Spec != nil {
+		format = img.Spec.Format
+	}
+
+	path := filepath.Join(files, name+builtImageExtension(format))
+
+	if !overwrite {
+		if _, err := os.Stat(path); err == nil {
+			cancel()
+
+			return BuildJob{}, fmt.Errorf("%w: %s", ErrImageExists, path) //nolint:exhaustruct // error
+		}
+	}
+
+	if err := os.MkdirAll(staging, 0o750); err != nil {
+		cancel()
+
+		return BuildJob{}, fmt.Errorf("creating build staging directory: %w", err) //nolint:exhaustruct // error
+	}
+
+	b := &buildJob{ //nolint:exhaustruct // partial initialization
+		job: BuildJob{ //nolint:exhaustruct // partial initialization
+			ID:      hex.EncodeToString(id),
+			Image:   name,
+			User:    user,
+			Status:  BuildRunning,
+			Path:    path,
+			Started: time.Now().UTC(),
+		},
+		clients: make(map[chan []byte]struct{}),
+		cancel:  cancel,
+	}
+
+	buildJobs[b.job.ID] = b
+
+	go func() {
+		defer cancel()
+
+		plog.Info(plog.TypeSystem, "image build started", "image", name, "job", b.job.ID, "user", user)
+
+		err := buildImage(ctx, b, name, verbosity, cache, false, staging)
+		if err == nil && ctx.Err() == nil {
+			err = moveBuiltImage(filepath.Join(staging, name), b.job.Path, overwrite)
+		}
+
+		cleanupBuildStaging(staging, name)
+		b.finish(ctx, err)
+	}()
+
+	return b.snapshot(), nil
+}
+
+// builtImageExtension returns the extension built images with the given format
+// are saved with.
+func builtImageExtension(format v1.Format) string {
+	if format == "" || format == v1.FormatQcow2 {
+		return ".qc2"
+	}
+
+	return "." + string(format)
+}
+
+// cleanupBuildStaging removes everything a build left in the given staging
+// directory (e.g., its build script, log, and any partially built image),
+// except the image's cached root filesystem tarball.
+func cleanupBuildStaging(dir, name string) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+
+	for _, e := range entries {
+		if e.Name() == name+".tar" {
+			continue
+		}
+
+		if err := os.RemoveAll(filepath.Join(dir, e.Name())); err != nil {
+			plog.Warn(plog.TypeSystem, "removing image build file", "path", filepath.Join(dir, e.Name()), "err", err)
+		}
+	}
+
+	// Only succeeds if there's no cached tarball.
+	_ = os.Remove(dir)
+}
+
+// GetBuild returns the build job with the given ID.
+func GetBuild(id string) (BuildJob, error) {
+	buildJobsMu.Lock()
+	defer buildJobsMu.Unlock()
+
+	b, ok := buildJobs[id]
+	if !ok {
+		return BuildJob{}, fmt.Errorf("%w: %s", ErrBuildNotFound, id) //nolint:exhaustruct // error
+	}
+
+	return b.snapshot(), nil
+}
+
+// ListBuilds returns all running build jobs and those that recently finished,
+// most recently started first.
+func ListBuilds() []BuildJob {
+	buildJobsMu.Lock()
+	defer buildJobsMu.Unlock()
+
+	pruneBuildJobs()
+
+	jobs := make([]BuildJob, 0, len(buildJobs))
+
+	for _, b := range buildJobs {
+		jobs = append(jobs, b.snapshot())
+	}
+
+	sort.Slice(jobs, func(i, j int) bool { return jobs[i].Started.After(jobs[j].Started) })
+
+	return jobs
+}
+
+// CancelBuild cancels the running build job with the given ID.
+func CancelBuild(id string) error {
+	buildJobsMu.Lock()
+	b, ok := buildJobs[id]
+	buildJobsMu.Unlock()
+
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrBuildNotFound, id)
+	}
+
+	if b.snapshot().Status != BuildRunning {
+		return fmt.Errorf("%w: %s", ErrBuildFinished, id)
+	}
+
+	b.cancel()
+
+	return nil
+}
+
+// AttachBuild returns the output of the build job with the given ID so far and
+// a channel that receives its output as it's written. The channel is closed
+// when the build finishes or the client falls too far behind. The returned
+// function must be called to detach once the client is done.
+func AttachBuild(id string) ([]byte, <-chan []byte, func(), error) {
+	buildJobsMu.Lock()
+	b, ok := buildJobs[id]
+	buildJobsMu.Unlock()
+
+	if !ok {
+		return nil, nil, nil, fmt.Errorf("%w: %s", ErrBuildNotFound, id)
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	ch := make(chan []byte, buildClientBuffer)
+	log := append([]byte(nil), b.log...)
+
+	if b.job.Status != BuildRunning {
+		close(ch)
+
+		return log, ch, func() {}, nil
+	}
+
+	b.clients[ch] = struct{}{}
+
+	detach := func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+
+		if _, ok := b.clients[ch]; ok {
+			delete(b.clients, ch)
+			close(ch)
+		}
+	}
+
+	return log, ch, detach, nil
+}
+
+// Write appends build output to the job's log and sends it to attached
+// clients.
+func (b *buildJob) Write(p []byte) (int, error) {
+	data := append([]byte(nil), p...)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.log = append(b.log, data...)
+
+	if over := len(b.log) - buildLogLimit; over > 0 {
+		b.log = append(b.log[:0], b.log[over:]...)
+	}
+
+	for ch := range b.clients {
+		select {
+		case ch <- data:
+		default:
+			// Drop clients that can't keep up rather than blocking the build.
+			delete(b.clients, ch)
+			close(ch)
+		}
+	}
+
+	return len(p), nil
+}
+
+func (b *buildJob) finish(ctx context.Context, err error) {
+	status := BuildSucceeded
+
+	switch {
+	case ctx.Err() != nil:
+		status = BuildCanceled
+	case err != nil:
+		status = BuildFailed
+	}
+
+	if err != nil {
+		fmt.Fprintf(b, "\nbuild %s: %v\n", status, err)
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.job.Status = status
+	b.job.Finished = time.Now().UTC()
+
+	if err != nil {
+		b.job.Error = err.Error()
+	}
+
+	for ch := range b.clients {
+		delete(b.clients, ch)
+		close(ch)
+	}
+
+	plog.Info(plog.TypeSystem, "image build finished", "image", b.job.Image, "job", b.job.ID, "status", status)
+}
+
+func (b *buildJob) snapshot() BuildJob {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.job
+}
+
+// pruneBuildJobs removes finished build jobs older than the retention period.
+// The caller must hold buildJobsMu.
+func pruneBuildJobs() {
+	for id, b := range buildJobs {
+		if j := b.snapshot(); j.Status != BuildRunning && time.Since(j.Finished) > buildJobRetention {
+			delete(buildJobs, id)
+		}
+	}
+}
@@ -0,0 +1,270 @@
+//nolint:testpackage // testing internals
+package image
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"phenix/store"
+	"phenix/types"
+	v1 "phenix/types/version/v1"
+)
+
+// fakeBuild writes a line of output, then blocks until the build is canceled or
+// released.
+func fakeBuild(t *testing.T, release <-chan error) {
+	t.Helper()
+
+	var (
+		originalBuild = buildImage
+		originalDir   = buildFilesDir
+		files         = t.TempDir()
+	)
+
+	t.Cleanup(func() { buildImage, buildFilesDir = originalBuild, originalDir })
+
+	buildFilesDir = func() string { return files }
+
+	buildImage = func(ctx context.Context, w io.Writer, name string, _ int, _, _ bool, output string) error {
+		fmt.Fprintf(w, "building %s\n", name)
+
+		// Leave a partial image and scratch files behind like a real build.
+		for _, f := range []string{name, name + ".sh", name + ".tar"} {
+			_ = os.WriteFile(filepath.Join(output, f), []byte(f), 0o600)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case err := <-release:
+			return err
+		}
+	}
+}
+
+func testImage(name string, format v1.Format) types.Image {
+	return types.Image{
+		Metadata: store.ConfigMetadata{Name: name},      //nolint:exhaustruct // partial initialization
+		Spec:     &v1.Image{Name: name, Format: format}, //nolint:exhaustruct // partial initialization
+	}
+}
+
+func waitForBuild(t *testing.T, id string) BuildJob {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+
+	for time.Now().Before(deadline) {
+		job, err := GetBuild(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if job.Status != BuildRunning {
+			return job
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	t.Fatalf("build job %s still running", id)
+
+	return BuildJob{} //nolint:exhaustruct // unreachable
+}
+
+func TestBuildJob(t *testing.T) {
+	release := make(chan error)
+	fakeBuild(t, release)
+
+	job, err := StartBuild(testImage("foo", ""), "alice", 0, false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := StartBuild(testImage("foo", ""), "bob", 0, false, false); !errors.Is(err, ErrBuildRunning) {
+		t.Fatalf("expected build already running, got %v", err)
+	}
+
+	_, output, detach, err := AttachBuild(job.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer detach()
+
+	release <- errors.New("vmdb2 failed")
+
+	var log strings.Builder
+
+	// The channel is closed once the build finishes.
+	for data := range output {
+		log.Write(data)
+	}
+
+	job = waitForBuild(t, job.ID)
+
+	if job.Status != BuildFailed || job.Error != "vmdb2 failed" {
+		t.Fatalf("expected failed build, got %+v", job)
+	}
+
+	scrollback, _, _, err := AttachBuild(job.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, want := range []string{"building foo", "build failed: vmdb2 failed"} {
+		if !strings.Contains(string(scrollback), want) {
+			t.Errorf("expected build log to contain %q, got %q", want, scrollback)
+		}
+	}
+
+	if err := CancelBuild(job.ID); !errors.Is(err, ErrBuildFinished) {
+		t.Fatalf("expected build already finished, got %v", err)
+	}
+}
+
+func TestCancelBuild(t *testing.T) {
+	fakeBuild(t, nil)
+
+	job, err := StartBuild(testImage("bar", ""), "alice", 0, false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := CancelBuild(job.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	if job = waitForBuild(t, job.ID); job.Status != BuildCanceled {
+		t.Fatalf("expected canceled build, got %s", job.Status)
+	}
+
+	assertStaged(t, job)
+
+	if _, err := os.Stat(job.Path); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("expected no image for canceled build, got %v", err)
+	}
+
+	if _, err := GetBuild("missing"); !errors.Is(err, ErrBuildNotFound) {
+		t.Fatalf("expected build not found, got %v", err)
+	}
+}
+
+func TestBuildJobStaging(t *testing.T) {
+	release := make(chan error)
+	fakeBuild(t, release)
+
+	job, err := StartBuild(testImage("baz", v1.FormatRaw), "alice", 0, false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if filepath.Base(job.Path) != "baz.raw" {
+		t.Fatalf("expected image saved with format extension, got %s", job.Path)
+	}
+
+	release <- nil
+
+	if job = waitForBuild(t, job.ID); job.Status != BuildSucceeded {
+		t.Fatalf("expected successful build, got %+v", job)
+	}
+
+	if body, err := os.ReadFile(job.Path); err != nil || string(body) != "baz" {
+		t.Fatalf("expected built image moved into place, got %q (%v)", body, err)
+	}
+
+	assertStaged(t, job)
+}
+
+func TestBuildJobExistingImage(t *testing.T) {
+	release := make(chan error)
+	fakeBuild(t, release)
+
+	path := filepath.Join(buildFilesDir(), "qux.qc2")
+
+	if err := os.WriteFile(path, []byte("original"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := StartBuild(testImage("qux", ""), "alice", 0, false, false); !errors.Is(err, ErrImageExists) {
+		t.Fatalf("expected image already exists, got %v", err)
+	}
+
+	job, err := StartBuild(testImage("qux", ""), "alice", 0, false, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	release <- nil
+
+	if job = waitForBuild(t, job.ID); job.Status != BuildSucceeded {
+		t.Fatalf("expected successful build, got %+v", job)
+	}
+
+	if body, err := os.ReadFile(path); err != nil || string(body) != "qux" {
+		t.Fatalf("expected existing image replaced, got %q (%v)", body, err)
+	}
+
+	// An image created while the build runs isn't replaced either.
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+
+	job, err = StartBuild(testImage("qux", ""), "alice", 0, false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(path, []byte("original"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	release <- nil
+
+	if job = waitForBuild(t, job.ID); job.Status != BuildFailed || !strings.Contains(job.Error, ErrImageExists.Error()) {
+		t.Fatalf("expected failed build, got %+v", job)
+	}
+
+	if body, err := os.ReadFile(path); err != nil || string(body) != "original" {
+		t.Fatalf("expected existing image kept, got %q (%v)", body, err)
+	}
+}
+
+// assertStaged checks only the cached rootfs tarball is left in the build's
+// staging directory, and nothing but the built image (if any) and the staging
+// directory are in the files directory.
+func assertStaged(t *testing.T, job BuildJob) {
+	t.Helper()
+
+	var (
+		files   = filepath.Dir(job.Path)
+		staging = filepath.Join(files, buildStagingDir, job.Image)
+	)
+
+	entries, err := os.ReadDir(staging)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(entries) != 1 || entries[0].Name() != job.Image+".tar" {
+		t.Fatalf("expected only cached tarball in staging directory, got %v", entries)
+	}
+
+	entries, err = os.ReadDir(files)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, e := range entries {
+		if e.Name() != buildStagingDir && e.Name() != filepath.Base(job.Path) {
+			t.Errorf("unexpected file %s in files directory", e.Name())
+		}
+	}
+}
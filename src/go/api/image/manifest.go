@@ -0,0 +1,332 @@
+package image
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"phenix/api/disk/manifest"
+	v1 "phenix/types/version/v1"
+	"phenix/util/mm"
+	"phenix/version"
+)
+
+const (
+	// BuildManifestSuffix is appended to a built image's path to get the path of
+	// its build manifest.
+	BuildManifestSuffix = ".build.json"
+
+	// packageListSuffix is appended to a built image's path to get the path of
+	// the list of packages installed in it, written by the build (see the
+	// vmdb.tmpl and rpm.tmpl templates) as tab-separated name, version, and
+	// architecture lines.
+	packageListSuffix = ".packages"
+)
+
+var ErrNoBuildManifest = errors.New("no build manifest for image")
+
+// BuildManifest describes what went into a built image.
+type BuildManifest struct {
+	Image         string             `json:"image"`
+	Path          string             `json:"path"`
+	SHA256        string             `json:"sha256"`
+	Variant       string             `json:"variant"`
+	Release       string             `json:"release"`
+	Distro        string             `json:"distro"`
+	Mirror        string             `json:"mirror"`
+	Components    []string           `json:"components,omitempty"`
+	Kernel        []string           `json:"kernel,omitempty"`
+	Format        v1.Format          `json:"format"`
+	Built         time.Time          `json:"built"`
+	PhenixVersion string             `json:"phenixVersion"`
+	Packages      []InstalledPackage `json:"packages"`
+	Overlays      []BuildInput       `json:"overlays,omitempty"`
+	Scripts       []BuildInput       `json:"scripts,omitempty"`
+}
+
+// InstalledPackage is a package installed in a built image, as reported by the
+// image's package manager.
+type InstalledPackage struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+	Arch    string `json:"arch"`
+}
+
+// BuildInput is an overlay or script applied to an image while it was built.
+// For overlays, the hash covers the path, type, and content of every file in
+// the overlay directory.
+type BuildInput struct {
+	Name   string `json:"name"`
+	SHA256 string `json:"sha256"`
+}
+
+// BuildManifestPath returns the path of the build manifest for the image at the
+// given path.
+func BuildManifestPath(image string) string {
+	return image + BuildManifestSuffix
+}
+
+// ResolveImagePath returns the given image path if it exists. Otherwise, the
+// image is assumed to be relative to the minimega files directory.
+func ResolveImagePath(image string) string {
+	if _, err := os.Stat(image); err == nil || filepath.IsAbs(image) {
+		return image
+	}
+
+	return filepath.Join(mm.GetMMFilesDirectory(), image)
+}
+
+// ReadBuildManifest reads the build manifest for the image at the given path.
+func ReadBuildManifest(image string) (BuildManifest, error) {
+	var m BuildManifest
+
+	body, err := os.ReadFile(BuildManifestPath(image))
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return m, fmt.Errorf("%w: %s", ErrNoBuildManifest, image)
+		}
+
+		return m, fmt.Errorf("reading build manifest: %w", err)
+	}
+
+	if err := json.Unmarshal(body, &m); err != nil {
+		return m, fmt.Errorf("parsing build manifest: %w", err)
+	}
+
+	return m, nil
+}
+
+// writeBuildManifest writes the build manifest for the image built at the
+// given path from the given configuration. The list of installed packages
+// written by the build is consumed.
+func writeBuildManifest(img v1.Image, name, image string, sum manifest.Manifest) error {
+	list := image + packageListSuffix
+
+	packages, err := readPackageList(list)
+	if err != nil {
+		return fmt.Errorf("reading installed package list: %w", err)
+	}
+
+	m := BuildManifest{ //nolint:exhaustruct // partial initialization
+		Image:         name,
+		Path:          image,
+		SHA256:        sum.SHA256,
+		Variant:       img.Variant,
+		Release:       img.Release,
+		Distro:        imageDistro(img),
+		Mirror:        img.Mirror,
+		Components:    img.Components,
+		Kernel:        img.Kernel,
+		Format:        img.Format,
+		Built:         time.Now().UTC(),
+		PhenixVersion: version.Tag,
+		Packages:      packages,
+	}
+
+	for _, overlay := range img.Overlays {
+		sum, err := hashDir(overlay)
+		if err != nil {
+			return fmt.Errorf("hashing overlay %s: %w", overlay, err)
+		}
+
+		m.Overlays = append(m.Overlays, BuildInput{Name: overlay, SHA256: sum})
+	}
+
+	for _, script := range img.ScriptOrder {
+		sum := sha256.Sum256([]byte(img.Scripts[script]))
+		m.Scripts = append(m.Scripts, BuildInput{Name: script, SHA256: hex.EncodeToString(sum[:])})
+	}
+
+	if err := saveBuildManifest(m, image); err != nil {
+		return err
+	}
+
+	_ = os.Remove(list)
+
+	return nil
+}
+
+// saveBuildManifest writes the given build manifest for the image at the given
+// path.
+func saveBuildManifest(m BuildManifest, image string) error {
+	body, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshaling build manifest: %w", err)
+	}
+
+	if err := os.WriteFile(BuildManifestPath(image), body, 0o644); err != nil { //nolint:gosec // manifests are readable like images
+		return fmt.Errorf("writing build manifest: %w", err)
+	}
+
+	return nil
+}
+
+// moveBuiltImage moves the built image at src to dst, along with its manifest
+// and build manifest, if any. It returns ErrImageExists if dst already exists,
+// unless overwrite is set.
+func moveBuiltImage(src, dst string, overwrite bool) error {
+	if overwrite {
+		if err := os.Rename(src, dst); err != nil {
+			return fmt.Errorf("moving built image into place: %w", err)
+		}
+	} else {
+		// The image may have been created since the build started, so link rather
+		// than rename it into place since linking fails if the image exists.
+		if err := os.Link(src, dst); err != nil {
+			if errors.Is(err, os.ErrExist) {
+				return fmt.Errorf("%w: %s", ErrImageExists, dst)
+			}
+
+			return fmt.Errorf("moving built image into place: %w", err)
+		}
+
+		_ = os.Remove(src)
+	}
+
+	if err := manifest.Move(src, dst); err != nil {
+		return fmt.Errorf("moving manifest for built image: %w", err)
+	}
+
+	m, err := ReadBuildManifest(src)
+	if errors.Is(err, ErrNoBuildManifest) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	m.Path = dst
+
+	if err := saveBuildManifest(m, dst); err != nil {
+		return err
+	}
+
+	_ = os.Remove(BuildManifestPath(src))
+
+	return nil
+}
+
+// readPackageList parses the list of installed packages at the given path,
+// sorted by name.
+func readPackageList(path string) ([]InstalledPackage, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err //nolint:wrapcheck // wrapped by caller
+	}
+
+	defer f.Close()
+
+	return parsePackageList(f)
+}
+
+func parsePackageList(r io.Reader) ([]InstalledPackage, error) {
+	var (
+		packages []InstalledPackage
+		scanner  = bufio.NewScanner(r)
+	)
+
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		fields := strings.Split(line, "\t")
+		if len(fields) != 3 { //nolint:mnd // name, version, and architecture
+			return nil, fmt.Errorf("malformed package list line %q", line)
+		}
+
+		packages = append(packages, InstalledPackage{Name: fields[0], Version: fields[1], Arch: fields[2]})
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("reading package list: %w", err)
+	}
+
+	slices.SortFunc(packages, func(a, b InstalledPackage) int {
+		if c := strings.Compare(a.Name, b.Name); c != 0 {
+			return c
+		}
+
+		return strings.Compare(a.Arch, b.Arch)
+	})
+
+	return packages, nil
+}
+
+// hashDir hashes the path, type, and content of every file in the given
+// directory, in lexical order, so any change to the directory's contents
+// changes the hash.
+func hashDir(dir string) (string, error) {
+	h := sha256.New()
+
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, _ := filepath.Rel(dir, path)
+
+		switch {
+		case d.Type()&fs.ModeSymlink != 0:
+			target, err := os.Readlink(path)
+			if err != nil {
+				return err //nolint:wrapcheck // wrapped below
+			}
+
+			fmt.Fprintf(h, "l %s %s\n", rel, target)
+		case d.IsDir():
+			fmt.Fprintf(h, "d %s\n", rel)
+		case d.Type().IsRegular():
+			f, err := os.Open(path)
+			if err != nil {
+				return err //nolint:wrapcheck // wrapped below
+			}
+
+			defer f.Close()
+
+			fh := sha256.New()
+
+			if _, err := io.Copy(fh, f); err != nil {
+				return err //nolint:wrapcheck // wrapped below
+			}
+
+			fmt.Fprintf(h, "f %s %x\n", rel, fh.Sum(nil))
+		}
+
+		return nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("walking %s: %w", dir, err)
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// imageDistro returns the distribution of the given image configuration's
+// release.
+func imageDistro(img v1.Image) string {
+	if isRPMVariant(img.Variant) {
+		distro, _, _ := parseRPMRelease(img.Release)
+
+		return distro
+	}
+
+	switch {
+	case slices.Contains(debianReleases, img.Release):
+		return "debian"
+	case img.Release == "kali" || slices.Contains(kaliReleases, img.Release):
+		return "kali"
+	default:
+		return "ubuntu"
+	}
+}
@@ -0,0 +1,186 @@
+//nolint:testpackage // testing internals
+package image
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"phenix/api/disk/manifest"
+	"phenix/tmpl"
+	v1 "phenix/types/version/v1"
+)
+
+func TestWriteBuildManifest(t *testing.T) {
+	var (
+		dir     = t.TempDir()
+		out     = filepath.Join(dir, "jammy.qc2")
+		overlay = filepath.Join(dir, "overlay")
+	)
+
+	if err := os.MkdirAll(overlay+"/etc", 0o750); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(overlay+"/etc/motd", []byte("hello\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	list := "openssh-server\t1:8.9p1-3ubuntu0.10\tamd64\nbash\t5.1-6ubuntu1\tamd64\n"
+
+	if err := os.WriteFile(out+packageListSuffix, []byte(list), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	img := v1.Image{ //nolint:exhaustruct // partial initialization
+		Variant:     "minbase",
+		Release:     "jammy",
+		Mirror:      "http://us.archive.ubuntu.com/ubuntu",
+		Format:      v1.FormatQcow2,
+		Overlays:    []string{overlay},
+		Scripts:     map[string]string{"POSTBUILD_APT_CLEANUP": PostbuildAptCleanup},
+		ScriptOrder: []string{"POSTBUILD_APT_CLEANUP"},
+	}
+
+	sum := manifest.Manifest{SHA256: strings.Repeat("ab", 32)} //nolint:exhaustruct // partial initialization
+
+	if err := writeBuildManifest(img, "jammy", out, sum); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := os.Stat(out + packageListSuffix); !errors.Is(err, os.ErrNotExist) {
+		t.Error("expected package list to be removed")
+	}
+
+	m, err := ReadBuildManifest(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if m.Distro != "ubuntu" || m.SHA256 != sum.SHA256 {
+		t.Errorf("unexpected build manifest %+v", m)
+	}
+
+	if len(m.Packages) != 2 || m.Packages[0].Name != "bash" || m.Packages[1].Version != "1:8.9p1-3ubuntu0.10" {
+		t.Errorf("expected sorted packages, got %+v", m.Packages)
+	}
+
+	if len(m.Overlays) != 1 || len(m.Scripts) != 1 {
+		t.Fatalf("expected overlay and script hashes, got %+v %+v", m.Overlays, m.Scripts)
+	}
+
+	// Changing an overlay's contents changes its hash.
+	if err := os.WriteFile(overlay+"/etc/motd", []byte("goodbye\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if sum, _ := hashDir(overlay); sum == m.Overlays[0].SHA256 {
+		t.Error("expected overlay hash to change")
+	}
+
+	if _, err := ReadBuildManifest(filepath.Join(dir, "missing.qc2")); !errors.Is(err, ErrNoBuildManifest) {
+		t.Errorf("expected no build manifest, got %v", err)
+	}
+}
+
+func TestMoveBuiltImage(t *testing.T) {
+	var (
+		dir = t.TempDir()
+		src = filepath.Join(dir, "staging", "jammy")
+		dst = filepath.Join(dir, "jammy.qc2")
+	)
+
+	if err := os.MkdirAll(filepath.Dir(src), 0o750); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(src, []byte("image"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := manifest.Record(src); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := saveBuildManifest(BuildManifest{Image: "jammy", Path: src}, src); err != nil { //nolint:exhaustruct // partial initialization
+		t.Fatal(err)
+	}
+
+	if err := moveBuiltImage(src, dst, false); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := manifest.Verify(dst, true); err != nil {
+		t.Errorf("expected manifest moved with image, got %v", err)
+	}
+
+	m, err := ReadBuildManifest(dst)
+	if err != nil || m.Path != dst {
+		t.Errorf("expected build manifest path updated, got %+v (%v)", m, err)
+	}
+
+	for _, f := range []string{src, manifest.Path(src), BuildManifestPath(src)} {
+		if _, err := os.Stat(f); !errors.Is(err, os.ErrNotExist) {
+			t.Errorf("expected %s to be moved", f)
+		}
+	}
+}
+
+func TestParsePackageList(t *testing.T) {
+	if _, err := parsePackageList(strings.NewReader("bash 5.1 amd64\n")); err == nil {
+		t.Error("expected error for malformed package list")
+	}
+}
+
+func TestBuildManifestSPDX(t *testing.T) {
+	m := BuildManifest{ //nolint:exhaustruct // partial initialization
+		Image:    "rocky",
+		SHA256:   strings.Repeat("cd", 32),
+		Variant:  "rpm-minbase",
+		Release:  "rocky9",
+		Distro:   distroRocky,
+		Packages: []InstalledPackage{{Name: "bash", Version: "5.1.8-9.el9", Arch: "x86_64"}},
+		Scripts:  []BuildInput{{Name: "POSTBUILD_DNF_CLEANUP", SHA256: strings.Repeat("ef", 32)}},
+	}
+
+	doc := m.SPDX()
+
+	if doc.SPDXVersion != "SPDX-2.3" || len(doc.Packages) != 3 {
+		t.Fatalf("unexpected SPDX document %+v", doc)
+	}
+
+	pkg := doc.Packages[1]
+
+	if want := "pkg:rpm/rocky/bash@5.1.8-9.el9?arch=x86_64"; pkg.ExternalRefs[0].ReferenceLocator != want {
+		t.Errorf("expected purl %s, got %s", want, pkg.ExternalRefs[0].ReferenceLocator)
+	}
+
+	if !strings.HasSuffix(doc.DocumentNamespace, m.SHA256) {
+		t.Errorf("expected document namespace to include image hash, got %s", doc.DocumentNamespace)
+	}
+
+	// Two packages related to the image plus the document describing it.
+	if len(doc.Relationships) != 3 {
+		t.Errorf("expected 3 relationships, got %+v", doc.Relationships)
+	}
+}
+
+func TestVmdbPackageList(t *testing.T) {
+	build := vmdbBuild{ //nolint:exhaustruct // partial initialization
+		Image:       v1.Image{Release: "jammy", Size: "10G", Format: v1.FormatQcow2}, //nolint:exhaustruct // partial initialization
+		PackageList: "/tmp/images/jammy.qc2.packages",
+	}
+
+	var buf bytes.Buffer
+
+	if err := tmpl.GenerateFromTemplate("vmdb.tmpl", build, &buf); err != nil {
+		t.Fatal(err)
+	}
+
+	if want := `dpkg-query -W -f='${Package}\t${Version}\t${Architecture}\n' > "/tmp/images/jammy.qc2.packages"`; !strings.Contains(buf.String(), want) {
+		t.Errorf("expected vmdb config to list installed packages, got:\n%s", buf.String())
+	}
+}
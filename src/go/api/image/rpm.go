@@ -0,0 +1,192 @@
+package image
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	v1 "phenix/types/version/v1"
+)
+
+// rpmVariantPrefix is the prefix of image variants built with dnf instead of
+// vmdb2 and debootstrap (e.g., rpm-minbase).
+const rpmVariantPrefix = "rpm-"
+
+// RPM distributions that can be built.
+const (
+	distroRocky  = "rocky"
+	distroAlma   = "alma"
+	distroFedora = "fedora"
+)
+
+// rpmReleaseRe matches RPM releases given as the distribution and its major (or
+// major.minor) version, e.g. rocky9, alma-9.4, or fedora41.
+var rpmReleaseRe = regexp.MustCompile(`^(rocky|alma|almalinux|fedora)-?(\d+(?:\.\d+)?)$`) //nolint:gochecknoglobals // global constant
+
+// rpmMirrors are the default mirrors for each RPM distribution.
+var rpmMirrors = map[string]string{ //nolint:gochecknoglobals // global constant
+	distroRocky:  "https://dl.rockylinux.org/pub/rocky",
+	distroAlma:   "https://repo.almalinux.org/almalinux",
+	distroFedora: "https://dl.fedoraproject.org/pub/fedora/linux",
+}
+
+// rpmGPGKeys are the URLs of the keys each RPM distribution's packages are
+// signed with, formatted with the mirror and the release's major version.
+// Fedora doesn't publish its keys on its mirrors.
+var rpmGPGKeys = map[string]string{ //nolint:gochecknoglobals // global constant
+	distroRocky:  "%s/RPM-GPG-KEY-Rocky-%s",
+	distroAlma:   "%s/RPM-GPG-KEY-AlmaLinux-%s",
+	distroFedora: "https://src.fedoraproject.org/rpms/fedora-repos/raw/rawhide/f/RPM-GPG-KEY-fedora-%[2]s-primary",
+}
+
+// rpmBuild is the data used to generate the rpm.tmpl build script.
+type rpmBuild struct {
+	v1.Image
+
+	Output     string
+	Tarball    string
+	Log        string
+	ReleaseVer string
+	Repos      []string
+	GPGKey     string
+	Verbose    bool
+}
+
+// isRPMVariant returns true if images with the given variant are built with
+// dnf instead of vmdb2.
+func isRPMVariant(variant string) bool {
+	return strings.HasPrefix(variant, rpmVariantPrefix)
+}
+
+// parseRPMRelease returns the distribution and version of the given RPM
+// release (e.g., "rocky" and "9" for rocky9).
+func parseRPMRelease(release string) (string, string, error) {
+	match := rpmReleaseRe.FindStringSubmatch(strings.ToLower(release))
+	if match == nil {
+		return "", "", fmt.Errorf("release %s is not a supported RPM release (e.g., rocky9, alma9, or fedora41)", release)
+	}
+
+	distro := match[1]
+	if distro == "almalinux" {
+		distro = distroAlma
+	}
+
+	return distro, match[2], nil
+}
+
+// rpmRepos returns the base URLs of the repositories to install packages from
+// for the given distribution and version. For Rocky and Alma, the components
+// are the repositories to use (e.g., BaseOS and AppStream). For Fedora, they're
+// the release and updates trees.
+func rpmRepos(distro, version, mirror string, components []string) []string {
+	mirror = strings.TrimSuffix(mirror, "/")
+
+	repos := make([]string, 0, len(components))
+
+	for _, c := range components {
+		switch distro {
+		case distroFedora:
+			switch c {
+			case "updates":
+				repos = append(repos, fmt.Sprintf("%s/updates/%s/Everything/x86_64/", mirror, version))
+			default:
+				repos = append(repos, fmt.Sprintf("%s/%s/%s/Everything/x86_64/os/", mirror, c, version))
+			}
+		default:
+			repos = append(repos, fmt.Sprintf("%s/%s/%s/x86_64/os/", mirror, version, c))
+		}
+	}
+
+	return repos
+}
+
+// rpmGPGKey returns the URL of the key packages for the given distribution and
+// version are signed with.
+func rpmGPGKey(distro, version, mirror string) string {
+	major, _, _ := strings.Cut(version, ".")
+
+	return fmt.Sprintf(rpmGPGKeys[distro], strings.TrimSuffix(mirror, "/"), major)
+}
+
+// setupRPMImage sets the mirror, components, packages, and scripts for images
+// built with dnf. An error is returned if the variant or release isn't
+// supported.
+func setupRPMImage(img *v1.Image) error {
+	distro, _, err := parseRPMRelease(img.Release)
+	if err != nil {
+		return err
+	}
+
+	// The mirror defaults to Ubuntu's, which can't be right for RPM releases.
+	if img.Mirror == "" || img.Mirror == "http://us.archive.ubuntu.com/ubuntu" {
+		img.Mirror = rpmMirrors[distro]
+	}
+
+	if len(img.Components) == 0 {
+		if distro == distroFedora {
+			img.Components = append(img.Components, FedoraComponents...)
+		} else {
+			img.Components = append(img.Components, ELComponents...)
+		}
+	}
+
+	img.Scripts = make(map[string]string)
+
+	if !img.SkipDefaultPackages {
+		img.Packages = append(img.Packages, RPMDefaultPackages...)
+	}
+
+	switch img.Variant {
+	case "rpm-minbase":
+		img.Packages = append(img.Packages, RPMPackages...)
+		img.Packages = append(img.Packages, RPMReleasePackages[distro])
+	default:
+		return fmt.Errorf("variant %s is not implemented", img.Variant)
+	}
+
+	_ = addScriptToImage(img, "POSTBUILD_DNF_CLEANUP", PostbuildDnfCleanup)
+	_ = addScriptToImage(img, "POSTBUILD_RPM_NO_ROOT_PASSWD", PostbuildRPMNoRootPasswd)
+	_ = addScriptToImage(img, "POSTBUILD_SELINUX_PERMISSIVE", PostbuildSELinuxPermissive)
+	_ = addScriptToImage(img, "POSTBUILD_PHENIX_HOSTNAME", PostbuildPhenixHostname)
+	_ = addScriptToImage(img, "POSTBUILD_PHENIX_BASE", PostbuildPhenixBase)
+
+	return nil
+}
+
+// newRPMBuild returns the data used to generate the build script for the
+// given image, which is written to the given output directory.
+func newRPMBuild(img v1.Image, name, output string, verbosity int) (rpmBuild, error) {
+	distro, version, err := parseRPMRelease(img.Release)
+	if err != nil {
+		return rpmBuild{}, err //nolint:exhaustruct // error
+	}
+
+	build := rpmBuild{
+		Image:      img,
+		Output:     output + "/" + name,
+		Tarball:    output + "/" + name + ".tar",
+		Log:        "",
+		ReleaseVer: version,
+		Repos:      rpmRepos(distro, version, img.Mirror, img.Components),
+		GPGKey:     rpmGPGKey(distro, version, img.Mirror),
+		Verbose:    verbosity >= VVerbose,
+	}
+
+	if verbosity >= VVVerbose {
+		build.Log = output + "/" + name + ".log"
+	}
+
+	return build, nil
+}
+
+// Script returns the image's scripts in order, to be run in a chroot of the
+// image. Unlike PostBuild, script lines aren't indented for YAML.
+func (b rpmBuild) Script() string {
+	scripts := make([]string, 0, len(b.ScriptOrder))
+
+	for _, o := range b.ScriptOrder {
+		scripts = append(scripts, strings.Trim(b.Scripts[o], "\n"))
+	}
+
+	return strings.Join(scripts, "\n")
+}
@@ -0,0 +1,143 @@
+//nolint:testpackage // testing internals
+package image
+
+import (
+	"bytes"
+	"slices"
+	"strings"
+	"testing"
+
+	"phenix/tmpl"
+	v1 "phenix/types/version/v1"
+)
+
+func TestParseRPMRelease(t *testing.T) {
+	cases := map[string][2]string{
+		"rocky9":     {"rocky", "9"},
+		"alma-9.4":   {"alma", "9.4"},
+		"almalinux8": {"alma", "8"},
+		"Fedora41":   {"fedora", "41"},
+	}
+
+	for release, want := range cases {
+		distro, version, err := parseRPMRelease(release)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", release, err)
+
+			continue
+		}
+
+		if distro != want[0] || version != want[1] {
+			t.Errorf("%s: expected %s %s, got %s %s", release, want[0], want[1], distro, version)
+		}
+	}
+
+	for _, release := range []string{"jammy", "rocky", "centos7"} {
+		if _, _, err := parseRPMRelease(release); err == nil {
+			t.Errorf("%s: expected error", release)
+		}
+	}
+}
+
+func TestSetupRPMImage(t *testing.T) {
+	img := v1.Image{ //nolint:exhaustruct // partial initialization
+		Variant: "rpm-minbase",
+		Release: "fedora41",
+		Mirror:  "http://us.archive.ubuntu.com/ubuntu",
+	}
+
+	if err := SetupImage(&img); err != nil {
+		t.Fatal(err)
+	}
+
+	if img.Mirror != rpmMirrors[distroFedora] {
+		t.Errorf("expected Fedora mirror, got %s", img.Mirror)
+	}
+
+	if !slices.Contains(img.Packages, "fedora-release") || !slices.Contains(img.Packages, "grub2-pc") {
+		t.Errorf("expected release and boot packages, got %v", img.Packages)
+	}
+
+	if slices.Contains(img.ScriptOrder, "POSTBUILD_APT_CLEANUP") {
+		t.Error("expected no apt scripts for RPM image")
+	}
+
+	want := []string{
+		"https://dl.fedoraproject.org/pub/fedora/linux/releases/41/Everything/x86_64/os/",
+		"https://dl.fedoraproject.org/pub/fedora/linux/updates/41/Everything/x86_64/",
+	}
+
+	if repos := rpmRepos(distroFedora, "41", img.Mirror, img.Components); !slices.Equal(repos, want) {
+		t.Errorf("expected repos %v, got %v", want, repos)
+	}
+
+	key := "https://src.fedoraproject.org/rpms/fedora-repos/raw/rawhide/f/RPM-GPG-KEY-fedora-41-primary"
+
+	if got := rpmGPGKey(distroFedora, "41", img.Mirror); got != key {
+		t.Errorf("expected GPG key %s, got %s", key, got)
+	}
+
+	img.Variant = "rpm-unknown"
+
+	if err := SetupImage(&img); err == nil {
+		t.Error("expected error for unknown RPM variant")
+	}
+}
+
+func TestRPMBuildScript(t *testing.T) {
+	img := v1.Image{ //nolint:exhaustruct // partial initialization
+		Name:     "rocky",
+		Variant:  "rpm-minbase",
+		Release:  "rocky9",
+		Format:   v1.FormatQcow2,
+		Compress: true,
+		Size:     "10G",
+		Mirror:   rpmMirrors[distroRocky],
+		Packages: []string{"kernel", "openssh-server"},
+		Overlays: []string{"/phenix/overlays/foo"},
+		Kernel:   []string{"net.ifnames=0"},
+	}
+
+	if err := setupRPMImage(&img); err != nil {
+		t.Fatal(err)
+	}
+
+	build, err := newRPMBuild(img, "rocky", "/tmp/images", VVerbose)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+
+	if err := tmpl.GenerateFromTemplate("rpm.tmpl", build, &buf); err != nil {
+		t.Fatal(err)
+	}
+
+	script := buf.String()
+
+	for _, want := range []string{
+		`OUTPUT="/tmp/images/rocky"`,
+		"--releasever=9",
+		"--repofrompath=phenix0,https://dl.rockylinux.org/pub/rocky/9/BaseOS/x86_64/os/ --repo=phenix0",
+		"--repofrompath=phenix1,https://dl.rockylinux.org/pub/rocky/9/AppStream/x86_64/os/ --repo=phenix1",
+		"--setopt=phenix1.gpgcheck=1 --setopt=phenix1.gpgkey=https://dl.rockylinux.org/pub/rocky/RPM-GPG-KEY-Rocky-9",
+		`cp -a "/phenix/overlays/foo/." "${ROOT}/"`,
+		"passwd -d root",
+		"net.ifnames=0",
+		`rpm -qa --qf`,
+		"qemu-img convert -c -O qcow2",
+		"set -x",
+	} {
+		if !strings.Contains(script, want) {
+			t.Errorf("expected build script to contain %q", want)
+		}
+	}
+
+	if strings.Contains(script, "--nogpgcheck") {
+		t.Error("expected package signatures to be checked")
+	}
+
+	if strings.Contains(script, "tar -C \"${ROOT}\" -cpf") {
+		t.Error("expected rootfs not to be cached")
+	}
+}
@@ -0,0 +1,155 @@
+package image
+
+import (
+	"fmt"
+	"net/url"
+	"time"
+)
+
+const (
+	spdxVersion     = "SPDX-2.3"
+	spdxNoAssertion = "NOASSERTION"
+	spdxImageID     = "SPDXRef-Image"
+)
+
+// SPDXDocument is an SPDX 2.3 document, serialized as SPDX JSON.
+type SPDXDocument struct {
+	SPDXVersion       string             `json:"spdxVersion"`
+	DataLicense       string             `json:"dataLicense"`
+	SPDXID            string             `json:"SPDXID"`
+	Name              string             `json:"name"`
+	DocumentNamespace string             `json:"documentNamespace"`
+	CreationInfo      SPDXCreationInfo   `json:"creationInfo"`
+	DocumentDescribes []string           `json:"documentDescribes"`
+	Packages          []SPDXPackage      `json:"packages"`
+	Relationships     []SPDXRelationship `json:"relationships"`
+}
+
+type SPDXCreationInfo struct {
+	Created  string   `json:"created"`
+	Creators []string `json:"creators"`
+}
+
+type SPDXPackage struct {
+	Name                  string            `json:"name"`
+	SPDXID                string            `json:"SPDXID"`
+	VersionInfo           string            `json:"versionInfo,omitempty"`
+	DownloadLocation      string            `json:"downloadLocation"`
+	FilesAnalyzed         bool              `json:"filesAnalyzed"`
+	PrimaryPackagePurpose string            `json:"primaryPackagePurpose,omitempty"`
+	Checksums             []SPDXChecksum    `json:"checksums,omitempty"`
+	ExternalRefs          []SPDXExternalRef `json:"externalRefs,omitempty"`
+	Comment               string            `json:"comment,omitempty"`
+}
+
+type SPDXChecksum struct {
+	Algorithm     string `json:"algorithm"`
+	ChecksumValue string `json:"checksumValue"`
+}
+
+type SPDXExternalRef struct {
+	ReferenceCategory string `json:"referenceCategory"`
+	ReferenceType     string `json:"referenceType"`
+	ReferenceLocator  string `json:"referenceLocator"`
+}
+
+type SPDXRelationship struct {
+	SPDXElementID      string `json:"spdxElementId"`
+	RelationshipType   string `json:"relationshipType"`
+	RelatedSPDXElement string `json:"relatedSpdxElement"`
+}
+
+// SPDX converts the build manifest to an SPDX document describing the image,
+// the packages installed in it, and the overlays and scripts it was built
+// from.
+func (m BuildManifest) SPDX() SPDXDocument {
+	id := m.SHA256
+	if id == "" {
+		id = m.Built.Format("20060102T150405Z")
+	}
+
+	doc := SPDXDocument{
+		SPDXVersion:       spdxVersion,
+		DataLicense:       "CC0-1.0",
+		SPDXID:            "SPDXRef-DOCUMENT",
+		Name:              m.Image,
+		DocumentNamespace: fmt.Sprintf("https://phenix.sandia.gov/spdx/images/%s-%s", url.PathEscape(m.Image), id),
+		CreationInfo: SPDXCreationInfo{
+			Created:  m.Built.UTC().Format(time.RFC3339),
+			Creators: []string{"Tool: phenix-" + m.PhenixVersion},
+		},
+		DocumentDescribes: []string{spdxImageID},
+	}
+
+	image := SPDXPackage{ //nolint:exhaustruct // partial initialization
+		Name:                  m.Image,
+		SPDXID:                spdxImageID,
+		VersionInfo:           m.Release,
+		DownloadLocation:      spdxNoAssertion,
+		PrimaryPackagePurpose: "OPERATING-SYSTEM",
+		Comment:               fmt.Sprintf("%s %s disk image (%s variant) built from %s", m.Distro, m.Release, m.Variant, m.Mirror),
+	}
+
+	if m.SHA256 != "" {
+		image.Checksums = []SPDXChecksum{{Algorithm: "SHA256", ChecksumValue: m.SHA256}}
+	}
+
+	doc.Packages = append(doc.Packages, image)
+
+	purlType := "deb"
+	if isRPMVariant(m.Variant) {
+		purlType = "rpm"
+	}
+
+	for i, pkg := range m.Packages {
+		id := fmt.Sprintf("SPDXRef-Package-%d", i)
+
+		purl := fmt.Sprintf(
+			"pkg:%s/%s/%s@%s?arch=%s",
+			purlType, m.Distro, url.PathEscape(pkg.Name), url.QueryEscape(pkg.Version), url.QueryEscape(pkg.Arch),
+		)
+
+		doc.Packages = append(doc.Packages, SPDXPackage{ //nolint:exhaustruct // partial initialization
+			Name:                  pkg.Name,
+			SPDXID:                id,
+			VersionInfo:           pkg.Version,
+			DownloadLocation:      spdxNoAssertion,
+			PrimaryPackagePurpose: "LIBRARY",
+			ExternalRefs: []SPDXExternalRef{
+				{ReferenceCategory: "PACKAGE-MANAGER", ReferenceType: "purl", ReferenceLocator: purl},
+			},
+		})
+
+		doc.Relationships = append(doc.Relationships, SPDXRelationship{
+			SPDXElementID: spdxImageID, RelationshipType: "CONTAINS", RelatedSPDXElement: id,
+		})
+	}
+
+	inputs := func(kind string, inputs []BuildInput) {
+		for i, in := range inputs {
+			id := fmt.Sprintf("SPDXRef-%s-%d", kind, i)
+
+			doc.Packages = append(doc.Packages, SPDXPackage{ //nolint:exhaustruct // partial initialization
+				Name:                  in.Name,
+				SPDXID:                id,
+				DownloadLocation:      spdxNoAssertion,
+				PrimaryPackagePurpose: "SOURCE",
+				Checksums:             []SPDXChecksum{{Algorithm: "SHA256", ChecksumValue: in.SHA256}},
+				Comment:               "phenix image build " + kind,
+			})
+
+			doc.Relationships = append(doc.Relationships, SPDXRelationship{
+				SPDXElementID: spdxImageID, RelationshipType: "GENERATED_FROM", RelatedSPDXElement: id,
+			})
+		}
+	}
+
+	inputs("overlay", m.Overlays)
+	inputs("script", m.Scripts)
+
+	doc.Relationships = append(doc.Relationships, SPDXRelationship{
+		SPDXElementID: doc.SPDXID, RelationshipType: "DESCRIBES", RelatedSPDXElement: spdxImageID,
+	})
+
+	return doc
+}
@@ -0,0 +1,238 @@
+package upload
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultChunkSize is the default size of chunks sent by Client.
+	DefaultChunkSize = 64 << 20
+
+	// DefaultRetries is the default number of times Client retries a chunk.
+	DefaultRetries = 10
+
+	clientRetryDelay = 2 * time.Second
+)
+
+// Client uploads files to a phenix web server using the resumable upload API.
+type Client struct {
+	// URL is the base URL of the phenix web server (e.g., http://localhost:3000).
+	URL string
+	// Token is the API token used to authenticate, if auth is enabled.
+	Token string
+
+	ChunkSize int64
+	Retries   int
+
+	// Overwrite, if set, replaces an existing file with the same name.
+	Overwrite bool
+
+	HTTPClient *http.Client
+
+	// Progress, if set, is called after each chunk is sent.
+	Progress func(Upload)
+}
+
+// NewClient returns a client for the phenix web server at the given URL.
+func NewClient(url, token string) *Client {
+	return &Client{
+		URL:        strings.TrimSuffix(url, "/"),
+		Token:      token,
+		ChunkSize:  DefaultChunkSize,
+		Retries:    DefaultRetries,
+		Overwrite:  false,
+		HTTPClient: http.DefaultClient,
+		Progress:   nil,
+	}
+}
+
+// Upload uploads the file at the given path. If id is provided, the existing
+// upload with that ID is resumed rather than a new one created. Each chunk is
+// retried, resuming from the offset the server last received, until it has
+// failed the client's number of retries in a row.
+func (c *Client) Upload(ctx context.Context, path string, kind Kind, expName, id string) (Upload, error) {
+	var upload Upload
+
+	f, err := os.Open(path)
+	if err != nil {
+		return upload, fmt.Errorf("opening %s: %w", path, err)
+	}
+
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return upload, fmt.Errorf("getting info for %s: %w", path, err)
+	}
+
+	if id == "" {
+		upload, err = c.create(ctx, kind, expName, filepath.Base(path), info.Size())
+	} else {
+		upload, err = c.Get(ctx, id)
+	}
+
+	if err != nil {
+		return upload, err
+	}
+
+	if upload.Size != info.Size() {
+		return upload, fmt.Errorf("upload %s is for a %d byte file, but %s is %d bytes", upload.ID, upload.Size, path, info.Size())
+	}
+
+	var (
+		buf      = make([]byte, c.ChunkSize)
+		failures int
+	)
+
+	for !upload.Complete() {
+		n, err := f.ReadAt(buf[:min(c.ChunkSize, upload.Size-upload.Offset)], upload.Offset)
+		if err != nil && !errors.Is(err, io.EOF) {
+			return upload, fmt.Errorf("reading %s: %w", path, err)
+		}
+
+		if err := c.patch(ctx, upload.ID, upload.Offset, buf[:n]); err != nil {
+			failures++
+
+			if failures > c.Retries || ctx.Err() != nil {
+				return upload, fmt.Errorf("uploading %s (resume with upload ID %s): %w", path, upload.ID, err)
+			}
+
+			select {
+			case <-ctx.Done():
+				return upload, fmt.Errorf("uploading %s (resume with upload ID %s): %w", path, upload.ID, ctx.Err())
+			case <-time.After(clientRetryDelay):
+			}
+		} else {
+			failures = 0
+		}
+
+		// Always get the offset from the server since failed chunks may have been
+		// partially received.
+		current, err := c.Get(ctx, upload.ID)
+		if err != nil {
+			if failures++; failures > c.Retries {
+				return upload, err
+			}
+
+			continue
+		}
+
+		upload = current
+
+		if c.Progress != nil {
+			c.Progress(upload)
+		}
+	}
+
+	return upload, nil
+}
+
+// Get returns the upload with the given ID from the server.
+func (c *Client) Get(ctx context.Context, id string) (Upload, error) {
+	var upload Upload
+
+	resp, err := c.do(ctx, http.MethodGet, "/"+id, nil, nil)
+	if err != nil {
+		return upload, err
+	}
+
+	defer resp.Body.Close()
+
+	if err := json.NewDecoder(resp.Body).Decode(&upload); err != nil {
+		return upload, fmt.Errorf("parsing upload %s: %w", id, err)
+	}
+
+	return upload, nil
+}
+
+func (c *Client) create(ctx context.Context, kind Kind, expName, filename string, size int64) (Upload, error) {
+	var upload Upload
+
+	body, err := json.Marshal(map[string]any{
+		"kind":       kind,
+		"experiment": expName,
+		"filename":   filename,
+		"size":       size,
+		"overwrite":  c.Overwrite,
+	})
+	if err != nil {
+		return upload, fmt.Errorf("marshaling upload request: %w", err)
+	}
+
+	headers := http.Header{"Content-Type": {"application/json"}}
+
+	resp, err := c.do(ctx, http.MethodPost, "", bytes.NewReader(body), headers)
+	if err != nil {
+		return upload, err
+	}
+
+	defer resp.Body.Close()
+
+	if err := json.NewDecoder(resp.Body).Decode(&upload); err != nil {
+		return upload, fmt.Errorf("parsing upload: %w", err)
+	}
+
+	return upload, nil
+}
+
+func (c *Client) patch(ctx context.Context, id string, offset int64, chunk []byte) error {
+	sum := sha256.Sum256(chunk)
+
+	headers := http.Header{
+		"Content-Type":    {"application/offset+octet-stream"},
+		"Upload-Offset":   {strconv.FormatInt(offset, 10)},
+		"Upload-Checksum": {"sha256 " + base64.StdEncoding.EncodeToString(sum[:])},
+	}
+
+	resp, err := c.do(ctx, http.MethodPatch, "/"+id, bytes.NewReader(chunk), headers)
+	if err != nil {
+		return err
+	}
+
+	_ = resp.Body.Close()
+
+	return nil
+}
+
+func (c *Client) do(ctx context.Context, method, path string, body io.Reader, headers http.Header) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, c.URL+"/api/v1/uploads"+path, body)
+	if err != nil {
+		return nil, fmt.Errorf("creating request: %w", err)
+	}
+
+	for k, v := range headers {
+		req.Header[k] = v
+	}
+
+	if c.Token != "" {
+		req.Header.Set("X-Phenix-Auth-Token", "Bearer "+c.Token)
+	}
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("sending %s request: %w", method, err)
+	}
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		defer resp.Body.Close()
+
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 4096)) //nolint:mnd // error message size
+
+		return nil, fmt.Errorf("%s request failed (%s): %s", method, resp.Status, strings.TrimSpace(string(msg)))
+	}
+
+	return resp, nil
+}
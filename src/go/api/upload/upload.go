@@ -0,0 +1,562 @@
+// Package upload implements resumable, chunked uploads of disk images and
+// experiment files. An upload is created with the final size of the file,
+// after which chunks are written to it in order, each at the offset the
+// previous chunk ended at. Progress is saved to disk after every chunk, so an
+// upload interrupted by a dropped connection (or a server restart) can be
+// resumed from the last offset the server received.
+//
+// The protocol used by the web server follows the core tus protocol and its
+// checksum extension: offsets are sent in the Upload-Offset header and chunk
+// checksums in the Upload-Checksum header (e.g., "sha256 <base64 digest>").
+package upload
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"phenix/api/disk/manifest"
+	"phenix/api/experiment"
+	"phenix/util/common"
+	"phenix/util/mm"
+	"phenix/util/plog"
+)
+
+// Kind is the kind of file being uploaded, which determines where it's saved.
+type Kind string
+
+const (
+	// KindDisk uploads are saved to the minimega files directory.
+	KindDisk Kind = "disk"
+	// KindExperimentFile uploads are saved to an experiment's files directory.
+	KindExperimentFile Kind = "experiment"
+)
+
+// Statuses of the manifest recorded for completed disk uploads.
+const (
+	ManifestPending  = "pending"
+	ManifestRecorded = "recorded"
+	ManifestFailed   = "failed"
+)
+
+// manifestRetryDelay is how long to wait for a busy upload before updating its
+// manifest status.
+const manifestRetryDelay = 100 * time.Millisecond
+
+// Expiry is how long an upload is kept after it was last written to. Expired
+// uploads, complete or not, are removed the next time an upload is created.
+const Expiry = 7 * 24 * time.Hour
+
+var (
+	ErrUploadNotFound      = errors.New("upload not found")
+	ErrUploadBusy          = errors.New("upload is already being written to")
+	ErrUploadComplete      = errors.New("upload is already complete")
+	ErrOffsetMismatch      = errors.New("upload offset mismatch")
+	ErrChecksumMismatch    = errors.New("chunk checksum mismatch")
+	ErrUnsupportedChecksum = errors.New("unsupported checksum algorithm")
+	ErrUploadTooLarge      = errors.New("chunk exceeds upload size")
+	ErrFileExists          = errors.New("file already exists")
+)
+
+var (
+	active   = make(map[string]struct{}) //nolint:gochecknoglobals // uploads being written to
+	activeMu sync.Mutex                  //nolint:gochecknoglobals // package level lock
+)
+
+// Upload describes a resumable upload.
+type Upload struct {
+	ID         string `json:"id"`
+	User       string `json:"user"`
+	Kind       Kind   `json:"kind"`
+	Experiment string `json:"experiment,omitempty"`
+	Filename   string `json:"filename"`
+	// Path is where the file is saved once the upload is complete.
+	Path string `json:"path"`
+	Size int64  `json:"size"`
+	// Overwrite is true if an existing file at Path can be replaced.
+	Overwrite bool `json:"overwrite,omitempty"`
+	// Offset is the number of bytes received so far.
+	Offset    int64     `json:"offset"`
+	Created   time.Time `json:"created"`
+	Updated   time.Time `json:"updated"`
+	Completed time.Time `json:"completed,omitzero"`
+	// Manifest is the status of the manifest recorded for a completed disk
+	// upload. Manifests are recorded in the background since hashing a large
+	// image can take a while.
+	Manifest string `json:"manifest,omitempty"`
+}
+
+// Complete returns true if all of the upload's bytes have been received.
+func (u Upload) Complete() bool {
+	return !u.Completed.IsZero()
+}
+
+// Progress returns the percentage of the upload received so far.
+func (u Upload) Progress() float64 {
+	if u.Size == 0 {
+		return 100 //nolint:mnd // percent
+	}
+
+	return float64(u.Offset) / float64(u.Size) * 100 //nolint:mnd // percent
+}
+
+// partPath returns the path data is written to until the upload is complete.
+// It's in the same directory as the final file so it can be renamed into place.
+func (u Upload) partPath() string {
+	return filepath.Join(filepath.Dir(u.Path), ".upload-"+u.ID+".part")
+}
+
+// Create creates a new upload of a file with the given name and size for the
+// given user. An empty file is created for the upload's data, so the returned
+// upload is ready to be written to. It returns ErrFileExists if the file already
+// exists, unless overwrite is true.
+func Create(user string, kind Kind, expName, filename string, size int64, overwrite bool) (Upload, error) {
+	var upload Upload
+
+	name := filepath.Base(filename)
+	if filename == "" || name == "." || name == ".." || name == "/" {
+		return upload, fmt.Errorf("invalid upload filename %q", filename)
+	}
+
+	if size < 0 {
+		return upload, fmt.Errorf("invalid upload size %d", size)
+	}
+
+	var dir string
+
+	switch kind {
+	case KindDisk:
+		dir = filepath.Dir(mm.GetMMFullPath(name))
+	case KindExperimentFile:
+		exp, err := experiment.Get(expName)
+		if err != nil {
+			return upload, fmt.Errorf("getting experiment %s: %w", expName, err)
+		}
+
+		dir = exp.FilesDir()
+	default:
+		return upload, fmt.Errorf("unknown upload kind %q", kind)
+	}
+
+	pruneExpired()
+
+	id, err := newID()
+	if err != nil {
+		return upload, err
+	}
+
+	now := time.Now().UTC()
+
+	upload = Upload{ //nolint:exhaustruct // partial initialization
+		ID:         id,
+		User:       user,
+		Kind:       kind,
+		Experiment: expName,
+		Filename:   name,
+		Path:       filepath.Join(dir, name),
+		Size:       size,
+		Overwrite:  overwrite,
+		Created:    now,
+		Updated:    now,
+	}
+
+	if kind == KindDisk {
+		upload.Experiment = ""
+	}
+
+	if _, err := os.Stat(upload.Path); err == nil && !overwrite {
+		return upload, fmt.Errorf("%w: %s", ErrFileExists, upload.Path)
+	}
+
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return upload, fmt.Errorf("creating upload directory %s: %w", dir, err)
+	}
+
+	f, err := os.OpenFile(upload.partPath(), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+	if err != nil {
+		return upload, fmt.Errorf("creating upload file: %w", err)
+	}
+
+	_ = f.Close()
+
+	if size == 0 {
+		if err := complete(&upload); err != nil {
+			return upload, err
+		}
+	}
+
+	if err := save(upload); err != nil {
+		_ = os.Remove(upload.partPath())
+
+		return upload, err
+	}
+
+	if upload.Manifest == ManifestPending {
+		go recordManifest(upload)
+	}
+
+	plog.Info(plog.TypeSystem, "upload created", "id", id, "user", user, "kind", kind, "file", upload.Path, "size", size)
+
+	return upload, nil
+}
+
+// Get returns the upload with the given ID.
+func Get(id string) (Upload, error) {
+	var upload Upload
+
+	if id == "" || strings.ContainsAny(id, `/\.`) {
+		return upload, fmt.Errorf("%w: %s", ErrUploadNotFound, id)
+	}
+
+	body, err := os.ReadFile(metadataPath(id))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return upload, fmt.Errorf("%w: %s", ErrUploadNotFound, id)
+		}
+
+		return upload, fmt.Errorf("reading upload %s: %w", id, err)
+	}
+
+	if err := json.Unmarshal(body, &upload); err != nil {
+		return upload, fmt.Errorf("parsing upload %s: %w", id, err)
+	}
+
+	return upload, nil
+}
+
+// List returns the uploads created by the given user, oldest first. If no user
+// is provided, uploads for all users are returned.
+func List(user string) ([]Upload, error) {
+	paths, err := filepath.Glob(filepath.Join(uploadsPath(), "*.json"))
+	if err != nil {
+		return nil, fmt.Errorf("listing uploads: %w", err)
+	}
+
+	var uploads []Upload
+
+	for _, path := range paths {
+		upload, err := Get(strings.TrimSuffix(filepath.Base(path), ".json"))
+		if err != nil {
+			return nil, err
+		}
+
+		if user == "" || upload.User == user {
+			uploads = append(uploads, upload)
+		}
+	}
+
+	sort.Slice(uploads, func(i, j int) bool { return uploads[i].Created.Before(uploads[j].Created) })
+
+	return uploads, nil
+}
+
+// Write writes a chunk of data read from r to the upload with the given ID at
+// the given offset, which must match the number of bytes received so far. If a
+// checksum is provided, the chunk is discarded unless it matches. Otherwise, as
+// much of the chunk as was read before an error (e.g., a dropped connection) is
+// kept, so the upload can be resumed from wherever it left off. The file is
+// moved to its final path once all of its bytes have been received.
+func Write(id string, offset int64, r io.Reader, checksum string) (Upload, error) {
+	var h hash.Hash
+
+	if checksum != "" {
+		var err error
+
+		if h, err = newChecksumHash(checksum); err != nil {
+			return Upload{}, err //nolint:exhaustruct // error
+		}
+	}
+
+	if err := lock(id); err != nil {
+		return Upload{}, err //nolint:exhaustruct // error
+	}
+
+	defer unlock(id)
+
+	upload, err := Get(id)
+	if err != nil {
+		return upload, err
+	}
+
+	if upload.Complete() {
+		return upload, ErrUploadComplete
+	}
+
+	if offset != upload.Offset {
+		return upload, fmt.Errorf("%w: expected offset %d, got %d", ErrOffsetMismatch, upload.Offset, offset)
+	}
+
+	f, err := os.OpenFile(upload.partPath(), os.O_WRONLY, 0o600)
+	if err != nil {
+		return upload, fmt.Errorf("opening upload %s: %w", id, err)
+	}
+
+	defer f.Close()
+
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		return upload, fmt.Errorf("seeking upload %s: %w", id, err)
+	}
+
+	var (
+		w         io.Writer = f
+		remaining           = upload.Size - offset
+	)
+
+	if h != nil {
+		w = io.MultiWriter(f, h)
+	}
+
+	// Read one byte past the remaining size to detect chunks that are too large.
+	n, copyErr := io.Copy(w, io.LimitReader(r, remaining+1))
+
+	switch {
+	case n > remaining:
+		copyErr = fmt.Errorf("%w: %d bytes remaining", ErrUploadTooLarge, remaining)
+		n = 0
+	case copyErr == nil && h != nil && !checksumMatches(h, checksum):
+		copyErr = ErrChecksumMismatch
+		n = 0
+	case copyErr != nil && h != nil:
+		// Partial chunks can't be verified.
+		n = 0
+	}
+
+	if err := f.Truncate(offset + n); err != nil {
+		return upload, fmt.Errorf("truncating upload %s: %w", id, err)
+	}
+
+	if err := f.Sync(); err != nil {
+		return upload, fmt.Errorf("syncing upload %s: %w", id, err)
+	}
+
+	upload.Offset += n
+	upload.Updated = time.Now().UTC()
+
+	if upload.Offset == upload.Size {
+		if err := complete(&upload); err != nil {
+			return upload, err
+		}
+	}
+
+	if err := save(upload); err != nil {
+		return upload, err
+	}
+
+	if upload.Manifest == ManifestPending {
+		go recordManifest(upload)
+	}
+
+	if copyErr != nil {
+		return upload, fmt.Errorf("writing upload %s: %w", id, copyErr)
+	}
+
+	return upload, nil
+}
+
+// Delete cancels the upload with the given ID, removing any data received for
+// it. Completed files are left in place.
+func Delete(id string) error {
+	if err := lock(id); err != nil {
+		return err
+	}
+
+	defer unlock(id)
+
+	upload, err := Get(id)
+	if err != nil {
+		return err
+	}
+
+	if !upload.Complete() {
+		if err := os.Remove(upload.partPath()); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("removing upload %s data: %w", id, err)
+		}
+	}
+
+	if err := os.Remove(metadataPath(id)); err != nil {
+		return fmt.Errorf("removing upload %s: %w", id, err)
+	}
+
+	return nil
+}
+
+func complete(upload *Upload) error {
+	if upload.Overwrite {
+		if err := os.Rename(upload.partPath(), upload.Path); err != nil {
+			return fmt.Errorf("moving upload %s into place: %w", upload.ID, err)
+		}
+	} else {
+		// The file may have been created since the upload was, so link rather than
+		// rename it into place since linking fails if the file exists.
+		if err := os.Link(upload.partPath(), upload.Path); err != nil {
+			if errors.Is(err, os.ErrExist) {
+				return fmt.Errorf("%w: %s", ErrFileExists, upload.Path)
+			}
+
+			return fmt.Errorf("moving upload %s into place: %w", upload.ID, err)
+		}
+
+		_ = os.Remove(upload.partPath())
+	}
+
+	upload.Completed = time.Now().UTC()
+
+	// The manifest is recorded once the upload is saved, by the caller.
+	if upload.Kind == KindDisk {
+		upload.Manifest = ManifestPending
+	}
+
+	plog.Info(plog.TypeSystem, "upload complete", "id", upload.ID, "user", upload.User, "file", upload.Path)
+
+	return nil
+}
+
+// recordManifest records the manifest for the given completed disk upload and
+// updates the upload's manifest status. The upload is already in place, so a
+// failed manifest isn't fatal; it can be recorded later with `phenix disk
+// verify --record`.
+func recordManifest(upload Upload) {
+	status := ManifestRecorded
+
+	if _, err := manifest.Record(upload.Path); err != nil {
+		plog.Warn(plog.TypeSystem, "recording manifest for upload", "id", upload.ID, "file", upload.Path, "err", err)
+
+		status = ManifestFailed
+	}
+
+	for lock(upload.ID) != nil {
+		time.Sleep(manifestRetryDelay)
+	}
+
+	defer unlock(upload.ID)
+
+	// The upload may have been deleted while the manifest was being recorded.
+	current, err := Get(upload.ID)
+	if err != nil {
+		return
+	}
+
+	current.Manifest = status
+
+	if err := save(current); err != nil {
+		plog.Warn(plog.TypeSystem, "saving upload manifest status", "id", upload.ID, "err", err)
+	}
+}
+
+func save(upload Upload) error {
+	if err := os.MkdirAll(uploadsPath(), 0o750); err != nil {
+		return fmt.Errorf("creating uploads directory: %w", err)
+	}
+
+	body, err := json.Marshal(upload)
+	if err != nil {
+		return fmt.Errorf("marshaling upload %s: %w", upload.ID, err)
+	}
+
+	// Write to a temporary file first so a crash never leaves corrupt metadata.
+	tmp := metadataPath(upload.ID) + ".tmp"
+
+	if err := os.WriteFile(tmp, body, 0o600); err != nil {
+		return fmt.Errorf("writing upload %s: %w", upload.ID, err)
+	}
+
+	if err := os.Rename(tmp, metadataPath(upload.ID)); err != nil {
+		return fmt.Errorf("writing upload %s: %w", upload.ID, err)
+	}
+
+	return nil
+}
+
+func pruneExpired() {
+	uploads, err := List("")
+	if err != nil {
+		plog.Error(plog.TypeSystem, "listing uploads to prune", "err", err)
+
+		return
+	}
+
+	for _, upload := range uploads {
+		if time.Since(upload.Updated) < Expiry {
+			continue
+		}
+
+		if err := Delete(upload.ID); err != nil {
+			plog.Error(plog.TypeSystem, "pruning expired upload", "id", upload.ID, "err", err)
+		}
+	}
+}
+
+func lock(id string) error {
+	activeMu.Lock()
+	defer activeMu.Unlock()
+
+	if _, ok := active[id]; ok {
+		return fmt.Errorf("%w: %s", ErrUploadBusy, id)
+	}
+
+	active[id] = struct{}{}
+
+	return nil
+}
+
+func unlock(id string) {
+	activeMu.Lock()
+	defer activeMu.Unlock()
+
+	delete(active, id)
+}
+
+// newChecksumHash returns the hash for the given checksum, formatted as the
+// algorithm name and base64 encoded digest separated by a space.
+func newChecksumHash(checksum string) (hash.Hash, error) {
+	algo, _, ok := strings.Cut(checksum, " ")
+	if !ok {
+		return nil, fmt.Errorf("invalid checksum %q", checksum)
+	}
+
+	switch strings.ToLower(algo) {
+	case "sha256":
+		return sha256.New(), nil
+	case "sha512":
+		return sha512.New(), nil
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedChecksum, algo)
+	}
+}
+
+func checksumMatches(h hash.Hash, checksum string) bool {
+	_, digest, _ := strings.Cut(checksum, " ")
+
+	return base64.StdEncoding.EncodeToString(h.Sum(nil)) == strings.TrimSpace(digest)
+}
+
+func newID() (string, error) {
+	id := make([]byte, 16) //nolint:mnd // 128-bit ID
+
+	if _, err := rand.Read(id); err != nil {
+		return "", fmt.Errorf("generating upload ID: %w", err)
+	}
+
+	return hex.EncodeToString(id), nil
+}
+
+func metadataPath(id string) string {
+	return filepath.Join(uploadsPath(), id+".json")
+}
+
+func uploadsPath() string {
+	return filepath.Join(common.PhenixBase, "uploads")
+}
@@ -0,0 +1,234 @@
+//nolint:testpackage // testing internals
+package upload
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"phenix/api/disk/manifest"
+	"phenix/util/common"
+)
+
+func newTestUpload(t *testing.T, size int64) Upload {
+	t.Helper()
+
+	common.PhenixBase = t.TempDir() //nolint:reassign // test configuration
+
+	upload := Upload{ //nolint:exhaustruct // partial initialization
+		ID:       "test",
+		Kind:     KindDisk,
+		Filename: "test.qcow2",
+		Path:     filepath.Join(t.TempDir(), "test.qcow2"),
+		Size:     size,
+		Created:  time.Now(),
+		Updated:  time.Now(),
+	}
+
+	if err := os.WriteFile(upload.partPath(), nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := save(upload); err != nil {
+		t.Fatal(err)
+	}
+
+	return upload
+}
+
+// waitForManifest waits for the manifest of the upload with the given ID to be
+// recorded in the background. The upload is locked while its manifest status is
+// saved, so it also waits for the lock to be released.
+func waitForManifest(t *testing.T, id string) Upload {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+
+	for {
+		upload, err := Get(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if upload.Manifest != ManifestPending && lock(id) == nil {
+			unlock(id)
+
+			return upload
+		}
+
+		if time.Now().After(deadline) {
+			t.Fatal("expected manifest to be recorded")
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func checksumOf(data []byte) string {
+	sum := sha256.Sum256(data)
+
+	return "sha256 " + base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// failingReader returns an error after reading its data, like a request body
+// from a dropped connection.
+type failingReader struct {
+	r io.Reader
+}
+
+func (f failingReader) Read(p []byte) (int, error) {
+	n, err := f.r.Read(p)
+	if errors.Is(err, io.EOF) {
+		return n, io.ErrUnexpectedEOF
+	}
+
+	return n, err
+}
+
+func TestWrite(t *testing.T) {
+	upload := newTestUpload(t, 10)
+
+	upload, err := Write(upload.ID, 0, bytes.NewReader([]byte("hello")), checksumOf([]byte("hello")))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if upload.Offset != 5 || upload.Complete() {
+		t.Fatalf("expected incomplete upload at offset 5, got offset %d", upload.Offset)
+	}
+
+	if _, err := Write(upload.ID, 0, bytes.NewReader([]byte("world")), ""); !errors.Is(err, ErrOffsetMismatch) {
+		t.Fatalf("expected offset mismatch, got %v", err)
+	}
+
+	if _, err := Write(upload.ID, 5, bytes.NewReader([]byte("world")), checksumOf([]byte("w0rld"))); !errors.Is(err, ErrChecksumMismatch) {
+		t.Fatalf("expected checksum mismatch, got %v", err)
+	}
+
+	if _, err := Write(upload.ID, 5, bytes.NewReader([]byte("world!")), ""); !errors.Is(err, ErrUploadTooLarge) {
+		t.Fatalf("expected upload too large, got %v", err)
+	}
+
+	upload, err = Write(upload.ID, 5, bytes.NewReader([]byte("world")), checksumOf([]byte("world")))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !upload.Complete() {
+		t.Fatal("expected upload to be complete")
+	}
+
+	body, err := os.ReadFile(upload.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(body) != "helloworld" {
+		t.Fatalf("expected helloworld, got %s", body)
+	}
+
+	if upload.Manifest != ManifestPending {
+		t.Fatalf("expected pending manifest, got %q", upload.Manifest)
+	}
+
+	upload = waitForManifest(t, upload.ID)
+
+	if upload.Manifest != ManifestRecorded {
+		t.Fatalf("expected recorded manifest, got %q", upload.Manifest)
+	}
+
+	if m, err := manifest.Read(upload.Path); err != nil || m.Size != 10 {
+		t.Fatalf("expected manifest size 10, got %+v (%v)", m, err)
+	}
+
+	if _, err := Write(upload.ID, 10, bytes.NewReader(nil), ""); !errors.Is(err, ErrUploadComplete) {
+		t.Fatalf("expected upload complete, got %v", err)
+	}
+}
+
+func TestWriteInterrupted(t *testing.T) {
+	upload := newTestUpload(t, 10)
+
+	// Partial chunks are kept when there's no checksum to verify.
+	upload, err := Write(upload.ID, 0, failingReader{bytes.NewReader([]byte("hel"))}, "")
+	if err == nil {
+		t.Fatal("expected error for interrupted chunk")
+	}
+
+	if upload.Offset != 3 {
+		t.Fatalf("expected offset 3, got %d", upload.Offset)
+	}
+
+	// Partial chunks are discarded when there's a checksum since they can't be
+	// verified.
+	upload, err = Write(upload.ID, 3, failingReader{bytes.NewReader([]byte("lo"))}, checksumOf([]byte("lowor")))
+	if err == nil {
+		t.Fatal("expected error for interrupted chunk")
+	}
+
+	if upload.Offset != 3 {
+		t.Fatalf("expected offset 3, got %d", upload.Offset)
+	}
+
+	upload, err = Get(upload.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if upload.Offset != 3 {
+		t.Fatalf("expected saved offset 3, got %d", upload.Offset)
+	}
+}
+
+func TestWriteExistingFile(t *testing.T) {
+	upload := newTestUpload(t, 5)
+
+	if err := os.WriteFile(upload.Path, []byte("older"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Write(upload.ID, 0, bytes.NewReader([]byte("newer")), ""); !errors.Is(err, ErrFileExists) {
+		t.Fatalf("expected file exists, got %v", err)
+	}
+
+	if body, _ := os.ReadFile(upload.Path); string(body) != "older" {
+		t.Fatalf("expected existing file to be kept, got %s", body)
+	}
+
+	upload = newTestUpload(t, 5)
+	upload.Overwrite = true
+
+	if err := save(upload); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(upload.Path, []byte("older"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Write(upload.ID, 0, bytes.NewReader([]byte("newer")), ""); err != nil {
+		t.Fatal(err)
+	}
+
+	waitForManifest(t, upload.ID)
+
+	if body, _ := os.ReadFile(upload.Path); string(body) != "newer" {
+		t.Fatalf("expected existing file to be replaced, got %s", body)
+	}
+}
+
+func TestGetInvalidID(t *testing.T) {
+	common.PhenixBase = t.TempDir() //nolint:reassign // test configuration
+
+	for _, id := range []string{"", "../test", "test.json"} {
+		if _, err := Get(id); !errors.Is(err, ErrUploadNotFound) {
+			t.Errorf("expected not found for ID %q, got %v", id, err)
+		}
+	}
+}
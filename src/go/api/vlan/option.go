@@ -11,6 +11,10 @@ type options struct {
 	min int
 	max int
 
+	pool  string
+	size  int
+	roles []string
+
 	force bool
 }
 
@@ -54,6 +58,24 @@ func Max(m int) Option {
 	}
 }
 
+func PoolName(p string) Option {
+	return func(o *options) {
+		o.pool = p
+	}
+}
+
+func Size(s int) Option {
+	return func(o *options) {
+		o.size = s
+	}
+}
+
+func Roles(r ...string) Option {
+	return func(o *options) {
+		o.roles = r
+	}
+}
+
 func Force(f bool) Option {
 	return func(o *options) {
 		o.force = f
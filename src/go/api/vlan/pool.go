@@ -0,0 +1,427 @@
+package vlan
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"sync"
+
+	"github.com/activeshadow/structs"
+	"github.com/mitchellh/mapstructure"
+
+	"phenix/api/config"
+	"phenix/api/experiment"
+	"phenix/store"
+	"phenix/types"
+)
+
+const (
+	// PoolAnnotation is the experiment annotation used to request a VLAN range
+	// from a specific pool. It is also set on experiments that have been
+	// allocated a VLAN range from a pool to track the allocation.
+	PoolAnnotation = "phenix.vlan/pool"
+
+	// RoleAnnotation is the experiment annotation used to identify the role of
+	// the user creating an experiment so pools reserved for a role can be used.
+	RoleAnnotation = "phenix.vlan/role"
+
+	poolKind    = "VLANPool"
+	poolVersion = store.APIGroup + "/v1"
+
+	minVLANID = 1
+	maxVLANID = 4094
+)
+
+var (
+	ErrPoolNotFound  = errors.New("VLAN pool not found")
+	ErrPoolExhausted = errors.New("no free VLAN range available in pool")
+	ErrPoolReserved  = errors.New("VLAN pool is reserved")
+)
+
+// poolMu serializes allocations so two experiments being created at the same
+// time can't be allocated overlapping VLAN ranges.
+var poolMu sync.Mutex //nolint:gochecknoglobals // package level lock
+
+// Pool is a cluster-wide range of VLAN IDs that experiments are automatically
+// allocated non-overlapping VLAN ranges from. Pools with roles are reserved
+// for experiments created by users with one of the roles.
+type Pool struct {
+	Name  string   `json:"name"            mapstructure:"name"  structs:"name"  yaml:"name"`
+	Min   int      `json:"min"             mapstructure:"min"   structs:"min"   yaml:"min"`
+	Max   int      `json:"max"             mapstructure:"max"   structs:"max"   yaml:"max"`
+	Size  int      `json:"size"            mapstructure:"size"  structs:"size"  yaml:"size"`
+	Roles []string `json:"roles,omitempty" mapstructure:"roles" structs:"roles" yaml:"roles,omitempty"`
+
+	Allocations []Allocation `json:"allocations,omitempty" mapstructure:"-" structs:"-" yaml:"allocations,omitempty"`
+}
+
+// Allocation is a VLAN range allocated to an experiment from a pool.
+type Allocation struct {
+	Experiment string `json:"experiment" yaml:"experiment"`
+	Min        int    `json:"min"        yaml:"min"`
+	Max        int    `json:"max"        yaml:"max"`
+	Running    bool   `json:"running"    yaml:"running"`
+}
+
+// AvailableTo returns true if the pool is not reserved or is reserved for the
+// given role.
+func (p Pool) AvailableTo(role string) bool {
+	return len(p.Roles) == 0 || slices.Contains(p.Roles, role)
+}
+
+func init() { //nolint:gochecknoinits // config hook
+	config.RegisterConfigHook("Experiment", func(stage string, c *store.Config) error {
+		if stage != "create" {
+			return nil
+		}
+
+		return allocate(c)
+	})
+}
+
+// Pools returns all the VLAN pools, including the VLAN ranges currently
+// allocated to experiments from each pool.
+func Pools() ([]Pool, error) {
+	pools, err := listPools()
+	if err != nil {
+		return nil, err
+	}
+
+	exps, err := experiment.List()
+	if err != nil {
+		return nil, fmt.Errorf("getting list of experiments: %w", err)
+	}
+
+	for _, exp := range exps {
+		name := exp.Metadata.Annotations[PoolAnnotation]
+		if name == "" {
+			continue
+		}
+
+		for i := range pools {
+			if pools[i].Name != name {
+				continue
+			}
+
+			pools[i].Allocations = append(pools[i].Allocations, Allocation{
+				Experiment: exp.Metadata.Name,
+				Min:        exp.Spec.VLANs().Min(),
+				Max:        exp.Spec.VLANs().Max(),
+				Running:    exp.Running(),
+			})
+		}
+	}
+
+	for _, pool := range pools {
+		sort.Slice(pool.Allocations, func(i, j int) bool {
+			return pool.Allocations[i].Min < pool.Allocations[j].Min
+		})
+	}
+
+	return pools, nil
+}
+
+// CreatePool creates a new VLAN pool with the given name, VLAN range, and the
+// size of the VLAN ranges allocated to experiments from it. It returns an error
+// if the pool's range overlaps with an existing pool.
+func CreatePool(opts ...Option) error {
+	o := newOptions(opts...)
+
+	if o.pool == "" {
+		return errors.New("no VLAN pool name provided")
+	}
+
+	if !config.NameRegex.MatchString(o.pool) {
+		return fmt.Errorf("invalid VLAN pool name %s", o.pool)
+	}
+
+	if o.min < minVLANID || o.max > maxVLANID {
+		return fmt.Errorf("VLAN pool range must be between %d and %d", minVLANID, maxVLANID)
+	}
+
+	if o.min > o.max {
+		return errors.New("min VLAN ID must not be greater than max VLAN ID")
+	}
+
+	if o.size < 1 || o.size > o.max-o.min+1 {
+		return fmt.Errorf("VLAN pool allocation size must be between 1 and %d", o.max-o.min+1)
+	}
+
+	pools, err := listPools()
+	if err != nil {
+		return err
+	}
+
+	for _, other := range pools {
+		if o.min <= other.Max && other.Min <= o.max {
+			return fmt.Errorf(
+				"VLAN pool range %d-%d overlaps with pool %s (%d-%d)",
+				o.min, o.max, other.Name, other.Min, other.Max,
+			)
+		}
+	}
+
+	pool := Pool{ //nolint:exhaustruct // partial initialization
+		Name:  o.pool,
+		Min:   o.min,
+		Max:   o.max,
+		Size:  o.size,
+		Roles: o.roles,
+	}
+
+	c := newPoolConfig(o.pool)
+	c.Spec = structs.MapDefaultCase(&pool, structs.CASESNAKE)
+
+	if err := store.Create(c); err != nil {
+		if errors.Is(err, store.ErrExist) {
+			return fmt.Errorf("VLAN pool %s already exists", o.pool)
+		}
+
+		return fmt.Errorf("storing VLAN pool %s: %w", o.pool, err)
+	}
+
+	return nil
+}
+
+// DeletePool deletes the given VLAN pool. It returns an error if experiments
+// are still allocated VLAN ranges from the pool unless forced. Experiments
+// keep their VLAN ranges when a pool is deleted.
+func DeletePool(opts ...Option) error {
+	o := newOptions(opts...)
+
+	if o.pool == "" {
+		return errors.New("no VLAN pool name provided")
+	}
+
+	pools, err := Pools()
+	if err != nil {
+		return err
+	}
+
+	idx := slices.IndexFunc(pools, func(p Pool) bool { return p.Name == o.pool })
+	if idx < 0 {
+		return fmt.Errorf("%w: %s", ErrPoolNotFound, o.pool)
+	}
+
+	if n := len(pools[idx].Allocations); n > 0 && !o.force {
+		return fmt.Errorf("VLAN pool %s has %d experiment allocation(s)", o.pool, n)
+	}
+
+	if err := store.Delete(newPoolConfig(o.pool)); err != nil {
+		return fmt.Errorf("deleting VLAN pool %s: %w", o.pool, err)
+	}
+
+	return nil
+}
+
+// allocate sets the VLAN range for the given experiment config to a free range
+// from a VLAN pool. Experiments created with an explicit VLAN range or explicit
+// VLAN alias IDs are left as-is. If the experiment is annotated with a pool,
+// only that pool is used. Otherwise, pools reserved for the role the experiment
+// is annotated with are preferred over unreserved pools.
+func allocate(c *store.Config) error {
+	exp, err := types.DecodeExperimentFromConfig(*c)
+	if err != nil {
+		return fmt.Errorf("decoding experiment from config: %w", err)
+	}
+
+	vlans := exp.Spec.VLANs()
+
+	if vlans.Min() != 0 || vlans.Max() != 0 {
+		return nil
+	}
+
+	// The experiment create hook sets every VLAN alias in the topology to 0 (to
+	// be allocated at start), so only aliases with an explicit ID count.
+	for _, id := range vlans.Aliases() {
+		if id != 0 {
+			return nil
+		}
+	}
+
+	poolMu.Lock()
+	defer poolMu.Unlock()
+
+	pools, err := listPools()
+	if err != nil {
+		return err
+	}
+
+	var (
+		name = c.Metadata.Annotations[PoolAnnotation]
+		role = c.Metadata.Annotations[RoleAnnotation]
+	)
+
+	candidates, err := candidatePools(pools, name, role)
+	if err != nil {
+		return err
+	}
+
+	if len(candidates) == 0 {
+		return nil
+	}
+
+	used, err := usedRanges(c.Metadata.Name)
+	if err != nil {
+		return err
+	}
+
+	for _, pool := range candidates {
+		lo, hi, ok := freeRange(pool, used)
+		if !ok {
+			continue
+		}
+
+		if err := exp.Spec.SetVLANRange(lo, hi, false); err != nil {
+			return fmt.Errorf("setting VLAN range from pool %s: %w", pool.Name, err)
+		}
+
+		c.Spec = structs.MapDefaultCase(exp.Spec, structs.CASESNAKE)
+
+		if c.Metadata.Annotations == nil {
+			c.Metadata.Annotations = make(store.Annotations)
+		}
+
+		c.Metadata.Annotations[PoolAnnotation] = pool.Name
+
+		return nil
+	}
+
+	if name != "" {
+		return fmt.Errorf("%w %s", ErrPoolExhausted, name)
+	}
+
+	return fmt.Errorf("%w for role %q", ErrPoolExhausted, role)
+}
+
+// candidatePools returns the pools an experiment can be allocated a VLAN range
+// from, in the order they should be tried.
+func candidatePools(pools []Pool, name, role string) ([]Pool, error) {
+	if name != "" {
+		idx := slices.IndexFunc(pools, func(p Pool) bool { return p.Name == name })
+		if idx < 0 {
+			return nil, fmt.Errorf("%w: %s", ErrPoolNotFound, name)
+		}
+
+		// Experiments created without a role (e.g., via the command line) can use
+		// any pool explicitly.
+		if role != "" && !pools[idx].AvailableTo(role) {
+			return nil, fmt.Errorf("%w: %s not available to role %s", ErrPoolReserved, name, role)
+		}
+
+		return []Pool{pools[idx]}, nil
+	}
+
+	var reserved, shared []Pool
+
+	for _, pool := range pools {
+		switch {
+		case len(pool.Roles) == 0:
+			shared = append(shared, pool)
+		case role != "" && pool.AvailableTo(role):
+			reserved = append(reserved, pool)
+		}
+	}
+
+	return append(reserved, shared...), nil
+}
+
+// usedRanges returns the VLAN ranges in use by all experiments except the given
+// one. Running experiments also include the VLAN IDs actually in use, since
+// minimega may have allocated them outside of the experiment's VLAN range.
+func usedRanges(exclude string) ([][2]int, error) {
+	exps, err := experiment.List()
+	if err != nil {
+		return nil, fmt.Errorf("getting list of experiments: %w", err)
+	}
+
+	var used [][2]int
+
+	for _, exp := range exps {
+		if exp.Metadata.Name == exclude {
+			continue
+		}
+
+		lo, hi := exp.Spec.VLANs().Min(), exp.Spec.VLANs().Max()
+
+		if lo != 0 || hi != 0 {
+			if lo == 0 {
+				lo = minVLANID
+			}
+
+			if hi == 0 {
+				hi = maxVLANID
+			}
+
+			used = append(used, [2]int{lo, hi})
+		}
+
+		if exp.Running() {
+			for _, id := range exp.Status.VLANs() {
+				used = append(used, [2]int{id, id})
+			}
+		}
+	}
+
+	return used, nil
+}
+
+// freeRange returns the lowest range of the pool's allocation size within the
+// pool that doesn't overlap with any of the used ranges.
+func freeRange(pool Pool, used [][2]int) (int, int, bool) {
+	lo := pool.Min
+
+	for lo+pool.Size-1 <= pool.Max {
+		hi := lo + pool.Size - 1
+		overlap := false
+
+		for _, r := range used {
+			if lo <= r[1] && r[0] <= hi {
+				overlap = true
+				lo = r[1] + 1
+
+				break
+			}
+		}
+
+		if !overlap {
+			return lo, hi, true
+		}
+	}
+
+	return 0, 0, false
+}
+
+func listPools() ([]Pool, error) {
+	configs, err := store.List(poolKind)
+	if err != nil {
+		return nil, fmt.Errorf("getting list of VLAN pools from store: %w", err)
+	}
+
+	pools := make([]Pool, 0, len(configs))
+
+	for _, c := range configs {
+		var pool Pool
+
+		if err := mapstructure.Decode(c.Spec, &pool); err != nil {
+			return nil, fmt.Errorf("decoding VLAN pool %s: %w", c.Metadata.Name, err)
+		}
+
+		pools = append(pools, pool)
+	}
+
+	sort.Slice(pools, func(i, j int) bool { return pools[i].Name < pools[j].Name })
+
+	return pools, nil
+}
+
+func newPoolConfig(name string) *store.Config {
+	return &store.Config{ //nolint:exhaustruct // partial initialization
+		Version: poolVersion,
+		Kind:    poolKind,
+		Metadata: store.ConfigMetadata{ //nolint:exhaustruct // partial initialization
+			Name: name,
+		},
+	}
+}
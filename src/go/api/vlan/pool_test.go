@@ -0,0 +1,162 @@
+//nolint:testpackage // testing internals
+package vlan
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+
+	"phenix/api/config"
+	"phenix/store"
+	"phenix/types"
+)
+
+func TestFreeRange(t *testing.T) {
+	pool := Pool{Name: "shared", Min: 100, Max: 199, Size: 20} //nolint:exhaustruct // partial initialization
+
+	cases := []struct {
+		name   string
+		used   [][2]int
+		lo, hi int
+		ok     bool
+	}{
+		{"empty", nil, 100, 119, true},
+		{"skip allocated", [][2]int{{100, 119}, {120, 139}}, 140, 159, true},
+		{"fill gap", [][2]int{{100, 119}, {150, 169}}, 120, 139, true},
+		{"gap too small", [][2]int{{100, 119}, {130, 199}}, 0, 0, false},
+		{"running VLAN ID", [][2]int{{105, 105}}, 106, 125, true},
+		{"manual overlap", [][2]int{{1, 150}}, 151, 170, true},
+		{"exhausted", [][2]int{{1, 4094}}, 0, 0, false},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			lo, hi, ok := freeRange(pool, c.used)
+
+			if ok != c.ok || lo != c.lo || hi != c.hi {
+				t.Fatalf("expected (%d, %d, %v), got (%d, %d, %v)", c.lo, c.hi, c.ok, lo, hi, ok)
+			}
+		})
+	}
+}
+
+func TestCandidatePools(t *testing.T) {
+	pools := []Pool{
+		{Name: "blue", Min: 100, Max: 199, Size: 10, Roles: []string{"Blue Team"}}, //nolint:exhaustruct // partial initialization
+		{Name: "red", Min: 200, Max: 299, Size: 10, Roles: []string{"Red Team"}},   //nolint:exhaustruct // partial initialization
+		{Name: "shared", Min: 300, Max: 399, Size: 10},                             //nolint:exhaustruct // partial initialization
+	}
+
+	names := func(pools []Pool) []string {
+		var n []string
+
+		for _, p := range pools {
+			n = append(n, p.Name)
+		}
+
+		return n
+	}
+
+	got, err := candidatePools(pools, "", "Red Team")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if n := names(got); len(n) != 2 || n[0] != "red" || n[1] != "shared" {
+		t.Fatalf("expected reserved pool before shared pool, got %v", n)
+	}
+
+	got, err = candidatePools(pools, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if n := names(got); len(n) != 1 || n[0] != "shared" {
+		t.Fatalf("expected only shared pool, got %v", n)
+	}
+
+	if _, err := candidatePools(pools, "blue", "Red Team"); !errors.Is(err, ErrPoolReserved) {
+		t.Fatalf("expected reserved pool error, got %v", err)
+	}
+
+	if got, err := candidatePools(pools, "blue", ""); err != nil || len(got) != 1 {
+		t.Fatalf("expected explicit pool without role to be allowed, got %v (%v)", names(got), err)
+	}
+
+	if _, err := candidatePools(pools, "green", ""); !errors.Is(err, ErrPoolNotFound) {
+		t.Fatalf("expected pool not found error, got %v", err)
+	}
+}
+
+func TestAllocateAfterExperimentCreateHook(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	original := store.DefaultStore
+	t.Cleanup(func() { store.DefaultStore = original }) //nolint:reassign // restore test double
+
+	pool := newPoolConfig("shared")
+	pool.Spec = map[string]any{"name": "shared", "min": 100, "max": 199, "size": 10}
+
+	var created *store.Config
+
+	m := store.NewMockStore(ctrl)
+
+	m.EXPECT().List(gomock.Any()).AnyTimes().DoAndReturn(func(kinds ...string) (store.Configs, error) {
+		if len(kinds) == 1 && kinds[0] == poolKind {
+			return store.Configs{*pool}, nil
+		}
+
+		return nil, nil
+	})
+
+	m.EXPECT().Create(gomock.Any()).DoAndReturn(func(c *store.Config) error {
+		created = c
+
+		return nil
+	})
+
+	store.DefaultStore = m //nolint:reassign // install test double
+
+	// The experiment create hook runs first and sets the EXP alias to 0.
+	exp := &store.Config{ //nolint:exhaustruct // partial initialization
+		Version:  store.APIGroup + "/v1",
+		Kind:     "Experiment",
+		Metadata: store.ConfigMetadata{Name: "test"}, //nolint:exhaustruct // partial initialization
+		Spec: map[string]any{
+			"experimentName": "test",
+			"topology": map[string]any{
+				"nodes": []any{
+					map[string]any{
+						"general":  map[string]any{"hostname": "host"},
+						"hardware": map[string]any{"drives": []any{map[string]any{"image": "base.qc2"}}},
+						"network": map[string]any{
+							"interfaces": []any{map[string]any{"name": "eth0", "vlan": "EXP"}},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	if _, err := config.Create(config.CreateFromConfig(exp)); err != nil {
+		t.Fatal(err)
+	}
+
+	if created == nil {
+		t.Fatal("expected experiment config to be stored")
+	}
+
+	got, err := types.DecodeExperimentFromConfig(*created)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if vlans := got.Spec.VLANs(); vlans.Min() != 100 || vlans.Max() != 109 {
+		t.Fatalf("expected VLAN range 100 - 109, got %d - %d", vlans.Min(), vlans.Max())
+	}
+
+	if name := created.Metadata.Annotations[PoolAnnotation]; name != "shared" {
+		t.Fatalf("expected pool annotation shared, got %q", name)
+	}
+}
@@ -0,0 +1,227 @@
+package vm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+
+	"golang.org/x/sync/errgroup"
+
+	"phenix/api/experiment"
+	"phenix/util/mm"
+)
+
+// Actions that can be applied to VMs in a batch.
+const (
+	BatchStart    = "start"
+	BatchStop     = "stop"
+	BatchRestart  = "restart"
+	BatchRedeploy = "redeploy"
+	BatchSnapshot = "snapshot"
+	BatchShutdown = "shutdown"
+	BatchSetTags  = "set-tags"
+
+	// DefaultBatchParallelism is the maximum number of VMs a batch action is
+	// applied to concurrently unless configured otherwise.
+	DefaultBatchParallelism = 8
+)
+
+var ErrUnknownBatchAction = errors.New("unknown batch action")
+
+// BatchActions are all the actions that can be applied to VMs in a batch.
+var BatchActions = []string{ //nolint:gochecknoglobals // global constant
+	BatchStart, BatchStop, BatchRestart, BatchRedeploy, BatchSnapshot, BatchShutdown, BatchSetTags,
+}
+
+// BatchResult is the result of applying a batch action to a single VM.
+type BatchResult struct {
+	VM    string `json:"vm"`
+	Error string `json:"error,omitempty"`
+}
+
+// BatchTargets returns the names of the VMs in the given experiment selected
+// by the given options. VMs are selected by name, by label (see
+// MatchesAnyLabel), or both, unless all VMs are selected explicitly. External
+// VMs are never selected.
+func BatchTargets(expName string, opts ...BatchOption) ([]string, error) {
+	o := newBatchOptions(opts...)
+
+	if !o.all && len(o.vms) == 0 && len(o.labels) == 0 {
+		return nil, fmt.Errorf("%w: no VM names or labels provided", ErrNoVMsSelected)
+	}
+
+	vms, err := List(expName)
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+
+	for _, vm := range vms {
+		if vm.State == "EXTERNAL" {
+			continue
+		}
+
+		if len(o.vms) > 0 && !slices.Contains(o.vms, vm.Name) {
+			continue
+		}
+
+		selected, err := selectedByLabels(vm.Tags, o.labels)
+		if err != nil {
+			return nil, err
+		}
+
+		if !selected {
+			continue
+		}
+
+		names = append(names, vm.Name)
+	}
+
+	if len(names) == 0 {
+		return nil, fmt.Errorf("%w in experiment %s", ErrNoVMsSelected, expName)
+	}
+
+	return names, nil
+}
+
+// Batch applies the given action to every VM in the given experiment selected
+// by the given options, running up to the configured parallelism at a time. It
+// returns the result for each VM, in the order the VMs are defined in the
+// topology. Errors applying the action to a specific VM are included in the
+// VM's result rather than returned.
+func Batch(ctx context.Context, expName, action string, opts ...BatchOption) ([]BatchResult, error) {
+	o := newBatchOptions(opts...)
+
+	if !slices.Contains(BatchActions, action) {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownBatchAction, action)
+	}
+
+	if action == BatchSetTags && o.tags == nil {
+		return nil, errors.New("no tags provided")
+	}
+
+	names, err := BatchTargets(expName, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		// Setting tags saves the experiment spec for each VM, so it can't be done
+		// concurrently without VMs clobbering each other's changes.
+		tagMu sync.Mutex
+
+		apply = func(ctx context.Context, name string) error {
+			return batchApply(ctx, expName, name, action, o, &tagMu)
+		}
+	)
+
+	return runBatch(ctx, names, o, apply), nil
+}
+
+// runBatch calls the given function for each of the given VMs, running up to
+// the configured parallelism at a time, and returns the result for each VM in
+// the order given.
+func runBatch(
+	ctx context.Context,
+	names []string,
+	o batchOptions,
+	apply func(context.Context, string) error,
+) []BatchResult {
+	var (
+		results = make([]BatchResult, len(names))
+		g, _    = errgroup.WithContext(ctx)
+	)
+
+	g.SetLimit(o.parallelism)
+
+	for i, name := range names {
+		results[i].VM = name
+
+		g.Go(func() error {
+			if err := apply(ctx, name); err != nil {
+				results[i].Error = err.Error()
+			}
+
+			if o.callback != nil {
+				o.callback(results[i])
+			}
+
+			// Errors are reported per-VM, so never cancel the remaining VMs.
+			return nil
+		})
+	}
+
+	_ = g.Wait()
+
+	return results
+}
+
+func batchApply(ctx context.Context, expName, vmName, action string, o batchOptions, tagMu *sync.Mutex) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if o.lock != nil {
+		unlock, err := o.lock(action, vmName)
+		if err != nil {
+			return err
+		}
+
+		defer unlock()
+	}
+
+	switch action {
+	case BatchStart:
+		if err := mm.StartVM(mm.NS(expName), mm.VMName(vmName)); err != nil {
+			return fmt.Errorf("starting VM %s: %w", vmName, err)
+		}
+
+		experiment.RecordEvent(expName, vmName, "VM started", nil)
+	case BatchStop:
+		if err := mm.StopVM(mm.NS(expName), mm.VMName(vmName)); err != nil {
+			return fmt.Errorf("stopping VM %s: %w", vmName, err)
+		}
+
+		experiment.RecordEvent(expName, vmName, "VM stopped", nil)
+	case BatchRestart:
+		return Restart(expName, vmName)
+	case BatchShutdown:
+		return Shutdown(expName, vmName)
+	case BatchRedeploy:
+		vm, err := Get(expName, vmName)
+		if err != nil {
+			return fmt.Errorf("getting VM %s: %w", vmName, err)
+		}
+
+		return Redeploy(expName, vmName, CPU(vm.CPUs), Memory(vm.RAM), Disk(vm.Disk), Inject(o.inject))
+	case BatchSnapshot:
+		name := o.snapshot
+		if name == "" {
+			name = getTimestamp()
+		}
+
+		return Snapshot(expName, vmName, name, func(string) {}, SnapshotWithCreator(o.creator))
+	case BatchSetTags:
+		tagMu.Lock()
+		defer tagMu.Unlock()
+
+		// UpdateWithTags modifies the given tags when appending, so each VM needs
+		// its own copy.
+		tags := make(map[string]string, len(o.tags))
+
+		for k, v := range o.tags {
+			tags[k] = v
+		}
+
+		return Update(
+			UpdateExperiment(expName),
+			UpdateVM(vmName),
+			UpdateWithTags(tags, o.appendTags),
+		)
+	}
+
+	return nil
+}
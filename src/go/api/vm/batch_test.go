@@ -0,0 +1,100 @@
+//nolint:testpackage // testing internals
+package vm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestBatchUnknownAction(t *testing.T) {
+	_, err := Batch(context.Background(), "test", "explode", BatchWithAllVMs())
+	if !errors.Is(err, ErrUnknownBatchAction) {
+		t.Fatalf("expected unknown batch action error, got %v", err)
+	}
+}
+
+func TestRunBatchPartialFailure(t *testing.T) {
+	var (
+		names     = []string{"vm-1", "vm-2", "vm-3", "vm-4"}
+		mu        sync.Mutex
+		callbacks []string
+	)
+
+	o := newBatchOptions(BatchWithCallback(func(r BatchResult) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		callbacks = append(callbacks, r.VM)
+	}))
+
+	results := runBatch(context.Background(), names, o, func(_ context.Context, name string) error {
+		if name == "vm-2" || name == "vm-4" {
+			return fmt.Errorf("%s failed", name)
+		}
+
+		return nil
+	})
+
+	if len(results) != len(names) {
+		t.Fatalf("expected %d results, got %d", len(names), len(results))
+	}
+
+	for i, r := range results {
+		if r.VM != names[i] {
+			t.Fatalf("expected result %d for %s, got %s", i, names[i], r.VM)
+		}
+
+		failed := r.VM == "vm-2" || r.VM == "vm-4"
+
+		if failed && r.Error != r.VM+" failed" {
+			t.Errorf("expected %s to fail, got error %q", r.VM, r.Error)
+		}
+
+		if !failed && r.Error != "" {
+			t.Errorf("expected %s to succeed, got error %q", r.VM, r.Error)
+		}
+	}
+
+	if len(callbacks) != len(names) {
+		t.Fatalf("expected callback for each VM, got %v", callbacks)
+	}
+}
+
+func TestRunBatchParallelism(t *testing.T) {
+	var (
+		names   = make([]string, 20)
+		active  atomic.Int32
+		maxSeen atomic.Int32
+	)
+
+	for i := range names {
+		names[i] = fmt.Sprintf("vm-%d", i)
+	}
+
+	o := newBatchOptions(BatchWithParallelism(3))
+
+	runBatch(context.Background(), names, o, func(context.Context, string) error {
+		n := active.Add(1)
+		defer active.Add(-1)
+
+		for {
+			seen := maxSeen.Load()
+			if n <= seen || maxSeen.CompareAndSwap(seen, n) {
+				break
+			}
+		}
+
+		time.Sleep(5 * time.Millisecond)
+
+		return nil
+	})
+
+	if got := maxSeen.Load(); got != 3 {
+		t.Fatalf("expected up to 3 VMs processed concurrently, got %d", got)
+	}
+}
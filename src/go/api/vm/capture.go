@@ -1,15 +1,25 @@
 package vm
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"strings"
+	"time"
 
 	"phenix/api/experiment"
+	"phenix/util/common"
+	"phenix/util/file"
 	"phenix/util/mm"
 )
 
+// captureStreamInterval is how often an in-progress capture being streamed is
+// checked for new packets.
+const captureStreamInterval = 2 * time.Second
+
 var (
 	ErrCaptureExists = errors.New("capture already exists")
 	ErrNoCaptures    = errors.New("no captures exist")
@@ -18,8 +28,9 @@ var (
 // StartCapture starts a packet capture on the given interface for the given VM
 // in the given experiment. The captured packets are written to the experiment's
 // files directory using the base name of the provided output file in PCAP
-// format. It returns any errors encountered while starting the packet capture.
-func StartCapture(expName, vmName string, iface int, out string) error {
+// format. The capture is filtered and bounded by the given limits, if any. It
+// returns any errors encountered while starting the packet capture.
+func StartCapture(expName, vmName string, iface int, out string, limits mm.CaptureLimits) error {
 	if expName == "" {
 		return errors.New("no experiment name provided")
 	}
@@ -60,6 +71,7 @@ func StartCapture(expName, vmName string, iface int, out string) error {
 		mm.VMName(vmName),
 		mm.CaptureInterface(iface),
 		mm.CaptureFile(out),
+		mm.CaptureWithLimits(limits),
 	); err != nil {
 		return fmt.Errorf(
 			"starting VM capture for interface %d on VM %s in experiment %s: %w",
@@ -70,6 +82,20 @@ func StartCapture(expName, vmName string, iface int, out string) error {
 		)
 	}
 
+	// Networks look like `EXP_1 (101)`, but only the VLAN alias is recorded.
+	vlan := vm.Networks[iface]
+
+	if match := vlanAliasRegex.FindStringSubmatch(vlan); match != nil {
+		vlan = match[1]
+	}
+
+	experiment.RecordEvent(
+		expName,
+		vmName,
+		experiment.JournalCaptureStarted,
+		map[string]any{"interface": iface, "vlan": vlan, "file": filepath.Base(out)},
+	)
+
 	return nil
 }
 
@@ -114,3 +140,220 @@ func StopCaptures(expName, vmName string) error {
 
 	return nil
 }
+
+// StreamCapture writes the capture for the given interface of the given VM in
+// the given experiment to w. Packets are written as they're captured until the
+// capture stops, the capture file is rotated, or the context is canceled. For
+// captures using a ring buffer, the file currently being written to is used.
+// Captures on other cluster hosts are streamed by periodically pulling the
+// packets captured since the last pull to the headnode.
+func StreamCapture(ctx context.Context, expName, vmName string, iface int, w io.Writer) error {
+	capture, err := getCapture(expName, vmName, iface)
+	if err != nil {
+		return err
+	}
+
+	host, err := mm.GetVMHost(mm.NS(expName), mm.VMName(vmName))
+	if err != nil {
+		return fmt.Errorf("unable to determine what host the VM is scheduled on: %w", err)
+	}
+
+	path := capture.Filepath
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(common.PhenixBase, "images", path)
+	}
+
+	current, err := currentCaptureFile(host, path)
+	if err != nil {
+		return fmt.Errorf("finding capture file: %w", err)
+	}
+
+	// copyNew writes the packets captured since it was last called to w.
+	var copyNew func() error
+
+	if mm.IsHeadnode(host) {
+		f, err := os.Open(current)
+		if err != nil {
+			return fmt.Errorf("opening capture file: %w", err)
+		}
+
+		defer f.Close()
+
+		copyNew = func() error {
+			_, err := io.Copy(w, f)
+
+			return err
+		}
+	} else {
+		var offset int64
+
+		copyNew = func() error {
+			n, err := copyRemoteCapture(expName, vmName, iface, host, current, offset, w)
+			offset += n
+
+			return err
+		}
+	}
+
+	for {
+		if err := copyNew(); err != nil {
+			return fmt.Errorf("streaming capture file: %w", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(captureStreamInterval):
+		}
+
+		if _, err := getCapture(expName, vmName, iface); err != nil {
+			break
+		}
+
+		if latest, err := currentCaptureFile(host, path); err != nil || latest != current {
+			break
+		}
+	}
+
+	// Write any packets captured since the last copy.
+	if err := copyNew(); err != nil {
+		return fmt.Errorf("streaming capture file: %w", err)
+	}
+
+	return nil
+}
+
+func getCapture(expName, vmName string, iface int) (mm.Capture, error) {
+	for _, capture := range mm.GetVMCaptures(mm.NS(expName), mm.VMName(vmName)) {
+		if capture.Interface == iface {
+			return capture, nil
+		}
+	}
+
+	return mm.Capture{}, fmt.Errorf( //nolint:exhaustruct // empty capture
+		"interface %d of vm %s in experiment %s: %w",
+		iface,
+		vmName,
+		expName,
+		ErrNoCaptures,
+	)
+}
+
+// currentCaptureFile returns the capture file currently being written to on
+// the given host. Captures using a ring buffer are written to numbered files
+// (e.g., foo.pcap0, foo.pcap1, ...) instead of the capture path itself.
+func currentCaptureFile(host, path string) (string, error) {
+	var (
+		dir  = filepath.Dir(path)
+		base = filepath.Base(path)
+	)
+
+	if !mm.IsHeadnode(host) {
+		// `ls -t` sorts by modification time, newest first.
+		resp, err := mm.MeshShellResponse(host, "ls -t "+dir)
+		if err != nil {
+			return "", fmt.Errorf("listing capture directory on host %s: %w", host, err)
+		}
+
+		for name := range strings.FieldsSeq(resp) {
+			if isCaptureFile(base, name) {
+				return filepath.Join(dir, name), nil
+			}
+		}
+
+		return "", fmt.Errorf("capture file %s not found on host %s", path, host)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return "", fmt.Errorf("listing capture directory: %w", err)
+	}
+
+	var (
+		current string
+		newest  time.Time
+	)
+
+	for _, entry := range entries {
+		if !isCaptureFile(base, entry.Name()) {
+			continue
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+
+		if info.ModTime().After(newest) {
+			current = filepath.Join(dir, entry.Name())
+			newest = info.ModTime()
+		}
+	}
+
+	if current == "" {
+		return "", fmt.Errorf("capture file %s not found", path)
+	}
+
+	return current, nil
+}
+
+// isCaptureFile returns true if name is the capture file with the given base
+// name or one of its numbered ring buffer files.
+func isCaptureFile(base, name string) bool {
+	suffix, ok := strings.CutPrefix(name, base)
+	if !ok {
+		return false
+	}
+
+	return strings.Trim(suffix, "0123456789") == ""
+}
+
+// copyRemoteCapture copies the contents of the given capture file on the given
+// host, starting at the given offset, to the headnode and writes it to w. It
+// returns the number of bytes written. The new contents are copied to the
+// experiment's tmp directory on the host first so the file being pulled to the
+// headnode isn't still being written to.
+func copyRemoteCapture(
+	expName, vmName string,
+	iface int,
+	host, path string,
+	offset int64,
+	w io.Writer,
+) (int64, error) {
+	var (
+		name = fmt.Sprintf("%s_%d%s", vmName, iface, filepath.Ext(path))
+		tmp  = fmt.Sprintf("%s/images/%s/tmp/%s", common.PhenixBase, expName, name)
+	)
+
+	if err := mm.MeshShell(host, "mkdir -p "+filepath.Dir(tmp)); err != nil {
+		return 0, fmt.Errorf("ensuring experiment tmp directory exists: %w", err)
+	}
+
+	// minimega runs shell commands without a shell, so use `dd` rather than output
+	// redirection to copy the file starting at the given offset.
+	cmd := fmt.Sprintf("dd if=%s of=%s bs=1M iflag=skip_bytes skip=%d status=none", path, tmp, offset)
+
+	if err := mm.MeshShell(host, cmd); err != nil {
+		return 0, fmt.Errorf("copying capture file on host %s: %w", host, err)
+	}
+
+	headnode, _ := os.Hostname()
+
+	if err := file.CopyFile(fmt.Sprintf("%s/tmp/%s", expName, name), headnode, nil); err != nil {
+		return 0, fmt.Errorf("pulling capture file to headnode: %w", err)
+	}
+
+	f, err := os.Open(tmp)
+	if err != nil {
+		return 0, fmt.Errorf("opening capture file: %w", err)
+	}
+
+	defer f.Close()
+
+	n, err := io.Copy(w, f)
+	if err != nil {
+		return n, fmt.Errorf("writing capture file: %w", err)
+	}
+
+	return n, nil
+}
@@ -0,0 +1,426 @@
+package vm
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"phenix/api/experiment"
+	ifaces "phenix/types/interfaces"
+	"phenix/util/file"
+	"phenix/util/mm"
+)
+
+const (
+	// exitCodeMarker prefixes the line written to STDOUT containing the exit code
+	// of commands executed via C2, since miniccc does not report exit codes.
+	exitCodeMarker = "phenix-exit-code:"
+
+	// ExitCodeUnknown is the exit code reported when a command's exit code could
+	// not be determined (e.g., if the command timed out).
+	ExitCodeUnknown = -1
+
+	defaultExecTimeout = 5 * time.Minute
+
+	// Base directories miniccc writes files sent to it via `cc send` to.
+	minicccFilesLinux   = "/tmp/miniccc/files"
+	minicccFilesWindows = `C:\minimega\files`
+)
+
+var ErrNoVMsSelected = errors.New("no VMs selected")
+
+// ExecResult is the result of executing a command in a VM via C2.
+type ExecResult struct {
+	VM       string `json:"vm"`
+	Stdout   string `json:"stdout"`
+	Stderr   string `json:"stderr"`
+	ExitCode int    `json:"exitCode"`
+	Error    string `json:"error,omitempty"`
+}
+
+// Exec executes the given command via C2 in the VMs selected by the given
+// options, waiting for each VM to respond or for the timeout to expire. Commands
+// are run via PowerShell on Windows VMs and via `sh` everywhere else. It returns
+// the result for each VM, sorted by VM name. Errors executing the command in a
+// specific VM are included in the VM's result rather than returned.
+func Exec(ctx context.Context, expName, command string, opts ...ExecOption) ([]ExecResult, error) {
+	o := newExecOptions(opts...)
+
+	if command == "" {
+		return nil, errors.New("no command provided")
+	}
+
+	nodes, err := execNodes(expName, o)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, o.timeout)
+	defer cancel()
+
+	var (
+		wg      mm.StateGroup
+		mu      sync.Mutex
+		results = make(map[string]*ExecResult)
+	)
+
+	for _, node := range nodes {
+		host := node.General().Hostname()
+		result := &ExecResult{VM: host, ExitCode: ExitCodeUnknown} //nolint:exhaustruct // partial initialization
+
+		results[host] = result
+
+		cmd := &mm.C2ParallelCommand{ //nolint:exhaustruct // partial initialization
+			Wait: &wg,
+			Options: []mm.C2Option{
+				mm.C2NS(expName),
+				mm.C2VM(host),
+				mm.C2Command(exitCodeCommand(node.Hardware().OSType(), command)),
+				mm.C2Timeout(o.timeout),
+			},
+			Meta: map[string]any{"vm": host},
+			ExpectedStdout: func(resp string) error {
+				mu.Lock()
+				defer mu.Unlock()
+
+				result.Stdout, result.ExitCode = parseExitCode(resp)
+
+				return nil
+			},
+			ExpectedStderr: func(resp string) error {
+				mu.Lock()
+				defer mu.Unlock()
+
+				result.Stderr = resp
+
+				return nil
+			},
+		}
+
+		mm.ScheduleC2ParallelCommand(ctx, cmd)
+	}
+
+	wg.Wait()
+
+	for _, state := range wg.States {
+		if state.Err == nil {
+			continue
+		}
+
+		host, _ := state.Meta["vm"].(string)
+
+		if result, ok := results[host]; ok {
+			result.Error = state.Err.Error()
+		}
+	}
+
+	sorted := make([]ExecResult, 0, len(results))
+
+	for _, result := range results {
+		sorted = append(sorted, *result)
+	}
+
+	slices.SortFunc(sorted, func(a, b ExecResult) int { return strings.Compare(a.VM, b.VM) })
+
+	return sorted, nil
+}
+
+// CopyToVM copies the contents of the given reader to the given path in the
+// given VM via C2. The contents are staged in the minimega files directory,
+// sent to the VM, and then moved to the destination path.
+func CopyToVM(ctx context.Context, expName, vmName string, r io.Reader, dst string, opts ...ExecOption) error {
+	o := newExecOptions(opts...)
+
+	node, err := execNode(expName, vmName)
+	if err != nil {
+		return err
+	}
+
+	staged := fmt.Sprintf("%s/cp/%s-%d", expName, vmName, time.Now().UnixNano())
+	path := mm.GetMMFullPath(staged)
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+		return fmt.Errorf("creating staging directory: %w", err)
+	}
+
+	f, err := os.Create(path) //nolint:gosec // path built from experiment and VM names
+	if err != nil {
+		return fmt.Errorf("creating staging file: %w", err)
+	}
+
+	_, err = io.Copy(f, r)
+	_ = f.Close()
+
+	defer func() { _ = file.DeleteFile(staged) }()
+
+	if err != nil {
+		return fmt.Errorf("staging file for VM %s: %w", vmName, err)
+	}
+
+	osType := node.Hardware().OSType()
+	cmd := exitCodeCommand(osType, moveCommand(osType, staged, dst))
+
+	ctx, cancel := context.WithTimeout(ctx, o.timeout)
+	defer cancel()
+
+	id, err := mm.ExecC2Command(
+		mm.C2NS(expName),
+		mm.C2VM(vmName),
+		mm.C2SendFile(staged),
+		mm.C2Command(cmd),
+		mm.C2Context(ctx),
+		mm.C2Timeout(o.timeout),
+		mm.C2Wait(),
+	)
+	if err != nil {
+		return fmt.Errorf("copying file to VM %s: %w", vmName, err)
+	}
+
+	stdout, stderr, err := c2Output(expName, vmName, id)
+	if err != nil {
+		return err
+	}
+
+	if _, code := parseExitCode(stdout); code != 0 {
+		return fmt.Errorf("moving file to %s in VM %s failed (exit code %d): %s", dst, vmName, code, stderr)
+	}
+
+	return nil
+}
+
+// CopyFromVM returns the contents of the file at the given path in the given
+// VM. The file is transferred base64-encoded via the C2 command response, so
+// it's best suited for small to moderately sized files.
+func CopyFromVM(ctx context.Context, expName, vmName, src string, opts ...ExecOption) ([]byte, error) {
+	o := newExecOptions(opts...)
+
+	node, err := execNode(expName, vmName)
+	if err != nil {
+		return nil, err
+	}
+
+	osType := node.Hardware().OSType()
+	cmd := exitCodeCommand(osType, readCommand(osType, src))
+
+	ctx, cancel := context.WithTimeout(ctx, o.timeout)
+	defer cancel()
+
+	id, err := mm.ExecC2Command(
+		mm.C2NS(expName),
+		mm.C2VM(vmName),
+		mm.C2Command(cmd),
+		mm.C2Context(ctx),
+		mm.C2Timeout(o.timeout),
+		mm.C2Wait(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("copying file from VM %s: %w", vmName, err)
+	}
+
+	stdout, stderr, err := c2Output(expName, vmName, id)
+	if err != nil {
+		return nil, err
+	}
+
+	encoded, code := parseExitCode(stdout)
+	if code != 0 {
+		return nil, fmt.Errorf("reading %s in VM %s failed (exit code %d): %s", src, vmName, code, stderr)
+	}
+
+	// Strip any line wrapping added by the encoder.
+	encoded = strings.Join(strings.Fields(encoded), "")
+
+	data, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("decoding contents of %s from VM %s: %w", src, vmName, err)
+	}
+
+	return data, nil
+}
+
+// ExecTargets returns the names of the VMs in the given experiment that would
+// be selected by the given options when executing a command.
+func ExecTargets(expName string, opts ...ExecOption) ([]string, error) {
+	nodes, err := execNodes(expName, newExecOptions(opts...))
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(nodes))
+
+	for _, node := range nodes {
+		names = append(names, node.General().Hostname())
+	}
+
+	return names, nil
+}
+
+// execNodes returns the running, non-external experiment nodes selected by the
+// given options. VMs selected by label that aren't running are skipped, but it's
+// an error for a VM selected by name not to be running.
+func execNodes(expName string, o execOptions) ([]ifaces.NodeSpec, error) {
+	exp, err := experiment.Get(expName)
+	if err != nil {
+		return nil, fmt.Errorf("getting experiment %s: %w", expName, err)
+	}
+
+	if !exp.Running() {
+		return nil, fmt.Errorf("experiment %s is not running", expName)
+	}
+
+	running := make(map[string]bool)
+
+	for _, vm := range mm.GetVMInfo(mm.NS(expName)) {
+		running[vm.Name] = vm.Running
+	}
+
+	var nodes []ifaces.NodeSpec
+
+	for _, node := range exp.Spec.Topology().Nodes() {
+		if node.External() {
+			continue
+		}
+
+		host := node.General().Hostname()
+
+		if len(o.vms) > 0 && !slices.Contains(o.vms, host) {
+			continue
+		}
+
+		selected, err := selectedByLabels(node.Labels(), o.labels)
+		if err != nil {
+			return nil, err
+		}
+
+		if !selected {
+			continue
+		}
+
+		if !running[host] {
+			if len(o.vms) > 0 {
+				return nil, fmt.Errorf("VM %s is not running", host)
+			}
+
+			continue
+		}
+
+		nodes = append(nodes, node)
+	}
+
+	if len(nodes) == 0 {
+		return nil, fmt.Errorf("%w in experiment %s", ErrNoVMsSelected, expName)
+	}
+
+	return nodes, nil
+}
+
+func execNode(expName, vmName string) (ifaces.NodeSpec, error) { //nolint:ireturn // interface
+	nodes, err := execNodes(expName, newExecOptions(ExecWithVMs(vmName)))
+	if err != nil {
+		return nil, fmt.Errorf("getting VM %s: %w", vmName, err)
+	}
+
+	return nodes[0], nil
+}
+
+func c2Output(ns, vm, id string) (string, string, error) {
+	stdout, err := mm.GetC2Response(
+		mm.C2NS(ns), mm.C2VM(vm), mm.C2CommandID(id), mm.C2ResponseTypeStdout(),
+	)
+	if err != nil {
+		return "", "", fmt.Errorf("getting STDOUT response from VM %s: %w", vm, err)
+	}
+
+	stderr, err := mm.GetC2Response(
+		mm.C2NS(ns), mm.C2VM(vm), mm.C2CommandID(id), mm.C2ResponseTypeStderr(),
+	)
+	if err != nil {
+		return "", "", fmt.Errorf("getting STDERR response from VM %s: %w", vm, err)
+	}
+
+	return stdout, stderr, nil
+}
+
+// exitCodeCommand wraps the given command so its exit code is written to
+// STDOUT, prefixed with exitCodeMarker, once the command completes.
+func exitCodeCommand(osType, command string) string {
+	if strings.EqualFold(osType, "windows") {
+		return mm.PowerShellCommand(fmt.Sprintf(
+			`& { %s }; '%s' + $(if ($?) { 0 } elseif ($LASTEXITCODE) { $LASTEXITCODE } else { 1 })`,
+			command,
+			exitCodeMarker,
+		))
+	}
+
+	command = strings.ReplaceAll(command, "'", `'"'"'`)
+
+	// The command is run in a subshell so the exit code is still written if the
+	// command calls `exit`.
+	return fmt.Sprintf(`sh -c '(%s); echo "%s$?"'`, command, exitCodeMarker)
+}
+
+// parseExitCode removes the exit code line written by exitCodeCommand from the
+// given STDOUT, returning the remaining output and the exit code. The exit code
+// is ExitCodeUnknown if no exit code line is present.
+func parseExitCode(stdout string) (string, int) {
+	idx := strings.LastIndex(stdout, exitCodeMarker)
+	if idx < 0 {
+		return stdout, ExitCodeUnknown
+	}
+
+	line := stdout[idx+len(exitCodeMarker):]
+
+	if end := strings.IndexAny(line, "\r\n"); end >= 0 {
+		line = line[:end]
+	}
+
+	code, err := strconv.Atoi(strings.TrimSpace(line))
+	if err != nil {
+		code = ExitCodeUnknown
+	}
+
+	return strings.TrimSuffix(stdout[:idx], "\n"), code
+}
+
+// moveCommand returns the command to move a file sent to a VM via `cc send`
+// from the miniccc files directory to the given destination.
+func moveCommand(osType, staged, dst string) string {
+	if strings.EqualFold(osType, "windows") {
+		src := minicccFilesWindows + `\` + strings.ReplaceAll(staged, "/", `\`)
+
+		return fmt.Sprintf(
+			"Move-Item -Force -LiteralPath '%s' -Destination '%s'",
+			src,
+			strings.ReplaceAll(dst, "'", "''"),
+		)
+	}
+
+	return fmt.Sprintf("mv -f -- %s %s", shellQuote(minicccFilesLinux+"/"+staged), shellQuote(dst))
+}
+
+// readCommand returns the command to write the base64-encoded contents of the
+// given file to STDOUT.
+func readCommand(osType, src string) string {
+	if strings.EqualFold(osType, "windows") {
+		return fmt.Sprintf(
+			"[Convert]::ToBase64String([IO.File]::ReadAllBytes('%s'))",
+			strings.ReplaceAll(src, "'", "''"),
+		)
+	}
+
+	return "base64 -- " + shellQuote(src)
+}
+
+// shellQuote single quotes the given string for use as a `sh` argument.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'"'"'`) + "'"
+}
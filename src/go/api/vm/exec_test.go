@@ -0,0 +1,65 @@
+//nolint:testpackage // testing internals
+package vm
+
+import (
+	"os/exec"
+	"testing"
+
+	"phenix/util/mm"
+)
+
+func TestParseExitCode(t *testing.T) {
+	out, code := parseExitCode("hello\nworld\nphenix-exit-code:3\n")
+	if out != "hello\nworld" || code != 3 {
+		t.Fatalf("unexpected output %q and exit code %d", out, code)
+	}
+
+	out, code = parseExitCode("partial output")
+	if out != "partial output" || code != ExitCodeUnknown {
+		t.Fatalf("expected unknown exit code without marker, got %q and %d", out, code)
+	}
+
+	if _, code := parseExitCode("phenix-exit-code:0\r\n"); code != 0 {
+		t.Fatalf("expected exit code 0 with CRLF line ending, got %d", code)
+	}
+}
+
+func TestExitCodeCommand(t *testing.T) {
+	sh, err := exec.LookPath("sh")
+	if err != nil {
+		t.Skip("sh not available")
+	}
+
+	cmd := exitCodeCommand("linux", `echo 'quoted output'; exit 2`)
+
+	// The wrapped command is itself run via `sh -c`, so run it the same way
+	// miniccc would.
+	out, err := exec.Command(sh, "-c", cmd).Output() //nolint:gosec // test command
+	if err != nil {
+		t.Fatalf("running wrapped command: %v", err)
+	}
+
+	stdout, code := parseExitCode(string(out))
+	if stdout != "quoted output" || code != 2 {
+		t.Fatalf("unexpected output %q and exit code %d", stdout, code)
+	}
+
+	cmd = exitCodeCommand("Windows", `Write-Output "it's done"`)
+
+	want := mm.PowerShellCommand(
+		`& { Write-Output "it's done" }; '` + exitCodeMarker + `' + $(if ($?) { 0 } elseif ($LASTEXITCODE) { $LASTEXITCODE } else { 1 })`,
+	)
+
+	if cmd != want {
+		t.Fatalf("expected %s, got %s", want, cmd)
+	}
+}
+
+func TestMoveCommandQuoting(t *testing.T) {
+	cmd := moveCommand("linux", "exp/cp/vm-1", "/tmp/it's here")
+
+	want := `mv -f -- '/tmp/miniccc/files/exp/cp/vm-1' '/tmp/it'"'"'s here'`
+	if cmd != want {
+		t.Fatalf("expected %s, got %s", want, cmd)
+	}
+}
@@ -0,0 +1,80 @@
+package vm
+
+import (
+	"errors"
+
+	"phenix/api/experiment"
+	ifaces "phenix/types/interfaces"
+	"phenix/util/mm"
+)
+
+// Impair applies the given impairment to the given interface of the given VM
+// in the given running experiment, replacing any impairment already applied to
+// the interface. Impairment is applied to the VM's tap on the cluster host the
+// VM is running on, so it affects traffic sent to the VM via the interface.
+func Impair(expName, vmName string, iface int, imp mm.Impairment) error {
+	if expName == "" {
+		return errors.New("no experiment name provided")
+	}
+
+	if err := mm.ImpairVMInterface(expName, vmName, iface, imp); err != nil {
+		return err
+	}
+
+	experiment.RecordEvent(
+		expName, vmName, "VM interface impaired",
+		map[string]any{"interface": iface, "impairment": imp.String()},
+	)
+
+	return nil
+}
+
+// ClearImpairment removes any impairment applied to the given interface of the
+// given VM in the given running experiment.
+func ClearImpairment(expName, vmName string, iface int) error {
+	if expName == "" {
+		return errors.New("no experiment name provided")
+	}
+
+	cleared, err := mm.ClearVMInterfaceImpairment(expName, vmName, iface)
+	if err != nil {
+		return err
+	}
+
+	if cleared {
+		experiment.RecordEvent(
+			expName, vmName, "VM interface impairment cleared",
+			map[string]any{"interface": iface},
+		)
+	}
+
+	return nil
+}
+
+// Impairments returns the impairment currently applied to each interface of
+// each running VM in the given experiment, keyed by VM name and then interface
+// index. Interfaces without impairment are not included.
+func Impairments(expName string) (map[string]map[int]mm.Impairment, error) {
+	return mm.GetVMImpairments(expName)
+}
+
+// linkProperties returns the link properties configured in the topology for
+// each interface of the given node, keyed by interface index.
+func linkProperties(node ifaces.NodeSpec) map[int]string {
+	var links map[int]string
+
+	for idx, iface := range node.Network().Interfaces() {
+		link := iface.Link()
+		if link == nil {
+			continue
+		}
+
+		if links == nil {
+			links = make(map[int]string)
+		}
+
+		links[idx] = experiment.LinkImpairment(link).String()
+	}
+
+	return links
+}
@@ -0,0 +1,56 @@
+package vm
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+
+	"phenix/api/experiment"
+	"phenix/util/mm"
+)
+
+// SendKeys types text and sends key chords (e.g., `ctrl-alt-delete`) to the
+// given VM in the given running experiment, as configured by the given
+// options. Text is typed before any key chords are sent. Keys are sent as
+// hardware key presses via QMP, so they work on BIOS screens and installers in
+// VMs without miniccc.
+func SendKeys(expName, vmName string, opts ...SendKeysOption) error {
+	o := newSendKeysOptions(opts...)
+
+	if expName == "" {
+		return errors.New("no experiment name provided")
+	}
+
+	if vmName == "" {
+		return errors.New("no VM name provided")
+	}
+
+	text, err := mm.TextKeyChords(o.text)
+	if err != nil {
+		return err
+	}
+
+	keys, err := mm.ParseKeyChords(o.keys)
+	if err != nil {
+		return err
+	}
+
+	chords := slices.Concat(text, keys)
+
+	if len(chords) == 0 {
+		return fmt.Errorf("%w: no text or keys provided", mm.ErrInvalidKeys)
+	}
+
+	if !experiment.Running(expName) {
+		return fmt.Errorf("experiment %s is not running", expName)
+	}
+
+	if err := mm.SendVMKeys(expName, vmName, chords, o.delay); err != nil {
+		return err
+	}
+
+	// Don't record the text typed since it may include passwords.
+	experiment.RecordEvent(expName, vmName, "VM keys sent", map[string]any{"keys": o.keys, "characters": len(text)})
+
+	return nil
+}
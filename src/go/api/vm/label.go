@@ -0,0 +1,63 @@
+package vm
+
+import (
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+)
+
+// MatchesAnyLabel returns true if any of the given VM labels (or tags) match
+// any of the given labels. Labels are matched case-insensitively and may be
+// glob patterns. The label "all" matches every VM, and no labels match no VMs.
+func MatchesAnyLabel(tags map[string]string, labels []string) (bool, error) {
+	if len(labels) == 0 {
+		return false, nil
+	}
+
+	if slices.ContainsFunc(labels, IsAllLabel) {
+		return true, nil
+	}
+
+	for label := range tags {
+		for _, expected := range labels {
+			matched, err := labelMatches(label, expected)
+			if err != nil {
+				return false, fmt.Errorf("invalid label %q: %w", expected, err)
+			}
+
+			if matched {
+				return true, nil
+			}
+		}
+	}
+
+	return false, nil
+}
+
+// IsAllLabel returns true if the given label selects every VM.
+func IsAllLabel(label string) bool {
+	return strings.EqualFold(label, "all")
+}
+
+func labelMatches(label, expected string) (bool, error) {
+	if strings.EqualFold(label, expected) {
+		return true, nil
+	}
+
+	if !strings.ContainsAny(expected, "*?[") {
+		return false, nil
+	}
+
+	return path.Match(strings.ToLower(expected), strings.ToLower(label))
+}
+
+// selectedByLabels returns true if the given VM labels (or tags) match any of
+// the given labels, or if no labels are given to select VMs by.
+func selectedByLabels(tags map[string]string, labels []string) (bool, error) {
+	if len(labels) == 0 {
+		return true, nil
+	}
+
+	return MatchesAnyLabel(tags, labels)
+}
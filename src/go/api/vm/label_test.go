@@ -0,0 +1,269 @@
+//nolint:testpackage // testing internals
+package vm
+
+import "testing"
+
+// ---------------------------------------------------------------------------
+// labelMatches
+// ---------------------------------------------------------------------------
+
+func TestLabelMatches_ExactMatch(t *testing.T) {
+	matched, err := labelMatches("my-app", "my-app")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !matched {
+		t.Fatal("expected exact match")
+	}
+}
+
+func TestLabelMatches_CaseInsensitiveExact(t *testing.T) {
+	matched, err := labelMatches("My-App", "MY-APP")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !matched {
+		t.Fatal("expected case-insensitive exact match")
+	}
+}
+
+func TestLabelMatches_NoMatch(t *testing.T) {
+	matched, err := labelMatches("my-app", "other-label")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if matched {
+		t.Fatal("expected no match")
+	}
+}
+
+func TestLabelMatches_GlobSuffixWildcard(t *testing.T) {
+	matched, err := labelMatches("app-controller", "app-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !matched {
+		t.Fatal("expected suffix wildcard glob match")
+	}
+}
+
+func TestLabelMatches_GlobPrefixWildcard(t *testing.T) {
+	matched, err := labelMatches("sceptre-app", "*-app")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !matched {
+		t.Fatal("expected prefix wildcard glob match")
+	}
+}
+
+func TestLabelMatches_GlobMiddleWildcard(t *testing.T) {
+	matched, err := labelMatches("sceptre-ot-app", "sceptre-*-app")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !matched {
+		t.Fatal("expected middle wildcard glob match")
+	}
+}
+
+func TestLabelMatches_GlobNoMatch(t *testing.T) {
+	matched, err := labelMatches("my-label", "app-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if matched {
+		t.Fatal("expected no glob match")
+	}
+}
+
+func TestLabelMatches_GlobCaseInsensitive(t *testing.T) {
+	matched, err := labelMatches("App-Controller", "app-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !matched {
+		t.Fatal("expected case-insensitive glob match")
+	}
+}
+
+func TestLabelMatches_SingleCharWildcard(t *testing.T) {
+	matched, err := labelMatches("vm1", "vm?")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !matched {
+		t.Fatal("expected single-char wildcard match")
+	}
+}
+
+func TestLabelMatches_InvalidGlobReturnsError(t *testing.T) {
+	// path.Match returns an error for malformed bracket expressions
+	_, err := labelMatches("any-label", "[invalid")
+	if err == nil {
+		t.Fatal("expected error for invalid glob pattern")
+	}
+}
+
+// ---------------------------------------------------------------------------
+// MatchesAnyLabel
+// ---------------------------------------------------------------------------
+
+func TestMatchesAnyLabel_EmptyFilters(t *testing.T) {
+	tags := map[string]string{"app": "true"}
+
+	matched, err := MatchesAnyLabel(tags, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if matched {
+		t.Fatal("expected no match for empty filters")
+	}
+}
+
+func TestMatchesAnyLabel_AllKeyword(t *testing.T) {
+	tags := map[string]string{"app": "true"}
+
+	matched, err := MatchesAnyLabel(tags, []string{"all"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !matched {
+		t.Fatal("expected 'all' to match any VM")
+	}
+}
+
+func TestMatchesAnyLabel_AllKeywordCaseInsensitive(t *testing.T) {
+	tags := map[string]string{}
+
+	matched, err := MatchesAnyLabel(tags, []string{"ALL"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !matched {
+		t.Fatal("expected 'ALL' to match any VM")
+	}
+}
+
+func TestMatchesAnyLabel_AllKeywordMatchesVMWithNoLabels(t *testing.T) {
+	tags := map[string]string{}
+
+	matched, err := MatchesAnyLabel(tags, []string{"all"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !matched {
+		t.Fatal("expected 'all' to match VM even with no labels")
+	}
+}
+
+func TestMatchesAnyLabel_ExactLabelMatch(t *testing.T) {
+	tags := map[string]string{"sceptre-app": "true"}
+
+	matched, err := MatchesAnyLabel(tags, []string{"sceptre-app"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !matched {
+		t.Fatal("expected exact label to match")
+	}
+}
+
+func TestMatchesAnyLabel_NoMatchingLabel(t *testing.T) {
+	tags := map[string]string{"sceptre-app": "true"}
+
+	matched, err := MatchesAnyLabel(tags, []string{"other-label"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if matched {
+		t.Fatal("expected no match when label is absent")
+	}
+}
+
+func TestMatchesAnyLabel_MultipleFiltersOrSemantics(t *testing.T) {
+	tags := map[string]string{"label-b": "true"}
+
+	// VM has label-b; filter includes both label-a and label-b, should match.
+	matched, err := MatchesAnyLabel(tags, []string{"label-a", "label-b"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !matched {
+		t.Fatal("expected OR-semantics match when any filter matches")
+	}
+}
+
+func TestMatchesAnyLabel_NoLabelsOnVM(t *testing.T) {
+	var tags map[string]string
+
+	matched, err := MatchesAnyLabel(tags, []string{"some-label"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if matched {
+		t.Fatal("expected no match for VM with no labels")
+	}
+}
+
+func TestMatchesAnyLabel_GlobMatchesLabel(t *testing.T) {
+	tags := map[string]string{"sceptre-ot-sim": "true"}
+
+	matched, err := MatchesAnyLabel(tags, []string{"sceptre-*"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !matched {
+		t.Fatal("expected glob to match label")
+	}
+}
+
+func TestMatchesAnyLabel_GlobMatchesOneOfMultipleLabels(t *testing.T) {
+	tags := map[string]string{
+		"unrelated":   "true",
+		"sceptre-app": "true",
+	}
+
+	matched, err := MatchesAnyLabel(tags, []string{"sceptre-*"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !matched {
+		t.Fatal("expected glob to match when at least one label matches")
+	}
+}
+
+func TestSelectedByLabels(t *testing.T) {
+	tags := map[string]string{"role-web": "true"}
+
+	if selected, _ := selectedByLabels(tags, nil); !selected {
+		t.Fatal("expected no labels to select every VM")
+	}
+
+	if selected, _ := selectedByLabels(tags, []string{"role-*"}); !selected {
+		t.Fatal("expected matching label to select VM")
+	}
+
+	if selected, _ := selectedByLabels(tags, []string{"role-db"}); selected {
+		t.Fatal("expected mismatched label not to select VM")
+	}
+}
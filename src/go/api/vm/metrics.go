@@ -0,0 +1,158 @@
+package vm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"phenix/api/experiment"
+	"phenix/util/mm"
+	"phenix/util/plog"
+)
+
+const (
+	DefaultMetricsInterval  = 10 * time.Second
+	DefaultMetricsRetention = time.Hour
+)
+
+var (
+	// Rolling history of VM metrics, keyed by experiment name and then VM name.
+	metricsHistory   = make(map[string]map[string][]mm.VMMetrics) //nolint:gochecknoglobals // package level history
+	metricsHistoryMu sync.RWMutex                                 //nolint:gochecknoglobals // package level lock
+)
+
+// CurrentMetrics returns the current resource usage of each VM in the given
+// running experiment.
+func CurrentMetrics(expName string) ([]mm.VMMetrics, error) {
+	if expName == "" {
+		return nil, errors.New("no experiment name provided")
+	}
+
+	if !experiment.Running(expName) {
+		return nil, fmt.Errorf("experiment %s is not running", expName)
+	}
+
+	metrics, err := mm.GetVMMetrics(expName)
+	if err != nil {
+		return nil, fmt.Errorf("getting VM metrics for experiment %s: %w", expName, err)
+	}
+
+	return metrics, nil
+}
+
+// MetricsHistory returns the metrics collected for the given VM in the given
+// experiment, oldest first. If `since` is not the zero time, only metrics
+// collected after it are returned. Metrics are only collected while
+// CollectMetrics is running (e.g., as part of the UI server).
+func MetricsHistory(expName, vmName string, since time.Time) []mm.VMMetrics {
+	metricsHistoryMu.RLock()
+	defer metricsHistoryMu.RUnlock()
+
+	var history []mm.VMMetrics
+
+	for _, m := range metricsHistory[expName][vmName] {
+		if m.Timestamp.After(since) {
+			history = append(history, m)
+		}
+	}
+
+	return history
+}
+
+// CollectMetrics collects the resource usage of each VM in each running
+// experiment at the given interval until the given context is canceled,
+// keeping the given duration of history for each VM. The given callback, if
+// not nil, is called with the metrics collected for each experiment. History
+// for experiments that are no longer running is dropped.
+func CollectMetrics(
+	ctx context.Context,
+	interval, retention time.Duration,
+	cb func(string, []mm.VMMetrics),
+) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			collectMetrics(retention, cb)
+		}
+	}
+}
+
+func collectMetrics(retention time.Duration, cb func(string, []mm.VMMetrics)) {
+	exps, err := experiment.List()
+	if err != nil {
+		plog.Error(plog.TypeSystem, "listing experiments for VM metrics", "err", err)
+
+		return
+	}
+
+	running := make(map[string][]mm.VMMetrics)
+
+	for _, exp := range exps {
+		if !exp.Running() {
+			continue
+		}
+
+		metrics, err := mm.GetVMMetrics(exp.Metadata.Name)
+		if err != nil {
+			plog.Error(plog.TypeSystem, "collecting VM metrics", "exp", exp.Metadata.Name, "err", err)
+
+			continue
+		}
+
+		running[exp.Metadata.Name] = metrics
+	}
+
+	recordMetrics(running, time.Now().Add(-retention))
+
+	if cb != nil {
+		for name, metrics := range running {
+			cb(name, metrics)
+		}
+	}
+}
+
+// recordMetrics adds the given metrics, keyed by experiment name, to the
+// history and drops metrics collected before the given cutoff.
+func recordMetrics(running map[string][]mm.VMMetrics, cutoff time.Time) {
+	metricsHistoryMu.Lock()
+	defer metricsHistoryMu.Unlock()
+
+	for name := range metricsHistory {
+		if _, ok := running[name]; !ok {
+			delete(metricsHistory, name)
+		}
+	}
+
+	for name, metrics := range running {
+		history, ok := metricsHistory[name]
+		if !ok {
+			history = make(map[string][]mm.VMMetrics)
+			metricsHistory[name] = history
+		}
+
+		for _, m := range metrics {
+			history[m.VM] = append(history[m.VM], m)
+		}
+
+		for vm, samples := range history {
+			var keep int
+
+			for keep < len(samples) && samples[keep].Timestamp.Before(cutoff) {
+				keep++
+			}
+
+			if keep == len(samples) {
+				delete(history, vm)
+			} else {
+				history[vm] = samples[keep:]
+			}
+		}
+	}
+}
@@ -0,0 +1,44 @@
+//nolint:testpackage // testing internals
+package vm
+
+import (
+	"testing"
+	"time"
+
+	"phenix/util/mm"
+)
+
+func TestRecordMetrics(t *testing.T) {
+	t.Cleanup(func() { metricsHistory = make(map[string]map[string][]mm.VMMetrics) })
+
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	sample := func(vm string, offset time.Duration) mm.VMMetrics {
+		return mm.VMMetrics{VM: vm, Timestamp: base.Add(offset)} //nolint:exhaustruct // partial initialization
+	}
+
+	recordMetrics(map[string][]mm.VMMetrics{
+		"foo": {sample("a", 0), sample("b", 0)},
+		"bar": {sample("c", 0)},
+	}, base.Add(-time.Minute))
+
+	recordMetrics(map[string][]mm.VMMetrics{
+		"foo": {sample("a", time.Minute)},
+	}, base.Add(time.Second))
+
+	if _, ok := metricsHistory["bar"]; ok {
+		t.Fatal("expected history for experiment no longer running to be dropped")
+	}
+
+	if history := MetricsHistory("foo", "a", time.Time{}); len(history) != 1 || !history[0].Timestamp.Equal(base.Add(time.Minute)) {
+		t.Fatalf("unexpected history for VM a: %+v", history)
+	}
+
+	if history := MetricsHistory("foo", "b", time.Time{}); len(history) != 0 {
+		t.Fatalf("expected expired history for VM b to be dropped, got %+v", history)
+	}
+
+	if history := MetricsHistory("foo", "a", base.Add(time.Minute)); len(history) != 0 {
+		t.Fatalf("expected no history after since, got %+v", history)
+	}
+}
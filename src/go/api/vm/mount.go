@@ -0,0 +1,52 @@
+package vm
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+
+	"phenix/util/common"
+	"phenix/util/mm"
+	"phenix/util/mm/mmcli"
+)
+
+var ErrVMRunning = errors.New("VM is running")
+
+// OfflineDisk returns the path on the headnode of the disk to mount when
+// inspecting the given VM in the given experiment offline. The VM must not be
+// running. For VMs in a running experiment that use a snapshot, this is the
+// VM's snapshot in the minimega instance directory, which must be on the
+// headnode. Otherwise, it's the VM's disk image.
+func OfflineDisk(expName, vmName string) (string, error) {
+	vm, err := Get(expName, vmName)
+	if err != nil {
+		return "", err
+	}
+
+	if vm.Running {
+		return "", fmt.Errorf("%w: shut down VM %s or mount it online via miniccc", ErrVMRunning, vmName)
+	}
+
+	if vm.State == "" || !vm.Snapshot {
+		return vm.Disk, nil
+	}
+
+	cmd := mmcli.NewNamespacedCommand(expName)
+	cmd.Command = vmInfoCmd
+	cmd.Columns = []string{"host", "id"}
+	cmd.Filters = []string{"name=" + vmName}
+
+	status := mmcli.RunTabular(cmd)
+	if len(status) == 0 {
+		return vm.Disk, nil
+	}
+
+	if !mm.IsHeadnode(status[0]["host"]) {
+		return "", fmt.Errorf(
+			"snapshot for VM %s is on cluster host %s, but only disks on the headnode can be mounted offline",
+			vmName, status[0]["host"],
+		)
+	}
+
+	return filepath.Join(common.MinimegaBase, status[0]["id"], "disk-0.qcow2"), nil
+}
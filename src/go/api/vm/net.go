@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 
+	"phenix/api/experiment"
 	"phenix/util/mm"
 )
 
@@ -29,6 +30,11 @@ func Connect(expName, vmName string, iface int, vlan string) error {
 		return fmt.Errorf("connecting VM interface to VLAN: %w", err)
 	}
 
+	experiment.RecordEvent(
+		expName, vmName, "VM interface connected",
+		map[string]any{"interface": iface, "vlan": vlan},
+	)
+
 	return nil
 }
 
@@ -49,5 +55,10 @@ func Disconnect(expName, vmName string, iface int) error {
 		return fmt.Errorf("disconnecting VM interface: %w", err)
 	}
 
+	experiment.RecordEvent(
+		expName, vmName, "VM interface disconnected",
+		map[string]any{"interface": iface},
+	)
+
 	return nil
 }
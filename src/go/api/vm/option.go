@@ -1,5 +1,11 @@
 package vm
 
+import (
+	"time"
+
+	"phenix/util/mm"
+)
+
 type UpdateOption func(*updateOptions)
 
 type iface struct {
@@ -164,3 +170,324 @@ func InjectPartition(p int) RedeployOption {
 		o.part = p
 	}
 }
+
+type ExecOption func(*execOptions)
+
+type execOptions struct {
+	vms     []string
+	labels  []string
+	timeout time.Duration
+}
+
+func newExecOptions(opts ...ExecOption) execOptions {
+	o := execOptions{timeout: defaultExecTimeout} //nolint:exhaustruct // partial initialization
+
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	return o
+}
+
+// ExecWithVMs limits execution to the given VMs. All VMs in the experiment are
+// selected by default.
+func ExecWithVMs(v ...string) ExecOption {
+	return func(o *execOptions) {
+		o.vms = v
+	}
+}
+
+// ExecWithLabels limits execution to VMs matching any of the given labels (see
+// MatchesAnyLabel).
+func ExecWithLabels(l ...string) ExecOption {
+	return func(o *execOptions) {
+		o.labels = l
+	}
+}
+
+func ExecWithTimeout(t time.Duration) ExecOption {
+	return func(o *execOptions) {
+		if t > 0 {
+			o.timeout = t
+		}
+	}
+}
+
+// BatchOption is a function that configures options for applying an action to
+// VMs in a batch. It is used in `vm.Batch`.
+type BatchOption func(*batchOptions)
+
+type batchOptions struct {
+	all         bool
+	vms         []string
+	labels      []string
+	parallelism int
+
+	tags       map[string]string
+	appendTags bool
+	snapshot   string
+	creator    string
+	inject     bool
+
+	lock     func(string, string) (func(), error)
+	callback func(BatchResult)
+}
+
+func newBatchOptions(opts ...BatchOption) batchOptions {
+	o := batchOptions{parallelism: DefaultBatchParallelism} //nolint:exhaustruct // partial initialization
+
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	return o
+}
+
+// BatchWithVMs selects the VMs with the given names.
+func BatchWithVMs(v ...string) BatchOption {
+	return func(o *batchOptions) {
+		o.vms = v
+	}
+}
+
+// BatchWithAllVMs selects all the VMs in the experiment.
+func BatchWithAllVMs() BatchOption {
+	return func(o *batchOptions) {
+		o.all = true
+	}
+}
+
+// BatchWithLabels selects the VMs matching any of the given labels (see
+// MatchesAnyLabel).
+func BatchWithLabels(l ...string) BatchOption {
+	return func(o *batchOptions) {
+		o.labels = l
+	}
+}
+
+// BatchWithParallelism sets the maximum number of VMs the action is applied to
+// concurrently.
+func BatchWithParallelism(p int) BatchOption {
+	return func(o *batchOptions) {
+		if p > 0 {
+			o.parallelism = p
+		}
+	}
+}
+
+// BatchWithTags sets the tags to apply to VMs for the set-tags action.
+func BatchWithTags(t map[string]string, appendTags bool) BatchOption {
+	return func(o *batchOptions) {
+		o.tags = t
+		o.appendTags = appendTags
+	}
+}
+
+// BatchWithSnapshotName sets the name of the snapshots created for the
+// snapshot action. A timestamp is used by default.
+func BatchWithSnapshotName(n string) BatchOption {
+	return func(o *batchOptions) {
+		o.snapshot = n
+	}
+}
+
+// BatchWithCreator sets the user recorded in the snapshot catalog as the
+// creator of snapshots created by the snapshot action.
+func BatchWithCreator(c string) BatchOption {
+	return func(o *batchOptions) {
+		o.creator = c
+	}
+}
+
+// BatchWithInjects causes disk injections to be replicated for the redeploy
+// action.
+func BatchWithInjects(i bool) BatchOption {
+	return func(o *batchOptions) {
+		o.inject = i
+	}
+}
+
+// BatchWithLock sets the function called to lock each VM before the action is
+// applied to it. The function is passed the action and VM name and returns a
+// function to unlock the VM.
+func BatchWithLock(l func(string, string) (func(), error)) BatchOption {
+	return func(o *batchOptions) {
+		o.lock = l
+	}
+}
+
+// BatchWithCallback sets the function called with the result of applying the
+// action to each VM as soon as it's available.
+func BatchWithCallback(c func(BatchResult)) BatchOption {
+	return func(o *batchOptions) {
+		o.callback = c
+	}
+}
+
+// SnapshotOption is a function that configures options for a VM snapshot. It
+// is used in `vm.Snapshot`.
+type SnapshotOption func(*snapshotOptions)
+
+type snapshotOptions struct {
+	description string
+	creator     string
+}
+
+func newSnapshotOptions(opts ...SnapshotOption) snapshotOptions {
+	o := snapshotOptions{} //nolint:exhaustruct // partial initialization
+
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	return o
+}
+
+// SnapshotWithDescription sets the description recorded in the snapshot
+// catalog for the snapshot.
+func SnapshotWithDescription(d string) SnapshotOption {
+	return func(o *snapshotOptions) {
+		o.description = d
+	}
+}
+
+// SnapshotWithCreator sets the user recorded in the snapshot catalog as the
+// creator of the snapshot.
+func SnapshotWithCreator(c string) SnapshotOption {
+	return func(o *snapshotOptions) {
+		o.creator = c
+	}
+}
+
+// PruneOption is a function that configures options for pruning VM snapshots.
+// It is used in `vm.PruneSnapshots`.
+type PruneOption func(*pruneOptions)
+
+type pruneOptions struct {
+	keep      int
+	olderThan time.Duration
+	dryRun    bool
+	filter    func(SnapshotInfo) bool
+}
+
+func newPruneOptions(opts ...PruneOption) pruneOptions {
+	o := pruneOptions{keep: -1} //nolint:exhaustruct // partial initialization
+
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	return o
+}
+
+// PruneKeep prunes all but the given number of most recent snapshots for each
+// VM.
+func PruneKeep(k int) PruneOption {
+	return func(o *pruneOptions) {
+		o.keep = k
+	}
+}
+
+// PruneOlderThan prunes snapshots created more than the given duration ago.
+func PruneOlderThan(d time.Duration) PruneOption {
+	return func(o *pruneOptions) {
+		o.olderThan = d
+	}
+}
+
+// PruneDryRun reports the snapshots that would be pruned without deleting
+// them.
+func PruneDryRun(d bool) PruneOption {
+	return func(o *pruneOptions) {
+		o.dryRun = d
+	}
+}
+
+// PruneFilter limits pruning to the snapshots the given function returns true
+// for. Snapshots filtered out are ignored entirely, including when counting the
+// number of snapshots to keep.
+func PruneFilter(f func(SnapshotInfo) bool) PruneOption {
+	return func(o *pruneOptions) {
+		o.filter = f
+	}
+}
+
+// SendKeysOption is a function that configures options for sending keys to a
+// VM. It is used in `vm.SendKeys`.
+type SendKeysOption func(*sendKeysOptions)
+
+type sendKeysOptions struct {
+	text  string
+	keys  string
+	delay time.Duration
+}
+
+func newSendKeysOptions(opts ...SendKeysOption) sendKeysOptions {
+	o := sendKeysOptions{delay: mm.DefaultKeyDelay} //nolint:exhaustruct // partial initialization
+
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	return o
+}
+
+// SendKeysWithText types the given text (US keyboard layout) in the VM.
+func SendKeysWithText(t string) SendKeysOption {
+	return func(o *sendKeysOptions) {
+		o.text = t
+	}
+}
+
+// SendKeysWithChords sends the given space-separated key chords (e.g.,
+// `ctrl-alt-delete ret`) to the VM after any text is typed.
+func SendKeysWithChords(k string) SendKeysOption {
+	return func(o *sendKeysOptions) {
+		o.keys = k
+	}
+}
+
+// SendKeysWithDelay sets the delay between each key chord sent to the VM.
+func SendKeysWithDelay(d time.Duration) SendKeysOption {
+	return func(o *sendKeysOptions) {
+		if d > 0 {
+			o.delay = d
+		}
+	}
+}
+
+// SerialOption is a function that configures options for a VM serial console.
+// It is used in `vm.OpenSerialConsole`.
+type SerialOption func(*serialOptions)
+
+type serialOptions struct {
+	scrollback int
+	log        bool
+}
+
+func newSerialOptions(opts ...SerialOption) serialOptions {
+	o := serialOptions{scrollback: DefaultSerialScrollback, log: false}
+
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	return o
+}
+
+// SerialWithScrollback sets the number of bytes of output kept for the console.
+func SerialWithScrollback(s int) SerialOption {
+	return func(o *serialOptions) {
+		if s > 0 {
+			o.scrollback = s
+		}
+	}
+}
+
+// SerialWithLog logs output from the console to the experiment's files
+// directory.
+func SerialWithLog(l bool) SerialOption {
+	return func(o *serialOptions) {
+		o.log = l
+	}
+}
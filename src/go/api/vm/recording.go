@@ -0,0 +1,234 @@
+package vm
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"phenix/api/experiment"
+	"phenix/util/common"
+	"phenix/util/rfb"
+)
+
+// vncRecordingsDir is the directory, relative to an experiment's files
+// directory, VNC session recordings are written to.
+const vncRecordingsDir = "vnc"
+
+var ErrVNCRecordingNotFound = errors.New("VNC recording not found")
+
+// VNCRecording describes a recorded VNC session. Recordings are FBS files
+// containing the data sent by the VM's VNC server, which is enough to play
+// back the session in a VNC client.
+type VNCRecording struct {
+	ID    string    `json:"id"`
+	VM    string    `json:"vm"`
+	User  string    `json:"user"`
+	Start time.Time `json:"start"`
+	// End is zero while the session is still being recorded.
+	End time.Time `json:"end,omitzero"`
+	// File is the recording's path relative to the experiment's files
+	// directory, so it can be downloaded like any other experiment file.
+	File string `json:"file"`
+	Size int64  `json:"size"`
+}
+
+// VNCRecorder records a VNC session. Data sent by the VM's VNC server should be
+// written to it, and it must be closed when the session ends.
+type VNCRecorder struct {
+	*rfb.Recorder
+
+	expName   string
+	dir       string
+	f         *os.File
+	recording VNCRecording
+}
+
+// RecordVNC starts a recording of a VNC session by the given user to the given
+// VM in the given experiment. The recording is written to the experiment's
+// files directory.
+func RecordVNC(expName, vmName, user string) (*VNCRecorder, error) {
+	if expName == "" {
+		return nil, errors.New("no experiment name provided")
+	}
+
+	if vmName == "" {
+		return nil, errors.New("no VM name provided")
+	}
+
+	dir := vncRecordingsPath(expName)
+
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, fmt.Errorf("creating VNC recordings directory: %w", err)
+	}
+
+	var (
+		start = time.Now().UTC()
+		id    = fmt.Sprintf("%s_%s_%03d", vmName, start.Format("20060102_150405"), start.Nanosecond()/int(time.Millisecond))
+		path  = filepath.Join(dir, id+".fbs")
+	)
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("creating VNC recording %s: %w", id, err)
+	}
+
+	rec, err := rfb.NewRecorder(f)
+	if err != nil {
+		_ = f.Close()
+
+		return nil, err
+	}
+
+	recorder := &VNCRecorder{
+		Recorder: rec,
+		expName:  expName,
+		dir:      dir,
+		f:        f,
+		recording: VNCRecording{ //nolint:exhaustruct // partial initialization
+			ID:    id,
+			VM:    vmName,
+			User:  user,
+			Start: start,
+			File:  filepath.Join(vncRecordingsDir, id+".fbs"),
+		},
+	}
+
+	// Write metadata now so in-progress recordings are listed.
+	if err := recorder.writeMetadata(); err != nil {
+		_ = f.Close()
+
+		return nil, err
+	}
+
+	return recorder, nil
+}
+
+// Recording returns the details of the recording.
+func (r *VNCRecorder) Recording() VNCRecording {
+	return r.recording
+}
+
+// Close ends the recording, updates its details, and records the session in
+// the experiment's journal.
+func (r *VNCRecorder) Close() error {
+	if err := r.f.Close(); err != nil {
+		return fmt.Errorf("closing VNC recording %s: %w", r.recording.ID, err)
+	}
+
+	r.recording.End = time.Now().UTC()
+
+	if info, err := os.Stat(r.f.Name()); err == nil {
+		r.recording.Size = info.Size()
+	}
+
+	if err := r.writeMetadata(); err != nil {
+		return err
+	}
+
+	experiment.RecordEvent(
+		r.expName, r.recording.VM, "VNC session recorded",
+		map[string]any{
+			"user":      r.recording.User,
+			"recording": r.recording.ID,
+			"file":      r.recording.File,
+			"start":     r.recording.Start,
+			"end":       r.recording.End,
+		},
+	)
+
+	if err := r.Err(); err != nil {
+		return fmt.Errorf("recording VNC session %s: %w", r.recording.ID, err)
+	}
+
+	return nil
+}
+
+func (r *VNCRecorder) writeMetadata() error {
+	body, err := json.Marshal(r.recording)
+	if err != nil {
+		return fmt.Errorf("marshaling VNC recording %s: %w", r.recording.ID, err)
+	}
+
+	if err := os.WriteFile(filepath.Join(r.dir, r.recording.ID+".json"), body, 0o600); err != nil {
+		return fmt.Errorf("writing VNC recording %s metadata: %w", r.recording.ID, err)
+	}
+
+	return nil
+}
+
+// VNCRecordings returns the VNC sessions recorded for the given VM in the given
+// experiment, oldest first. If no VM name is provided, recordings for all VMs
+// in the experiment are returned.
+func VNCRecordings(expName, vmName string) ([]VNCRecording, error) {
+	if expName == "" {
+		return nil, errors.New("no experiment name provided")
+	}
+
+	paths, err := filepath.Glob(filepath.Join(vncRecordingsPath(expName), "*.json"))
+	if err != nil {
+		return nil, fmt.Errorf("listing VNC recordings: %w", err)
+	}
+
+	var recordings []VNCRecording
+
+	for _, path := range paths {
+		recording, err := readVNCRecording(path)
+		if err != nil {
+			return nil, err
+		}
+
+		if vmName == "" || recording.VM == vmName {
+			recordings = append(recordings, recording)
+		}
+	}
+
+	sort.Slice(recordings, func(i, j int) bool { return recordings[i].Start.Before(recordings[j].Start) })
+
+	return recordings, nil
+}
+
+// OpenVNCRecording opens the VNC recording with the given ID for the given VM
+// in the given experiment. The caller must close the returned file.
+func OpenVNCRecording(expName, vmName, id string) (*os.File, VNCRecording, error) {
+	if id == "" || strings.ContainsAny(id, `/\`) || strings.HasPrefix(id, ".") {
+		return nil, VNCRecording{}, fmt.Errorf("%w: %s", ErrVNCRecordingNotFound, id) //nolint:exhaustruct // not found
+	}
+
+	dir := vncRecordingsPath(expName)
+
+	recording, err := readVNCRecording(filepath.Join(dir, id+".json"))
+	if err != nil || recording.VM != vmName {
+		return nil, VNCRecording{}, fmt.Errorf("%w: %s", ErrVNCRecordingNotFound, id) //nolint:exhaustruct // not found
+	}
+
+	f, err := os.Open(filepath.Join(dir, id+".fbs"))
+	if err != nil {
+		return nil, recording, fmt.Errorf("opening VNC recording %s: %w", id, err)
+	}
+
+	return f, recording, nil
+}
+
+func readVNCRecording(path string) (VNCRecording, error) {
+	var recording VNCRecording
+
+	body, err := os.ReadFile(path)
+	if err != nil {
+		return recording, fmt.Errorf("reading VNC recording metadata: %w", err)
+	}
+
+	if err := json.Unmarshal(body, &recording); err != nil {
+		return recording, fmt.Errorf("parsing VNC recording metadata %s: %w", path, err)
+	}
+
+	return recording, nil
+}
+
+func vncRecordingsPath(expName string) string {
+	return filepath.Join(common.PhenixBase, "images", expName, "files", vncRecordingsDir)
+}
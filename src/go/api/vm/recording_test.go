@@ -0,0 +1,58 @@
+//nolint:testpackage // testing internals
+package vm
+
+import (
+	"errors"
+	"testing"
+
+	"phenix/util/common"
+)
+
+func TestVNCRecordings(t *testing.T) {
+	base := common.PhenixBase
+	common.PhenixBase = t.TempDir()
+
+	t.Cleanup(func() { common.PhenixBase = base })
+
+	recorder, err := RecordVNC("foo", "bar", "admin@foo.com")
+	if err != nil {
+		t.Fatalf("starting recording: %v", err)
+	}
+
+	_, _ = recorder.Write([]byte("RFB 003.008\n"))
+	_ = recorder.f.Close()
+
+	if _, err := RecordVNC("foo", "baz", "admin@foo.com"); err != nil {
+		t.Fatalf("starting recording: %v", err)
+	}
+
+	recordings, err := VNCRecordings("foo", "bar")
+	if err != nil {
+		t.Fatalf("listing recordings: %v", err)
+	}
+
+	if len(recordings) != 1 || recordings[0].ID != recorder.Recording().ID || recordings[0].User != "admin@foo.com" {
+		t.Fatalf("unexpected recordings %+v", recordings)
+	}
+
+	if all, _ := VNCRecordings("foo", ""); len(all) != 2 {
+		t.Fatalf("expected 2 recordings for experiment, got %d", len(all))
+	}
+
+	f, _, err := OpenVNCRecording("foo", "bar", recorder.Recording().ID)
+	if err != nil {
+		t.Fatalf("opening recording: %v", err)
+	}
+
+	_ = f.Close()
+
+	for _, id := range []string{recorder.Recording().ID + "x", "../" + recorder.Recording().ID} {
+		if _, _, err := OpenVNCRecording("foo", "bar", id); !errors.Is(err, ErrVNCRecordingNotFound) {
+			t.Errorf("expected not found error for %s, got %v", id, err)
+		}
+	}
+
+	if _, _, err := OpenVNCRecording("foo", "baz", recorder.Recording().ID); !errors.Is(err, ErrVNCRecordingNotFound) {
+		t.Errorf("expected not found error for other VM, got %v", err)
+	}
+}
@@ -0,0 +1,313 @@
+package vm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"phenix/api/experiment"
+	"phenix/util/common"
+	"phenix/util/mm"
+	"phenix/util/plog"
+)
+
+const (
+	// DefaultSerialScrollback is the default number of bytes of serial console
+	// output kept for each VM.
+	DefaultSerialScrollback = 256 * 1024
+
+	// serialLogsDir is the directory, relative to an experiment's files
+	// directory, serial console logs are written to.
+	serialLogsDir = "serial"
+
+	// serialClientBuffer is the number of reads buffered for each serial console
+	// client before the client is dropped for being too slow.
+	serialClientBuffer = 256
+
+	serialReadSize = 4096
+)
+
+var (
+	serialConsoles   = make(map[string]*SerialConsole) //nolint:gochecknoglobals // package level consoles
+	serialConsolesMu sync.Mutex                        //nolint:gochecknoglobals // package level lock
+)
+
+// SerialConsole is a connection to the serial port of a VM shared by all the
+// clients attached to it. Output is kept as scrollback for clients that attach
+// later and, optionally, logged to the experiment's files directory. The
+// connection is kept open until the VM's serial port is closed (e.g., when the
+// VM is killed) so output is captured even with no clients attached.
+type SerialConsole struct {
+	expName string
+	vmName  string
+	conn    net.Conn
+	log     *os.File
+
+	mu         sync.Mutex
+	scrollback []byte
+	limit      int
+	clients    map[chan []byte]struct{}
+	closed     bool
+}
+
+// OpenSerialConsole returns the serial console for the given VM in the given
+// running experiment, connecting to the VM's serial port if there isn't
+// already a console open for it. Options only apply when connecting.
+func OpenSerialConsole(ctx context.Context, expName, vmName string, opts ...SerialOption) (*SerialConsole, error) {
+	if expName == "" {
+		return nil, errors.New("no experiment name provided")
+	}
+
+	if vmName == "" {
+		return nil, errors.New("no VM name provided")
+	}
+
+	o := newSerialOptions(opts...)
+	key := expName + "/" + vmName
+
+	serialConsolesMu.Lock()
+	defer serialConsolesMu.Unlock()
+
+	if console, ok := serialConsoles[key]; ok {
+		return console, nil
+	}
+
+	if !experiment.Running(expName) {
+		return nil, fmt.Errorf("experiment %s is not running", expName)
+	}
+
+	conn, err := mm.DialVMSerial(ctx, expName, vmName)
+	if err != nil {
+		return nil, err
+	}
+
+	console := &SerialConsole{ //nolint:exhaustruct // partial initialization
+		expName: expName,
+		vmName:  vmName,
+		conn:    conn,
+		limit:   o.scrollback,
+		clients: make(map[chan []byte]struct{}),
+	}
+
+	if o.log {
+		console.log, err = openSerialLog(expName, vmName)
+		if err != nil {
+			_ = conn.Close()
+
+			return nil, err
+		}
+
+		experiment.RecordEvent(expName, vmName, "VM serial console logging started", map[string]any{"file": console.LogFile()})
+	}
+
+	serialConsoles[key] = console
+
+	go console.read(key)
+
+	return console, nil
+}
+
+// LogFile returns the path of the console's log file relative to the
+// experiment's files directory, or an empty string if output isn't logged.
+func (c *SerialConsole) LogFile() string {
+	if c.log == nil {
+		return ""
+	}
+
+	return filepath.Join(serialLogsDir, filepath.Base(c.log.Name()))
+}
+
+// Attach returns the console's scrollback and a channel that receives output
+// from the console as it's read. The channel is closed when the console is
+// closed or the client falls too far behind. The returned function must be
+// called to detach once the client is done.
+func (c *SerialConsole) Attach() ([]byte, <-chan []byte, func()) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	ch := make(chan []byte, serialClientBuffer)
+	scrollback := append([]byte(nil), c.scrollback...)
+
+	if c.closed {
+		close(ch)
+
+		return scrollback, ch, func() {}
+	}
+
+	c.clients[ch] = struct{}{}
+
+	detach := func() {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+
+		if _, ok := c.clients[ch]; ok {
+			delete(c.clients, ch)
+			close(ch)
+		}
+	}
+
+	return scrollback, ch, detach
+}
+
+// SerialClient is a client attached to a serial console. Reads return the
+// console's scrollback followed by its output as it's read, and writes go to
+// the VM's serial port, so a client can be piped to a websocket like a pty.
+type SerialClient struct {
+	console *SerialConsole
+	output  <-chan []byte
+	detach  func()
+	pending []byte
+}
+
+// NewClient attaches a new client to the console. The client must be closed to
+// detach it once it's done.
+func (c *SerialConsole) NewClient() *SerialClient {
+	scrollback, output, detach := c.Attach()
+
+	return &SerialClient{console: c, output: output, detach: detach, pending: scrollback}
+}
+
+// Read reads the console's output, returning io.EOF once the console is closed
+// or the client falls too far behind.
+func (c *SerialClient) Read(p []byte) (int, error) {
+	if len(c.pending) == 0 {
+		data, ok := <-c.output
+		if !ok {
+			return 0, io.EOF
+		}
+
+		c.pending = data
+	}
+
+	n := copy(p, c.pending)
+	c.pending = c.pending[n:]
+
+	return n, nil
+}
+
+// Write writes input to the VM's serial port.
+func (c *SerialClient) Write(p []byte) (int, error) {
+	return c.console.Write(p)
+}
+
+// Close detaches the client from the console. The console itself stays open.
+func (c *SerialClient) Close() error {
+	c.detach()
+
+	return nil
+}
+
+// Scrollback returns the output kept for the console.
+func (c *SerialConsole) Scrollback() []byte {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return append([]byte(nil), c.scrollback...)
+}
+
+// Write writes input to the VM's serial port.
+func (c *SerialConsole) Write(p []byte) (int, error) {
+	n, err := c.conn.Write(p)
+	if err != nil {
+		return n, fmt.Errorf("writing to serial console for VM %s: %w", c.vmName, err)
+	}
+
+	return n, nil
+}
+
+// Close closes the connection to the VM's serial port, detaching all clients.
+func (c *SerialConsole) Close() error {
+	if err := c.conn.Close(); err != nil {
+		return fmt.Errorf("closing serial console for VM %s: %w", c.vmName, err)
+	}
+
+	return nil
+}
+
+func (c *SerialConsole) read(key string) {
+	buf := make([]byte, serialReadSize)
+
+	for {
+		n, err := c.conn.Read(buf)
+		if n > 0 {
+			c.publish(append([]byte(nil), buf[:n]...))
+		}
+
+		if err != nil {
+			break
+		}
+	}
+
+	serialConsolesMu.Lock()
+	if serialConsoles[key] == c {
+		delete(serialConsoles, key)
+	}
+	serialConsolesMu.Unlock()
+
+	c.mu.Lock()
+	c.closed = true
+
+	for ch := range c.clients {
+		delete(c.clients, ch)
+		close(ch)
+	}
+	c.mu.Unlock()
+
+	_ = c.conn.Close()
+
+	if c.log != nil {
+		_ = c.log.Close()
+	}
+
+	plog.Info(plog.TypeSystem, "serial console closed", "exp", c.expName, "vm", c.vmName)
+}
+
+func (c *SerialConsole) publish(data []byte) {
+	if c.log != nil {
+		if _, err := c.log.Write(data); err != nil {
+			plog.Error(plog.TypeSystem, "writing serial console log", "exp", c.expName, "vm", c.vmName, "err", err)
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.scrollback = append(c.scrollback, data...)
+
+	if over := len(c.scrollback) - c.limit; over > 0 {
+		c.scrollback = append(c.scrollback[:0], c.scrollback[over:]...)
+	}
+
+	for ch := range c.clients {
+		select {
+		case ch <- data:
+		default:
+			// Drop clients that can't keep up rather than blocking the console.
+			delete(c.clients, ch)
+			close(ch)
+		}
+	}
+}
+
+func openSerialLog(expName, vmName string) (*os.File, error) {
+	dir := filepath.Join(common.PhenixBase, "images", expName, "files", serialLogsDir)
+
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, fmt.Errorf("creating serial logs directory: %w", err)
+	}
+
+	name := fmt.Sprintf("%s_%s.log", vmName, time.Now().UTC().Format("20060102_150405"))
+
+	f, err := os.OpenFile(filepath.Join(dir, name), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("creating serial console log for VM %s: %w", vmName, err)
+	}
+
+	return f, nil
+}
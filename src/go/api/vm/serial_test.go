@@ -0,0 +1,120 @@
+//nolint:testpackage // testing internals
+package vm
+
+import (
+	"errors"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestSerialConsole(t *testing.T) {
+	vmSide, consoleSide := net.Pipe()
+
+	console := &SerialConsole{ //nolint:exhaustruct // partial initialization
+		expName: "foo",
+		vmName:  "bar",
+		conn:    consoleSide,
+		limit:   8,
+		clients: make(map[chan []byte]struct{}),
+	}
+
+	serialConsoles["foo/bar"] = console
+
+	go console.read("foo/bar")
+
+	_, _ = vmSide.Write([]byte("booting..."))
+
+	waitFor := func(cond func() bool) {
+		t.Helper()
+
+		deadline := time.Now().Add(time.Second)
+
+		for !cond() {
+			if time.Now().After(deadline) {
+				t.Fatal("timed out waiting for serial console")
+			}
+
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	waitFor(func() bool { return string(console.Scrollback()) == "oting..." })
+
+	scrollback, output, detach := console.Attach()
+	defer detach()
+
+	if string(scrollback) != "oting..." {
+		t.Fatalf("expected scrollback to be trimmed, got %q", scrollback)
+	}
+
+	_, _ = vmSide.Write([]byte("login:"))
+
+	if data := <-output; string(data) != "login:" {
+		t.Fatalf("unexpected output %q", data)
+	}
+
+	go func() { _, _ = console.Write([]byte("root\n")) }()
+
+	buf := make([]byte, 16)
+
+	if n, _ := vmSide.Read(buf); string(buf[:n]) != "root\n" {
+		t.Fatalf("unexpected input %q", buf[:n])
+	}
+
+	_ = vmSide.Close()
+
+	if _, ok := <-output; ok {
+		t.Fatal("expected output to be closed with the console")
+	}
+
+	serialConsolesMu.Lock()
+	defer serialConsolesMu.Unlock()
+
+	if _, ok := serialConsoles["foo/bar"]; ok {
+		t.Fatal("expected closed console to be removed")
+	}
+}
+
+func TestSerialClient(t *testing.T) {
+	vmSide, consoleSide := net.Pipe()
+
+	console := &SerialConsole{ //nolint:exhaustruct // partial initialization
+		expName:    "foo",
+		vmName:     "baz",
+		conn:       consoleSide,
+		limit:      64,
+		scrollback: []byte("boot\n"),
+		clients:    make(map[chan []byte]struct{}),
+	}
+
+	serialConsoles["foo/baz"] = console
+
+	go console.read("foo/baz")
+
+	client := console.NewClient()
+	defer func() { _ = client.Close() }()
+
+	buf := make([]byte, 3)
+
+	if n, _ := client.Read(buf); string(buf[:n]) != "boo" {
+		t.Fatalf("expected scrollback first, got %q", buf[:n])
+	}
+
+	if n, _ := client.Read(buf); string(buf[:n]) != "t\n" {
+		t.Fatalf("expected rest of scrollback, got %q", buf[:n])
+	}
+
+	_, _ = vmSide.Write([]byte("ok"))
+
+	if n, _ := client.Read(buf); string(buf[:n]) != "ok" {
+		t.Fatalf("unexpected output %q", buf[:n])
+	}
+
+	_ = vmSide.Close()
+
+	if _, err := client.Read(buf); !errors.Is(err, io.EOF) {
+		t.Fatalf("expected EOF once console is closed, got %v", err)
+	}
+}
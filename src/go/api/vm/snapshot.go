@@ -0,0 +1,437 @@
+package vm
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"phenix/api/experiment"
+	"phenix/util/file"
+	"phenix/util/mm"
+	"phenix/util/plog"
+)
+
+const snapshotCatalogDir = "snapshots"
+
+var (
+	ErrSnapshotNotFound = errors.New("snapshot not found")
+	ErrNoPruneCriteria  = errors.New("must provide the number of snapshots to keep or a maximum age")
+)
+
+// Mutex to protect concurrent updates to VM snapshot catalog files.
+var snapshotCatalogMu sync.Mutex //nolint:gochecknoglobals // global lock
+
+// SnapshotInfo describes a VM snapshot. Snapshots created by phenix are
+// recorded in a per-VM catalog along with who created them and why. Snapshot
+// files found in the experiment files directory that aren't in the catalog
+// (e.g., created by older versions of phenix) are still included, but with
+// only the details that can be determined from the files themselves.
+type SnapshotInfo struct {
+	Name         string    `json:"name"`
+	VM           string    `json:"vm"`
+	Description  string    `json:"description,omitempty"`
+	Creator      string    `json:"creator,omitempty"`
+	Created      time.Time `json:"created"`
+	Memory       bool      `json:"memory"`
+	BackingChain []string  `json:"backingChain,omitempty"`
+	Size         int64     `json:"size"`
+
+	// Cataloged is false for snapshot files not recorded in the catalog.
+	Cataloged bool `json:"cataloged"`
+
+	// Missing is true for cataloged snapshots whose files no longer exist.
+	Missing bool `json:"missing,omitempty"`
+}
+
+// File returns the name of the snapshot's files in the experiment files
+// directory, without the file extension.
+func (s SnapshotInfo) File() string {
+	return s.VM + "__" + s.Name
+}
+
+// SnapshotCatalog returns all the snapshots for the given VM in the given
+// experiment, sorted by creation time. If the VM name is empty, snapshots for
+// all VMs in the experiment are returned, sorted by VM name and then creation
+// time.
+func SnapshotCatalog(expName, vmName string) ([]SnapshotInfo, error) {
+	exp, err := experiment.Get(expName)
+	if err != nil {
+		return nil, fmt.Errorf("getting experiment %s: %w", expName, err)
+	}
+
+	var vms []string
+
+	if vmName != "" {
+		if exp.Spec.Topology().FindNodeByName(vmName) == nil {
+			return nil, fmt.Errorf("VM %s not found in experiment %s", vmName, expName)
+		}
+
+		vms = []string{vmName}
+	} else {
+		for _, node := range exp.Spec.Topology().Nodes() {
+			if !node.External() {
+				vms = append(vms, node.General().Hostname())
+			}
+		}
+	}
+
+	files, err := file.GetExperimentFiles(expName, "")
+	if err != nil {
+		return nil, fmt.Errorf("getting experiment files: %w", err)
+	}
+
+	var snapshots []SnapshotInfo
+
+	for _, vm := range vms {
+		catalog, err := readSnapshotCatalog(exp.Spec.BaseDir(), vm)
+		if err != nil {
+			return nil, err
+		}
+
+		snapshots = append(snapshots, mergeSnapshotFiles(vm, catalog, files)...)
+	}
+
+	return snapshots, nil
+}
+
+// DescribeSnapshot returns the details of the given snapshot for the given VM
+// in the given experiment. The snapshot name can optionally include the VM
+// name prefix and file extension used for snapshot files.
+func DescribeSnapshot(expName, vmName, name string) (SnapshotInfo, error) {
+	if vmName == "" {
+		return SnapshotInfo{}, errors.New("no VM name provided")
+	}
+
+	snapshots, err := SnapshotCatalog(expName, vmName)
+	if err != nil {
+		return SnapshotInfo{}, err
+	}
+
+	name = snapshotName(vmName, name)
+
+	idx := slices.IndexFunc(snapshots, func(s SnapshotInfo) bool { return s.Name == name })
+	if idx < 0 {
+		return SnapshotInfo{}, fmt.Errorf("%w: %s for VM %s", ErrSnapshotNotFound, name, vmName)
+	}
+
+	return snapshots[idx], nil
+}
+
+// DeleteSnapshot deletes the files for the given snapshot from all cluster
+// nodes and removes the snapshot from the VM's snapshot catalog.
+func DeleteSnapshot(expName, vmName, name string) error {
+	snapshot, err := DescribeSnapshot(expName, vmName, name)
+	if err != nil {
+		return err
+	}
+
+	return deleteSnapshots(expName, vmName, snapshot)
+}
+
+// PruneSnapshots deletes snapshots for the given VM in the given experiment
+// that match the given prune options. If the VM name is empty, snapshots for
+// all VMs in the experiment are pruned. Cataloged snapshots whose files no
+// longer exist are always pruned from the catalog. It returns the snapshots
+// that were (or, for a dry run, would be) pruned.
+func PruneSnapshots(expName, vmName string, opts ...PruneOption) ([]SnapshotInfo, error) {
+	o := newPruneOptions(opts...)
+
+	if o.keep < 0 && o.olderThan == 0 {
+		return nil, ErrNoPruneCriteria
+	}
+
+	snapshots, err := SnapshotCatalog(expName, vmName)
+	if err != nil {
+		return nil, err
+	}
+
+	if o.filter != nil {
+		snapshots = slices.DeleteFunc(snapshots, func(s SnapshotInfo) bool { return !o.filter(s) })
+	}
+
+	pruned := pruneCandidates(snapshots, o, time.Now())
+
+	if o.dryRun {
+		return pruned, nil
+	}
+
+	byVM := make(map[string][]SnapshotInfo)
+
+	for _, snapshot := range pruned {
+		byVM[snapshot.VM] = append(byVM[snapshot.VM], snapshot)
+	}
+
+	for vm, snapshots := range byVM {
+		if err := deleteSnapshots(expName, vm, snapshots...); err != nil {
+			return nil, err
+		}
+	}
+
+	return pruned, nil
+}
+
+// pruneCandidates returns the snapshots that should be pruned. Snapshots are
+// expected to be grouped by VM and sorted by creation time within each group.
+func pruneCandidates(snapshots []SnapshotInfo, o pruneOptions, now time.Time) []SnapshotInfo {
+	var (
+		pruned []SnapshotInfo
+		counts = make(map[string]int)
+	)
+
+	for _, snapshot := range snapshots {
+		if !snapshot.Missing {
+			counts[snapshot.VM]++
+		}
+	}
+
+	for _, snapshot := range snapshots {
+		if snapshot.Missing {
+			pruned = append(pruned, snapshot)
+
+			continue
+		}
+
+		var prune bool
+
+		// Snapshots are sorted oldest first, so the oldest snapshots beyond the
+		// number to keep are pruned first.
+		if o.keep >= 0 && counts[snapshot.VM] > o.keep {
+			prune = true
+		}
+
+		// Uncataloged snapshots without a parsable timestamp have an unknown age,
+		// so they're never pruned by age.
+		if o.olderThan > 0 && !snapshot.Created.IsZero() && now.Sub(snapshot.Created) > o.olderThan {
+			prune = true
+		}
+
+		if prune {
+			pruned = append(pruned, snapshot)
+			counts[snapshot.VM]--
+		}
+	}
+
+	return pruned
+}
+
+func deleteSnapshots(expName, vmName string, snapshots ...SnapshotInfo) error {
+	exp, err := experiment.Get(expName)
+	if err != nil {
+		return fmt.Errorf("getting experiment %s: %w", expName, err)
+	}
+
+	remove := make(map[string]struct{})
+
+	for _, snapshot := range snapshots {
+		if !snapshot.Missing {
+			exts := []string{".hdd"}
+
+			if snapshot.Memory {
+				exts = append(exts, ".state")
+			}
+
+			for _, ext := range exts {
+				path := fmt.Sprintf("%s/files/%s%s", expName, snapshot.File(), ext)
+
+				if err := file.DeleteFile(path); err != nil {
+					return fmt.Errorf("deleting snapshot %s for VM %s: %w", snapshot.Name, vmName, err)
+				}
+			}
+		}
+
+		remove[snapshot.Name] = struct{}{}
+
+		experiment.RecordEvent(expName, vmName, "VM snapshot deleted", map[string]any{"snapshot": snapshot.Name})
+	}
+
+	return updateSnapshotCatalog(exp.Spec.BaseDir(), vmName, func(catalog []SnapshotInfo) []SnapshotInfo {
+		return slices.DeleteFunc(catalog, func(s SnapshotInfo) bool {
+			_, ok := remove[s.Name]
+
+			return ok
+		})
+	})
+}
+
+// recordSnapshot adds the given snapshot to the VM's snapshot catalog,
+// replacing any existing snapshot with the same name.
+func recordSnapshot(expName string, snapshot SnapshotInfo) error {
+	exp, err := experiment.Get(expName)
+	if err != nil {
+		return fmt.Errorf("getting experiment %s: %w", expName, err)
+	}
+
+	return updateSnapshotCatalog(exp.Spec.BaseDir(), snapshot.VM, func(catalog []SnapshotInfo) []SnapshotInfo {
+		catalog = slices.DeleteFunc(catalog, func(s SnapshotInfo) bool { return s.Name == snapshot.Name })
+
+		return append(catalog, snapshot)
+	})
+}
+
+// snapshotBackingChain returns the backing chain of the given snapshot disk on
+// the given cluster host, starting with the disk itself. Errors are logged
+// rather than returned since the backing chain is informational only.
+func snapshotBackingChain(host, path string) []string {
+	chain, err := mm.ImageBackingChain(host, path)
+	if err != nil {
+		plog.Warn(plog.TypeSystem, "unable to get snapshot backing chain", "path", path, "err", err)
+
+		return nil
+	}
+
+	return chain
+}
+
+// mergeSnapshotFiles merges the given VM's snapshot catalog with the snapshot
+// files found in the experiment files directory.
+func mergeSnapshotFiles(vmName string, catalog []SnapshotInfo, files file.Files) []SnapshotInfo {
+	var (
+		prefix = vmName + "__"
+		found  = make(map[string]*SnapshotInfo)
+		hdd    = make(map[string]bool)
+	)
+
+	for i := range catalog {
+		catalog[i].VM = vmName
+		catalog[i].Cataloged = true
+		catalog[i].Missing = true
+		catalog[i].Size = 0
+
+		found[catalog[i].Name] = &catalog[i]
+	}
+
+	for _, f := range files {
+		ext := filepath.Ext(f.Name)
+		if ext != ".hdd" && ext != ".state" {
+			continue
+		}
+
+		base := strings.TrimSuffix(f.Name, ext)
+		if !strings.HasPrefix(base, prefix) {
+			continue
+		}
+
+		name := strings.TrimPrefix(base, prefix)
+
+		snapshot, ok := found[name]
+		if !ok {
+			created, _ := time.Parse(time.RFC3339, f.Date)
+
+			snapshot = &SnapshotInfo{ //nolint:exhaustruct // partial initialization
+				Name:    name,
+				VM:      vmName,
+				Created: created,
+				Missing: true,
+			}
+
+			found[name] = snapshot
+		}
+
+		snapshot.Size += f.Size
+
+		switch ext {
+		case ".hdd":
+			snapshot.Missing = false
+			hdd[name] = true
+		case ".state":
+			snapshot.Memory = true
+		}
+	}
+
+	// Uncataloged snapshots are only reported if their disk file exists.
+	snapshots := make([]SnapshotInfo, 0, len(found))
+
+	for _, snapshot := range found {
+		if !snapshot.Cataloged && !hdd[snapshot.Name] {
+			continue
+		}
+
+		snapshots = append(snapshots, *snapshot)
+	}
+
+	slices.SortFunc(snapshots, func(a, b SnapshotInfo) int {
+		if c := a.Created.Compare(b.Created); c != 0 {
+			return c
+		}
+
+		return strings.Compare(a.Name, b.Name)
+	})
+
+	return snapshots
+}
+
+// snapshotName normalizes the given snapshot name, removing the VM name prefix
+// and file extension used for snapshot files.
+func snapshotName(vmName, name string) string {
+	if ext := filepath.Ext(name); ext == ".hdd" || ext == ".state" {
+		name = strings.TrimSuffix(name, ext)
+	}
+
+	return strings.TrimPrefix(name, vmName+"__")
+}
+
+func snapshotCatalogPath(baseDir, vmName string) string {
+	return filepath.Join(baseDir, snapshotCatalogDir, vmName+".json")
+}
+
+func readSnapshotCatalog(baseDir, vmName string) ([]SnapshotInfo, error) {
+	body, err := os.ReadFile(snapshotCatalogPath(baseDir, vmName))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+
+		return nil, fmt.Errorf("reading snapshot catalog for VM %s: %w", vmName, err)
+	}
+
+	var catalog []SnapshotInfo
+
+	if err := json.Unmarshal(body, &catalog); err != nil {
+		return nil, fmt.Errorf("parsing snapshot catalog for VM %s: %w", vmName, err)
+	}
+
+	return catalog, nil
+}
+
+func updateSnapshotCatalog(baseDir, vmName string, update func([]SnapshotInfo) []SnapshotInfo) error {
+	snapshotCatalogMu.Lock()
+	defer snapshotCatalogMu.Unlock()
+
+	catalog, err := readSnapshotCatalog(baseDir, vmName)
+	if err != nil {
+		return err
+	}
+
+	catalog = update(catalog)
+
+	path := snapshotCatalogPath(baseDir, vmName)
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+		return fmt.Errorf("creating snapshot catalog directory: %w", err)
+	}
+
+	// Only the details recorded at creation time are persisted. Everything
+	// else is determined from the snapshot files when the catalog is read.
+	for i := range catalog {
+		catalog[i].Size = 0
+		catalog[i].Cataloged = false
+		catalog[i].Missing = false
+	}
+
+	body, err := json.MarshalIndent(catalog, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encoding snapshot catalog for VM %s: %w", vmName, err)
+	}
+
+	if err := os.WriteFile(path, body, 0o600); err != nil {
+		return fmt.Errorf("writing snapshot catalog for VM %s: %w", vmName, err)
+	}
+
+	return nil
+}
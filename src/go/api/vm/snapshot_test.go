@@ -0,0 +1,88 @@
+//nolint:testpackage // testing internals
+package vm
+
+import (
+	"testing"
+	"time"
+
+	"phenix/util/file"
+)
+
+func TestSnapshotName(t *testing.T) {
+	for _, name := range []string{"base", "host-00__base", "host-00__base.hdd", "base.state"} {
+		if got := snapshotName("host-00", name); got != "base" {
+			t.Fatalf("expected base for %s, got %s", name, got)
+		}
+	}
+}
+
+func TestMergeSnapshotFiles(t *testing.T) {
+	created := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	catalog := []SnapshotInfo{
+		{Name: "cataloged", Creator: "alice", Created: created},
+		{Name: "deleted", Created: created.Add(-time.Hour)},
+	}
+
+	files := file.Files{
+		{Name: "host-00__cataloged.hdd", Size: 10},
+		{Name: "host-00__cataloged.state", Size: 5},
+		{Name: "host-00__legacy.hdd", Size: 7, Date: "2023-01-01T00:00:00Z"},
+		{Name: "host-00__orphan.state", Size: 3},
+		{Name: "host-01__other.hdd", Size: 1},
+		{Name: "notes.txt", Size: 1},
+	}
+
+	snapshots := mergeSnapshotFiles("host-00", catalog, files)
+
+	if len(snapshots) != 3 {
+		t.Fatalf("expected 3 snapshots, got %d: %+v", len(snapshots), snapshots)
+	}
+
+	legacy, deleted, cataloged := snapshots[0], snapshots[1], snapshots[2]
+
+	if legacy.Name != "legacy" || legacy.Cataloged || legacy.Missing || legacy.Memory || legacy.Size != 7 {
+		t.Fatalf("unexpected uncataloged snapshot: %+v", legacy)
+	}
+
+	if deleted.Name != "deleted" || !deleted.Missing {
+		t.Fatalf("expected deleted snapshot to be missing: %+v", deleted)
+	}
+
+	if cataloged.Name != "cataloged" || cataloged.Missing || !cataloged.Memory || cataloged.Size != 15 ||
+		cataloged.Creator != "alice" {
+		t.Fatalf("unexpected cataloged snapshot: %+v", cataloged)
+	}
+}
+
+func TestPruneCandidates(t *testing.T) {
+	now := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+
+	snapshots := []SnapshotInfo{
+		{VM: "a", Name: "a1", Created: now.Add(-72 * time.Hour)},
+		{VM: "a", Name: "a2", Created: now.Add(-48 * time.Hour)},
+		{VM: "a", Name: "a3", Created: now.Add(-1 * time.Hour)},
+		{VM: "a", Name: "gone", Missing: true},
+		{VM: "b", Name: "b1", Created: now.Add(-96 * time.Hour)},
+		{VM: "c", Name: "undated"},
+	}
+
+	names := func(snapshots []SnapshotInfo) []string {
+		var n []string
+		for _, s := range snapshots {
+			n = append(n, s.Name)
+		}
+
+		return n
+	}
+
+	pruned := names(pruneCandidates(snapshots, newPruneOptions(PruneKeep(1)), now))
+	if len(pruned) != 3 || pruned[0] != "a1" || pruned[1] != "a2" || pruned[2] != "gone" {
+		t.Fatalf("unexpected snapshots pruned keeping 1: %v", pruned)
+	}
+
+	pruned = names(pruneCandidates(snapshots, newPruneOptions(PruneOlderThan(60*time.Hour)), now))
+	if len(pruned) != 3 || pruned[0] != "a1" || pruned[1] != "gone" || pruned[2] != "b1" {
+		t.Fatalf("unexpected snapshots pruned older than 60h: %v", pruned)
+	}
+}
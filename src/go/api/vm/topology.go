@@ -18,10 +18,11 @@ type topology struct {
 }
 
 type edge struct {
-	ID     int `json:"id"`
-	Source int `json:"source"`
-	Target int `json:"target"`
-	Length int `json:"length"`
+	ID     int    `json:"id"`
+	Source int    `json:"source"`
+	Target int    `json:"target"`
+	Length int    `json:"length"`
+	Link   string `json:"link,omitempty"`
 }
 
 func Topology(exp string, ignore []string) (topology, error) {
@@ -97,7 +98,13 @@ func Topology(exp string, ignore []string) (topology, error) {
 
 			edges = append(
 				edges,
-				edge{ID: edgeID, Source: node.ID, Target: network.ID, Length: defaultEdgeLength},
+				edge{
+					ID:     edgeID,
+					Source: node.ID,
+					Target: network.ID,
+					Length: defaultEdgeLength,
+					Link:   vm.Impairments[i],
+				},
 			)
 			edgeID++
 		}
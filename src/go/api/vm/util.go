@@ -2,7 +2,6 @@ package vm
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"io"
 	"os"
@@ -19,11 +18,6 @@ import (
 
 const snapshotOffset = 2
 
-type qemuBackingChain struct {
-	Filename    string `json:"filename"`
-	BackingFile string `json:"backing-filename"`
-}
-
 var diskNameWithTstampRegex = regexp.MustCompile(`(.*)_\d{14}`)
 
 func GetNewDiskName(expName, vmName string) (string, error) {
@@ -69,13 +63,13 @@ func getBackingImage(path string) (string, error) {
 		path = common.PhenixBase + "/images/" + path
 	}
 
-	chain, err := getImageBackingChain(path)
+	chain, err := mm.ImageBackingChain("", path)
 	if err != nil {
 		return "", fmt.Errorf("getting image backing chain for %s: %w", path, err)
 	}
 
 	// backing image should always be last image in chain
-	path = chain[len(chain)-1].Filename
+	path = chain[len(chain)-1]
 
 	stats, err := os.Lstat(path)
 	if err != nil {
@@ -96,7 +90,7 @@ func getBackingImage(path string) (string, error) {
 }
 
 func getImageSnapshots(path string) ([]string, error) {
-	chain, err := getImageBackingChain(path)
+	chain, err := mm.ImageBackingChain("", path)
 	if err != nil {
 		return nil, fmt.Errorf("getting image backing chain for %s: %w", path, err)
 	}
@@ -110,29 +104,12 @@ func getImageSnapshots(path string) ([]string, error) {
 	// range chain in reverse to get snapshots in correct order for rebasing
 	// skip last entry since it will be the base image (not a snapshot)
 	for i := len(chain) - snapshotOffset; i >= 0; i-- {
-		snapshots = append(snapshots, chain[i].Filename)
+		snapshots = append(snapshots, chain[i])
 	}
 
 	return snapshots, nil
 }
 
-func getImageBackingChain(path string) ([]qemuBackingChain, error) {
-	cmd := fmt.Sprintf("qemu-img info --backing-chain %s --output json", path)
-
-	resp, err := mm.MeshShellResponse("", cmd)
-	if err != nil {
-		return nil, fmt.Errorf("getting image info for %s: %w", path, err)
-	}
-
-	var chain []qemuBackingChain
-
-	if err := json.Unmarshal([]byte(resp), &chain); err != nil {
-		return nil, fmt.Errorf("parsing image info for %s: %w", path, err)
-	}
-
-	return chain, nil
-}
-
 type copier struct {
 	subs []chan float64
 }
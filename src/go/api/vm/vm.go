@@ -22,6 +22,7 @@ import (
 	"phenix/util/file"
 	"phenix/util/mm"
 	"phenix/util/mm/mmcli"
+	"phenix/util/plog"
 )
 
 const (
@@ -111,6 +112,7 @@ func List(expName string) ([]mm.VM, error) { //nolint:funlen // complex logic
 			OSType:          node.Hardware().OSType(),
 			Snapshot:        snapshot,
 			Tags:            node.Labels(),
+			Impairments:     linkProperties(node),
 		}
 
 		for _, iface := range node.Network().Interfaces() {
@@ -218,6 +220,7 @@ func Get(expName, vmName string) (*mm.VM, error) {
 			Labels:          node.Labels(),
 			Tags:            node.Labels(),
 			Annotations:     node.Annotations(),
+			Impairments:     linkProperties(node),
 		}
 
 		for _, iface := range node.Network().Interfaces() {
@@ -436,6 +439,48 @@ func Screenshot(expName, vmName, size string) ([]byte, error) {
 	return screenshot, nil
 }
 
+// Start starts the VM with the given name in the experiment with the given
+// name and records it in the experiment's journal. It returns any errors
+// encountered while starting the VM.
+func Start(expName, vmName string) error {
+	if expName == "" {
+		return errors.New("no experiment name provided")
+	}
+
+	if vmName == "" {
+		return errors.New("no VM name provided")
+	}
+
+	if err := mm.StartVM(mm.NS(expName), mm.VMName(vmName)); err != nil {
+		return fmt.Errorf("starting VM: %w", err)
+	}
+
+	experiment.RecordEvent(expName, vmName, "VM started", nil)
+
+	return nil
+}
+
+// Stop stops the VM with the given name in the experiment with the given name
+// and records it in the experiment's journal. It returns any errors encountered
+// while stopping the VM.
+func Stop(expName, vmName string) error {
+	if expName == "" {
+		return errors.New("no experiment name provided")
+	}
+
+	if vmName == "" {
+		return errors.New("no VM name provided")
+	}
+
+	if err := mm.StopVM(mm.NS(expName), mm.VMName(vmName)); err != nil {
+		return fmt.Errorf("stopping VM: %w", err)
+	}
+
+	experiment.RecordEvent(expName, vmName, "VM stopped", nil)
+
+	return nil
+}
+
 // Pause stops a running VM with the given name in the experiment with the given
 // name. It returns any errors encountered while pausing the VM.
 func Pause(expName, vmName string) error {
@@ -456,6 +501,8 @@ func Pause(expName, vmName string) error {
 		return fmt.Errorf("pausing VM: %w", err)
 	}
 
+	experiment.RecordEvent(expName, vmName, "VM paused", nil)
+
 	return nil
 }
 
@@ -477,7 +524,13 @@ func Restart(expName, vmName string) error {
 
 	// Using "system_reset" on a VM that is in the "QUIT" state fails
 	if state == vmStateQuit {
-		return mm.StartVM(mm.NS(expName), mm.VMName(vmName))
+		if err := mm.StartVM(mm.NS(expName), mm.VMName(vmName)); err != nil {
+			return fmt.Errorf("starting VM %s: %w", vmName, err)
+		}
+
+		experiment.RecordEvent(expName, vmName, "VM restarted", nil)
+
+		return nil
 	}
 
 	cmd := mmcli.NewNamespacedCommand(expName)
@@ -489,6 +542,8 @@ func Restart(expName, vmName string) error {
 		return fmt.Errorf("restarting VM %s: %w", vmName, err)
 	}
 
+	experiment.RecordEvent(expName, vmName, "VM restarted", nil)
+
 	return nil
 }
 
@@ -563,6 +618,8 @@ func Shutdown(expName, vmName string) error {
 		}
 	}
 
+	experiment.RecordEvent(expName, vmName, "VM shut down", nil)
+
 	return nil
 }
 
@@ -664,6 +721,8 @@ func ResetDiskState(expName, vmName string) error {
 		return fmt.Errorf("starting VM %s in experiment %s: %w", vmName, expName, err)
 	}
 
+	experiment.RecordEvent(expName, vmName, "VM disk state reset", nil)
+
 	return nil
 }
 
@@ -683,6 +742,8 @@ func Resume(expName, vmName string) error {
 		return fmt.Errorf("resuming VM: %w", err)
 	}
 
+	experiment.RecordEvent(expName, vmName, "VM resumed", nil)
+
 	return nil
 }
 
@@ -742,6 +803,19 @@ func Redeploy(expName, vmName string, opts ...RedeployOption) error {
 		return fmt.Errorf("redeploying VM: %w", err)
 	}
 
+	experiment.RecordEvent(expName, vmName, "VM redeployed", nil)
+
+	// Redeploying a VM recreates its taps, so link properties configured in the
+	// topology have to be applied again. As with starting an experiment, failing
+	// to apply them shouldn't fail the redeploy.
+	if exp, err := experiment.Get(expName); err == nil {
+		if node := exp.Spec.Topology().FindNodeByName(vmName); node != nil {
+			if err := experiment.ApplyLinkProperties(expName, node); err != nil {
+				plog.Warn(plog.TypeSystem, "unable to apply link properties to redeployed VM", "exp", expName, "vm", vmName, "err", err)
+			}
+		}
+	}
+
 	return nil
 }
 
@@ -761,6 +835,8 @@ func Kill(expName, vmName string) error {
 		return fmt.Errorf("killing VM: %w", err)
 	}
 
+	experiment.RecordEvent(expName, vmName, "VM killed", nil)
+
 	return nil
 }
 
@@ -785,8 +861,11 @@ func Snapshots(expName, vmName string) ([]string, error) {
 }
 
 // Snapshot takes a snapshot of the current state of the VM (disk and memory)
-// snapshots can later be restored.
-func Snapshot(expName, vmName, out string, cb func(string)) error { //nolint:funlen // complex logic
+// snapshots can later be restored. The snapshot is recorded in the VM's
+// snapshot catalog along with the details provided via the given options.
+func Snapshot(expName, vmName, out string, cb func(string), opts ...SnapshotOption) error { //nolint:funlen // complex logic
+	o := newSnapshotOptions(opts...)
+
 	vm, err := Get(expName, vmName)
 	if err != nil {
 		return fmt.Errorf("getting VM details: %w", err)
@@ -797,6 +876,7 @@ func Snapshot(expName, vmName, out string, cb func(string)) error { //nolint:fun
 	}
 
 	out = strings.TrimSuffix(out, filepath.Ext(out))
+	name := out
 	out = fmt.Sprintf("%s_%s__%s", expName, vmName, out)
 
 	// ***** BEGIN: SNAPSHOT VM *****
@@ -907,6 +987,22 @@ func Snapshot(expName, vmName, out string, cb func(string)) error { //nolint:fun
 		return fmt.Errorf("moving disk snapshot to experiment files directory: %w", err)
 	}
 
+	snapshot := SnapshotInfo{ //nolint:exhaustruct // partial initialization
+		Name:         name,
+		VM:           vmName,
+		Description:  o.description,
+		Creator:      o.creator,
+		Created:      time.Now().UTC(),
+		Memory:       true,
+		BackingChain: snapshotBackingChain(host, fmt.Sprintf("%s/%s.hdd", dst, final)),
+	}
+
+	if err := recordSnapshot(expName, snapshot); err != nil {
+		return fmt.Errorf("recording snapshot in catalog: %w", err)
+	}
+
+	experiment.RecordEvent(expName, vmName, "VM snapshot created", map[string]any{"snapshot": name})
+
 	return nil
 }
 
@@ -984,6 +1080,8 @@ func Restore(expName, vmName, snap string) error {
 		return fmt.Errorf("starting VM %s: %w", vmName, err)
 	}
 
+	experiment.RecordEvent(expName, vmName, "VM snapshot restored", map[string]any{"snapshot": filepath.Base(snap)})
+
 	return nil
 }
 
@@ -1412,8 +1510,9 @@ func MemorySnapshot(expName, vmName, out string, cb func(string)) (string, error
 
 // CaptureSubnet starts packet captures for all the VMs that
 // have an interface in the specified subnet.  The vmList argument
-// is optional and defines the list of VMs to search.
-func CaptureSubnet(expName, subnet string, vmList []string) ([]mm.Capture, error) {
+// is optional and defines the list of VMs to search. Each capture
+// is filtered and bounded by the given limits, if any.
+func CaptureSubnet(expName, subnet string, vmList []string, limits mm.CaptureLimits) ([]mm.Capture, error) {
 	// Make sure the experiment is running
 	exp, err := experiment.Get(expName)
 	if err != nil {
@@ -1424,6 +1523,10 @@ func CaptureSubnet(expName, subnet string, vmList []string) ([]mm.Capture, error
 		return nil, errors.New("packet captures can only be started for a running experiment")
 	}
 
+	if err := limits.Validate(); err != nil {
+		return nil, fmt.Errorf("validating capture limits: %w", err)
+	}
+
 	vms, err := List(expName)
 	if err != nil {
 		return nil, fmt.Errorf("getting vm list for %s failed", expName)
@@ -1486,7 +1589,7 @@ func CaptureSubnet(expName, subnet string, vmList []string) ([]mm.Capture, error
 				timeStamp := getTimestamp()
 
 				filename := fmt.Sprintf("%s_%d_%s.pcap", vm.Name, iface, timeStamp)
-				if StartCapture(expName, vm.Name, iface, filename) == nil {
+				if StartCapture(expName, vm.Name, iface, filename, limits) == nil {
 					matchedVMs = append(matchedVMs, vm.Name)
 				}
 			}
@@ -61,10 +61,12 @@ type ntpTypeConfig struct {
 // NTPTemplateData is the data passed to NTP configuration templates.
 // Source is the upstream NTP server IP address (empty string means use the
 // local clock). Server controls whether the config should allow other hosts to
-// use this VM as an NTP source.
+// use this VM as an NTP source. VirtualClock is set when the experiment has a
+// clock configured, in which case large time steps must always be allowed.
 type NTPTemplateData struct {
-	Source string
-	Server bool
+	Source       string
+	Server       bool
+	VirtualClock bool
 }
 
 type NTP struct{}
@@ -108,6 +110,8 @@ func (NTP) preStartWithAppConfig(_ context.Context, exp *types.Experiment, ntpDi
 		"chronyd": {"chrony_linux.tmpl", "/etc/chrony/chrony.conf", ""},
 	}
 
+	virtual := exp.Spec.Clock().Enabled()
+
 	for _, app := range exp.Apps() {
 		if app.Name() != "ntp" {
 			continue
@@ -149,7 +153,7 @@ func (NTP) preStartWithAppConfig(_ context.Context, exp *types.Experiment, ntpDi
 					return fmt.Errorf("unknown NTP client type %s provided for host %s", hmd.Client, host.Hostname())
 				}
 
-				data := NTPTemplateData{Source: source, Server: false}
+				data := NTPTemplateData{Source: source, Server: false, VirtualClock: virtual}
 				if err := tmpl.CreateFileFromTemplate(tc.tmpl, data, cfg); err != nil {
 					return fmt.Errorf("generating NTP client config for host %s: %w", host.Hostname(), err)
 				}
@@ -171,7 +175,14 @@ func (NTP) preStartWithAppConfig(_ context.Context, exp *types.Experiment, ntpDi
 					)
 				}
 
-				data := NTPTemplateData{Source: source, Server: true}
+				// Servers must follow the experiment's virtual time rather than
+				// syncing to a real-time source outside of the experiment, so only
+				// upstream sources that are experiment nodes are used.
+				if virtual && hmd.Source.Hostname == "" {
+					source = ""
+				}
+
+				data := NTPTemplateData{Source: source, Server: true, VirtualClock: virtual}
 				if err := tmpl.CreateFileFromTemplate(tc.tmpl, data, cfg); err != nil {
 					return fmt.Errorf(
 						"generating NTP server config for host %s: %w",
@@ -208,6 +219,8 @@ func (NTP) preStartWithNodeLabels(_ context.Context, exp *types.Experiment, ntpD
 		return fmt.Errorf("creating experiment NTP directory path: %w", err)
 	}
 
+	virtual := exp.Spec.Clock().Enabled()
+
 	// Configure topology nodes as NTP clients.
 	for _, node := range exp.Spec.Topology().Nodes() {
 		if !defaultAppsEnabled(node) {
@@ -226,7 +239,7 @@ func (NTP) preStartWithNodeLabels(_ context.Context, exp *types.Experiment, ntpD
 		ntpFile := ntpDir + "/" + node.General().Hostname() + "_ntp"
 
 		if strings.EqualFold(node.Type(), "router") {
-			data := NTPTemplateData{Source: serverAddr, Server: false}
+			data := NTPTemplateData{Source: serverAddr, Server: false, VirtualClock: virtual}
 			err := tmpl.CreateFileFromTemplate("ntp_linux.tmpl", data, ntpFile)
 			if err != nil {
 				return fmt.Errorf("generating Router NTP script: %w", err)
@@ -244,7 +257,7 @@ func (NTP) preStartWithNodeLabels(_ context.Context, exp *types.Experiment, ntpD
 
 		switch strings.ToLower(node.Hardware().OSType()) {
 		case osLinux, "rhel", "centos":
-			data := NTPTemplateData{Source: serverAddr, Server: false}
+			data := NTPTemplateData{Source: serverAddr, Server: false, VirtualClock: virtual}
 			err := tmpl.CreateFileFromTemplate("ntp_linux.tmpl", data, ntpFile)
 			if err != nil {
 				return fmt.Errorf("generating Linux NTP script: %w", err)
@@ -252,7 +265,7 @@ func (NTP) preStartWithNodeLabels(_ context.Context, exp *types.Experiment, ntpD
 
 			node.AddInject(ntpFile, "/etc/ntp.conf", "", "")
 		case osWindows:
-			data := NTPTemplateData{Source: serverAddr, Server: false}
+			data := NTPTemplateData{Source: serverAddr, Server: false, VirtualClock: virtual}
 			err := tmpl.CreateFileFromTemplate("ntp_windows.tmpl", data, ntpFile)
 			if err != nil {
 				return fmt.Errorf("generating Windows NTP script: %w", err)
@@ -217,3 +217,29 @@ func TestSystemdTimesyncdTemplate(t *testing.T) {
 		t.Fatalf("expected NTP= line in timesyncd config:\n%s", buf.String())
 	}
 }
+
+// TestNTPTemplatesVirtualClock verifies that the NTP templates allow large
+// clock steps at any time when the experiment clock is virtual.
+func TestNTPTemplatesVirtualClock(t *testing.T) {
+	data := app.NTPTemplateData{Source: "10.0.0.1", Server: false, VirtualClock: true}
+
+	var buf bytes.Buffer
+
+	if err := tmpl.GenerateFromTemplate("chrony_linux.tmpl", data, &buf); err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(buf.String(), "makestep 1.0 -1") {
+		t.Fatalf("expected unlimited makestep directive for virtual clock:\n%s", buf.String())
+	}
+
+	buf.Reset()
+
+	if err := tmpl.GenerateFromTemplate("ntp_linux.tmpl", data, &buf); err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(buf.String(), "tinker panic 0") {
+		t.Fatalf("expected tinker panic directive for virtual clock:\n%s", buf.String())
+	}
+}
@@ -0,0 +1,308 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v3"
+
+	"phenix/api/disk"
+	"phenix/api/upload"
+	v1 "phenix/types/version/v1"
+	"phenix/util/mm"
+)
+
+func newDiskCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "disk",
+		Short: "Disk management",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return cmd.Help()
+		},
+	}
+
+	return cmd
+}
+
+func newDiskUploadCmd() *cobra.Command {
+	desc := `Upload a disk image or experiment file to a phēnix server
+
+  Used to upload a file to a phēnix web server using its resumable upload API.
+  The file is sent in chunks, each verified with a SHA-256 checksum, and chunks
+  that fail (e.g., due to a dropped connection) are resent from wherever the
+  server left off. If the upload still fails, it can be resumed later by
+  passing the upload ID printed in the error to --resume.
+
+  By default the file is uploaded as a disk image to the minimega files
+  directory. Use --experiment to upload it to an experiment's files directory
+  instead.`
+
+	cmd := &cobra.Command{
+		Use:   "upload <path>",
+		Short: "Upload a disk image or experiment file to a phēnix server",
+		Long:  desc,
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var (
+				path = args[0]
+				url  = viper.GetString("disk.upload.url")
+				exp  = MustGetString(cmd.Flags(), "experiment")
+				kind = upload.KindDisk
+			)
+
+			if url == "" {
+				return errors.New("the URL of the phēnix server is required")
+			}
+
+			if exp != "" {
+				kind = upload.KindExperimentFile
+			}
+
+			client := upload.NewClient(url, viper.GetString("disk.upload.auth-token"))
+			client.ChunkSize = int64(MustGetInt(cmd.Flags(), "chunk-size")) << 20 //nolint:mnd // MiB
+			client.Retries = MustGetInt(cmd.Flags(), "retries")
+			client.Overwrite = MustGetBool(cmd.Flags(), "overwrite")
+
+			if client.ChunkSize <= 0 {
+				return errors.New("chunk size must be greater than zero")
+			}
+
+			client.Progress = func(u upload.Upload) {
+				fmt.Fprintf(os.Stderr, "\ruploading %s: %.1f%% (%d/%d bytes)", u.Filename, u.Progress(), u.Offset, u.Size)
+			}
+
+			u, err := client.Upload(cmd.Context(), path, kind, exp, MustGetString(cmd.Flags(), "resume"))
+
+			fmt.Fprintln(os.Stderr)
+
+			if err != nil {
+				return fmt.Errorf("uploading %s: %w", path, err)
+			}
+
+			fmt.Fprintf(os.Stdout, "uploaded %s to %s\n", path, u.Path)
+
+			return nil
+		},
+	}
+
+	cmd.Flags().StringP("url", "u", "http://localhost:3000", "URL of the phēnix web server")
+	cmd.Flags().StringP("auth-token", "t", "", "phēnix API token (required if auth is enabled)")
+	cmd.Flags().StringP("experiment", "e", "", "Upload to the given experiment's files directory")
+	cmd.Flags().StringP("resume", "r", "", "ID of a previously interrupted upload to resume")
+	cmd.Flags().Int("chunk-size", 64, "Size of each chunk in MiB") //nolint:mnd // default chunk size
+	cmd.Flags().Int("retries", upload.DefaultRetries, "Number of times to retry a failed chunk")
+	cmd.Flags().Bool("overwrite", false, "Overwrite an existing file with the same name")
+
+	_ = viper.BindPFlag("disk.upload.url", cmd.Flags().Lookup("url"))
+	_ = viper.BindPFlag("disk.upload.auth-token", cmd.Flags().Lookup("auth-token"))
+	_ = viper.BindEnv("disk.upload.url")
+	_ = viper.BindEnv("disk.upload.auth-token")
+
+	return cmd
+}
+
+func newDiskConvertCmd() *cobra.Command {
+	desc := `Convert a disk image to another format
+
+  Used to convert a disk image to a new image in the given format (qcow2, raw,
+  vmdk, vdi, or vhdx) using qemu-img. Relative paths are relative to the
+  minimega files directory. The destination's extension is set to match the
+  format if it doesn't already.`
+
+	cmd := &cobra.Command{
+		Use:   "convert <src> <dst>",
+		Short: "Convert a disk image to another format",
+		Long:  desc,
+		Args:  cobra.ExactArgs(2), //nolint:mnd // src and dst
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var (
+				src    = mm.GetMMFullPath(args[0])
+				dst    = mm.GetMMFullPath(args[1])
+				format = v1.Format(MustGetString(cmd.Flags(), "format"))
+				ext    = disk.FormatExtension(format)
+			)
+
+			if ext == "" {
+				return fmt.Errorf("unsupported disk format %q", format)
+			}
+
+			if !strings.HasSuffix(dst, ext) {
+				dst += ext
+			}
+
+			if err := disk.ConvertDisk(src, dst, format); err != nil {
+				return fmt.Errorf("converting %s: %w", src, err)
+			}
+
+			fmt.Fprintf(os.Stdout, "converted %s to %s\n", src, dst)
+
+			return nil
+		},
+	}
+
+	cmd.Flags().StringP("format", "f", string(v1.FormatQcow2), "Format to convert to (qcow2, raw, vmdk, vdi, vhdx)")
+
+	return cmd
+}
+
+func newDiskImportOVACmd() *cobra.Command {
+	desc := `Import a virtual machine from an OVA
+
+  Used to import a virtual machine exported from another hypervisor as an OVA
+  (or an OVF with its disks in the same directory). Its disks are converted to
+  the given format in the minimega files directory, and a draft topology node
+  with the CPU, memory, and NIC count from the OVF descriptor is printed. The
+  node's interfaces must be assigned to VLANs before it's added to a topology.`
+
+	cmd := &cobra.Command{
+		Use:   "import-ova <file.ova>",
+		Short: "Import a virtual machine from an OVA",
+		Long:  desc,
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var (
+				path   = mm.GetMMFullPath(args[0])
+				name   = MustGetString(cmd.Flags(), "name")
+				format = v1.Format(MustGetString(cmd.Flags(), "format"))
+			)
+
+			// Prefer OVAs relative to the current directory over the files directory.
+			if _, err := os.Stat(args[0]); err == nil {
+				path, _ = filepath.Abs(args[0])
+			}
+
+			imported, err := disk.ImportOVA(path, name, format)
+			if err != nil {
+				return fmt.Errorf("importing %s: %w", args[0], err)
+			}
+
+			for _, d := range imported.Disks {
+				fmt.Fprintf(os.Stderr, "imported disk %s\n", d)
+			}
+
+			node, err := yaml.Marshal([]*v1.Node{imported.Node})
+			if err != nil {
+				return fmt.Errorf("marshaling draft node: %w", err)
+			}
+
+			fmt.Fprint(os.Stdout, string(node))
+
+			return nil
+		},
+	}
+
+	cmd.Flags().StringP("name", "n", "", "Name for the imported VM and its disks (defaults to the name in the OVF)")
+	cmd.Flags().StringP("format", "f", string(v1.FormatQcow2), "Format to convert disks to (qcow2, raw, vmdk, vdi, vhdx)")
+
+	return cmd
+}
+
+func newDiskGCCmd() *cobra.Command {
+	desc := `Delete orphaned disk overlays and snapshots
+
+  Used to prune the minimega files directory. The backing-file graph of every
+  disk image in the directory is built, and overlays and snapshots that aren't
+  referenced by any topology, experiment, snapshot catalog, or running VM (and
+  don't back an image that is) are deleted. Base images are never deleted.
+
+  Use --dry-run to see what would be deleted and how much space it would free.`
+
+	cmd := &cobra.Command{
+		Use:   "gc",
+		Short: "Delete orphaned disk overlays and snapshots",
+		Long:  desc,
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			dryRun := MustGetBool(cmd.Flags(), "dry-run")
+
+			orphans, freed, err := disk.CollectGarbage(dryRun)
+			if len(orphans) > 0 {
+				printTableOfOrphanedImages(os.Stdout, orphans)
+			}
+
+			if err != nil {
+				return fmt.Errorf("collecting orphaned disk images: %w", err)
+			}
+
+			switch {
+			case len(orphans) == 0:
+				fmt.Fprintln(os.Stdout, "\nThere are no orphaned disk images")
+			case dryRun:
+				fmt.Fprintf(os.Stdout, "\nDeleting %d orphaned disk image(s) would free %.1f MB\n", len(orphans), float64(freed)/(1<<20)) //nolint:mnd // bytes to MB
+			default:
+				fmt.Fprintf(os.Stdout, "\nDeleted %d orphaned disk image(s), freeing %.1f MB\n", len(orphans), float64(freed)/(1<<20)) //nolint:mnd // bytes to MB
+			}
+
+			return nil
+		},
+	}
+
+	cmd.Flags().Bool("dry-run", false, "Report orphaned disk images without deleting them")
+
+	return cmd
+}
+
+func newDiskVerifyCmd() *cobra.Command {
+	desc := `Verify disk images against their manifests
+
+  Used to detect disk images that were modified after their manifests were
+  recorded, such as a shared base image edited in place underneath its
+  overlays. Manifests are recorded whenever an image is built, uploaded,
+  cloned, or committed to. Relative paths are relative to the minimega files
+  directory, and every disk image in the directory is verified if no paths are
+  given.
+
+  By default only each image's size and modification time are compared. Use
+  --full to rehash images instead, and --record to record manifests for images
+  that don't have one yet.`
+
+	cmd := &cobra.Command{
+		Use:   "verify [path...]",
+		Short: "Verify disk images against their manifests",
+		Long:  desc,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			results, err := disk.VerifyImages(args, MustGetBool(cmd.Flags(), "full"), MustGetBool(cmd.Flags(), "record"))
+			if err != nil {
+				return fmt.Errorf("verifying disk images: %w", err)
+			}
+
+			printTableOfImageVerifications(os.Stdout, results)
+
+			var modified int
+
+			for _, result := range results {
+				if result.Status == disk.VerifyModified || result.Status == disk.VerifyError {
+					modified++
+				}
+			}
+
+			if modified > 0 {
+				return fmt.Errorf("%d disk image(s) failed verification", modified)
+			}
+
+			return nil
+		},
+	}
+
+	cmd.Flags().Bool("full", false, "Rehash images instead of only comparing size and modification time")
+	cmd.Flags().Bool("record", false, "Record manifests for images that don't have one")
+
+	return cmd
+}
+
+func init() { //nolint:gochecknoinits // cobra command
+	diskCmd := newDiskCmd()
+
+	diskCmd.AddCommand(newDiskUploadCmd())
+	diskCmd.AddCommand(newDiskConvertCmd())
+	diskCmd.AddCommand(newDiskImportOVACmd())
+	diskCmd.AddCommand(newDiskGCCmd())
+	diskCmd.AddCommand(newDiskVerifyCmd())
+
+	addCommandToRoot(diskCmd, true)
+}
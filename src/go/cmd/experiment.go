@@ -2,12 +2,16 @@ package cmd
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"os"
+	"os/user"
 	"path/filepath"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
@@ -15,6 +19,7 @@ import (
 	"phenix/api/config"
 	"phenix/api/experiment"
 	"phenix/api/scorch/scorchexe"
+	"phenix/api/vlan"
 	"phenix/app"
 	"phenix/scheduler"
 	"phenix/store"
@@ -233,6 +238,11 @@ func newExperimentCreateCmd() *cobra.Command {
 				experiment.CreateWithDefaultBridge(MustGetString(cmd.Flags(), "default-bridge")),
 			}
 
+			if pool := MustGetString(cmd.Flags(), "vlan-pool"); pool != "" {
+				annotations := map[string]string{vlan.PoolAnnotation: pool}
+				opts = append(opts, experiment.CreateWithAnnotations(annotations))
+			}
+
 			ctx := notes.Context(context.Background(), false)
 
 			if err := experiment.Create(ctx, opts...); err != nil {
@@ -257,6 +267,8 @@ func newExperimentCreateCmd() *cobra.Command {
 		StringP("default-bridge", "b", "phenix", "Default bridge name to use for experiment (optional)")
 	cmd.Flags().Int("vlan-min", 0, "VLAN pool minimum")
 	cmd.Flags().Int("vlan-max", 0, "VLAN pool maximum")
+	cmd.Flags().
+		String("vlan-pool", "", "Name of VLAN pool to allocate VLAN range from when no min/max is set (optional)")
 	cmd.Flags().StringSlice("disabled-apps", []string{}, "Comma separated ist of apps to disable")
 
 	return cmd
@@ -501,6 +513,8 @@ func newExperimentStartCmd() *cobra.Command {
 					experiment.StartWithMMErrorsAsWarnings(
 						MustGetBool(cmd.Flags(), "treat-mm-errors-as-warnings"),
 					),
+					experiment.StartWithSkipPreflight(MustGetBool(cmd.Flags(), "skip-preflight")),
+					experiment.StartWithVerifyImages(MustGetBool(cmd.Flags(), "verify-images")),
 				}
 
 				err := experiment.Start(ctx, opts...)
@@ -559,6 +573,8 @@ func newExperimentStartCmd() *cobra.Command {
 		Bool("treat-mm-errors-as-warnings", false, "Treat errors from minimega as warnings instead of failing")
 	cmd.Flags().Int("vlan-min", 0, "VLAN pool minimum")
 	cmd.Flags().Int("vlan-max", 0, "VLAN pool maximum")
+	cmd.Flags().Bool("skip-preflight", false, "Skip pre-flight checks before launching the experiment")
+	cmd.Flags().Bool("verify-images", false, "Check backing images of VM disks for modifications during pre-flight checks")
 
 	return cmd
 }
@@ -708,6 +724,8 @@ func newExperimentRestartCmd() *cobra.Command {
 					ctx,
 					experiment.StartWithName(exp.Metadata.Name),
 					experiment.StartWithDryRun(dryrun),
+					experiment.StartWithSkipPreflight(MustGetBool(cmd.Flags(), "skip-preflight")),
+					experiment.StartWithVerifyImages(MustGetBool(cmd.Flags(), "verify-images")),
 				)
 				if err != nil {
 					err := util.HumanizeError(
@@ -736,6 +754,8 @@ func newExperimentRestartCmd() *cobra.Command {
 	}
 
 	cmd.Flags().Bool("dry-run", false, "Do everything but actually call out to minimega")
+	cmd.Flags().Bool("skip-preflight", false, "Skip pre-flight checks before launching the experiment")
+	cmd.Flags().Bool("verify-images", false, "Check backing images of VM disks for modifications during pre-flight checks")
 
 	return cmd
 }
@@ -983,6 +1003,395 @@ func newExperimentScorchCmd() *cobra.Command {
 	return cmd
 }
 
+func newExperimentPreflightCmd() *cobra.Command {
+	desc := `Run pre-flight checks for an experiment
+
+  Used to verify the cluster is able to run the given (stopped) experiment
+  without actually starting it. Checks include drive images existing on the
+  hosts VMs are scheduled to, hosts having enough free memory for the VMs
+  scheduled to them, VLAN IDs not being used by other experiments, bridges and
+  required binaries existing on hosts, and user apps being in PATH. The same
+  checks are run automatically when an experiment is started.
+
+  Use --verify-images to also check the backing images of VM disks for
+  modifications made underneath their overlays, either since their manifests
+  were recorded or since the overlays were last written.`
+
+	cmd := &cobra.Command{
+		Use:               "preflight <experiment name>",
+		Short:             "Run pre-flight checks for an experiment",
+		Long:              desc,
+		ValidArgsFunction: expNameCompletion(false),
+		Args:              argsWithUsage(cobra.ExactArgs(1)),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			name := args[0]
+
+			report, err := experiment.Preflight(
+				name,
+				experiment.PreflightWithVerifyImages(MustGetBool(cmd.Flags(), "verify-images")),
+			)
+			if err != nil {
+				err := util.HumanizeError(err, "%s", "Unable to run pre-flight checks for the "+name+" experiment")
+
+				return err.Humanized()
+			}
+
+			switch output := MustGetString(cmd.Flags(), "output"); output {
+			case "table":
+				printTableOfPreflightChecks(os.Stdout, report.Checks...)
+			case FormatJSON:
+				body, err := json.MarshalIndent(report, "", "  ")
+				if err != nil {
+					err := util.HumanizeError(err, "Unable to convert pre-flight report to JSON")
+
+					return err.Humanized()
+				}
+
+				fmt.Fprintln(os.Stdout, string(body))
+			default:
+				return fmt.Errorf("unrecognized output format '%s'", output)
+			}
+
+			if err := report.Err(); err != nil {
+				err := util.HumanizeError(err, "%s", "Pre-flight checks failed for the "+name+" experiment")
+
+				return err.Humanized()
+			}
+
+			plog.Info(plog.TypeSystem, "experiment pre-flight checks passed", "exp", name)
+
+			return nil
+		},
+	}
+
+	cmd.Flags().StringP("output", "o", "table", "Pre-flight report output format ('table' or 'json')")
+	cmd.Flags().Bool("verify-images", false, "Check backing images of VM disks for modifications")
+
+	return cmd
+}
+
+func newExperimentNoteCmd() *cobra.Command {
+	desc := `Add a note to an experiment journal
+
+  Used to add a timestamped annotation to the journal of the given experiment.
+  Structured data can be attached to the note using one or more '--data
+  key=value' flags. The note is attributed to the current user.`
+
+	cmd := &cobra.Command{
+		Use:               "note <experiment name> <message>",
+		Short:             "Add a note to an experiment journal",
+		Long:              desc,
+		ValidArgsFunction: expNameCompletion(false),
+		Args:              argsWithUsage(cobra.MinimumNArgs(2)), //nolint:mnd // name and message
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var (
+				name  = args[0]
+				entry = experiment.JournalEntry{ //nolint:exhaustruct // partial initialization
+					Source:  MustGetString(cmd.Flags(), "source"),
+					Author:  currentUsername(),
+					VM:      MustGetString(cmd.Flags(), "vm"),
+					Message: strings.Join(args[1:], " "),
+				}
+			)
+
+			switch entry.Source {
+			case experiment.JournalSourceUser, experiment.JournalSourceApp:
+			default:
+				return fmt.Errorf("unsupported note source '%s'", entry.Source)
+			}
+
+			for _, kv := range MustGetStringArray(cmd.Flags(), "data") {
+				key, value, ok := strings.Cut(kv, "=")
+				if !ok || key == "" {
+					return fmt.Errorf("invalid note data '%s' (expected key=value)", kv)
+				}
+
+				if entry.Data == nil {
+					entry.Data = make(map[string]any)
+				}
+
+				entry.Data[key] = value
+			}
+
+			if _, err := experiment.AddJournalEntry(name, entry); err != nil {
+				err := util.HumanizeError(err, "%s", "Unable to add note to the "+name+" experiment")
+
+				return err.Humanized()
+			}
+
+			plog.Info(plog.TypeSystem, "experiment note added", "exp", name)
+
+			return nil
+		},
+	}
+
+	cmd.Flags().StringArrayP("data", "d", nil, "Structured data to attach to the note (key=value)")
+	cmd.Flags().String("vm", "", "Name of VM the note applies to")
+	cmd.Flags().String("source", experiment.JournalSourceUser, "Source of the note ('user' or 'app')")
+
+	return cmd
+}
+
+func newExperimentClockCmd() *cobra.Command {
+	desc := `View or set the virtual time of an experiment
+
+  Used to view the current virtual time of the VMs in a running experiment.
+  The initial virtual time is configured via the experiment's clock setting,
+  either as a fixed start time or as an offset from the current time.`
+
+	cmd := &cobra.Command{
+		Use:               "clock <experiment name>",
+		Short:             "View or set the virtual time of an experiment",
+		Long:              desc,
+		ValidArgsFunction: expNameCompletion(false),
+		Args:              argsWithUsage(cobra.ExactArgs(1)),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			exp, err := experiment.Get(args[0])
+			if err != nil {
+				err := util.HumanizeError(err, "%s", "Unable to get the "+args[0]+" experiment")
+
+				return err.Humanized()
+			}
+
+			if !exp.Running() {
+				return fmt.Errorf("experiment %s is not running", args[0])
+			}
+
+			offset := exp.Status.ClockOffset()
+
+			fmt.Fprintf(
+				os.Stdout,
+				"%s (offset %s)\n",
+				time.Now().Add(offset).UTC().Format(time.RFC3339),
+				offset,
+			)
+
+			return nil
+		},
+	}
+
+	set := &cobra.Command{
+		Use:   "set <experiment name> <time|offset>",
+		Short: "Shift the virtual time of a running experiment",
+		Long: `Shift the virtual time of a running experiment
+
+  Used to shift the clocks of all the VMs in a running experiment via miniccc.
+  The time can be given as an RFC3339 timestamp (e.g., 2019-03-01T08:00:00Z) or
+  as an offset from the current time (e.g., -8760h). VMs without an active
+  miniccc agent are skipped.`,
+		ValidArgsFunction: expNameCompletion(false),
+		Args:              argsWithUsage(cobra.ExactArgs(2)), //nolint:mnd // name and time
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := notes.Context(context.Background(), false)
+
+			virtual, err := experiment.SetClock(ctx, args[0], args[1])
+			if err != nil {
+				err := util.HumanizeError(err, "%s", "Unable to set the clock for the "+args[0]+" experiment")
+
+				return err.Humanized()
+			}
+
+			notes.PrettyPrint(ctx, false)
+
+			plog.Info(
+				plog.TypeSystem,
+				"experiment clock set",
+				"exp", args[0],
+				"time", virtual.UTC().Format(time.RFC3339),
+			)
+
+			return nil
+		},
+	}
+
+	cmd.AddCommand(set)
+
+	return cmd
+}
+
+func newExperimentJournalCmd() *cobra.Command {
+	desc := `Display or export an experiment journal
+
+  Used to display the journal of the given experiment, including notes added by
+  users and apps and events recorded automatically by phenix. The journal can
+  be exported as JSON or CSV using the '--output' flag.`
+
+	cmd := &cobra.Command{
+		Use:               "journal <experiment name>",
+		Short:             "Display or export an experiment journal",
+		Long:              desc,
+		ValidArgsFunction: expNameCompletion(false),
+		Args:              argsWithUsage(cobra.ExactArgs(1)),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var (
+				name   = args[0]
+				output = MustGetString(cmd.Flags(), "output")
+				file   = MustGetString(cmd.Flags(), "file")
+				since  time.Time
+			)
+
+			if s := MustGetString(cmd.Flags(), "since"); s != "" {
+				var err error
+
+				since, err = time.Parse(time.RFC3339, s)
+				if err != nil {
+					return fmt.Errorf("invalid since timestamp '%s' (expected RFC3339)", s)
+				}
+			}
+
+			entries, err := experiment.Journal(name, since)
+			if err != nil {
+				err := util.HumanizeError(err, "%s", "Unable to get journal for the "+name+" experiment")
+
+				return err.Humanized()
+			}
+
+			if output == "table" {
+				if len(entries) == 0 {
+					plog.Warn(plog.TypeSystem, "no journal entries available", "exp", name)
+				} else {
+					printTableOfJournalEntries(os.Stdout, entries...)
+				}
+
+				return nil
+			}
+
+			var w io.Writer = os.Stdout
+
+			if file != "" {
+				f, err := os.Create(file) //nolint:gosec // user provided path
+				if err != nil {
+					return fmt.Errorf("creating journal export file: %w", err)
+				}
+
+				defer f.Close()
+
+				w = f
+			}
+
+			if err := experiment.ExportJournal(w, entries, output); err != nil {
+				err := util.HumanizeError(err, "%s", "Unable to export journal for the "+name+" experiment")
+
+				return err.Humanized()
+			}
+
+			if file != "" {
+				plog.Info(plog.TypeSystem, "experiment journal exported", "exp", name, "path", file)
+			}
+
+			return nil
+		},
+	}
+
+	cmd.Flags().StringP("output", "o", "table", "Journal output format ('table', 'json', or 'csv')")
+	cmd.Flags().StringP("file", "f", "", "Path to write exported journal to (defaults to STDOUT)")
+	cmd.Flags().String("since", "", "Only include entries after the given RFC3339 timestamp")
+
+	return cmd
+}
+
+func newExperimentCapturesCmd() *cobra.Command {
+	desc := `Work with the packet captures recorded for an experiment
+
+  Used to work with the packet captures recorded for VMs in an experiment; see
+  command help for merge for additional arguments.`
+
+	cmd := &cobra.Command{
+		Use:   "captures",
+		Short: "Work with experiment packet captures",
+		Long:  desc,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return cmd.Help()
+		},
+	}
+
+	merge := &cobra.Command{
+		Use:   "merge <experiment name>",
+		Short: "Merge experiment packet captures into a single PCAPNG file",
+		Long: `Merge experiment packet captures into a single PCAPNG file
+
+  Used to merge all the per-VM and per-subnet packet captures recorded for an
+  experiment into a single time-ordered PCAPNG file, with an interface for each
+  VM interface captured. Captures still in progress are not included.`,
+		ValidArgsFunction: expNameCompletion(false),
+		Args:              argsWithUsage(cobra.ExactArgs(1)),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var (
+				name = args[0]
+				out  = MustGetString(cmd.Flags(), "output")
+				opts = []experiment.MergeCapturesOption{
+					experiment.MergeCapturesWithVLAN(MustGetString(cmd.Flags(), "vlan")),
+				}
+			)
+
+			for _, flag := range []string{"from", "to"} {
+				s := MustGetString(cmd.Flags(), flag)
+				if s == "" {
+					continue
+				}
+
+				ts, err := time.Parse(time.RFC3339, s)
+				if err != nil {
+					return fmt.Errorf("invalid %s timestamp '%s' (expected RFC3339)", flag, s)
+				}
+
+				if flag == "from" {
+					opts = append(opts, experiment.MergeCapturesFrom(ts))
+				} else {
+					opts = append(opts, experiment.MergeCapturesTo(ts))
+				}
+			}
+
+			if out == "" {
+				out = name + "-captures.pcapng"
+			}
+
+			f, err := os.Create(out) //nolint:gosec // user provided path
+			if err != nil {
+				return fmt.Errorf("creating merged capture file: %w", err)
+			}
+
+			defer f.Close()
+
+			count, err := experiment.MergeCaptures(name, f, opts...)
+			if err != nil {
+				_ = os.Remove(out)
+
+				err := util.HumanizeError(err, "%s", "Unable to merge packet captures for the "+name+" experiment")
+
+				return err.Humanized()
+			}
+
+			plog.Info(plog.TypeSystem, "experiment packet captures merged", "exp", name, "path", out, "packets", count)
+
+			return nil
+		},
+	}
+
+	merge.Flags().StringP("output", "o", "", "Path to write merged capture to (defaults to <experiment name>-captures.pcapng)")
+	merge.Flags().String("vlan", "", "Only include captures recorded on the given VLAN alias")
+	merge.Flags().String("from", "", "Only include packets captured after the given RFC3339 timestamp")
+	merge.Flags().String("to", "", "Only include packets captured before the given RFC3339 timestamp")
+
+	cmd.AddCommand(merge)
+
+	return cmd
+}
+
+// currentUsername returns the name of the user running phenix, preferring the
+// user that invoked sudo if applicable.
+func currentUsername() string {
+	if sudo := os.Getenv("SUDO_USER"); sudo != "" && sudoRanPhenix() {
+		return sudo
+	}
+
+	if u, err := user.Current(); err == nil {
+		return u.Username
+	}
+
+	return ""
+}
+
 func init() { //nolint:gochecknoinits // cobra command
 	experimentCmd := newExperimentCmd()
 
@@ -999,6 +1408,11 @@ func init() { //nolint:gochecknoinits // cobra command
 	experimentCmd.AddCommand(newExperimentReconfigureCmd())
 	experimentCmd.AddCommand(newExperimentTriggerRunningCmd())
 	experimentCmd.AddCommand(newExperimentScorchCmd())
+	experimentCmd.AddCommand(newExperimentPreflightCmd())
+	experimentCmd.AddCommand(newExperimentNoteCmd())
+	experimentCmd.AddCommand(newExperimentJournalCmd())
+	experimentCmd.AddCommand(newExperimentClockCmd())
+	experimentCmd.AddCommand(newExperimentCapturesCmd())
 
 	addCommandToRoot(experimentCmd, true)
 }
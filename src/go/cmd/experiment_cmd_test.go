@@ -23,6 +23,10 @@ func TestExperimentCommandsShowUsageForMissingArguments(t *testing.T) {
 		{name: "reconfigure", newCommand: newExperimentReconfigureCmd},
 		{name: "trigger-running", newCommand: newExperimentTriggerRunningCmd},
 		{name: "scorch", newCommand: newExperimentScorchCmd},
+		{name: "preflight", newCommand: newExperimentPreflightCmd},
+		{name: "note", newCommand: newExperimentNoteCmd},
+		{name: "journal", newCommand: newExperimentJournalCmd},
+		{name: "clock", newCommand: newExperimentClockCmd},
 	}
 
 	for _, test := range tests {
@@ -2,6 +2,7 @@ package cmd
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"os"
@@ -85,11 +86,17 @@ func newImageCreateCmd() *cobra.Command {
 	When specifying the --size option, the following units can be used:
 
 	M - Megabytes
-	G - Gigabytes`
+	G - Gigabytes
+
+	Debian, Ubuntu, and Kali images are built with vmdb2 and debootstrap. Rocky,
+	Alma, and Fedora images are built with dnf when the rpm-minbase variant is
+	used, in which case the release is the distro and version (e.g., rocky9) and
+	the components are the repositories to install packages from.`
 
 	example := `
   phenix image create <image name>
-  phenix image create --size 2G --variant mingui --release noble --compress --overlays foobar --packages foo --scripts bar <image name>`
+  phenix image create --size 2G --variant mingui --release noble --compress --overlays foobar --packages foo --scripts bar <image name>
+  phenix image create --variant rpm-minbase --release rocky9 <image name>`
 
 	cmd := &cobra.Command{
 		Use:     "create <image name>",
@@ -161,10 +168,12 @@ func newImageCreateCmd() *cobra.Command {
 	}
 
 	cmd.Flags().StringP("size", "s", "10G", "Image size to use")
-	cmd.Flags().StringP("variant", "v", "minbase", "Image variant to use")
-	cmd.Flags().StringP("release", "r", "jammy", "OS release codename")
 	cmd.Flags().
-		StringP("mirror", "m", "http://us.archive.ubuntu.com/ubuntu", "Debootstrap mirror (must match release)")
+		StringP("variant", "v", "minbase", "Image variant to use (minbase, mingui, or rpm-minbase for RPM releases)")
+	cmd.Flags().
+		StringP("release", "r", "jammy", "OS release codename (or distro and version for RPM releases, e.g. rocky9, alma9, fedora41)")
+	cmd.Flags().
+		StringP("mirror", "m", "http://us.archive.ubuntu.com/ubuntu", "Debootstrap or dnf mirror (must match release)")
 	cmd.Flags().
 		StringP("components", "l", "", "List of components from the mirror to download packages from (separated by comma)")
 	cmd.Flags().StringP("format", "f", "qcow2", "Format of disk image")
@@ -302,7 +311,7 @@ func newImageBuildCmd() *cobra.Command {
 	desc := `Build a virtual disk image
 
   Used to build a new virtual disk using an existing configuration; vmdb2 must
-  be in path.`
+  be in path (or dnf, for configurations with an RPM variant).`
 
 	example := `
   phenix image build <configuration name>
@@ -376,6 +385,68 @@ func newImageBuildCmd() *cobra.Command {
 	return cmd
 }
 
+func newImageInspectCmd() *cobra.Command {
+	desc := `Inspect a built virtual disk image
+
+  Used to show the build manifest written next to a disk image when it was
+  built, including the packages (and their versions) installed in the image and
+  the overlays and scripts applied to it. The image can be given as a path or
+  relative to the minimega files directory. Use '--output spdx' to export the
+  manifest as an SPDX JSON software bill of materials.`
+
+	example := `
+  phenix image inspect ubuntu.qc2
+  phenix image inspect --output spdx /path/to/ubuntu.qc2 > ubuntu.spdx.json`
+
+	cmd := &cobra.Command{
+		Use:     "inspect <image>",
+		Short:   "Inspect a built virtual disk image",
+		Long:    desc,
+		Example: example,
+		Args:    argsWithUsage(cobra.ExactArgs(1)),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			path := image.ResolveImagePath(args[0])
+
+			m, err := image.ReadBuildManifest(path)
+			if err != nil {
+				err := util.HumanizeError(err, "%s", "Unable to read the build manifest for the "+args[0]+" image")
+
+				return err.Humanized()
+			}
+
+			var v any
+
+			switch output := MustGetString(cmd.Flags(), "output"); output {
+			case "table":
+				printImageBuildManifest(os.Stdout, m)
+
+				return nil
+			case FormatJSON:
+				v = m
+			case "spdx":
+				v = m.SPDX()
+			default:
+				return fmt.Errorf("unrecognized output format '%s'", output)
+			}
+
+			body, err := json.MarshalIndent(v, "", "  ")
+			if err != nil {
+				err := util.HumanizeError(err, "Unable to convert build manifest to JSON")
+
+				return err.Humanized()
+			}
+
+			fmt.Fprintln(os.Stdout, string(body))
+
+			return nil
+		},
+	}
+
+	cmd.Flags().StringP("output", "o", "table", "Build manifest output format ('table', 'json', or 'spdx')")
+
+	return cmd
+}
+
 func newImageDeleteCmd() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "delete <configuration name>",
@@ -542,6 +613,109 @@ func newImageUpdateCmd() *cobra.Command {
 	return cmd
 }
 
+func newImageInjectMinicccCmd() *cobra.Command {
+	desc := `Inject the miniccc agent into a Linux disk image
+
+  Used to add the miniccc agent and a service to start it at boot into an
+  existing Linux disk image (for example, a vendor-supplied appliance image), so
+  features that rely on the agent (state of health, mounting VM filesystems,
+  C2, etc.) work with VMs using the image. The agent is injected into
+  /usr/local/bin/miniccc on the given partition, and the service is injected
+  into the appropriate locations for the image's init system (systemd, sysv,
+  or openrc).
+
+  Use the verify subcommand to check an image has the agent installed and
+  configured to start at boot for its init system.`
+
+	example := `
+  phenix image inject-miniccc /phenix/images/appliance.qc2 --init openrc --partition 2
+  phenix image inject-miniccc verify /phenix/images/appliance.qc2`
+
+	cmd := &cobra.Command{
+		Use:     "inject-miniccc <path to disk>",
+		Short:   "Inject the miniccc agent into a Linux disk image",
+		Long:    desc,
+		Example: example,
+		Args:    argsWithUsage(cobra.ExactArgs(1)),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var (
+				disk      = args[0]
+				exe       = MustGetString(cmd.Flags(), "miniccc")
+				init      = MustGetString(cmd.Flags(), "init")
+				partition = MustGetInt(cmd.Flags(), "partition")
+			)
+
+			err := image.InjectMiniccc(exe, disk, partition, init)
+			if err != nil {
+				err := util.HumanizeError(err, "%s", "Unable to inject miniccc into the "+disk+" image")
+
+				return err.Humanized()
+			}
+
+			plog.Info(plog.TypeSystem, "miniccc injected into image", "disk", disk, "init", init)
+
+			return nil
+		},
+	}
+
+	cmd.Flags().String("init", image.InitSystemd, "Init system to start miniccc with (systemd, sysv, or openrc)")
+	cmd.Flags().Int("partition", 0, "Partition to inject into (defaults to the partition in the disk path, or 1)")
+	cmd.Flags().String("miniccc", "/opt/minimega/bin/miniccc", "Path to the miniccc executable to inject")
+
+	verify := &cobra.Command{
+		Use:   "verify <path to disk>",
+		Short: "Verify the miniccc agent is configured in a Linux disk image",
+		Long: `Verify the miniccc agent is configured in a Linux disk image
+
+  Used to check the miniccc agent is installed in a Linux disk image and
+  configured to start at boot and connect to minimega for the image's init
+  system. The image is mounted read-only on the headnode to be checked, so it
+  must not be in use by a running VM.`,
+		Args: argsWithUsage(cobra.ExactArgs(1)),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			disk := args[0]
+
+			report, err := image.VerifyMiniccc(disk, MustGetInt(cmd.Flags(), "partition"))
+			if err != nil {
+				err := util.HumanizeError(err, "%s", "Unable to verify miniccc in the "+disk+" image")
+
+				return err.Humanized()
+			}
+
+			switch output := MustGetString(cmd.Flags(), "output"); output {
+			case "table":
+				printTableOfAgentChecks(os.Stdout, report.Checks...)
+			case FormatJSON:
+				body, err := json.MarshalIndent(report, "", "  ")
+				if err != nil {
+					err := util.HumanizeError(err, "Unable to convert agent report to JSON")
+
+					return err.Humanized()
+				}
+
+				fmt.Fprintln(os.Stdout, string(body))
+			default:
+				return fmt.Errorf("unrecognized output format '%s'", output)
+			}
+
+			if err := report.Err(); err != nil {
+				err := util.HumanizeError(err, "%s", "miniccc is not configured in the "+disk+" image")
+
+				return err.Humanized()
+			}
+
+			return nil
+		},
+	}
+
+	verify.Flags().Int("partition", 0, "Partition to verify (defaults to the partition in the disk path, or 1)")
+	verify.Flags().StringP("output", "o", "table", "Agent report output format ('table' or 'json')")
+
+	cmd.AddCommand(verify)
+
+	return cmd
+}
+
 func newImageInjectMiniExeCmd() *cobra.Command {
 	desc := `Inject a minimega executable into a disk image
 
@@ -563,8 +737,11 @@ func newImageInjectMiniExeCmd() *cobra.Command {
 	In a Linux disk image, the minimega executable will be injected into
 	/usr/local/bin and the service file and symlinks will be injected into the
 	appropriate locations, depending on which init system is being used. For
-	systemd, they will be injected into /etc/systemd/system, and for sysinitv they
-	will be injected into /etc/init.d and /etc/rc5.d. The protonuke service will
+	systemd, they will be injected into /etc/systemd/system, for sysinitv they
+	will be injected into /etc/init.d and /etc/rc5.d, and for openrc (miniccc
+	only) they will be injected into /etc/init.d and /etc/runlevels/default. See
+	'phenix image inject-miniccc' for injecting miniccc into Linux appliance
+	images and verifying it's configured. The protonuke service will
 	only start if a file is present at /etc/default/protonuke, and that file
 	should contain a single line setting the PROTONUKE_ARGS variable to a set of
 	protonuke command line arguments. The minirouter service expects the miniccc
@@ -602,7 +779,7 @@ func newImageInjectMiniExeCmd() *cobra.Command {
 	}
 
 	cmd.Flags().
-		String("init-system", "systemd", "Linux init system to generate boot scripts for (Linux: systemd, sysinitv; Windows: startup)")
+		String("init-system", "systemd", "Linux init system to generate boot scripts for (Linux: systemd, sysinitv, openrc; Windows: startup)")
 
 	return cmd
 }
@@ -615,11 +792,13 @@ func init() { //nolint:gochecknoinits // cobra command
 	imageCmd.AddCommand(newImageCreateFromCmd())
 	imageCmd.AddCommand(newImageEditCmd())
 	imageCmd.AddCommand(newImageBuildCmd())
+	imageCmd.AddCommand(newImageInspectCmd())
 	imageCmd.AddCommand(newImageDeleteCmd())
 	imageCmd.AddCommand(newImageAppendCmd())
 	imageCmd.AddCommand(newImageRemoveCmd())
 	imageCmd.AddCommand(newImageUpdateCmd())
 	imageCmd.AddCommand(newImageInjectMiniExeCmd())
+	imageCmd.AddCommand(newImageInjectMinicccCmd())
 
 	addCommandToRoot(imageCmd, true)
 }
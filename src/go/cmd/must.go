@@ -2,6 +2,7 @@ package cmd
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/spf13/pflag"
 )
@@ -41,3 +42,12 @@ func MustGetStringArray(flags *pflag.FlagSet, name string) []string {
 
 	return val
 }
+
+func MustGetDuration(flags *pflag.FlagSet, name string) time.Duration {
+	val, err := flags.GetDuration(name)
+	if err != nil {
+		panic(fmt.Sprintf("Getting value for %s: %v", name, err))
+	}
+
+	return val
+}
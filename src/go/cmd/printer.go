@@ -0,0 +1,247 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/olekukonko/tablewriter"
+
+	"phenix/api/disk"
+	"phenix/api/experiment"
+	"phenix/api/image"
+	"phenix/api/vlan"
+	"phenix/api/vm"
+)
+
+func printTableOfVLANPools(writer io.Writer, pools []vlan.Pool) {
+	table := tablewriter.NewWriter(writer)
+	table.SetHeader([]string{"Pool", "VLAN Range", "Size", "Roles", "Experiment", "Allocation"})
+	table.SetAutoMergeCellsByColumnIndex([]int{0, 1, 2, 3})
+
+	for _, pool := range pools {
+		var (
+			r     = fmt.Sprintf("%d - %d", pool.Min, pool.Max)
+			size  = strconv.Itoa(pool.Size)
+			roles = strings.Join(pool.Roles, ", ")
+		)
+
+		if len(pool.Allocations) == 0 {
+			table.Append([]string{pool.Name, r, size, roles, "", ""})
+
+			continue
+		}
+
+		for _, alloc := range pool.Allocations {
+			a := fmt.Sprintf("%d - %d", alloc.Min, alloc.Max)
+
+			table.Append([]string{pool.Name, r, size, roles, alloc.Experiment, a})
+		}
+	}
+
+	table.Render()
+}
+
+// printTableOfJournalEntries writes the given experiment journal entries to the
+// given writer as an ASCII table. The table headers are set to Timestamp,
+// Source, Author, VM, Message, and Data.
+func printTableOfJournalEntries(writer io.Writer, entries ...experiment.JournalEntry) {
+	table := tablewriter.NewWriter(writer)
+
+	table.SetHeader([]string{"Timestamp", "Source", "Author", "VM", "Message", "Data"})
+	table.SetAutoWrapText(false)
+
+	for _, entry := range entries {
+		var data string
+
+		if len(entry.Data) > 0 {
+			body, _ := json.Marshal(entry.Data)
+			data = string(body)
+		}
+
+		table.Append([]string{
+			entry.Timestamp.Local().Format(time.RFC3339),
+			entry.Source,
+			entry.Author,
+			entry.VM,
+			entry.Message,
+			data,
+		})
+	}
+
+	table.Render()
+}
+
+// printTableOfPreflightChecks writes the given experiment pre-flight checks to
+// the given writer as an ASCII table. The table headers are set to Check, Host,
+// Target, Status, and Message.
+func printTableOfPreflightChecks(writer io.Writer, checks ...experiment.PreflightCheck) {
+	table := tablewriter.NewWriter(writer)
+
+	table.SetHeader([]string{"Check", "Host", "Target", "Status", "Message"})
+	table.SetAutoWrapText(false)
+
+	for _, c := range checks {
+		table.Append([]string{c.Check, c.Host, c.Target, strings.ToUpper(c.Status), c.Message})
+	}
+
+	table.Render()
+}
+
+func printTableOfBatchResults(writer io.Writer, results []vm.BatchResult) {
+	table := tablewriter.NewWriter(writer)
+
+	table.SetHeader([]string{"VM", "Status", "Error"})
+	table.SetAutoWrapText(false)
+
+	for _, r := range results {
+		status := "OK"
+
+		if r.Error != "" {
+			status = "FAILED"
+		}
+
+		table.Append([]string{r.VM, status, r.Error})
+	}
+
+	table.Render()
+}
+
+func printTableOfSnapshots(writer io.Writer, snapshots []vm.SnapshotInfo) {
+	table := tablewriter.NewWriter(writer)
+
+	table.SetHeader([]string{"VM", "Name", "Created", "Creator", "Memory", "Size (MB)", "Description"})
+	table.SetAutoWrapText(false)
+
+	for _, s := range snapshots {
+		var (
+			created = "unknown"
+			size    = fmt.Sprintf("%.1f", float64(s.Size)/(1<<20)) //nolint:mnd // bytes to MB
+		)
+
+		if !s.Created.IsZero() {
+			created = s.Created.Local().Format(time.RFC3339)
+		}
+
+		if s.Missing {
+			size = "missing"
+		}
+
+		if !s.Cataloged {
+			created += " (uncataloged)"
+		}
+
+		table.Append([]string{
+			s.VM, s.Name, created, s.Creator, strconv.FormatBool(s.Memory), size, s.Description,
+		})
+	}
+
+	table.Render()
+}
+
+func printTableOfOrphanedImages(writer io.Writer, images []disk.GraphImage) {
+	table := tablewriter.NewWriter(writer)
+
+	table.SetHeader([]string{"Image", "Backing Image", "Size (MB)"})
+	table.SetAutoWrapText(false)
+
+	var total int64
+
+	for _, image := range images {
+		table.Append([]string{
+			image.Path,
+			image.Backing,
+			fmt.Sprintf("%.1f", float64(image.Size)/(1<<20)), //nolint:mnd // bytes to MB
+		})
+
+		total += image.Size
+	}
+
+	table.SetFooter([]string{"", "Total", fmt.Sprintf("%.1f", float64(total)/(1<<20))}) //nolint:mnd // bytes to MB
+	table.Render()
+}
+
+func printTableOfImageVerifications(writer io.Writer, results []disk.ImageVerification) {
+	table := tablewriter.NewWriter(writer)
+
+	table.SetHeader([]string{"Image", "Status", "SHA-256", "Message"})
+	table.SetAutoWrapText(false)
+
+	for _, result := range results {
+		table.Append([]string{result.Path, result.Status, result.SHA256, result.Message})
+	}
+
+	table.Render()
+}
+
+func printTableOfAgentChecks(writer io.Writer, checks ...image.AgentCheck) {
+	table := tablewriter.NewWriter(writer)
+
+	table.SetHeader([]string{"Check", "Status", "Message"})
+	table.SetAutoWrapText(false)
+
+	for _, c := range checks {
+		table.Append([]string{c.Check, strings.ToUpper(c.Status), c.Message})
+	}
+
+	table.Render()
+}
+
+// printImageBuildManifest writes the given build manifest to the given writer
+// as an ASCII table describing the build, followed by a table of the packages
+// installed in the image and a table of the overlays and scripts applied.
+func printImageBuildManifest(writer io.Writer, m image.BuildManifest) {
+	table := tablewriter.NewWriter(writer)
+
+	table.SetAutoWrapText(false)
+
+	table.AppendBulk([][]string{
+		{"Image", m.Image},
+		{"Path", m.Path},
+		{"SHA-256", m.SHA256},
+		{"Variant", m.Variant},
+		{"Release", m.Release},
+		{"Distro", m.Distro},
+		{"Mirror", m.Mirror},
+		{"Components", strings.Join(m.Components, ", ")},
+		{"Kernel", strings.Join(m.Kernel, " ")},
+		{"Format", string(m.Format)},
+		{"Built", m.Built.Format(time.RFC3339)},
+		{"phenix Version", m.PhenixVersion},
+	})
+
+	table.Render()
+
+	table = tablewriter.NewWriter(writer)
+
+	table.SetHeader([]string{"Package", "Version", "Arch"})
+	table.SetAutoWrapText(false)
+
+	for _, pkg := range m.Packages {
+		table.Append([]string{pkg.Name, pkg.Version, pkg.Arch})
+	}
+
+	table.Render()
+
+	if len(m.Overlays) == 0 && len(m.Scripts) == 0 {
+		return
+	}
+
+	table = tablewriter.NewWriter(writer)
+
+	table.SetHeader([]string{"Type", "Name", "SHA-256"})
+	table.SetAutoWrapText(false)
+
+	for _, overlay := range m.Overlays {
+		table.Append([]string{"overlay", overlay.Name, overlay.SHA256})
+	}
+
+	for _, script := range m.Scripts {
+		table.Append([]string{"script", script.Name, script.SHA256})
+	}
+
+	table.Render()
+}
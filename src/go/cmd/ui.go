@@ -7,6 +7,7 @@ import (
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 
+	"phenix/api/vm"
 	"phenix/util"
 	"phenix/util/plog"
 	"phenix/web"
@@ -43,6 +44,15 @@ func newUICmd() *cobra.Command {
 				web.ServeWithFeatures(viper.GetStringSlice("ui.features")),
 				web.ServeWithProxyAuthHeader(viper.GetString("ui.proxy-auth-header")),
 				web.ServeWithUnixSocketGID(viper.GetInt("unix-socket-gid")),
+				web.ServeWithVMMetrics(
+					viper.GetDuration("ui.metrics.interval"),
+					viper.GetDuration("ui.metrics.retention"),
+				),
+				web.ServeWithVNCRecording(viper.GetBool("ui.vnc-recording")),
+				web.ServeWithSerialConsoles(
+					viper.GetInt("ui.serial.scrollback"),
+					viper.GetBool("ui.serial.logging"),
+				),
 			}
 
 			if level := viper.GetString("ui.logs.level"); level != "" {
@@ -96,6 +106,11 @@ func newUICmd() *cobra.Command {
 	uiCmd.Flags().String("logs.level", "", "log level to publish to UI. Defaults to file level")
 	uiCmd.Flags().StringSlice("features", nil, "list of features to enable (options: vm-mount)")
 	uiCmd.Flags().Bool("minimega-console", false, "enable minimega console access in UI")
+	uiCmd.Flags().Duration("metrics.interval", vm.DefaultMetricsInterval, "how often to collect VM metrics (0 to disable)")
+	uiCmd.Flags().Duration("metrics.retention", vm.DefaultMetricsRetention, "how long to keep VM metrics history")
+	uiCmd.Flags().Bool("vnc-recording", false, "record VNC sessions to each experiment's files directory")
+	uiCmd.Flags().Int("serial.scrollback", vm.DefaultSerialScrollback, "bytes of serial console output to keep for each VM")
+	uiCmd.Flags().Bool("serial.logging", false, "log VM serial console output to each experiment's files directory")
 
 	_ = viper.BindPFlag("ui.listen-endpoint", uiCmd.Flags().Lookup("listen-endpoint"))
 	_ = viper.BindPFlag("ui.base-path", uiCmd.Flags().Lookup("base-path"))
@@ -110,6 +125,11 @@ func newUICmd() *cobra.Command {
 	_ = viper.BindPFlag("ui.logs.level", uiCmd.Flags().Lookup("logs.level"))
 	_ = viper.BindPFlag("ui.features", uiCmd.Flags().Lookup("features"))
 	_ = viper.BindPFlag("ui.minimega-console", uiCmd.Flags().Lookup("minimega-console"))
+	_ = viper.BindPFlag("ui.metrics.interval", uiCmd.Flags().Lookup("metrics.interval"))
+	_ = viper.BindPFlag("ui.metrics.retention", uiCmd.Flags().Lookup("metrics.retention"))
+	_ = viper.BindPFlag("ui.vnc-recording", uiCmd.Flags().Lookup("vnc-recording"))
+	_ = viper.BindPFlag("ui.serial.scrollback", uiCmd.Flags().Lookup("serial.scrollback"))
+	_ = viper.BindPFlag("ui.serial.logging", uiCmd.Flags().Lookup("serial.logging"))
 
 	_ = viper.BindEnv("ui.listen-endpoint")
 	_ = viper.BindEnv("ui.base-path")
@@ -124,6 +144,11 @@ func newUICmd() *cobra.Command {
 	_ = viper.BindEnv("ui.logs.level")
 	_ = viper.BindEnv("ui.features")
 	_ = viper.BindEnv("ui.minimega-console")
+	_ = viper.BindEnv("ui.metrics.interval")
+	_ = viper.BindEnv("ui.metrics.retention")
+	_ = viper.BindEnv("ui.vnc-recording")
+	_ = viper.BindEnv("ui.serial.scrollback")
+	_ = viper.BindEnv("ui.serial.logging")
 
 	uiCmd.Flags().Bool("log-requests", false, "Log HTTP requests")
 	uiCmd.Flags().
@@ -14,7 +14,10 @@ import (
 	"phenix/util/printer"
 )
 
-const aliasArgs = 3
+const (
+	aliasArgs       = 3
+	defaultPoolSize = 64
+)
 
 func newVlanCmd() *cobra.Command {
 	cmd := &cobra.Command{
@@ -181,11 +184,103 @@ func newVlanRangeCmd() *cobra.Command {
 	return cmd
 }
 
+func newVlanPoolsCmd() *cobra.Command {
+	desc := `View or manage VLAN pools
+
+  VLAN pools are cluster-wide ranges of VLAN IDs that experiments created
+  without a VLAN range are automatically allocated non-overlapping VLAN
+  ranges from. Pools can optionally be reserved for one or more roles.`
+
+	cmd := &cobra.Command{
+		Use:   "pools",
+		Short: "View or manage VLAN pools and their experiment allocations",
+		Long:  desc,
+		Args:  argsWithUsage(cobra.NoArgs),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			pools, err := vlan.Pools()
+			if err != nil {
+				err := util.HumanizeError(err, "Unable to display VLAN pools")
+
+				return err.Humanized()
+			}
+
+			printTableOfVLANPools(os.Stdout, pools)
+
+			return nil
+		},
+	}
+
+	create := &cobra.Command{
+		Use:   "create <pool name> <range minimum> <range maximum>",
+		Short: "Create a VLAN pool",
+		Args:  argsWithUsage(cobra.ExactArgs(aliasArgs)),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			vmin, err := strconv.Atoi(args[1])
+			if err != nil {
+				return errors.New("the VLAN range minimum identifier provided is not a valid integer")
+			}
+
+			vmax, err := strconv.Atoi(args[2])
+			if err != nil {
+				return errors.New("the VLAN range maximum identifier provided is not a valid integer")
+			}
+
+			if err := vlan.CreatePool(
+				vlan.PoolName(args[0]),
+				vlan.Min(vmin),
+				vlan.Max(vmax),
+				vlan.Size(MustGetInt(cmd.Flags(), "size")),
+				vlan.Roles(MustGetStringArray(cmd.Flags(), "role")...),
+			); err != nil {
+				err := util.HumanizeError(err, "%s", "Unable to create the "+args[0]+" VLAN pool")
+
+				return err.Humanized()
+			}
+
+			plog.Info(plog.TypeSystem, "vlan pool created", "pool", args[0])
+
+			return nil
+		},
+	}
+
+	create.Flags().
+		IntP("size", "s", defaultPoolSize, "Number of VLANs allocated to each experiment from the pool")
+	create.Flags().
+		StringArray("role", nil, "Role the pool is reserved for (can be specified multiple times)")
+
+	remove := &cobra.Command{
+		Use:   "delete <pool name>",
+		Short: "Delete a VLAN pool",
+		Args:  argsWithUsage(cobra.ExactArgs(1)),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := vlan.DeletePool(
+				vlan.PoolName(args[0]),
+				vlan.Force(MustGetBool(cmd.Flags(), "force")),
+			); err != nil {
+				err := util.HumanizeError(err, "%s", "Unable to delete the "+args[0]+" VLAN pool")
+
+				return err.Humanized()
+			}
+
+			plog.Info(plog.TypeSystem, "vlan pool deleted", "pool", args[0])
+
+			return nil
+		},
+	}
+
+	remove.Flags().BoolP("force", "f", false, "Delete pool even if experiments are allocated from it")
+
+	cmd.AddCommand(create, remove)
+
+	return cmd
+}
+
 func init() { //nolint:gochecknoinits // cobra command
 	vlanCmd := newVlanCmd()
 
 	vlanCmd.AddCommand(newVlanAliasCmd())
 	vlanCmd.AddCommand(newVlanRangeCmd())
+	vlanCmd.AddCommand(newVlanPoolsCmd())
 
 	addCommandToRoot(vlanCmd, true)
 }
@@ -1,16 +1,20 @@
 package cmd
 
 import (
+	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"os"
-	"path"
 	"regexp"
 	"slices"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 
 	"phenix/api/experiment"
 	"phenix/api/vm"
@@ -35,6 +39,10 @@ const (
 	stopSubnetArgs   = 2
 	stopAllArgs      = 1
 	memSnapArgs      = 3
+	copyArgs         = 3
+	impairArgs       = 3
+
+	defaultVMExecTimeout = 5 * time.Minute
 )
 
 func vmArgsCompletion(_ *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
@@ -94,64 +102,20 @@ func normalizeVMLabels(labels []string) []string {
 	return normalized
 }
 
-func vmLabelMatchesLabel(label, expected string) (bool, error) {
-	if strings.EqualFold(label, expected) {
-		return true, nil
-	}
-
-	if strings.ContainsAny(expected, "*?[") {
-		matched, err := path.Match(strings.ToLower(expected), strings.ToLower(label))
-		if err != nil {
-			return false, err
-		}
-
-		if matched {
-			return true, nil
-		}
-	}
-
-	return false, nil
-}
-
-func vmMatchesAnyLabel(vmInfo mm.VM, labels []string) (bool, error) {
-	if len(labels) == 0 {
-		return false, nil
-	}
-
-	if slices.ContainsFunc(labels, func(label string) bool { return strings.EqualFold(label, "all") }) {
-		return true, nil
-	}
-
-	for label := range vmInfo.Tags {
-		for _, expected := range labels {
-			matched, err := vmLabelMatchesLabel(label, expected)
-			if err != nil {
-				return false, fmt.Errorf("invalid label %q: %w", expected, err)
-			}
-
-			if matched {
-				return true, nil
-			}
-		}
-	}
-
-	return false, nil
-}
-
 func listVMsByLabel(expName string, labels []string) ([]mm.VM, error) {
 	allVMs, err := vm.List(expName)
 	if err != nil {
 		return nil, err
 	}
 
-	if slices.ContainsFunc(labels, func(label string) bool { return strings.EqualFold(label, "all") }) {
+	if slices.ContainsFunc(labels, vm.IsAllLabel) {
 		return allVMs, nil
 	}
 
 	var matchedVMs []mm.VM
 
 	for _, vmInfo := range allVMs {
-		matched, matchErr := vmMatchesAnyLabel(vmInfo, labels)
+		matched, matchErr := vm.MatchesAnyLabel(vmInfo.Tags, labels)
 		if matchErr != nil {
 			return nil, matchErr
 		}
@@ -278,7 +242,7 @@ func newVMInfoCmd() *cobra.Command {
 					return err.Humanized()
 				}
 
-				printer.PrintTableOfVMs(os.Stdout, MustGetBool(cmd.Flags(), "taps"), vms...)
+				printer.PrintTableOfVMs(os.Stdout, MustGetBool(cmd.Flags(), "taps"), withImpairments(args[0], vms...)...)
 
 				return nil
 			}
@@ -292,7 +256,7 @@ func newVMInfoCmd() *cobra.Command {
 					return err.Humanized()
 				}
 
-				printer.PrintTableOfVMs(os.Stdout, MustGetBool(cmd.Flags(), "taps"), vms...)
+				printer.PrintTableOfVMs(os.Stdout, MustGetBool(cmd.Flags(), "taps"), withImpairments(args[0], vms...)...)
 			case infoArgs:
 				vm, err := vm.Get(args[0], args[1])
 				if err != nil {
@@ -305,7 +269,7 @@ func newVMInfoCmd() *cobra.Command {
 					return err.Humanized()
 				}
 
-				printer.PrintTableOfVMs(os.Stdout, MustGetBool(cmd.Flags(), "taps"), *vm)
+				printer.PrintTableOfVMs(os.Stdout, MustGetBool(cmd.Flags(), "taps"), withImpairments(args[0], *vm)...)
 			default:
 				return errors.New("invalid argument")
 			}
@@ -320,6 +284,33 @@ func newVMInfoCmd() *cobra.Command {
 	return cmd
 }
 
+// withImpairments replaces the link properties configured in the topology for
+// each running VM with the link impairment currently applied to its interfaces,
+// since impairment can be changed at runtime. Impairment is informational, so
+// errors getting it are ignored.
+func withImpairments(expName string, vms ...mm.VM) []mm.VM {
+	impairments, err := vm.Impairments(expName)
+	if err != nil {
+		plog.Debug(plog.TypeSystem, "unable to get vm link impairments", "exp", expName, "err", err)
+
+		return vms
+	}
+
+	for i, v := range vms {
+		if !v.Running {
+			continue
+		}
+
+		vms[i].Impairments = make(map[int]string)
+
+		for idx, imp := range impairments[v.Name] {
+			vms[i].Impairments[idx] = imp.String()
+		}
+	}
+
+	return vms
+}
+
 func newVMPauseCmd() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:               "pause <experiment name> [vm name]",
@@ -725,12 +716,122 @@ func newVMNetDisconnectCmd() *cobra.Command {
 	}
 }
 
+func newVMNetImpairCmd() *cobra.Command {
+	desc := `Apply or clear link impairment for a VM interface
+
+  Used to apply network impairment (delay, jitter, packet loss, corruption,
+  reordering, and rate limiting) to traffic sent to a VM interface in a running
+  experiment. Applying impairment replaces any impairment already applied to the
+  interface. Use --clear to remove impairment from the interface. Impairment
+  currently applied is shown by 'phenix vm info'.
+
+  Example: phenix vm net impair myexp myvm 0 --delay 50ms --jitter 10ms --loss 2% --rate 1mbit`
+
+	cmd := &cobra.Command{
+		Use:               "impair <experiment name> <vm name> <iface index>",
+		Short:             "Apply or clear link impairment for a VM interface",
+		Long:              desc,
+		ValidArgsFunction: vmArgsCompletion,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) != impairArgs {
+				return errors.New("must provide an experiment name, VM name, and iface index")
+			}
+
+			var (
+				expName = args[0]
+				vmName  = args[1]
+			)
+
+			iface, err := strconv.Atoi(args[2])
+			if err != nil {
+				return errors.New("the network interface index must be an integer")
+			}
+
+			if MustGetBool(cmd.Flags(), "clear") {
+				if err := vm.ClearImpairment(expName, vmName, iface); err != nil {
+					err := util.HumanizeError(
+						err,
+						"%s",
+						"Unable to clear impairment on the interface on the "+vmName+" VM",
+					)
+
+					return err.Humanized()
+				}
+
+				plog.Info(
+					plog.TypeSystem,
+					"vm interface impairment cleared",
+					"iface",
+					iface,
+					"vm",
+					vmName,
+					"exp",
+					expName,
+				)
+
+				return nil
+			}
+
+			imp := mm.Impairment{
+				Delay:  MustGetDuration(cmd.Flags(), "delay"),
+				Jitter: MustGetDuration(cmd.Flags(), "jitter"),
+				Rate:   MustGetString(cmd.Flags(), "rate"),
+			}
+
+			for flag, pct := range map[string]*float64{
+				"loss":       &imp.Loss,
+				"corruption": &imp.Corruption,
+				"reordering": &imp.Reordering,
+			} {
+				if *pct, err = mm.ParsePercent(MustGetString(cmd.Flags(), flag)); err != nil {
+					return err
+				}
+			}
+
+			if err := vm.Impair(expName, vmName, iface, imp); err != nil {
+				err := util.HumanizeError(
+					err,
+					"%s",
+					"Unable to impair the interface on the "+vmName+" VM",
+				)
+
+				return err.Humanized()
+			}
+
+			plog.Info(
+				plog.TypeSystem,
+				"vm interface impaired",
+				"iface",
+				iface,
+				"vm",
+				vmName,
+				"exp",
+				expName,
+				"impairment",
+				imp.String(),
+			)
+
+			return nil
+		},
+	}
+
+	cmd.Flags().Duration("delay", 0, "Delay added to packets (e.g., 50ms)")
+	cmd.Flags().Duration("jitter", 0, "Random variation in delay (requires --delay)")
+	cmd.Flags().String("loss", "0", "Percentage of packets dropped (e.g., 2%)")
+	cmd.Flags().String("corruption", "0", "Percentage of packets corrupted (e.g., 0.1%)")
+	cmd.Flags().String("reordering", "0", "Percentage of packets reordered (requires --delay)")
+	cmd.Flags().String("rate", "", "Maximum rate of traffic (e.g., 1mbit)")
+	cmd.Flags().Bool("clear", false, "Clear impairment applied to the interface (other flags are ignored)")
+
+	return cmd
+}
+
 func newVMNetCmd() *cobra.Command {
 	desc := `Modify network connectivity for a VM
 
   Used to modify the network connectivity for a virtual machine in a running
-  experiment; see command help for connect or disconnect for additional
-  arguments.`
+  experiment; see command help for connect, disconnect, or impair for
+  additional arguments.`
 
 	cmd := &cobra.Command{
 		Use:   "net",
@@ -743,6 +844,7 @@ func newVMNetCmd() *cobra.Command {
 
 	cmd.AddCommand(newVMNetConnectCmd())
 	cmd.AddCommand(newVMNetDisconnectCmd())
+	cmd.AddCommand(newVMNetImpairCmd())
 
 	return cmd
 }
@@ -785,7 +887,7 @@ func newVMCaptureCmd() *cobra.Command {
 				return errors.New("the network interface index must be an integer")
 			}
 
-			if err := vm.StartCapture(expName, vmName, iface, out); err != nil {
+			if err := vm.StartCapture(expName, vmName, iface, out, captureLimitsFromFlags(cmd.Flags())); err != nil {
 				err := util.HumanizeError(
 					err,
 					"%s",
@@ -860,7 +962,7 @@ func newVMCaptureCmd() *cobra.Command {
 				}
 			}
 
-			vms, err := vm.CaptureSubnet(expName, subnet, vmList)
+			vms, err := vm.CaptureSubnet(expName, subnet, vmList, captureLimitsFromFlags(cmd.Flags()))
 			if err != nil {
 				err := util.HumanizeError(
 					err,
@@ -1011,9 +1113,34 @@ func newVMCaptureCmd() *cobra.Command {
 	startSubnetCaptures.Flags().StringP("filter", "f", "", "Filter to restrict the list of VMs")
 	stopSubnetCaptures.Flags().StringP("filter", "f", "", "Filter to restrict the list of VMs")
 
+	addCaptureLimitFlags(startVMCapture.Flags())
+	addCaptureLimitFlags(startSubnetCaptures.Flags())
+
 	return cmd
 }
 
+func addCaptureLimitFlags(flags *pflag.FlagSet) {
+	flags.String("bpf", "", "BPF filter to apply to the capture (e.g., 'tcp port 502')")
+	flags.Int("max-size", 0, "Size, in MB, at which to rotate the capture file")
+	flags.Int(
+		"max-files",
+		0,
+		fmt.Sprintf("Number of rotated capture files to keep (default %d with --max-size)", mm.DefaultCaptureRingFiles),
+	)
+	flags.Duration("max-duration", 0, "Stop the capture after the given duration")
+	flags.Int("max-packets", 0, "Stop the capture after the given number of packets")
+}
+
+func captureLimitsFromFlags(flags *pflag.FlagSet) mm.CaptureLimits {
+	return mm.CaptureLimits{
+		Filter:      MustGetString(flags, "bpf"),
+		MaxFileSize: MustGetInt(flags, "max-size"),
+		MaxFiles:    MustGetInt(flags, "max-files"),
+		MaxDuration: MustGetDuration(flags, "max-duration"),
+		MaxPackets:  MustGetInt(flags, "max-packets"),
+	}
+}
+
 func newVMMemorySnapshotCmd() *cobra.Command {
 	desc := `Create an ELF memory snapshot of the VM
 
@@ -1068,6 +1195,597 @@ func newVMMemorySnapshotCmd() *cobra.Command {
 	return cmd
 }
 
+func newVMExecCmd() *cobra.Command {
+	desc := `Execute a command in VM(s)
+
+  Used to execute a command in one or more VMs in a running experiment via
+  miniccc, waiting for the command to complete. The command's STDOUT, STDERR,
+  and exit code are reported for each VM. The command is run via PowerShell on
+  Windows VMs and via 'sh' everywhere else. Use '--label' to execute the
+  command in all the VMs matching the given label(s).
+
+  Example: phenix vm exec foo host-00 -- ip addr show`
+
+	cmd := &cobra.Command{
+		Use:               "exec <experiment name> [vm name] -- <command>",
+		Short:             "Execute a command in VM(s)",
+		Long:              desc,
+		ValidArgsFunction: vmArgsCompletion,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			dash := cmd.ArgsLenAtDash()
+			if dash < 0 || dash == len(args) {
+				return errors.New("must provide a command to execute after '--'")
+			}
+
+			expName, vmNames, err := vmTargetNamesForCommand(cmd, args[:dash])
+			if err != nil {
+				return err
+			}
+
+			var (
+				command = strings.Join(args[dash:], " ")
+				timeout = MustGetDuration(cmd.Flags(), "timeout")
+			)
+
+			results, err := vm.Exec(
+				context.Background(),
+				expName,
+				command,
+				vm.ExecWithVMs(vmNames...),
+				vm.ExecWithTimeout(timeout),
+			)
+			if err != nil {
+				err := util.HumanizeError(err, "%s", "Unable to execute command in the "+expName+" experiment")
+
+				return err.Humanized()
+			}
+
+			switch output := MustGetString(cmd.Flags(), "output"); output {
+			case "text":
+				printExecResults(results)
+			case FormatJSON:
+				body, err := json.MarshalIndent(results, "", "  ")
+				if err != nil {
+					err := util.HumanizeError(err, "Unable to convert exec results to JSON")
+
+					return err.Humanized()
+				}
+
+				fmt.Fprintln(os.Stdout, string(body))
+			default:
+				return fmt.Errorf("unrecognized output format '%s'", output)
+			}
+
+			var failed []string
+
+			for _, result := range results {
+				if result.Error != "" || result.ExitCode != 0 {
+					failed = append(failed, result.VM)
+				}
+			}
+
+			if len(failed) > 0 {
+				return fmt.Errorf("command failed in VM(s): %s", strings.Join(failed, ", "))
+			}
+
+			return nil
+		},
+	}
+
+	cmd.Flags().Duration("timeout", defaultVMExecTimeout, "Time to wait for the command to complete in each VM")
+	cmd.Flags().StringP("output", "o", "text", "Exec results output format ('text' or 'json')")
+	addVMLabelFlag(cmd)
+
+	return cmd
+}
+
+// printExecResults prints the STDOUT and STDERR of each result to STDOUT and
+// STDERR, respectively. Output is prefixed with a header identifying the VM
+// when there's more than one result.
+func printExecResults(results []vm.ExecResult) {
+	for _, result := range results {
+		if len(results) > 1 {
+			fmt.Fprintf(os.Stdout, "==> %s (exit code %d) <==\n", result.VM, result.ExitCode)
+		}
+
+		if result.Stdout != "" {
+			fmt.Fprintln(os.Stdout, strings.TrimSuffix(result.Stdout, "\n"))
+		}
+
+		if result.Stderr != "" {
+			fmt.Fprintln(os.Stderr, strings.TrimSuffix(result.Stderr, "\n"))
+		}
+
+		if result.Error != "" {
+			fmt.Fprintf(os.Stderr, "error executing command in VM %s: %s\n", result.VM, result.Error)
+		}
+	}
+}
+
+func newVMCopyCmd() *cobra.Command {
+	desc := `Copy a file to or from a VM
+
+  Used to copy a file to or from a VM in a running experiment via miniccc.
+  Paths in VMs are specified as <vm name>:<path>. Use '-' as the local path to
+  read from STDIN or write to STDOUT.
+
+  Examples:
+    phenix vm cp foo host-00:/etc/hosts ./hosts
+    phenix vm cp foo ./script.sh host-00:/tmp/script.sh`
+
+	cmd := &cobra.Command{
+		Use:               "cp <experiment name> <src> <dst>",
+		Short:             "Copy a file to or from a VM",
+		Long:              desc,
+		ValidArgsFunction: expNameCompletion(false),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) != copyArgs {
+				return errors.New("must provide an experiment name, source, and destination")
+			}
+
+			var (
+				expName = args[0]
+				timeout = MustGetDuration(cmd.Flags(), "timeout")
+				ctx     = context.Background()
+			)
+
+			srcVM, srcPath, srcRemote := parseVMCopyPath(args[1])
+			dstVM, dstPath, dstRemote := parseVMCopyPath(args[2])
+
+			switch {
+			case srcRemote && dstRemote:
+				return errors.New("copying files between VMs is not supported")
+			case srcRemote:
+				data, err := vm.CopyFromVM(ctx, expName, srcVM, srcPath, vm.ExecWithTimeout(timeout))
+				if err != nil {
+					err := util.HumanizeError(err, "%s", "Unable to copy file from the "+srcVM+" VM")
+
+					return err.Humanized()
+				}
+
+				if dstPath == "-" {
+					_, err = os.Stdout.Write(data)
+				} else {
+					err = os.WriteFile(dstPath, data, 0o600)
+				}
+
+				if err != nil {
+					return fmt.Errorf("writing %s: %w", dstPath, err)
+				}
+			case dstRemote:
+				var r io.Reader = os.Stdin
+
+				if srcPath != "-" {
+					f, err := os.Open(srcPath)
+					if err != nil {
+						return fmt.Errorf("opening %s: %w", srcPath, err)
+					}
+
+					defer f.Close()
+
+					r = f
+				}
+
+				if err := vm.CopyToVM(ctx, expName, dstVM, r, dstPath, vm.ExecWithTimeout(timeout)); err != nil {
+					err := util.HumanizeError(err, "%s", "Unable to copy file to the "+dstVM+" VM")
+
+					return err.Humanized()
+				}
+			default:
+				return errors.New("either the source or destination must be a VM path (<vm name>:<path>)")
+			}
+
+			return nil
+		},
+	}
+
+	cmd.Flags().Duration("timeout", defaultVMExecTimeout, "Time to wait for the copy to complete")
+
+	return cmd
+}
+
+// parseVMCopyPath splits the given path into a VM name and path if it's in the
+// form <vm name>:<path>. Local paths containing a colon are still treated as
+// local if a path separator precedes the colon.
+func parseVMCopyPath(arg string) (string, string, bool) {
+	name, path, ok := strings.Cut(arg, ":")
+	if !ok || name == "" || strings.ContainsAny(name, `/\`) {
+		return "", arg, false
+	}
+
+	return name, path, true
+}
+
+func newVMBatchCmd() *cobra.Command {
+	desc := `Apply an action to many VMs at once
+
+  Used to apply an action to every VM in an experiment matching the given VM
+  names and/or label(s), running the action for multiple VMs concurrently.
+  Labels are matched the same way as '--label' for other VM commands. Use 'all'
+  as the VM name to select every VM.
+
+  Actions: ` + strings.Join(vm.BatchActions, ", ") + `
+
+  Examples:
+    phenix vm batch foo restart --label role
+    phenix vm batch foo set-tags all --tag owner=blue --append-tags`
+
+	cmd := &cobra.Command{
+		Use:               "batch <experiment name> <action> [vm name...]",
+		Short:             "Apply an action to many VMs at once",
+		Long:              desc,
+		ValidArgsFunction: expNameCompletion(false),
+		Args:              argsWithUsage(cobra.MinimumNArgs(2)), //nolint:mnd // experiment name and action
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var (
+				expName = args[0]
+				action  = args[1]
+				names   = args[2:]
+			)
+
+			opts := []vm.BatchOption{
+				vm.BatchWithLabels(normalizeVMLabels(MustGetStringArray(cmd.Flags(), "label"))...),
+				vm.BatchWithParallelism(MustGetInt(cmd.Flags(), "parallelism")),
+				vm.BatchWithSnapshotName(MustGetString(cmd.Flags(), "snapshot-name")),
+				vm.BatchWithCreator(currentUsername()),
+				vm.BatchWithInjects(MustGetBool(cmd.Flags(), "replicate-injects")),
+			}
+
+			if slices.ContainsFunc(names, vm.IsAllLabel) {
+				opts = append(opts, vm.BatchWithAllVMs())
+			} else {
+				opts = append(opts, vm.BatchWithVMs(names...))
+			}
+
+			if cmd.Flags().Changed("tag") {
+				tags, err := parseKeyValues(MustGetStringArray(cmd.Flags(), "tag"))
+				if err != nil {
+					return fmt.Errorf("parsing tags: %w", err)
+				}
+
+				opts = append(opts, vm.BatchWithTags(tags, MustGetBool(cmd.Flags(), "append-tags")))
+			}
+
+			results, err := vm.Batch(context.Background(), expName, action, opts...)
+			if err != nil {
+				err := util.HumanizeError(err, "%s", "Unable to apply "+action+" to VMs in the "+expName+" experiment")
+
+				return err.Humanized()
+			}
+
+			printTableOfBatchResults(os.Stdout, results)
+
+			var failed int
+
+			for _, result := range results {
+				if result.Error != "" {
+					failed++
+				}
+			}
+
+			if failed > 0 {
+				return fmt.Errorf("%s failed for %d of %d VM(s)", action, failed, len(results))
+			}
+
+			plog.Info(plog.TypeSystem, "batch vm action applied", "exp", expName, "action", action, "vms", len(results))
+
+			return nil
+		},
+	}
+
+	cmd.Flags().IntP("parallelism", "p", vm.DefaultBatchParallelism, "Maximum number of VMs to apply the action to concurrently")
+	cmd.Flags().StringArrayP("tag", "t", nil, "Tag to set for the set-tags action (key=value)")
+	cmd.Flags().Bool("append-tags", false, "Append tags to existing tags for the set-tags action")
+	cmd.Flags().String("snapshot-name", "", "Name of the snapshots for the snapshot action (defaults to a timestamp)")
+	cmd.Flags().BoolP("replicate-injects", "r", false, "Recreate disk snapshots and VM injections for the redeploy action")
+	addVMLabelFlag(cmd)
+
+	return cmd
+}
+
+// parseKeyValues parses the given key=value pairs into a map.
+func parseKeyValues(pairs []string) (map[string]string, error) {
+	m := make(map[string]string)
+
+	for _, pair := range pairs {
+		k, v, ok := strings.Cut(pair, "=")
+		if !ok || k == "" {
+			return nil, fmt.Errorf("invalid key=value pair %q", pair)
+		}
+
+		m[k] = v
+	}
+
+	return m, nil
+}
+
+func newVMSnapshotCmd() *cobra.Command {
+	desc := `Manage VM snapshots
+
+  Used to create, list, describe, delete, and prune VM snapshots (disk and
+  memory) for a running experiment. Snapshots are recorded in a per-VM catalog
+  that includes who created them, when, and why.`
+
+	cmd := &cobra.Command{
+		Use:   "snapshot",
+		Short: "Manage VM snapshots",
+		Long:  desc,
+	}
+
+	create := &cobra.Command{
+		Use:               "create <experiment name> <vm name> <snapshot name>",
+		Short:             "Create a VM snapshot",
+		ValidArgsFunction: vmArgsCompletion,
+		Args:              argsWithUsage(cobra.ExactArgs(3)), //nolint:mnd // experiment, VM, and snapshot names
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var (
+				expName = args[0]
+				vmName  = args[1]
+				name    = args[2]
+				opts    = []vm.SnapshotOption{
+					vm.SnapshotWithDescription(MustGetString(cmd.Flags(), "description")),
+					vm.SnapshotWithCreator(currentUsername()),
+				}
+			)
+
+			if err := vm.Snapshot(expName, vmName, name, nil, opts...); err != nil {
+				err := util.HumanizeError(err, "%s", "Unable to snapshot the "+vmName+" VM")
+
+				return err.Humanized()
+			}
+
+			plog.Info(plog.TypeSystem, "vm snapshot created", "vm", vmName, "exp", expName, "snapshot", name)
+
+			return nil
+		},
+	}
+
+	create.Flags().StringP("description", "d", "", "Description of the snapshot")
+
+	list := &cobra.Command{
+		Use:               "list <experiment name> [vm name]",
+		Short:             "List VM snapshots",
+		ValidArgsFunction: vmArgsCompletion,
+		Args:              argsWithUsage(cobra.RangeArgs(1, 2)), //nolint:mnd // experiment and optional VM names
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var vmName string
+
+			if len(args) > 1 {
+				vmName = args[1]
+			}
+
+			snapshots, err := vm.SnapshotCatalog(args[0], vmName)
+			if err != nil {
+				err := util.HumanizeError(err, "%s", "Unable to list snapshots for the "+args[0]+" experiment")
+
+				return err.Humanized()
+			}
+
+			printTableOfSnapshots(os.Stdout, snapshots)
+
+			return nil
+		},
+	}
+
+	describe := &cobra.Command{
+		Use:               "describe <experiment name> <vm name> <snapshot name>",
+		Short:             "Describe a VM snapshot",
+		ValidArgsFunction: vmArgsCompletion,
+		Args:              argsWithUsage(cobra.ExactArgs(3)), //nolint:mnd // experiment, VM, and snapshot names
+		RunE: func(cmd *cobra.Command, args []string) error {
+			snapshot, err := vm.DescribeSnapshot(args[0], args[1], args[2])
+			if err != nil {
+				err := util.HumanizeError(err, "%s", "Unable to describe the "+args[2]+" snapshot")
+
+				return err.Humanized()
+			}
+
+			body, err := json.MarshalIndent(snapshot, "", "  ")
+			if err != nil {
+				err := util.HumanizeError(err, "Unable to convert snapshot to JSON")
+
+				return err.Humanized()
+			}
+
+			fmt.Fprintln(os.Stdout, string(body))
+
+			return nil
+		},
+	}
+
+	deleteCmd := &cobra.Command{
+		Use:               "delete <experiment name> <vm name> <snapshot name>",
+		Short:             "Delete a VM snapshot",
+		ValidArgsFunction: vmArgsCompletion,
+		Args:              argsWithUsage(cobra.ExactArgs(3)), //nolint:mnd // experiment, VM, and snapshot names
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := vm.DeleteSnapshot(args[0], args[1], args[2]); err != nil {
+				err := util.HumanizeError(err, "%s", "Unable to delete the "+args[2]+" snapshot")
+
+				return err.Humanized()
+			}
+
+			plog.Info(plog.TypeSystem, "vm snapshot deleted", "vm", args[1], "exp", args[0], "snapshot", args[2])
+
+			return nil
+		},
+	}
+
+	prune := &cobra.Command{
+		Use:   "prune <experiment name> [vm name]",
+		Short: "Prune old VM snapshots",
+		Long: `Prune old VM snapshots
+
+  Used to delete all but the most recent snapshots for each VM (--keep) and/or
+  snapshots older than a given age (--older-than). Catalog entries for
+  snapshots whose files no longer exist are always removed.`,
+		ValidArgsFunction: vmArgsCompletion,
+		Args:              argsWithUsage(cobra.RangeArgs(1, 2)), //nolint:mnd // experiment and optional VM names
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var vmName string
+
+			if len(args) > 1 {
+				vmName = args[1]
+			}
+
+			opts := []vm.PruneOption{
+				vm.PruneOlderThan(MustGetDuration(cmd.Flags(), "older-than")),
+				vm.PruneDryRun(MustGetBool(cmd.Flags(), "dry-run")),
+			}
+
+			if cmd.Flags().Changed("keep") {
+				opts = append(opts, vm.PruneKeep(MustGetInt(cmd.Flags(), "keep")))
+			}
+
+			pruned, err := vm.PruneSnapshots(args[0], vmName, opts...)
+			if err != nil {
+				err := util.HumanizeError(err, "%s", "Unable to prune snapshots for the "+args[0]+" experiment")
+
+				return err.Humanized()
+			}
+
+			if len(pruned) == 0 {
+				fmt.Fprintln(os.Stdout, "no snapshots to prune")
+
+				return nil
+			}
+
+			printTableOfSnapshots(os.Stdout, pruned)
+
+			return nil
+		},
+	}
+
+	prune.Flags().Int("keep", 0, "Number of most recent snapshots to keep for each VM")
+	prune.Flags().Duration("older-than", 0, "Prune snapshots older than the given duration (e.g., 72h)")
+	prune.Flags().Bool("dry-run", false, "Show the snapshots that would be pruned without deleting them")
+
+	cmd.AddCommand(create, list, describe, deleteCmd, prune)
+
+	return cmd
+}
+
+func newVMTopCmd() *cobra.Command {
+	desc := `Display VM resource usage
+
+  Used to display the current CPU, memory, disk, and network usage of each VM
+  in a running experiment, sorted by CPU usage by default. Disk values are
+  totals since each VM was started.`
+
+	cmd := &cobra.Command{
+		Use:               "top <experiment name>",
+		Short:             "Display VM resource usage",
+		Long:              desc,
+		ValidArgsFunction: expNameCompletion(false),
+		Args:              argsWithUsage(cobra.ExactArgs(1)),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			metrics, err := vm.CurrentMetrics(args[0])
+			if err != nil {
+				err := util.HumanizeError(err, "%s", "Unable to get VM metrics for the "+args[0]+" experiment")
+
+				return err.Humanized()
+			}
+
+			var less func(a, b mm.VMMetrics) bool
+
+			switch sortBy := MustGetString(cmd.Flags(), "sort"); sortBy {
+			case "cpu":
+				less = func(a, b mm.VMMetrics) bool { return a.CPU > b.CPU }
+			case "memory":
+				less = func(a, b mm.VMMetrics) bool { return a.Memory > b.Memory }
+			case "disk":
+				less = func(a, b mm.VMMetrics) bool {
+					return a.DiskReadBytes+a.DiskWriteBytes > b.DiskReadBytes+b.DiskWriteBytes
+				}
+			case "network":
+				less = func(a, b mm.VMMetrics) bool { return a.NetworkRx+a.NetworkTx > b.NetworkRx+b.NetworkTx }
+			case "name":
+				less = func(a, b mm.VMMetrics) bool { return a.VM < b.VM }
+			default:
+				return fmt.Errorf("unsupported sort column '%s'", sortBy)
+			}
+
+			slices.SortStableFunc(metrics, func(a, b mm.VMMetrics) int {
+				switch {
+				case less(a, b):
+					return -1
+				case less(b, a):
+					return 1
+				default:
+					return 0
+				}
+			})
+
+			printer.PrintTableOfVMMetrics(os.Stdout, metrics)
+
+			return nil
+		},
+	}
+
+	cmd.Flags().String("sort", "cpu", "Column to sort by ('cpu', 'memory', 'disk', 'network', or 'name')")
+
+	return cmd
+}
+
+func newVMTypeCmd() *cobra.Command {
+	desc := `Type text and send keys to a VM
+
+  Used to type text and send key chords to a VM in a running experiment as
+  hardware key presses, so no guest agent (e.g., miniccc) is needed. This makes
+  it possible to automate installers and BIOS screens. Text is typed using a US
+  keyboard layout before any keys are sent. Keys are given as space-separated
+  chords of QEMU key codes or common aliases joined by '-' or '+' (e.g.,
+  'ctrl-alt-delete', 'f2', 'tab', 'enter'). Container VMs are not supported.
+
+  Example: phenix vm type myexp myvm 'root' --keys 'enter'
+           phenix vm type myexp myvm --keys 'ctrl-alt-delete'`
+
+	cmd := &cobra.Command{
+		Use:               "type <experiment name> <vm name> [text]",
+		Short:             "Type text and send keys to a VM",
+		Long:              desc,
+		ValidArgsFunction: vmArgsCompletion,
+		Args:              argsWithUsage(cobra.RangeArgs(2, 3)), //nolint:mnd // experiment, VM, and optional text
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var (
+				expName = args[0]
+				vmName  = args[1]
+				keys    = MustGetString(cmd.Flags(), "keys")
+				text    string
+			)
+
+			if len(args) > 2 { //nolint:mnd // optional text argument
+				text = args[2]
+			}
+
+			if MustGetBool(cmd.Flags(), "enter") {
+				keys = strings.TrimSpace(keys + " ret")
+			}
+
+			err := vm.SendKeys(
+				expName,
+				vmName,
+				vm.SendKeysWithText(text),
+				vm.SendKeysWithChords(keys),
+				vm.SendKeysWithDelay(MustGetDuration(cmd.Flags(), "delay")),
+			)
+			if err != nil {
+				err := util.HumanizeError(err, "%s", "Unable to send keys to the "+vmName+" VM")
+
+				return err.Humanized()
+			}
+
+			return nil
+		},
+	}
+
+	cmd.Flags().String("keys", "", "Space-separated key chords to send after any text (e.g., 'ctrl-alt-delete')")
+	cmd.Flags().Bool("enter", false, "Press enter after typing text and sending keys")
+	cmd.Flags().Duration("delay", mm.DefaultKeyDelay, "Delay between each key press")
+
+	return cmd
+}
+
 func init() { //nolint:gochecknoinits // cobra command
 	vmCmd := newVMCmd()
 
@@ -1083,6 +1801,12 @@ func init() { //nolint:gochecknoinits // cobra command
 	vmCmd.AddCommand(newVMNetCmd())
 	vmCmd.AddCommand(newVMCaptureCmd())
 	vmCmd.AddCommand(newVMMemorySnapshotCmd())
+	vmCmd.AddCommand(newVMExecCmd())
+	vmCmd.AddCommand(newVMCopyCmd())
+	vmCmd.AddCommand(newVMBatchCmd())
+	vmCmd.AddCommand(newVMSnapshotCmd())
+	vmCmd.AddCommand(newVMTopCmd())
+	vmCmd.AddCommand(newVMTypeCmd())
 
 	addCommandToRoot(vmCmd, true)
 }
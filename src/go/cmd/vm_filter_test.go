@@ -4,8 +4,6 @@ import (
 	"testing"
 
 	"github.com/spf13/cobra"
-
-	"phenix/util/mm"
 )
 
 // ---------------------------------------------------------------------------
@@ -54,262 +52,6 @@ func TestNormalizeVMLabels_EmptyPiecesSkipped(t *testing.T) {
 	}
 }
 
-// ---------------------------------------------------------------------------
-// vmLabelMatchesLabel
-// ---------------------------------------------------------------------------
-
-func TestVMLabelMatchesLabel_ExactMatch(t *testing.T) {
-	matched, err := vmLabelMatchesLabel("my-app", "my-app")
-	if err != nil {
-		t.Fatal(err)
-	}
-
-	if !matched {
-		t.Fatal("expected exact match")
-	}
-}
-
-func TestVMLabelMatchesLabel_CaseInsensitiveExact(t *testing.T) {
-	matched, err := vmLabelMatchesLabel("My-App", "MY-APP")
-	if err != nil {
-		t.Fatal(err)
-	}
-
-	if !matched {
-		t.Fatal("expected case-insensitive exact match")
-	}
-}
-
-func TestVMLabelMatchesLabel_NoMatch(t *testing.T) {
-	matched, err := vmLabelMatchesLabel("my-app", "other-label")
-	if err != nil {
-		t.Fatal(err)
-	}
-
-	if matched {
-		t.Fatal("expected no match")
-	}
-}
-
-func TestVMLabelMatchesLabel_GlobSuffixWildcard(t *testing.T) {
-	matched, err := vmLabelMatchesLabel("app-controller", "app-*")
-	if err != nil {
-		t.Fatal(err)
-	}
-
-	if !matched {
-		t.Fatal("expected suffix wildcard glob match")
-	}
-}
-
-func TestVMLabelMatchesLabel_GlobPrefixWildcard(t *testing.T) {
-	matched, err := vmLabelMatchesLabel("sceptre-app", "*-app")
-	if err != nil {
-		t.Fatal(err)
-	}
-
-	if !matched {
-		t.Fatal("expected prefix wildcard glob match")
-	}
-}
-
-func TestVMLabelMatchesLabel_GlobMiddleWildcard(t *testing.T) {
-	matched, err := vmLabelMatchesLabel("sceptre-ot-app", "sceptre-*-app")
-	if err != nil {
-		t.Fatal(err)
-	}
-
-	if !matched {
-		t.Fatal("expected middle wildcard glob match")
-	}
-}
-
-func TestVMLabelMatchesLabel_GlobNoMatch(t *testing.T) {
-	matched, err := vmLabelMatchesLabel("my-label", "app-*")
-	if err != nil {
-		t.Fatal(err)
-	}
-
-	if matched {
-		t.Fatal("expected no glob match")
-	}
-}
-
-func TestVMLabelMatchesLabel_GlobCaseInsensitive(t *testing.T) {
-	matched, err := vmLabelMatchesLabel("App-Controller", "app-*")
-	if err != nil {
-		t.Fatal(err)
-	}
-
-	if !matched {
-		t.Fatal("expected case-insensitive glob match")
-	}
-}
-
-func TestVMLabelMatchesLabel_SingleCharWildcard(t *testing.T) {
-	matched, err := vmLabelMatchesLabel("vm1", "vm?")
-	if err != nil {
-		t.Fatal(err)
-	}
-
-	if !matched {
-		t.Fatal("expected single-char wildcard match")
-	}
-}
-
-func TestVMLabelMatchesLabel_InvalidGlobReturnsError(t *testing.T) {
-	// path.Match returns an error for malformed bracket expressions
-	_, err := vmLabelMatchesLabel("any-label", "[invalid")
-	if err == nil {
-		t.Fatal("expected error for invalid glob pattern")
-	}
-}
-
-// ---------------------------------------------------------------------------
-// vmMatchesAnyLabel
-// ---------------------------------------------------------------------------
-
-func makeVM(tags map[string]string) mm.VM {
-	return mm.VM{
-		Name: "vm-1",
-		Tags: tags,
-	}
-}
-
-func TestVMMatchesAnyLabel_EmptyFilters(t *testing.T) {
-	v := makeVM(map[string]string{"app": "true"})
-
-	matched, err := vmMatchesAnyLabel(v, nil)
-	if err != nil {
-		t.Fatal(err)
-	}
-
-	if matched {
-		t.Fatal("expected no match for empty filters")
-	}
-}
-
-func TestVMMatchesAnyLabel_AllKeyword(t *testing.T) {
-	v := makeVM(map[string]string{"app": "true"})
-
-	matched, err := vmMatchesAnyLabel(v, []string{"all"})
-	if err != nil {
-		t.Fatal(err)
-	}
-
-	if !matched {
-		t.Fatal("expected 'all' to match any VM")
-	}
-}
-
-func TestVMMatchesAnyLabel_AllKeywordCaseInsensitive(t *testing.T) {
-	v := makeVM(map[string]string{})
-
-	matched, err := vmMatchesAnyLabel(v, []string{"ALL"})
-	if err != nil {
-		t.Fatal(err)
-	}
-
-	if !matched {
-		t.Fatal("expected 'ALL' to match any VM")
-	}
-}
-
-func TestVMMatchesAnyLabel_AllKeywordMatchesVMWithNoLabels(t *testing.T) {
-	v := makeVM(map[string]string{})
-
-	matched, err := vmMatchesAnyLabel(v, []string{"all"})
-	if err != nil {
-		t.Fatal(err)
-	}
-
-	if !matched {
-		t.Fatal("expected 'all' to match VM even with no labels")
-	}
-}
-
-func TestVMMatchesAnyLabel_ExactLabelMatch(t *testing.T) {
-	v := makeVM(map[string]string{"sceptre-app": "true"})
-
-	matched, err := vmMatchesAnyLabel(v, []string{"sceptre-app"})
-	if err != nil {
-		t.Fatal(err)
-	}
-
-	if !matched {
-		t.Fatal("expected exact label to match")
-	}
-}
-
-func TestVMMatchesAnyLabel_NoMatchingLabel(t *testing.T) {
-	v := makeVM(map[string]string{"sceptre-app": "true"})
-
-	matched, err := vmMatchesAnyLabel(v, []string{"other-label"})
-	if err != nil {
-		t.Fatal(err)
-	}
-
-	if matched {
-		t.Fatal("expected no match when label is absent")
-	}
-}
-
-func TestVMMatchesAnyLabel_MultipleFiltersOrSemantics(t *testing.T) {
-	v := makeVM(map[string]string{"label-b": "true"})
-
-	// VM has label-b; filter includes both label-a and label-b, should match.
-	matched, err := vmMatchesAnyLabel(v, []string{"label-a", "label-b"})
-	if err != nil {
-		t.Fatal(err)
-	}
-
-	if !matched {
-		t.Fatal("expected OR-semantics match when any filter matches")
-	}
-}
-
-func TestVMMatchesAnyLabel_NoLabelsOnVM(t *testing.T) {
-	v := makeVM(nil)
-
-	matched, err := vmMatchesAnyLabel(v, []string{"some-label"})
-	if err != nil {
-		t.Fatal(err)
-	}
-
-	if matched {
-		t.Fatal("expected no match for VM with no labels")
-	}
-}
-
-func TestVMMatchesAnyLabel_GlobMatchesLabel(t *testing.T) {
-	v := makeVM(map[string]string{"sceptre-ot-sim": "true"})
-
-	matched, err := vmMatchesAnyLabel(v, []string{"sceptre-*"})
-	if err != nil {
-		t.Fatal(err)
-	}
-
-	if !matched {
-		t.Fatal("expected glob to match label")
-	}
-}
-
-func TestVMMatchesAnyLabel_GlobMatchesOneOfMultipleLabels(t *testing.T) {
-	v := makeVM(map[string]string{
-		"unrelated":   "true",
-		"sceptre-app": "true",
-	})
-
-	matched, err := vmMatchesAnyLabel(v, []string{"sceptre-*"})
-	if err != nil {
-		t.Fatal(err)
-	}
-
-	if !matched {
-		t.Fatal("expected glob to match when at least one label matches")
-	}
-}
-
 // ---------------------------------------------------------------------------
 // vmTargetNamesForCommand
 // ---------------------------------------------------------------------------
@@ -1,6 +1,9 @@
 package ifaces
 
-import "context"
+import (
+	"context"
+	"time"
+)
 
 type VLANSpec interface {
 	Init() error
@@ -14,6 +17,14 @@ type VLANSpec interface {
 	SetMax(int)
 }
 
+type ExperimentClock interface {
+	Start() string
+	Offset() string
+
+	Enabled() bool
+	Time(time.Time) time.Time
+}
+
 type ExperimentSpec interface { //nolint:interfacebloat // legacy interface
 	Init() error
 
@@ -23,6 +34,7 @@ type ExperimentSpec interface { //nolint:interfacebloat // legacy interface
 	Topology() TopologySpec
 	Scenario() ScenarioSpec
 	VLANs() VLANSpec
+	Clock() ExperimentClock
 	Schedules() map[string]string
 	DeployMode() string
 	UseGREMesh() bool
@@ -51,6 +63,7 @@ type ExperimentStatus interface { //nolint:interfacebloat // legacy interface
 	AppRunning() map[string]bool
 	VLANs() map[string]int
 	Schedules() map[string]string
+	ClockOffset() time.Duration
 
 	SetStartTime(string)
 	SetAppStatus(string, any)
@@ -58,6 +71,7 @@ type ExperimentStatus interface { //nolint:interfacebloat // legacy interface
 	SetAppRunning(string, bool)
 	SetVLANs(map[string]int)
 	SetSchedule(map[string]string)
+	SetClockOffset(time.Duration)
 
 	ParseAppStatus(string, any) error
 	ResetAppStatus()
@@ -170,6 +170,7 @@ type NodeNetworkInterface interface { //nolint:interfacebloat // legacy interfac
 	QinQ() bool
 	RulesetIn() string
 	RulesetOut() string
+	Link() NodeNetworkLink
 
 	SetName(string)
 	SetType(string)
@@ -191,6 +192,15 @@ type NodeNetworkInterface interface { //nolint:interfacebloat // legacy interfac
 	SetRulesetOut(string)
 }
 
+type NodeNetworkLink interface {
+	Bandwidth() string
+	Delay() time.Duration
+	Jitter() time.Duration
+	Loss() float64
+	Corruption() float64
+	Reordering() float64
+}
+
 type NodeNetworkRoute interface {
 	Destination() string
 	Next() string
@@ -267,9 +277,21 @@ type NodeDelay interface {
 	Timer() time.Duration
 	User() bool
 	C2() []NodeC2Delay
+	After() []string
+	Probe() NodeReadinessProbe
 }
 
 type NodeC2Delay interface {
 	Hostname() string
 	UseUUID() bool
 }
+
+type NodeReadinessProbe interface {
+	Command() string
+	Port() int
+	Address() string
+	File() string
+	Interval() time.Duration
+	Timeout() time.Duration
+	UseUUID() bool
+}
@@ -199,6 +199,10 @@ func (i Interface) RulesetOut() string {
 	return i.RulesetOutF
 }
 
+func (i Interface) Link() ifaces.NodeNetworkLink { //nolint:ireturn // interface
+	return nil
+}
+
 func (i *Interface) SetName(name string) {
 	i.NameF = name
 }
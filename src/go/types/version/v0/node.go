@@ -412,6 +412,14 @@ func (d Delay) C2() []ifaces.NodeC2Delay {
 	return nil
 }
 
+func (d Delay) After() []string {
+	return nil
+}
+
+func (d Delay) Probe() ifaces.NodeReadinessProbe { //nolint:ireturn // interface
+	return nil
+}
+
 func (n *Node) SetDefaults() {
 	if n.GeneralF.VMTypeF == "" {
 		n.GeneralF.VMTypeF = "kvm"
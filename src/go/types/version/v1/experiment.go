@@ -2,9 +2,11 @@ package v1
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"path/filepath"
 	"reflect"
+	"time"
 
 	"github.com/activeshadow/structs"
 	"github.com/mitchellh/mapstructure"
@@ -82,6 +84,64 @@ func (v VLANSpec) Validate() error {
 	return nil
 }
 
+// Clock configures the virtual time of an experiment's VMs. Either a fixed
+// start time (RFC3339) or an offset from the current time (e.g., "-8760h") can
+// be configured, but not both.
+type Clock struct {
+	StartF  string `json:"start,omitempty"  mapstructure:"start"  structs:"start"  yaml:"start,omitempty"`
+	OffsetF string `json:"offset,omitempty" mapstructure:"offset" structs:"offset" yaml:"offset,omitempty"`
+}
+
+func (c Clock) Start() string {
+	return c.StartF
+}
+
+func (c Clock) Offset() string {
+	return c.OffsetF
+}
+
+func (c Clock) Enabled() bool {
+	return c.StartF != "" || c.OffsetF != ""
+}
+
+// Time returns the virtual time corresponding to the given real time. The
+// given time is returned as-is if the clock is not enabled or is invalid.
+func (c Clock) Time(now time.Time) time.Time {
+	if c.StartF != "" {
+		if start, err := time.Parse(time.RFC3339, c.StartF); err == nil {
+			return start
+		}
+	}
+
+	if c.OffsetF != "" {
+		if offset, err := time.ParseDuration(c.OffsetF); err == nil {
+			return now.Add(offset)
+		}
+	}
+
+	return now
+}
+
+func (c Clock) Validate() error {
+	if c.StartF != "" && c.OffsetF != "" {
+		return errors.New("only one of clock start or clock offset can be set")
+	}
+
+	if c.StartF != "" {
+		if _, err := time.Parse(time.RFC3339, c.StartF); err != nil {
+			return fmt.Errorf("invalid clock start time %s (must be RFC3339): %w", c.StartF, err)
+		}
+	}
+
+	if c.OffsetF != "" {
+		if _, err := time.ParseDuration(c.OffsetF); err != nil {
+			return fmt.Errorf("invalid clock offset %s: %w", c.OffsetF, err)
+		}
+	}
+
+	return nil
+}
+
 type ExperimentSpec struct {
 	ExperimentNameF string            `json:"experimentName,omitempty" mapstructure:"experimentName" structs:"experimentName" yaml:"experimentName,omitempty"`
 	BaseDirF        string            `json:"baseDir"                  mapstructure:"baseDir"        structs:"baseDir"        yaml:"baseDir"`
@@ -89,6 +149,7 @@ type ExperimentSpec struct {
 	TopologyF       *TopologySpec     `json:"topology"                 mapstructure:"topology"       structs:"topology"       yaml:"topology"`
 	ScenarioF       *v2.ScenarioSpec  `json:"scenario"                 mapstructure:"scenario"       structs:"scenario"       yaml:"scenario"`
 	VLANsF          *VLANSpec         `json:"vlans"                    mapstructure:"vlans"          structs:"vlans"          yaml:"vlans"`
+	ClockF          *Clock            `json:"clock,omitempty"          mapstructure:"clock"          structs:"clock"          yaml:"clock,omitempty"`
 	SchedulesF      map[string]string `json:"schedules"                mapstructure:"schedules"      structs:"schedules"      yaml:"schedules"`
 	DeployModeF     string            `json:"deployMode"               mapstructure:"deployMode"     structs:"deployMode"     yaml:"deployMode"`
 	UseGREMeshF     bool              `json:"useGREMesh"               mapstructure:"useGREMesh"     structs:"useGREMesh"     yaml:"useGREMesh"`
@@ -122,6 +183,12 @@ func (e *ExperimentSpec) Init() error {
 		e.SchedulesF = make(map[string]string)
 	}
 
+	if e.ClockF != nil {
+		if err := e.ClockF.Validate(); err != nil {
+			return fmt.Errorf("validating clock: %w", err)
+		}
+	}
+
 	if e.TopologyF != nil {
 		err := e.TopologyF.Init(e.DefaultBridgeF)
 		if err != nil {
@@ -180,6 +247,25 @@ func (e ExperimentSpec) VLANs() ifaces.VLANSpec { //nolint:ireturn // interface
 	return e.VLANsF
 }
 
+func (e ExperimentSpec) Clock() ifaces.ExperimentClock { //nolint:ireturn // interface
+	if e.ClockF == nil {
+		return new(Clock)
+	}
+
+	return e.ClockF
+}
+
+// RTCBase returns the date and time VM real-time clocks should be set to at
+// launch, formatted for QEMU's `-rtc base=` option. It returns an empty string
+// if no experiment clock is configured.
+func (e ExperimentSpec) RTCBase() string {
+	if !e.Clock().Enabled() {
+		return ""
+	}
+
+	return e.Clock().Time(time.Now()).UTC().Format("2006-01-02T15:04:05")
+}
+
 func (e ExperimentSpec) Schedules() map[string]string {
 	if e.SchedulesF == nil {
 		return make(map[string]string)
@@ -254,6 +340,11 @@ func (e *ExperimentSpec) SetVLANRange(minVal, maxVal int, f bool) error {
 	}
 
 	for k, v := range e.VLANsF.AliasesF {
+		// Aliases without a VLAN ID yet are allocated from the range at start.
+		if v == 0 {
+			continue
+		}
+
 		if minVal != 0 && v < minVal {
 			return fmt.Errorf(
 				"topology VLAN %s (VLAN ID %d) is less than proposed experiment min VLAN ID of %d",
@@ -339,6 +430,10 @@ type ExperimentStatus struct {
 	AppsF      map[string]any    `json:"apps"      mapstructure:"apps"      structs:"apps"      yaml:"apps"`
 	VLANsF     map[string]int    `json:"vlans"     mapstructure:"vlans"     structs:"vlans"     yaml:"vlans"`
 
+	// Difference between the virtual time of the experiment's VMs and real time,
+	// formatted as a duration. Only set when an experiment clock is configured.
+	ClockOffsetF string `json:"clockOffset,omitempty" mapstructure:"clockOffset" structs:"clockOffset,omitempty" yaml:"clockOffset,omitempty"`
+
 	// Used to track details of an app's running stage. Requires special attention
 	// since it can be run periodically in the background and/or triggered
 	// manually via the CLI or UI.
@@ -406,6 +501,22 @@ func (s ExperimentStatus) Schedules() map[string]string {
 	return s.SchedulesF
 }
 
+func (s ExperimentStatus) ClockOffset() time.Duration {
+	offset, _ := time.ParseDuration(s.ClockOffsetF)
+
+	return offset
+}
+
+func (s *ExperimentStatus) SetClockOffset(offset time.Duration) {
+	if offset == 0 {
+		s.ClockOffsetF = ""
+
+		return
+	}
+
+	s.ClockOffsetF = offset.String()
+}
+
 func (s *ExperimentStatus) SetStartTime(t string) {
 	s.StartTimeF = t
 }
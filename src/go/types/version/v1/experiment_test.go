@@ -0,0 +1,63 @@
+package v1
+
+import (
+	"testing"
+	"time"
+)
+
+func TestClockValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		clock   Clock
+		wantErr bool
+	}{
+		{"empty", Clock{}, false},
+		{"start", Clock{StartF: "2019-03-01T08:00:00Z"}, false},
+		{"offset", Clock{OffsetF: "-8760h"}, false},
+		{"both", Clock{StartF: "2019-03-01T08:00:00Z", OffsetF: "-8760h"}, true},
+		{"invalid start", Clock{StartF: "March 1, 2019"}, true},
+		{"invalid offset", Clock{OffsetF: "one year"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.clock.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestClockTime(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	start := Clock{StartF: "2019-03-01T08:00:00Z"} //nolint:exhaustruct // partial initialization
+	if got := start.Time(now); !got.Equal(time.Date(2019, 3, 1, 8, 0, 0, 0, time.UTC)) {
+		t.Fatalf("expected fixed start time, got %v", got)
+	}
+
+	offset := Clock{OffsetF: "-24h"} //nolint:exhaustruct // partial initialization
+	if got := offset.Time(now); !got.Equal(now.Add(-24 * time.Hour)) {
+		t.Fatalf("expected offset time, got %v", got)
+	}
+
+	var disabled Clock
+	if disabled.Enabled() || !disabled.Time(now).Equal(now) {
+		t.Fatal("expected disabled clock to return real time")
+	}
+}
+
+func TestExperimentSpecRTCBase(t *testing.T) {
+	var spec ExperimentSpec
+
+	if base := spec.RTCBase(); base != "" {
+		t.Fatalf("expected no RTC base without clock, got %s", base)
+	}
+
+	spec.ClockF = &Clock{StartF: "2019-03-01T03:00:00-05:00"} //nolint:exhaustruct // partial initialization
+
+	if base := spec.RTCBase(); base != "2019-03-01T08:00:00" {
+		t.Fatalf("expected RTC base in UTC, got %s", base)
+	}
+}
@@ -4,8 +4,10 @@ import (
 	"fmt"
 	"net"
 	"strings"
+	"time"
 
 	ifaces "phenix/types/interfaces"
+	"phenix/util/mm"
 )
 
 const ruleIDDecrement = 10
@@ -135,25 +137,26 @@ func (n *Network) InterfaceMask(name string) int {
 }
 
 type Interface struct {
-	NameF       string   `json:"name"        mapstructure:"name"        structs:"name"        yaml:"name"`
-	TypeF       string   `json:"type"        mapstructure:"type"        structs:"type"        yaml:"type"`
-	ProtoF      string   `json:"proto"       mapstructure:"proto"       structs:"proto"       yaml:"proto"`
-	UDPPortF    int      `json:"udp_port"    mapstructure:"udp_port"    structs:"udp_port"    yaml:"udp_port"`
-	BaudRateF   int      `json:"baud_rate"   mapstructure:"baud_rate"   structs:"baud_rate"   yaml:"baud_rate"`
-	DeviceF     string   `json:"device"      mapstructure:"device"      structs:"device"      yaml:"device"`
-	VLANF       string   `json:"vlan"        mapstructure:"vlan"        structs:"vlan"        yaml:"vlan"`
-	BridgeF     string   `json:"bridge"      mapstructure:"bridge"      structs:"bridge"      yaml:"bridge"`
-	AutostartF  bool     `json:"autostart"   mapstructure:"autostart"   structs:"autostart"   yaml:"autostart"`
-	MACF        string   `json:"mac"         mapstructure:"mac"         structs:"mac"         yaml:"mac"`
-	DriverF     string   `json:"driver"      mapstructure:"driver"      structs:"driver"      yaml:"driver"`
-	MTUF        int      `json:"mtu"         mapstructure:"mtu"         structs:"mtu"         yaml:"mtu"`
-	AddressF    string   `json:"address"     mapstructure:"address"     structs:"address"     yaml:"address"`
-	MaskF       int      `json:"mask"        mapstructure:"mask"        structs:"mask"        yaml:"mask"`
-	GatewayF    string   `json:"gateway"     mapstructure:"gateway"     structs:"gateway"     yaml:"gateway"`
-	DNSF        []string `json:"dns"         mapstructure:"dns"         structs:"dns"         yaml:"dns"`
-	QinQF       bool     `json:"qinq"        mapstructure:"qinq"        structs:"qinq"        yaml:"qinq"`
-	RulesetInF  string   `json:"ruleset_in"  mapstructure:"ruleset_in"  structs:"ruleset_in"  yaml:"ruleset_in"`
-	RulesetOutF string   `json:"ruleset_out" mapstructure:"ruleset_out" structs:"ruleset_out" yaml:"ruleset_out"`
+	NameF       string   `json:"name"           mapstructure:"name"        structs:"name"        yaml:"name"`
+	TypeF       string   `json:"type"           mapstructure:"type"        structs:"type"        yaml:"type"`
+	ProtoF      string   `json:"proto"          mapstructure:"proto"       structs:"proto"       yaml:"proto"`
+	UDPPortF    int      `json:"udp_port"       mapstructure:"udp_port"    structs:"udp_port"    yaml:"udp_port"`
+	BaudRateF   int      `json:"baud_rate"      mapstructure:"baud_rate"   structs:"baud_rate"   yaml:"baud_rate"`
+	DeviceF     string   `json:"device"         mapstructure:"device"      structs:"device"      yaml:"device"`
+	VLANF       string   `json:"vlan"           mapstructure:"vlan"        structs:"vlan"        yaml:"vlan"`
+	BridgeF     string   `json:"bridge"         mapstructure:"bridge"      structs:"bridge"      yaml:"bridge"`
+	AutostartF  bool     `json:"autostart"      mapstructure:"autostart"   structs:"autostart"   yaml:"autostart"`
+	MACF        string   `json:"mac"            mapstructure:"mac"         structs:"mac"         yaml:"mac"`
+	DriverF     string   `json:"driver"         mapstructure:"driver"      structs:"driver"      yaml:"driver"`
+	MTUF        int      `json:"mtu"            mapstructure:"mtu"         structs:"mtu"         yaml:"mtu"`
+	AddressF    string   `json:"address"        mapstructure:"address"     structs:"address"     yaml:"address"`
+	MaskF       int      `json:"mask"           mapstructure:"mask"        structs:"mask"        yaml:"mask"`
+	GatewayF    string   `json:"gateway"        mapstructure:"gateway"     structs:"gateway"     yaml:"gateway"`
+	DNSF        []string `json:"dns"            mapstructure:"dns"         structs:"dns"         yaml:"dns"`
+	QinQF       bool     `json:"qinq"           mapstructure:"qinq"        structs:"qinq"        yaml:"qinq"`
+	RulesetInF  string   `json:"ruleset_in"     mapstructure:"ruleset_in"  structs:"ruleset_in"  yaml:"ruleset_in"`
+	RulesetOutF string   `json:"ruleset_out"    mapstructure:"ruleset_out" structs:"ruleset_out" yaml:"ruleset_out"`
+	LinkF       *Link    `json:"link,omitempty" mapstructure:"link"        structs:"link"        yaml:"link,omitempty"`
 
 	BridgeSetInTopo *bool `json:"-" mapstructure:"bridge_set_in_topo,omitempty" structs:"bridge_set_in_topo,omitempty" yaml:"-"`
 }
@@ -234,6 +237,15 @@ func (i Interface) RulesetOut() string {
 	return i.RulesetOutF
 }
 
+func (i Interface) Link() ifaces.NodeNetworkLink { //nolint:ireturn // interface
+	// avoid returning an interface holding a nil value (see OSPF above)
+	if i.LinkF == nil {
+		return nil
+	}
+
+	return i.LinkF
+}
+
 func (i *Interface) SetName(name string) {
 	i.NameF = name
 }
@@ -623,3 +635,66 @@ func (i Interface) NetworkMask() string {
 
 	return fmt.Sprintf("%d.%d.%d.%d", m[0], m[1], m[2], m[3])
 }
+
+// Link configures emulated link properties (applied using netem) for traffic
+// sent to a node via an interface. Durations are strings (e.g., "250ms") and
+// loss, corruption, and reordering are percentages.
+type Link struct {
+	BandwidthF  string  `json:"bandwidth,omitempty"  mapstructure:"bandwidth"  structs:"bandwidth"  yaml:"bandwidth,omitempty"`
+	DelayF      string  `json:"delay,omitempty"      mapstructure:"delay"      structs:"delay"      yaml:"delay,omitempty"`
+	JitterF     string  `json:"jitter,omitempty"     mapstructure:"jitter"     structs:"jitter"     yaml:"jitter,omitempty"`
+	LossF       float64 `json:"loss,omitempty"       mapstructure:"loss"       structs:"loss"       yaml:"loss,omitempty"`
+	CorruptionF float64 `json:"corruption,omitempty" mapstructure:"corruption" structs:"corruption" yaml:"corruption,omitempty"`
+	ReorderingF float64 `json:"reordering,omitempty" mapstructure:"reordering" structs:"reordering" yaml:"reordering,omitempty"`
+}
+
+func (l Link) Bandwidth() string {
+	return l.BandwidthF
+}
+
+func (l Link) Delay() time.Duration {
+	d, _ := time.ParseDuration(l.DelayF)
+
+	return d
+}
+
+func (l Link) Jitter() time.Duration {
+	d, _ := time.ParseDuration(l.JitterF)
+
+	return d
+}
+
+func (l Link) Loss() float64 {
+	return l.LossF
+}
+
+func (l Link) Corruption() float64 {
+	return l.CorruptionF
+}
+
+func (l Link) Reordering() float64 {
+	return l.ReorderingF
+}
+
+func (l Link) validate() error {
+	for name, value := range map[string]string{"delay": l.DelayF, "jitter": l.JitterF} {
+		if value == "" {
+			continue
+		}
+
+		if _, err := time.ParseDuration(value); err != nil {
+			return fmt.Errorf("invalid link %s %s: %w", name, value, err)
+		}
+	}
+
+	imp := mm.Impairment{
+		Delay:      l.Delay(),
+		Jitter:     l.Jitter(),
+		Loss:       l.LossF,
+		Corruption: l.CorruptionF,
+		Reordering: l.ReorderingF,
+		Rate:       l.BandwidthF,
+	}
+
+	return imp.Validate()
+}
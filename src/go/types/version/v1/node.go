@@ -360,6 +360,10 @@ func (n Node) Delayed() string {
 		return "cc:" + strings.Join(hosts, ",")
 	}
 
+	if len(n.DelayF.AfterF) > 0 {
+		return "after:" + strings.Join(n.DelayF.AfterF, ",")
+	}
+
 	return ""
 }
 
@@ -578,6 +582,12 @@ func (del Deletion) Description() string {
 }
 
 func (n Node) validate() error {
+	if n.DelayF != nil {
+		if err := n.DelayF.validate(); err != nil {
+			return err
+		}
+	}
+
 	if n.ExternalF != nil {
 		if external := *n.ExternalF; !external {
 			return errors.New(
@@ -602,6 +612,12 @@ func (n Node) validate() error {
 			continue
 		}
 
+		if iface.LinkF != nil {
+			if err := iface.LinkF.validate(); err != nil {
+				return fmt.Errorf("interface %q: %w", iface.NameF, err)
+			}
+		}
+
 		// ensure interface name only used once for each node
 		if name := iface.NameF; name != "" {
 			if _, ok := seenIfaces[name]; ok {
@@ -683,9 +699,11 @@ func (n *Node) setDefaults(bridge string) {
 }
 
 type Delay struct {
-	TimerF string    `json:"timer" mapstructure:"timer" structs:"timer" yaml:"timer"`
-	UserF  bool      `json:"user"  mapstructure:"user"  structs:"user"  yaml:"user"`
-	C2F    []C2Delay `json:"c2"    mapstructure:"c2"    structs:"c2"    yaml:"c2"`
+	TimerF string          `json:"timer" mapstructure:"timer" structs:"timer" yaml:"timer"`
+	UserF  bool            `json:"user"  mapstructure:"user"  structs:"user"  yaml:"user"`
+	C2F    []C2Delay       `json:"c2"    mapstructure:"c2"    structs:"c2"    yaml:"c2"`
+	AfterF []string        `json:"after" mapstructure:"after" structs:"after" yaml:"after"`
+	ProbeF *ReadinessProbe `json:"probe" mapstructure:"probe" structs:"probe" yaml:"probe"`
 }
 
 func (delay Delay) Timer() time.Duration {
@@ -712,6 +730,30 @@ func (delay Delay) C2() []ifaces.NodeC2Delay {
 	return delays
 }
 
+func (delay Delay) After() []string {
+	return delay.AfterF
+}
+
+func (delay Delay) Probe() ifaces.NodeReadinessProbe { //nolint:ireturn // interface
+	if delay.ProbeF == nil {
+		return nil
+	}
+
+	return delay.ProbeF
+}
+
+func (delay Delay) validate() error {
+	if delay.ProbeF == nil {
+		return nil
+	}
+
+	if len(delay.AfterF) == 0 {
+		return errors.New("delay probe requires at least one host in after")
+	}
+
+	return delay.ProbeF.validate()
+}
+
 type C2Delay struct {
 	HostnameF string `json:"hostname" mapstructure:"hostname" structs:"hostname" yaml:"hostname"`
 	UseUUIDF  bool   `json:"useUUID"  mapstructure:"useUUID"  structs:"useUUID"  yaml:"useUUID"`
@@ -725,6 +767,99 @@ func (c C2Delay) UseUUID() bool {
 	return c.UseUUIDF
 }
 
+// ReadinessProbe determines when the hosts a delayed node is waiting on (via
+// `after`) are ready. Exactly one of Command, Port, or File must be set. The
+// probe is run on each of the hosts being waited on via C2.
+type ReadinessProbe struct {
+	CommandF  string `json:"command"  mapstructure:"command"  structs:"command"  yaml:"command"`
+	PortF     int    `json:"port"     mapstructure:"port"     structs:"port"     yaml:"port"`
+	AddressF  string `json:"address"  mapstructure:"address"  structs:"address"  yaml:"address"`
+	FileF     string `json:"file"     mapstructure:"file"     structs:"file"     yaml:"file"`
+	IntervalF string `json:"interval" mapstructure:"interval" structs:"interval" yaml:"interval"`
+	TimeoutF  string `json:"timeout"  mapstructure:"timeout"  structs:"timeout"  yaml:"timeout"`
+	UseUUIDF  bool   `json:"useUUID"  mapstructure:"useUUID"  structs:"useUUID"  yaml:"useUUID"`
+}
+
+func (p ReadinessProbe) Command() string {
+	return p.CommandF
+}
+
+func (p ReadinessProbe) Port() int {
+	return p.PortF
+}
+
+func (p ReadinessProbe) Address() string {
+	if p.AddressF == "" {
+		return "127.0.0.1"
+	}
+
+	return p.AddressF
+}
+
+func (p ReadinessProbe) File() string {
+	return p.FileF
+}
+
+func (p ReadinessProbe) Interval() time.Duration {
+	if p.IntervalF == "" {
+		return 0
+	}
+
+	d, _ := time.ParseDuration(p.IntervalF)
+
+	return d
+}
+
+func (p ReadinessProbe) Timeout() time.Duration {
+	if p.TimeoutF == "" {
+		return 0
+	}
+
+	d, _ := time.ParseDuration(p.TimeoutF)
+
+	return d
+}
+
+func (p ReadinessProbe) UseUUID() bool {
+	return p.UseUUIDF
+}
+
+func (p ReadinessProbe) validate() error {
+	var kinds int
+
+	if p.CommandF != "" {
+		kinds++
+	}
+
+	if p.PortF != 0 {
+		if p.PortF < 0 || p.PortF > 65535 {
+			return fmt.Errorf("delay probe port %d is out of range", p.PortF)
+		}
+
+		kinds++
+	}
+
+	if p.FileF != "" {
+		kinds++
+	}
+
+	if kinds != 1 {
+		return errors.New("delay probe must specify exactly one of command, port, or file")
+	}
+
+	for _, d := range []string{p.IntervalF, p.TimeoutF} {
+		if d == "" {
+			continue
+		}
+
+		if _, err := time.ParseDuration(d); err != nil {
+			return fmt.Errorf("invalid delay probe duration %q: %w", d, err)
+		}
+	}
+
+	return nil
+}
+
 func (n Node) FileInjects(baseDir string) string {
 	injects := make([]string, len(n.InjectionsF))
 
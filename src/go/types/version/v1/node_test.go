@@ -106,6 +106,33 @@ func TestNodeValidate(t *testing.T) {
 				},
 			),
 		},
+		{
+			name: "internal node, valid link properties",
+			node: nodeWithIfaces(
+				&Interface{
+					NameF: "eth0",
+					LinkF: &Link{BandwidthF: "10mbit", DelayF: "250ms", JitterF: "20ms", LossF: 1.5, ReorderingF: 5},
+				},
+			),
+		},
+		{
+			name:    "internal node, invalid link delay",
+			node:    nodeWithIfaces(&Interface{NameF: "eth0", LinkF: &Link{DelayF: "slow"}}),
+			wantErr: true,
+			substr:  "invalid link delay",
+		},
+		{
+			name:    "internal node, link jitter without delay",
+			node:    nodeWithIfaces(&Interface{NameF: "eth0", LinkF: &Link{JitterF: "10ms"}}),
+			wantErr: true,
+			substr:  "jitter requires delay",
+		},
+		{
+			name:    "internal node, link loss out of range",
+			node:    nodeWithIfaces(&Interface{NameF: "eth0", LinkF: &Link{LossF: 150}}),
+			wantErr: true,
+			substr:  "loss must be between 0 and 100",
+		},
 	}
 
 	for _, tt := range tests {
@@ -130,3 +157,65 @@ func TestNodeValidate(t *testing.T) {
 		})
 	}
 }
+
+func TestNodeValidateDelayProbe(t *testing.T) {
+	tests := []struct {
+		name    string
+		delay   *Delay
+		wantErr string
+	}{
+		{
+			name:  "after without probe",
+			delay: &Delay{AfterF: []string{"dc"}},
+		},
+		{
+			name:  "after with port probe",
+			delay: &Delay{AfterF: []string{"dc"}, ProbeF: &ReadinessProbe{PortF: 389, TimeoutF: "10m"}},
+		},
+		{
+			name:    "probe without after",
+			delay:   &Delay{ProbeF: &ReadinessProbe{FileF: "/ready"}},
+			wantErr: "requires at least one host in after",
+		},
+		{
+			name:    "probe with multiple kinds",
+			delay:   &Delay{AfterF: []string{"dc"}, ProbeF: &ReadinessProbe{PortF: 389, FileF: "/ready"}},
+			wantErr: "exactly one of command, port, or file",
+		},
+		{
+			name:    "probe with no kind",
+			delay:   &Delay{AfterF: []string{"dc"}, ProbeF: &ReadinessProbe{}},
+			wantErr: "exactly one of command, port, or file",
+		},
+		{
+			name:    "probe with invalid port",
+			delay:   &Delay{AfterF: []string{"dc"}, ProbeF: &ReadinessProbe{PortF: 70000}},
+			wantErr: "out of range",
+		},
+		{
+			name:    "probe with invalid interval",
+			delay:   &Delay{AfterF: []string{"dc"}, ProbeF: &ReadinessProbe{CommandF: "true", IntervalF: "often"}},
+			wantErr: "invalid delay probe duration",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			node := Node{GeneralF: &General{HostnameF: "test-node"}, DelayF: tt.delay}
+
+			err := node.validate()
+
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("expected no error, got %v", err)
+				}
+
+				return
+			}
+
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
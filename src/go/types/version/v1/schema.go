@@ -1,5 +1,5 @@
 package v1
 
 var OpenAPI = []byte( //nolint:gochecknoglobals // global constant
-	"\nopenapi: \"3.0.0\"\ninfo:\n  title: phenix config specs\n  version: \"1.0\"\npaths: {}\ncomponents:\n  schemas:\n    Image:\n      type: object\n      required:\n      - format\n      - mirror\n      - release\n      - size\n      - variant\n      properties:\n        compress:\n          type: boolean\n          default: false\n          example: false\n        deb_append:\n          type: string\n          example: --components=main,restricted\n        format:\n          type: string\n          example: qcow2\n        mirror:\n          type: string\n          example: http://us.archive.ubuntu.com/ubuntu/\n        overlays:\n          type: array\n          nullable: true\n          items:\n            type: string\n          example:\n          - /phenix/vmdb/overlays/example-overlay\n        packages:\n          type: array\n          nullable: true\n          items:\n            type: string\n          example:\n          - isc-dhcp-client\n          - openssh-server\n        ramdisk:\n          type: boolean\n          default: false\n          example: false\n        release:\n          type: string\n          example: focal\n        script_order:\n          type: array\n          nullable: true\n          items:\n            type: string\n          example:\n          - POSTBUILD_APT_CLEANUP\n        scripts:\n          type: object\n          additionalProperties:\n            type: string\n          example:\n            POSTBUILD_APT_CLEANUP: |\n              apt clean || apt-get clean || echo \"unable to clean apt cache\"\n        size:\n          type: string\n          example: 10G\n        variant:\n          type: string\n          example: minbase\n    Role:\n      type: object\n      required:\n      - policies\n      - roleName\n      properties:\n        policies:\n          type: array\n          items:\n            type: object\n            properties:\n              resources:\n                type: array\n                items:\n                  type: string\n              resourceNames:\n                type: array\n                items:\n                  type: string\n              verbs:\n                type: array\n                items:\n                  type: string\n          example:\n          - resources:\n            - experiments\n            - experiments/*\n            resourceNames:\n            - '*'\n            verbs:\n            - list\n            - get\n        roleName:\n          type: string\n          example: Example Role\n    User:\n      type: object\n      required:\n      - first_name\n      - last_name\n      - username\n      properties:\n        first_name:\n          type: string\n          example: John\n        last_name:\n          type: string\n          example: Doe\n        password:\n          type: string\n          example: '<encrypted password>'\n          readOnly: true\n        rbac:\n          allOf:\n          - $ref: \"#/components/schemas/Role\"\n          readOnly: true\n        username:\n          type: string\n          example: johndoe@example.com\n    Topology:\n      type: object\n      anyOf:\n      - required:\n        - nodes\n      - required:\n        - includeTopologies\n      properties:\n        includeTopologies:\n          type: array\n          items:\n            type: string\n          example:\n          - /phenix/topologies/enterprise/phenix-configs/topology.yml\n          - store-topo\n        nodes:\n          type: array\n          items:\n            oneOf:\n            - $ref: '#/components/schemas/minimega_node'\n            - $ref: '#/components/schemas/external_node'\n    Scenario:\n      type: object\n      required:\n      - apps\n      properties:\n        apps:\n          type: object\n          properties:\n            experiment:\n              type: array\n              items:\n                type: object\n                required:\n                - name\n                properties:\n                  name:\n                    type: string\n                    minLength: 1\n    Experiment:\n      type: object\n      required:\n      - topology\n      properties:\n        topology:\n          $ref: \"#/components/schemas/Topology\"\n        scenario:\n          $ref: \"#/components/schemas/Scenario\"\n        baseDir:\n          type: string\n          example: /phenix/topologies/example-topo\n        experimentName:\n          type: string\n          example: example-exp\n          readOnly: true\n        vlans:\n          type: object\n          properties:\n            aliases:\n              type: object\n              additionalProperties:\n                type: integer\n              example:\n                MGMT: 200\n            min:\n              type: integer\n            max:\n              type: integer\n        schedule:\n          type: object\n          additionalProperties:\n            type: string\n          example:\n            ADServer: compute1\n    minimega_node:\n      type: object\n      required:\n      - type\n      - general\n      - hardware\n      properties:\n        type:\n          type: string\n          default: VirtualMachine\n          example: VirtualMachine\n        general:\n          type: object\n          required:\n          - hostname\n          properties:\n            hostname:\n              type: string\n              minLength: 1\n              maxLength: 63\n              pattern: '^[a-zA-Z0-9](?:[a-zA-Z0-9-]*[a-zA-Z0-9])?$'\n              example: ADServer\n            description:\n              type: string\n              example: Active Directory Server\n            vm_type:\n              type: string\n              enum:\n              - kvm\n              - container\n              - \"\"\n              default: kvm\n              example: kvm\n            snapshot:\n              type: boolean\n              default: false\n              example: false\n              nullable: true\n            do_not_boot:\n              type: boolean\n              default: false\n              example: false\n              nullable: true\n        hardware:\n          type: object\n          required:\n          - os_type\n          - drives\n          properties:\n            cpu:\n              type: string\n              default: Broadwell\n              example: Broadwell\n            vcpus:\n              oneOf:\n              - type: integer\n              - type: string\n              default: 1\n              example: 4\n            memory:\n              oneOf:\n              - type: integer\n              - type: string\n              default: 1024\n              example: 8192\n            os_type:\n              type: string\n              enum:\n              - centos\n              - linux\n              - minirouter\n              - rhel\n              - vyatta\n              - vyos\n              - windows\n              - other\n              default: linux\n              example: windows\n            drives:\n              type: array\n              minItems: 1\n              items:\n                type: object\n                required:\n                - image\n                properties:\n                  image:\n                    type: string\n                    minLength: 1\n                    example: ubuntu.qc2\n                  interface:\n                    type: string\n                    enum:\n                    - ahci\n                    - ide\n                    - scsi\n                    - sd\n                    - mtd\n                    - floppy\n                    - pflash\n                    - virtio\n                    - \"\"\n                    default: ide\n                    example: ide\n                  cache_mode:\n                    type: string\n                    enum:\n                    - none\n                    - writeback\n                    - unsafe\n                    - directsync\n                    - writethrough\n                    - \"\"\n                    default: writeback\n                    example: writeback\n                  inject_partition:\n                    type: integer\n                    default: 1\n                    example: 2\n                    nullable: true\n        network:\n          type: object\n          required:\n          - interfaces\n          properties:\n            interfaces:\n              type: array\n              nullable: true\n              items:\n                type: object\n                oneOf:\n                - $ref: '#/components/schemas/static_iface'\n                - $ref: '#/components/schemas/dhcp_iface'\n                - $ref: '#/components/schemas/serial_iface'\n            routes:\n              type: array\n              nullable: true\n              items:\n                type: object\n                required:\n                - destination\n                - next\n                properties:\n                  destination:\n                    type: string\n                    minLength: 1\n                    example: 192.168.0.0/24\n                  next:\n                    type: string\n                    minLength: 1\n                    example: 192.168.1.254\n                  cost:\n                    type: integer\n                    default: 1\n                    example: 1\n                    nullable: true\n            ospf:\n              type: object\n              required:\n              - router_id\n              - areas\n              properties:\n                router_id:\n                  type: string\n                  minLength: 1\n                  example: 0.0.0.1\n                areas:\n                  type: array\n                  items:\n                    type: object\n                    required:\n                    - area_id\n                    - area_networks\n                    properties:\n                      area_id:\n                        type: integer\n                        example: 1\n                        default: 1\n                      area_networks:\n                        type: array\n                        items:\n                          type: object\n                          required:\n                          - network\n                          properties:\n                            network:\n                              type: string\n                              minLength: 1\n                              example: 10.1.25.0/24\n            rulesets:\n              type: array\n              nullable: true\n              items:\n                type: object\n                required:\n                - name\n                - default\n                - rules\n                properties:\n                  name:\n                    type: string\n                    minLength: 1\n                    example: OutToDMZ\n                  description:\n                    type: string\n                    minLength: 1\n                    example: From Corp to the DMZ network\n                  default:\n                    type: string\n                    enum:\n                    - accept\n                    - drop\n                    - reject\n                    example: drop\n                  rules:\n                    type: array\n                    items:\n                      type: object\n                      required:\n                      - id\n                      - action\n                      - protocol\n                      properties:\n                        id:\n                          type: integer\n                          example: 10\n                        description:\n                          type: string\n                          example: Allow UDP 10.1.26.80 ==> 10.2.25.0/24:123\n                        action:\n                          type: string\n                          enum:\n                          - accept\n                          - drop\n                          - reject\n                          example: accept\n                        protocol:\n                          type: string\n                          enum:\n                          - tcp\n                          - udp\n                          - tcp_udp\n                          - icmp\n                          - esp\n                          - ah\n                          - all\n                          default: tcp\n                          example: tcp\n                        source:\n                          type: object\n                          required:\n                          - address\n                          properties:\n                            address:\n                              type: string\n                              minLength: 1\n                              example: 10.1.24.60\n                            port:\n                              type: integer\n                              example: 3389\n                        destination:\n                          type: object\n                          required:\n                          - address\n                          properties:\n                            address:\n                              type: string\n                              minLength: 1\n                              example: 10.1.24.60\n                            port:\n                              type: integer\n                              example: 3389\n        injections:\n          type: array\n          nullable: true\n          items:\n            type: object\n            required:\n            - src\n            - dst\n            properties:\n              src:\n                type: string\n                minLength: 1\n                example: foo.xml\n              dst:\n                type: string\n                minLength: 1\n                example: /etc/phenix/foo.xml\n              description:\n                type: string\n                example: phenix config file\n              permissions:\n                type: string\n                example: '0664'\n        delay:\n          type: object\n          nullable: true\n          properties:\n            timer:\n              type: string\n              example: 5m\n            user:\n              type: boolean\n            c2:\n              type: array\n              nullable: true\n              items:\n                type: object\n                properties:\n                  hostname:\n                    type: string\n                  useUUID:\n                    type: boolean\n        advanced:\n          type: object\n        commands:\n          type: array\n          nullable: true\n          items:\n            type: string\n          example:\n          - exec df -h\n    external_node:\n      type: object\n      required:\n      - external\n      - type\n      - general\n      properties:\n        external:\n          type: boolean\n        type:\n          type: string\n          default: HIL\n          example: HIL\n        general:\n          type: object\n          required:\n          - hostname\n          properties:\n            hostname:\n              type: string\n              example: ADServer\n            description:\n              type: string\n              example: Active Directory Server\n            vm_type:\n              type: string\n              enum:\n              - vm\n              - container\n              - \"\"\n              default: vm\n              example: vm\n        hardware:\n          type: object\n          nullable: true\n          required:\n          - os_type\n          properties:\n            cpu:\n              type: string\n              default: Broadwell\n              example: Broadwell\n            vcpus:\n              oneOf:\n              - type: integer\n              - type: string\n              default: 1\n              example: 4\n            memory:\n              oneOf:\n              - type: integer\n              - type: string\n              default: 1024\n              example: 8192\n            os_type:\n              type: string\n              default: linux\n              example: windows\n        network:\n          type: object\n          nullable: true\n          required:\n          - interfaces\n          properties:\n            interfaces:\n              type: array\n              items:\n                type: object\n                required:\n                - name\n                properties:\n                  name:\n                    type: string\n                    example: eth0\n                  proto:\n                    type: string\n                    enum:\n                    - static\n                    - dhcp\n                    - manual\n                    - \"\"\n                    default: dhcp\n                    example: static\n                  address:\n                    type: string\n                    format: ipv4\n                    example: 192.168.1.100\n                  mask:\n                    type: integer\n                    minimum: 0\n                    maximum: 32\n                    default: 24\n                    example: 24\n                  gateway:\n                    type: string\n                    format: ipv4\n                    example: 192.168.1.1\n                  vlan:\n                    type: string\n                    example: EXP-1\n    iface:\n      type: object\n      required:\n      - name\n      - vlan\n      properties:\n        name:\n          type: string\n          minLength: 1\n          example: eth0\n        vlan:\n          type: string\n          minLength: 1\n          example: EXP-1\n        autostart:\n          type: boolean\n          default: true\n        mac:\n          type: string\n          example: 00:11:22:33:44:55:66\n          pattern: '^([0-9a-fA-F]{2}[:-]){5}([0-9a-fA-F]){2}$'\n        mtu:\n          type: integer\n          default: 1500\n          example: 1500\n        bridge:\n          type: string\n          default: phenix\n        driver:\n          type: string\n          example: e1000\n        qinq:\n          type: boolean\n          default: false\n    iface_address:\n      type: object\n      required:\n      - address\n      - mask\n      properties:\n        address:\n          type: string\n          format: ipv4\n          minLength: 7\n          example: 192.168.1.100\n        mask:\n          type: integer\n          minimum: 0\n          maximum: 32\n          default: 24\n          example: 24\n        gateway:\n          type: string\n          format: ipv4\n          minLength: 7\n          example: 192.168.1.1\n        dns:\n          nullable: true\n          oneOf:\n          - type: string\n          - type: array\n            items:\n              type: string\n          example:\n          - 192.168.1.1\n          - 192.168.1.2\n    iface_rulesets:\n      type: object\n      properties:\n        ruleset_out:\n          type: string\n          example: OutToInet\n          pattern: '^[\\w-]+$'\n        ruleset_in:\n          type: string\n          example: InFromInet\n          pattern: '^[\\w-]+$'\n    static_iface:\n      allOf:\n      - $ref: '#/components/schemas/iface'\n      - $ref: '#/components/schemas/iface_address'\n      - $ref: '#/components/schemas/iface_rulesets'\n      required:\n      - type\n      - proto\n      properties:\n        type:\n          type: string\n          enum:\n          - ethernet\n          default: ethernet\n          example: ethernet\n        proto:\n          type: string\n          enum:\n          - static\n          - ospf\n          default: static\n          example: static\n    dhcp_iface:\n      allOf:\n      - $ref: '#/components/schemas/iface'\n      - $ref: '#/components/schemas/iface_rulesets'\n      required:\n      - type\n      - proto\n      properties:\n        type:\n          type: string\n          enum:\n          - ethernet\n          default: ethernet\n          example: ethernet\n        proto:\n          type: string\n          enum:\n          - dhcp\n          - manual\n          default: dhcp\n          example: dhcp\n    serial_iface:\n      allOf:\n      - $ref: '#/components/schemas/iface'\n      - $ref: '#/components/schemas/iface_address'\n      - $ref: '#/components/schemas/iface_rulesets'\n      required:\n      - type\n      - proto\n      - udp_port\n      - baud_rate\n      - device\n      properties:\n        type:\n          type: string\n          enum:\n          - serial\n          default: serial\n          example: serial\n        proto:\n          type: string\n          enum:\n          - static\n          default: static\n          example: static\n        udp_port:\n          type: integer\n          minimum: 0\n          maximum: 65535\n          default: 8989\n          example: 8989\n        baud_rate:\n          type: integer\n          enum:\n          - 110\n          - 300\n          - 600\n          - 1200\n          - 2400\n          - 4800\n          - 9600\n          - 14400\n          - 19200\n          - 38400\n          - 57600\n          - 115200\n          - 128000\n          - 256000\n          default: 9600\n          example: 9600\n        device:\n          type: string\n          minLength: 1\n          default: /dev/ttyS0\n          example: /dev/ttyS0\n          pattern:\n",
+	"\nopenapi: \"3.0.0\"\ninfo:\n  title: phenix config specs\n  version: \"1.0\"\npaths: {}\ncomponents:\n  schemas:\n    Image:\n      type: object\n      required:\n      - format\n      - mirror\n      - release\n      - size\n      - variant\n      properties:\n        compress:\n          type: boolean\n          default: false\n          example: false\n        deb_append:\n          type: string\n          example: --components=main,restricted\n        format:\n          type: string\n          example: qcow2\n        mirror:\n          type: string\n          example: http://us.archive.ubuntu.com/ubuntu/\n        overlays:\n          type: array\n          nullable: true\n          items:\n            type: string\n          example:\n          - /phenix/vmdb/overlays/example-overlay\n        packages:\n          type: array\n          nullable: true\n          items:\n            type: string\n          example:\n          - isc-dhcp-client\n          - openssh-server\n        ramdisk:\n          type: boolean\n          default: false\n          example: false\n        release:\n          type: string\n          example: focal\n        script_order:\n          type: array\n          nullable: true\n          items:\n            type: string\n          example:\n          - POSTBUILD_APT_CLEANUP\n        scripts:\n          type: object\n          additionalProperties:\n            type: string\n          example:\n            POSTBUILD_APT_CLEANUP: |\n              apt clean || apt-get clean || echo \"unable to clean apt cache\"\n        size:\n          type: string\n          example: 10G\n        variant:\n          type: string\n          example: minbase\n    Role:\n      type: object\n      required:\n      - policies\n      - roleName\n      properties:\n        policies:\n          type: array\n          items:\n            type: object\n            properties:\n              resources:\n                type: array\n                items:\n                  type: string\n              resourceNames:\n                type: array\n                items:\n                  type: string\n              verbs:\n                type: array\n                items:\n                  type: string\n          example:\n          - resources:\n            - experiments\n            - experiments/*\n            resourceNames:\n            - '*'\n            verbs:\n            - list\n            - get\n        roleName:\n          type: string\n          example: Example Role\n    User:\n      type: object\n      required:\n      - first_name\n      - last_name\n      - username\n      properties:\n        first_name:\n          type: string\n          example: John\n        last_name:\n          type: string\n          example: Doe\n        password:\n          type: string\n          example: '<encrypted password>'\n          readOnly: true\n        rbac:\n          allOf:\n          - $ref: \"#/components/schemas/Role\"\n          readOnly: true\n        username:\n          type: string\n          example: johndoe@example.com\n    Topology:\n      type: object\n      anyOf:\n      - required:\n        - nodes\n      - required:\n        - includeTopologies\n      properties:\n        includeTopologies:\n          type: array\n          items:\n            type: string\n          example:\n          - /phenix/topologies/enterprise/phenix-configs/topology.yml\n          - store-topo\n        nodes:\n          type: array\n          items:\n            oneOf:\n            - $ref: '#/components/schemas/minimega_node'\n            - $ref: '#/components/schemas/external_node'\n    Scenario:\n      type: object\n      required:\n      - apps\n      properties:\n        apps:\n          type: object\n          properties:\n            experiment:\n              type: array\n              items:\n                type: object\n                required:\n                - name\n                properties:\n                  name:\n                    type: string\n                    minLength: 1\n    Experiment:\n      type: object\n      required:\n      - topology\n      properties:\n        topology:\n          $ref: \"#/components/schemas/Topology\"\n        scenario:\n          $ref: \"#/components/schemas/Scenario\"\n        baseDir:\n          type: string\n          example: /phenix/topologies/example-topo\n        experimentName:\n          type: string\n          example: example-exp\n          readOnly: true\n        vlans:\n          type: object\n          properties:\n            aliases:\n              type: object\n              additionalProperties:\n                type: integer\n              example:\n                MGMT: 200\n            min:\n              type: integer\n            max:\n              type: integer\n        schedule:\n          type: object\n          additionalProperties:\n            type: string\n          example:\n            ADServer: compute1\n        clock:\n          type: object\n          nullable: true\n          properties:\n            start:\n              type: string\n              example: \"2019-03-01T08:00:00Z\"\n            offset:\n              type: string\n              example: -8760h\n    minimega_node:\n      type: object\n      required:\n      - type\n      - general\n      - hardware\n      properties:\n        type:\n          type: string\n          default: VirtualMachine\n          example: VirtualMachine\n        general:\n          type: object\n          required:\n          - hostname\n          properties:\n            hostname:\n              type: string\n              minLength: 1\n              maxLength: 63\n              pattern: '^[a-zA-Z0-9](?:[a-zA-Z0-9-]*[a-zA-Z0-9])?$'\n              example: ADServer\n            description:\n              type: string\n              example: Active Directory Server\n            vm_type:\n              type: string\n              enum:\n              - kvm\n              - container\n              - \"\"\n              default: kvm\n              example: kvm\n            snapshot:\n              type: boolean\n              default: false\n              example: false\n              nullable: true\n            do_not_boot:\n              type: boolean\n              default: false\n              example: false\n              nullable: true\n        hardware:\n          type: object\n          required:\n          - os_type\n          - drives\n          properties:\n            cpu:\n              type: string\n              default: Broadwell\n              example: Broadwell\n            vcpus:\n              oneOf:\n              - type: integer\n              - type: string\n              default: 1\n              example: 4\n            memory:\n              oneOf:\n              - type: integer\n              - type: string\n              default: 1024\n              example: 8192\n            os_type:\n              type: string\n              enum:\n              - centos\n              - linux\n              - minirouter\n              - rhel\n              - vyatta\n              - vyos\n              - windows\n              - other\n              default: linux\n              example: windows\n            drives:\n              type: array\n              minItems: 1\n              items:\n                type: object\n                required:\n                - image\n                properties:\n                  image:\n                    type: string\n                    minLength: 1\n                    example: ubuntu.qc2\n                  interface:\n                    type: string\n                    enum:\n                    - ahci\n                    - ide\n                    - scsi\n                    - sd\n                    - mtd\n                    - floppy\n                    - pflash\n                    - virtio\n                    - \"\"\n                    default: ide\n                    example: ide\n                  cache_mode:\n                    type: string\n                    enum:\n                    - none\n                    - writeback\n                    - unsafe\n                    - directsync\n                    - writethrough\n                    - \"\"\n                    default: writeback\n                    example: writeback\n                  inject_partition:\n                    type: integer\n                    default: 1\n                    example: 2\n                    nullable: true\n        network:\n          type: object\n          required:\n          - interfaces\n          properties:\n            interfaces:\n              type: array\n              nullable: true\n              items:\n                type: object\n                oneOf:\n                - $ref: '#/components/schemas/static_iface'\n                - $ref: '#/components/schemas/dhcp_iface'\n                - $ref: '#/components/schemas/serial_iface'\n            routes:\n              type: array\n              nullable: true\n              items:\n                type: object\n                required:\n                - destination\n                - next\n                properties:\n                  destination:\n                    type: string\n                    minLength: 1\n                    example: 192.168.0.0/24\n                  next:\n                    type: string\n                    minLength: 1\n                    example: 192.168.1.254\n                  cost:\n                    type: integer\n                    default: 1\n                    example: 1\n                    nullable: true\n            ospf:\n              type: object\n              required:\n              - router_id\n              - areas\n              properties:\n                router_id:\n                  type: string\n                  minLength: 1\n                  example: 0.0.0.1\n                areas:\n                  type: array\n                  items:\n                    type: object\n                    required:\n                    - area_id\n                    - area_networks\n                    properties:\n                      area_id:\n                        type: integer\n                        example: 1\n                        default: 1\n                      area_networks:\n                        type: array\n                        items:\n                          type: object\n                          required:\n                          - network\n                          properties:\n                            network:\n                              type: string\n                              minLength: 1\n                              example: 10.1.25.0/24\n            rulesets:\n              type: array\n              nullable: true\n              items:\n                type: object\n                required:\n                - name\n                - default\n                - rules\n                properties:\n                  name:\n                    type: string\n                    minLength: 1\n                    example: OutToDMZ\n                  description:\n                    type: string\n                    minLength: 1\n                    example: From Corp to the DMZ network\n                  default:\n                    type: string\n                    enum:\n                    - accept\n                    - drop\n                    - reject\n                    example: drop\n                  rules:\n                    type: array\n                    items:\n                      type: object\n                      required:\n                      - id\n                      - action\n                      - protocol\n                      properties:\n                        id:\n                          type: integer\n                          example: 10\n                        description:\n                          type: string\n                          example: Allow UDP 10.1.26.80 ==> 10.2.25.0/24:123\n                        action:\n                          type: string\n                          enum:\n                          - accept\n                          - drop\n                          - reject\n                          example: accept\n                        protocol:\n                          type: string\n                          enum:\n                          - tcp\n                          - udp\n                          - tcp_udp\n                          - icmp\n                          - esp\n                          - ah\n                          - all\n                          default: tcp\n                          example: tcp\n                        source:\n                          type: object\n                          required:\n                          - address\n                          properties:\n                            address:\n                              type: string\n                              minLength: 1\n                              example: 10.1.24.60\n                            port:\n                              type: integer\n                              example: 3389\n                        destination:\n                          type: object\n                          required:\n                          - address\n                          properties:\n                            address:\n                              type: string\n                              minLength: 1\n                              example: 10.1.24.60\n                            port:\n                              type: integer\n                              example: 3389\n        injections:\n          type: array\n          nullable: true\n          items:\n            type: object\n            required:\n            - src\n            - dst\n            properties:\n              src:\n                type: string\n                minLength: 1\n                example: foo.xml\n              dst:\n                type: string\n                minLength: 1\n                example: /etc/phenix/foo.xml\n              description:\n                type: string\n                example: phenix config file\n              permissions:\n                type: string\n                example: '0664'\n        delay:\n          type: object\n          nullable: true\n          properties:\n            timer:\n              type: string\n              example: 5m\n            user:\n              type: boolean\n            c2:\n              type: array\n              nullable: true\n              items:\n                type: object\n                properties:\n                  hostname:\n                    type: string\n                  useUUID:\n                    type: boolean\n            after:\n              type: array\n              nullable: true\n              items:\n                type: string\n              example:\n              - ADServer\n            probe:\n              type: object\n              nullable: true\n              properties:\n                command:\n                  type: string\n                  example: nltest /dsgetdc:example.com\n                port:\n                  type: integer\n                  minimum: 1\n                  maximum: 65535\n                  example: 389\n                address:\n                  type: string\n                  example: 127.0.0.1\n                file:\n                  type: string\n                  example: /etc/phenix/ready\n                interval:\n                  type: string\n                  example: 5s\n                timeout:\n                  type: string\n                  example: 10m\n                useUUID:\n                  type: boolean\n        advanced:\n          type: object\n        commands:\n          type: array\n          nullable: true\n          items:\n            type: string\n          example:\n          - exec df -h\n    external_node:\n      type: object\n      required:\n      - external\n      - type\n      - general\n      properties:\n        external:\n          type: boolean\n        type:\n          type: string\n          default: HIL\n          example: HIL\n        general:\n          type: object\n          required:\n          - hostname\n          properties:\n            hostname:\n              type: string\n              example: ADServer\n            description:\n              type: string\n              example: Active Directory Server\n            vm_type:\n              type: string\n              enum:\n              - vm\n              - container\n              - \"\"\n              default: vm\n              example: vm\n        hardware:\n          type: object\n          nullable: true\n          required:\n          - os_type\n          properties:\n            cpu:\n              type: string\n              default: Broadwell\n              example: Broadwell\n            vcpus:\n              oneOf:\n              - type: integer\n              - type: string\n              default: 1\n              example: 4\n            memory:\n              oneOf:\n              - type: integer\n              - type: string\n              default: 1024\n              example: 8192\n            os_type:\n              type: string\n              default: linux\n              example: windows\n        network:\n          type: object\n          nullable: true\n          required:\n          - interfaces\n          properties:\n            interfaces:\n              type: array\n              items:\n                type: object\n                required:\n                - name\n                properties:\n                  name:\n                    type: string\n                    example: eth0\n                  proto:\n                    type: string\n                    enum:\n                    - static\n                    - dhcp\n                    - manual\n                    - \"\"\n                    default: dhcp\n                    example: static\n                  address:\n                    type: string\n                    format: ipv4\n                    example: 192.168.1.100\n                  mask:\n                    type: integer\n                    minimum: 0\n                    maximum: 32\n                    default: 24\n                    example: 24\n                  gateway:\n                    type: string\n                    format: ipv4\n                    example: 192.168.1.1\n                  vlan:\n                    type: string\n                    example: EXP-1\n    iface:\n      type: object\n      required:\n      - name\n      - vlan\n      properties:\n        name:\n          type: string\n          minLength: 1\n          example: eth0\n        vlan:\n          type: string\n          minLength: 1\n          example: EXP-1\n        autostart:\n          type: boolean\n          default: true\n        mac:\n          type: string\n          example: 00:11:22:33:44:55:66\n          pattern: '^([0-9a-fA-F]{2}[:-]){5}([0-9a-fA-F]){2}$'\n        mtu:\n          type: integer\n          default: 1500\n          example: 1500\n        bridge:\n          type: string\n          default: phenix\n        driver:\n          type: string\n          example: e1000\n        qinq:\n          type: boolean\n          default: false\n        link:\n          $ref: '#/components/schemas/iface_link'\n    iface_link:\n      type: object\n      properties:\n        bandwidth:\n          type: string\n          example: 1mbit\n          pattern: '^\\d+(\\.\\d+)?([kKmMgGtT]?(bit|bps))$'\n        delay:\n          type: string\n          example: 250ms\n        jitter:\n          type: string\n          example: 10ms\n        loss:\n          type: number\n          minimum: 0\n          maximum: 100\n          example: 2\n        corruption:\n          type: number\n          minimum: 0\n          maximum: 100\n          example: 0.1\n        reordering:\n          type: number\n          minimum: 0\n          maximum: 100\n          example: 25\n    iface_address:\n      type: object\n      required:\n      - address\n      - mask\n      properties:\n        address:\n          type: string\n          format: ipv4\n          minLength: 7\n          example: 192.168.1.100\n        mask:\n          type: integer\n          minimum: 0\n          maximum: 32\n          default: 24\n          example: 24\n        gateway:\n          type: string\n          format: ipv4\n          minLength: 7\n          example: 192.168.1.1\n        dns:\n          nullable: true\n          oneOf:\n          - type: string\n          - type: array\n            items:\n              type: string\n          example:\n          - 192.168.1.1\n          - 192.168.1.2\n    iface_rulesets:\n      type: object\n      properties:\n        ruleset_out:\n          type: string\n          example: OutToInet\n          pattern: '^[\\w-]+$'\n        ruleset_in:\n          type: string\n          example: InFromInet\n          pattern: '^[\\w-]+$'\n    static_iface:\n      allOf:\n      - $ref: '#/components/schemas/iface'\n      - $ref: '#/components/schemas/iface_address'\n      - $ref: '#/components/schemas/iface_rulesets'\n      required:\n      - type\n      - proto\n      properties:\n        type:\n          type: string\n          enum:\n          - ethernet\n          default: ethernet\n          example: ethernet\n        proto:\n          type: string\n          enum:\n          - static\n          - ospf\n          default: static\n          example: static\n    dhcp_iface:\n      allOf:\n      - $ref: '#/components/schemas/iface'\n      - $ref: '#/components/schemas/iface_rulesets'\n      required:\n      - type\n      - proto\n      properties:\n        type:\n          type: string\n          enum:\n          - ethernet\n          default: ethernet\n          example: ethernet\n        proto:\n          type: string\n          enum:\n          - dhcp\n          - manual\n          default: dhcp\n          example: dhcp\n    serial_iface:\n      allOf:\n      - $ref: '#/components/schemas/iface'\n      - $ref: '#/components/schemas/iface_address'\n      - $ref: '#/components/schemas/iface_rulesets'\n      required:\n      - type\n      - proto\n      - udp_port\n      - baud_rate\n      - device\n      properties:\n        type:\n          type: string\n          enum:\n          - serial\n          default: serial\n          example: serial\n        proto:\n          type: string\n          enum:\n          - static\n          default: static\n          example: static\n        udp_port:\n          type: integer\n          minimum: 0\n          maximum: 65535\n          default: 8989\n          example: 8989\n        baud_rate:\n          type: integer\n          enum:\n          - 110\n          - 300\n          - 600\n          - 1200\n          - 2400\n          - 4800\n          - 9600\n          - 14400\n          - 19200\n          - 38400\n          - 57600\n          - 115200\n          - 128000\n          - 256000\n          default: 9600\n          example: 9600\n        device:\n          type: string\n          minLength: 1\n          default: /dev/ttyS0\n          example: /dev/ttyS0\n          pattern:\n",
 )
@@ -2,6 +2,7 @@ package v1
 
 import (
 	"fmt"
+	"sort"
 	"strings"
 
 	"github.com/hashicorp/go-multierror"
@@ -202,5 +203,106 @@ func (t *TopologySpec) Init(bridge string) error {
 		n.setDefaults(bridge)
 	}
 
+	if err := t.validateDelayDependencies(); err != nil {
+		errs = multierror.Append(errs, err)
+	}
+
 	return errs
 }
+
+// validateDelayDependencies ensures each host referenced by a node's `after`
+// delay exists in the topology and that no node ends up waiting on itself,
+// either directly or through a chain of other delayed nodes.
+func (t TopologySpec) validateDelayDependencies() error {
+	var (
+		errs  error
+		nodes = make(map[string]*Node)
+		deps  = make(map[string][]string)
+	)
+
+	for _, n := range t.NodesF {
+		nodes[n.GeneralF.HostnameF] = n
+	}
+
+	for _, n := range t.NodesF {
+		if n.DelayF == nil || len(n.DelayF.AfterF) == 0 {
+			continue
+		}
+
+		hostname := n.GeneralF.HostnameF
+
+		for _, other := range n.DelayF.AfterF {
+			dep, ok := nodes[other]
+			if !ok {
+				errs = multierror.Append(
+					errs,
+					fmt.Errorf("node %s delayed until unknown node %q is ready", hostname, other),
+				)
+
+				continue
+			}
+
+			if dep.External() {
+				errs = multierror.Append(
+					errs,
+					fmt.Errorf("node %s cannot be delayed until external node %q is ready", hostname, other),
+				)
+
+				continue
+			}
+
+			deps[hostname] = append(deps[hostname], other)
+		}
+	}
+
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+
+	state := make(map[string]int)
+
+	var visit func(string, []string) error
+
+	visit = func(host string, path []string) error {
+		switch state[host] {
+		case visiting:
+			return fmt.Errorf(
+				"circular delay dependency: %s",
+				strings.Join(append(path, host), " -> "),
+			)
+		case visited:
+			return nil
+		}
+
+		state[host] = visiting
+
+		for _, dep := range deps[host] {
+			if err := visit(dep, append(path, host)); err != nil {
+				return err
+			}
+		}
+
+		state[host] = visited
+
+		return nil
+	}
+
+	hosts := make([]string, 0, len(deps))
+	for host := range deps {
+		hosts = append(hosts, host)
+	}
+
+	sort.Strings(hosts)
+
+	for _, host := range hosts {
+		if err := visit(host, nil); err != nil {
+			errs = multierror.Append(errs, err)
+
+			break
+		}
+	}
+
+	return errs //nolint:wrapcheck // returning multierror
+}
@@ -54,3 +54,50 @@ func TestTopologyInitHostnames(t *testing.T) {
 		}
 	})
 }
+
+func TestTopologyInitDelayDependencies(t *testing.T) {
+	node := func(hostname string, after ...string) *Node {
+		n := &Node{
+			GeneralF:  &General{HostnameF: hostname},
+			HardwareF: &Hardware{},
+		}
+
+		if len(after) > 0 {
+			n.DelayF = &Delay{AfterF: after}
+		}
+
+		return n
+	}
+
+	t.Run("allows dependency chains", func(t *testing.T) {
+		topo := &TopologySpec{
+			NodesF: []*Node{node("dc"), node("member", "dc"), node("client", "member", "dc")},
+		}
+
+		if err := topo.Init("phenix"); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+	})
+
+	t.Run("rejects unknown dependencies", func(t *testing.T) {
+		topo := &TopologySpec{
+			NodesF: []*Node{node("member", "dc")},
+		}
+
+		err := topo.Init("phenix")
+		if err == nil || !strings.Contains(err.Error(), `unknown node "dc"`) {
+			t.Fatalf("expected unknown node error, got %v", err)
+		}
+	})
+
+	t.Run("rejects circular dependencies", func(t *testing.T) {
+		topo := &TopologySpec{
+			NodesF: []*Node{node("a", "b"), node("b", "c"), node("c", "a")},
+		}
+
+		err := topo.Init("phenix")
+		if err == nil || !strings.Contains(err.Error(), "circular delay dependency: a -> b -> c -> a") {
+			t.Fatalf("expected circular dependency error, got %v", err)
+		}
+	})
+}
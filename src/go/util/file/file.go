@@ -35,6 +35,14 @@ type ClusterFiles interface {
 	DeleteFile(path string) error
 }
 
+// IsPacketCapture returns true if the given file name is a PCAP file, including
+// the numbered files (e.g., foo.pcap0) written by captures using a ring buffer.
+func IsPacketCapture(name string) bool {
+	ext := filepath.Ext(name)
+
+	return strings.HasPrefix(ext, ".pcap") && strings.Trim(ext[len(".pcap"):], "0123456789") == ""
+}
+
 func GetExperimentFiles(exp, filter string) (Files, error) {
 	return DefaultClusterFiles.GetExperimentFiles(exp, filter) //nolint:wrapcheck // passthrough
 }
@@ -112,12 +120,12 @@ func (MMClusterFiles) GetExperimentFiles(exp, filter string) (Files, error) {
 				}
 			}
 
-			switch extension := filepath.Ext(name); extension {
-			case ".pcap":
+			switch extension := filepath.Ext(name); {
+			case IsPacketCapture(name):
 				file.Categories = append(file.Categories, "Packet Capture")
-			case ".elf":
+			case extension == ".elf":
 				file.Categories = append(file.Categories, "ELF Memory Snapshot")
-			case ".state":
+			case extension == ".state":
 				file.Categories = append(file.Categories, "VM Memory Snapshot")
 			}
 
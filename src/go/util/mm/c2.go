@@ -2,10 +2,13 @@ package mm
 
 import (
 	"context"
+	"encoding/base64"
+	"encoding/binary"
 	"errors"
 	"fmt"
 	"sync"
 	"time"
+	"unicode/utf16"
 
 	"phenix/util"
 )
@@ -189,3 +192,17 @@ func ScheduleC2ParallelCommand(ctx context.Context, cmd *C2ParallelCommand) {
 		}
 	}()
 }
+
+// PowerShellCommand returns a PowerShell invocation of the given script. The
+// script is passed as a base64-encoded UTF-16LE string via -EncodedCommand so
+// quotes in user-provided commands and paths don't need to be escaped.
+func PowerShellCommand(script string) string {
+	encoded := utf16.Encode([]rune(script))
+	buf := make([]byte, 2*len(encoded)) //nolint:mnd // two bytes per UTF-16 code unit
+
+	for i, r := range encoded {
+		binary.LittleEndian.PutUint16(buf[2*i:], r)
+	}
+
+	return "powershell -NoProfile -NonInteractive -EncodedCommand " + base64.StdEncoding.EncodeToString(buf)
+}
@@ -0,0 +1,16 @@
+//nolint:testpackage // testing internals
+package mm
+
+import "testing"
+
+func TestPowerShellCommand(t *testing.T) {
+	got := PowerShellCommand(`Get-Service "NTDS"`)
+
+	// Get-Service "NTDS" encoded as base64 UTF-16LE.
+	want := "powershell -NoProfile -NonInteractive -EncodedCommand " +
+		"RwBlAHQALQBTAGUAcgB2AGkAYwBlACAAIgBOAFQARABTACIA"
+
+	if got != want {
+		t.Fatalf("expected %q, got %q", want, got)
+	}
+}
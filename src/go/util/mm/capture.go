@@ -0,0 +1,309 @@
+package mm
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"phenix/util/common"
+	"phenix/util/plog"
+)
+
+const (
+	// DefaultCaptureRingFiles is the number of files kept for a capture with a
+	// max file size but no max number of files.
+	DefaultCaptureRingFiles = 5
+
+	// hostCaptureCacheTTL is how long the tcpdump captures running on a host are
+	// cached for, since getting them requires listing every process on the host.
+	hostCaptureCacheTTL = 10 * time.Second
+)
+
+var (
+	ErrInvalidCaptureLimits = errors.New("invalid capture limits")
+
+	// Characters in a BPF filter that minimega's command parser would
+	// interpret instead of passing through to tcpdump.
+	captureFilterInvalidChars = "\"'`;\\\n\r"
+)
+
+type cachedHostCaptures struct {
+	captures map[string]Capture
+	expires  time.Time
+}
+
+var (
+	hostCaptureCache   = make(map[string]cachedHostCaptures) //nolint:gochecknoglobals // global cache
+	hostCaptureCacheMu sync.Mutex                            //nolint:gochecknoglobals // global lock
+)
+
+// CaptureLimits filters and bounds a VM packet capture. Zero values are not
+// applied.
+type CaptureLimits struct {
+	// Filter is a BPF filter expression (e.g., `tcp port 502`).
+	Filter string `json:"filter,omitempty"`
+	// MaxFileSize is the size, in megabytes, at which the capture file is
+	// rotated.
+	MaxFileSize int `json:"max_file_size,omitempty"`
+	// MaxFiles is the number of rotated files to keep, overwriting the oldest
+	// file once reached. Defaults to DefaultCaptureRingFiles when MaxFileSize is
+	// set.
+	MaxFiles int `json:"max_files,omitempty"`
+	// MaxDuration is how long to capture for before stopping.
+	MaxDuration time.Duration `json:"max_duration,omitempty"`
+	// MaxPackets is the number of packets to capture before stopping.
+	MaxPackets int `json:"max_packets,omitempty"`
+}
+
+// Empty returns true if no filter or limits are configured.
+func (l CaptureLimits) Empty() bool {
+	return l == CaptureLimits{} //nolint:exhaustruct // zero value
+}
+
+// Validate returns an error if the capture limits are not valid.
+func (l CaptureLimits) Validate() error {
+	if strings.ContainsAny(l.Filter, captureFilterInvalidChars) {
+		return fmt.Errorf("%w: filter cannot contain quotes, semicolons, or backslashes", ErrInvalidCaptureLimits)
+	}
+
+	if l.MaxFileSize < 0 || l.MaxFiles < 0 || l.MaxPackets < 0 || l.MaxDuration < 0 {
+		return fmt.Errorf("%w: limits must not be negative", ErrInvalidCaptureLimits)
+	}
+
+	if l.MaxFiles > 0 && l.MaxFileSize == 0 {
+		return fmt.Errorf("%w: max files requires max file size", ErrInvalidCaptureLimits)
+	}
+
+	if l.MaxDuration > 0 && l.MaxDuration < time.Second {
+		return fmt.Errorf("%w: max duration must be at least one second", ErrInvalidCaptureLimits)
+	}
+
+	return nil
+}
+
+func (l CaptureLimits) String() string {
+	var parts []string
+
+	if l.Filter != "" {
+		parts = append(parts, "filter "+strconv.Quote(l.Filter))
+	}
+
+	if l.MaxFileSize > 0 {
+		files := l.MaxFiles
+		if files == 0 {
+			files = DefaultCaptureRingFiles
+		}
+
+		parts = append(parts, fmt.Sprintf("ring %dx%dMB", files, l.MaxFileSize))
+	}
+
+	if l.MaxDuration > 0 {
+		parts = append(parts, "duration "+l.MaxDuration.String())
+	}
+
+	if l.MaxPackets > 0 {
+		parts = append(parts, fmt.Sprintf("packets %d", l.MaxPackets))
+	}
+
+	return strings.Join(parts, " ")
+}
+
+// tcpdumpCommand returns the command to capture packets on the given tap to
+// the given file using tcpdump with the filter and limits applied. The capture
+// is wrapped with `timeout` when a max duration is set.
+func (l CaptureLimits) tcpdumpCommand(tap, path string) string {
+	args := []string{"tcpdump", "-i", tap, "-U", "-n", "-w", path}
+
+	if l.MaxFileSize > 0 {
+		files := l.MaxFiles
+		if files == 0 {
+			files = DefaultCaptureRingFiles
+		}
+
+		// tcpdump drops privileges after opening the first file, so keep root to
+		// be able to open the rotated files in the root-owned files directory.
+		args = append(args, "-C", strconv.Itoa(l.MaxFileSize), "-W", strconv.Itoa(files), "-Z", "root")
+	}
+
+	if l.MaxPackets > 0 {
+		args = append(args, "-c", strconv.Itoa(l.MaxPackets))
+	}
+
+	if l.Filter != "" {
+		args = append(args, strings.Fields(l.Filter)...)
+	}
+
+	if l.MaxDuration > 0 {
+		secs := int(l.MaxDuration.Round(time.Second).Seconds())
+		args = append([]string{"timeout", strconv.Itoa(secs) + "s"}, args...)
+	}
+
+	return strings.Join(args, " ")
+}
+
+// startHostCapture starts a tcpdump capture on the VM's tap on the cluster host
+// the VM is running on. The capture runs in the background and is tracked by
+// its process on the host.
+func startHostCapture(o options) error {
+	if err := o.capture.Validate(); err != nil {
+		return err
+	}
+
+	host, tap, err := vmTap(o.ns, o.vm, o.captureIface)
+	if err != nil {
+		return err
+	}
+
+	path := filepath.Join(common.PhenixBase, "images", o.captureFile)
+	cmd := "background " + o.capture.tcpdumpCommand(tap, path)
+
+	if err := MeshSend("", host, cmd); err != nil {
+		return fmt.Errorf("starting tcpdump on host %s: %w", host, err)
+	}
+
+	invalidateHostCaptures(host)
+
+	return nil
+}
+
+// stopHostCaptures stops the tcpdump captures running for the given captures.
+func stopHostCaptures(ns, vm string, captures []Capture) error {
+	vms := GetVMInfo(NS(ns), VMName(vm))
+	if len(vms) == 0 {
+		return fmt.Errorf("VM %s not found in namespace %s", vm, ns)
+	}
+
+	defer invalidateHostCaptures(vms[0].Host)
+
+	for _, capture := range captures {
+		if !capture.Host || capture.Interface >= len(vms[0].Taps) {
+			continue
+		}
+
+		// minimega passes shell arguments through unquoted, so use `.` in the
+		// pattern to match the spaces between tcpdump arguments.
+		pattern := fmt.Sprintf("^tcpdump.-i.%s.-U", vms[0].Taps[capture.Interface])
+
+		if err := MeshShell(vms[0].Host, "pkill -f "+pattern); err != nil {
+			return fmt.Errorf("stopping tcpdump for interface %d: %w", capture.Interface, err)
+		}
+	}
+
+	return nil
+}
+
+// hostCaptures returns the tcpdump captures running on the given cluster host,
+// keyed by tap name. Captures are cached for a short time so getting VM details
+// doesn't list the processes on every host each time.
+func hostCaptures(host string) map[string]Capture {
+	hostCaptureCacheMu.Lock()
+	defer hostCaptureCacheMu.Unlock()
+
+	if cached, ok := hostCaptureCache[host]; ok && time.Now().Before(cached.expires) {
+		return cached.captures
+	}
+
+	captures := make(map[string]Capture)
+
+	resp, err := MeshShellResponse(host, "ps -eo args=")
+	if err != nil {
+		plog.Warn(plog.TypeSystem, "unable to get processes on host", "host", host, "err", err)
+
+		return captures
+	}
+
+	for line := range strings.SplitSeq(resp, "\n") {
+		if tap, capture, ok := parseTcpdumpArgs(line); ok {
+			captures[tap] = capture
+		}
+	}
+
+	hostCaptureCache[host] = cachedHostCaptures{captures: captures, expires: time.Now().Add(hostCaptureCacheTTL)}
+
+	return captures
+}
+
+// invalidateHostCaptures removes the cached tcpdump captures for the given host
+// after captures are started or stopped on it.
+func invalidateHostCaptures(host string) {
+	hostCaptureCacheMu.Lock()
+	defer hostCaptureCacheMu.Unlock()
+
+	delete(hostCaptureCache, host)
+}
+
+// parseTcpdumpArgs parses the command line of a tcpdump process started by
+// startHostCapture, returning the tap being captured on and the capture. The
+// returned capture does not have the VM name or interface set. Only tcpdump
+// processes started the way startHostCapture starts them, writing to a PCAP
+// file in an experiment's files directory, are matched. For example:
+//
+//	tcpdump -i mega_tap1 -U -n -w /phenix/images/foo/files/bar.pcap -C 100 -W 5 tcp port 502
+func parseTcpdumpArgs(line string) (string, Capture, bool) {
+	var (
+		fields  = strings.Fields(line)
+		tap     string
+		capture = Capture{Host: true} //nolint:exhaustruct // partial initialization
+		filter  []string
+	)
+
+	// tcpdump -i <tap> -U -n -w <path>
+	if len(fields) < 7 || fields[0] != "tcpdump" || fields[1] != "-i" || //nolint:mnd // see above
+		fields[3] != "-U" || fields[4] != "-n" || fields[5] != "-w" {
+		return "", capture, false
+	}
+
+	if !isExperimentCaptureFile(fields[6]) {
+		return "", capture, false
+	}
+
+	for i := 1; i < len(fields); i++ {
+		next := func() string {
+			if i+1 < len(fields) {
+				return fields[i+1]
+			}
+
+			return ""
+		}
+
+		switch fields[i] {
+		case "-i":
+			tap = next()
+			i++
+		case "-w":
+			capture.Filepath = next()
+			i++
+		case "-C", "-W", "-Z", "-c":
+			i++
+		case "-U", "-n":
+		default:
+			filter = append(filter, fields[i])
+		}
+	}
+
+	if tap == "" || capture.Filepath == "" {
+		return "", capture, false
+	}
+
+	capture.Filter = strings.Join(filter, " ")
+
+	return tap, capture, true
+}
+
+// isExperimentCaptureFile returns true if the given path is a PCAP file in an
+// experiment's files directory (e.g., /phenix/images/foo/files/bar.pcap), which
+// is where StartCapture writes captures to.
+func isExperimentCaptureFile(path string) bool {
+	rel, err := filepath.Rel(filepath.Join(common.PhenixBase, "images"), path)
+	if err != nil {
+		return false
+	}
+
+	parts := strings.Split(rel, "/")
+
+	return len(parts) == 3 && parts[0] != ".." && parts[1] == "files" && filepath.Ext(rel) == ".pcap" //nolint:mnd // <exp>/files/<name>
+}
@@ -0,0 +1,106 @@
+//nolint:testpackage // testing internals
+package mm
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCaptureLimitsTcpdumpCommand(t *testing.T) {
+	limits := CaptureLimits{Filter: "tcp port 502", MaxFileSize: 100, MaxDuration: 90 * time.Second, MaxPackets: 1000}
+
+	cmd := limits.tcpdumpCommand("mega_tap1", "/phenix/images/foo/files/bar.pcap")
+	expected := "timeout 90s tcpdump -i mega_tap1 -U -n -w /phenix/images/foo/files/bar.pcap -C 100 -W 5 -Z root -c 1000 tcp port 502"
+
+	if cmd != expected {
+		t.Fatalf("unexpected command %q", cmd)
+	}
+
+	cmd = CaptureLimits{Filter: "udp"}.tcpdumpCommand("mega_tap2", "/tmp/baz.pcap")
+	if cmd != "tcpdump -i mega_tap2 -U -n -w /tmp/baz.pcap udp" {
+		t.Fatalf("unexpected command %q", cmd)
+	}
+}
+
+func TestCaptureLimitsTcpdumpRotation(t *testing.T) {
+	cases := []struct {
+		name   string
+		limits CaptureLimits
+		args   string
+	}{
+		{"no rotation", CaptureLimits{MaxFiles: 3}, ""},
+		{"default ring", CaptureLimits{MaxFileSize: 10}, " -C 10 -W 5 -Z root"},
+		{"ring size", CaptureLimits{MaxFileSize: 10, MaxFiles: 3}, " -C 10 -W 3 -Z root"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			cmd := c.limits.tcpdumpCommand("mega_tap1", "/tmp/bar.pcap")
+
+			if expected := "tcpdump -i mega_tap1 -U -n -w /tmp/bar.pcap" + c.args; cmd != expected {
+				t.Fatalf("expected %q, got %q", expected, cmd)
+			}
+		})
+	}
+}
+
+func TestParseTcpdumpArgs(t *testing.T) {
+	limits := CaptureLimits{Filter: "tcp port 502", MaxFileSize: 10, MaxFiles: 3, MaxPackets: 5}
+
+	// The `timeout` wrapper isn't included since only tcpdump itself is matched.
+	tap, capture, ok := parseTcpdumpArgs(limits.tcpdumpCommand("mega_tap1", "/phenix/images/foo/files/bar.pcap"))
+	if !ok {
+		t.Fatal("expected tcpdump command to be parsed")
+	}
+
+	if tap != "mega_tap1" || capture.Filepath != "/phenix/images/foo/files/bar.pcap" || capture.Filter != "tcp port 502" ||
+		!capture.Host {
+		t.Fatalf("unexpected tap %s and capture %+v", tap, capture)
+	}
+
+	for _, line := range []string{
+		"timeout 60s tcpdump -i mega_tap1 -U -n -w /tmp/bar.pcap",
+		"tcpdump -w /tmp/bar.pcap",
+		"tcpdump -i mega_tap1 -U -n",
+		"tcpdump -i mega_tap1 -U -n -w /tmp/bar.pcap",
+		"tcpdump -i eth0 -w /phenix/images/foo/files/bar.pcap",
+		"tcpdump -i mega_tap1 -U -n -w /phenix/images/foo/files/bar.log",
+		"tcpdump -i mega_tap1 -U -n -w /phenix/images/foo/bar.pcap",
+		"tcpdump -i mega_tap1 -U -n -w /phenix/images/../files/bar.pcap",
+		"/usr/bin/minimega -nostdin",
+		"",
+	} {
+		if _, _, ok := parseTcpdumpArgs(line); ok {
+			t.Fatalf("expected line %q to be ignored", line)
+		}
+	}
+}
+
+func TestCaptureLimitsValidate(t *testing.T) {
+	valid := []CaptureLimits{
+		{},
+		{Filter: "tcp and (port 80 or port 443)"},
+		{MaxFileSize: 100, MaxFiles: 10, MaxDuration: time.Hour, MaxPackets: 100},
+	}
+
+	for _, limits := range valid {
+		if err := limits.Validate(); err != nil {
+			t.Fatalf("expected limits %+v to be valid: %v", limits, err)
+		}
+	}
+
+	invalid := []CaptureLimits{
+		{Filter: "tcp; rm -rf /"},
+		{Filter: `host "foo"`},
+		{MaxFiles: 10},
+		{MaxPackets: -1},
+		{MaxDuration: time.Millisecond},
+	}
+
+	for _, limits := range invalid {
+		if err := limits.Validate(); !errors.Is(err, ErrInvalidCaptureLimits) {
+			t.Fatalf("expected limits %+v to be invalid, got %v", limits, err)
+		}
+	}
+}
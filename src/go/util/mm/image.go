@@ -0,0 +1,44 @@
+package mm
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// ImageBackingChain returns the paths of the disk image at the given path on
+// the given cluster host (the headnode if empty) and the images it's backed
+// by, starting with the image itself and ending with its base image.
+func ImageBackingChain(host, path string) ([]string, error) {
+	// Share locks so images in use by running VMs can still be inspected.
+	resp, err := MeshShellResponse(host, fmt.Sprintf("qemu-img info -U --backing-chain %s --output json", path))
+	if err != nil {
+		return nil, fmt.Errorf("getting image info for %s: %w", path, err)
+	}
+
+	chain, err := parseBackingChain(resp)
+	if err != nil {
+		return nil, fmt.Errorf("parsing image info for %s: %w", path, err)
+	}
+
+	return chain, nil
+}
+
+// parseBackingChain parses the JSON output of `qemu-img info --backing-chain`
+// into the paths of the images in the chain.
+func parseBackingChain(resp string) ([]string, error) {
+	var chain []struct {
+		Filename string `json:"filename"`
+	}
+
+	if err := json.Unmarshal([]byte(resp), &chain); err != nil {
+		return nil, err //nolint:wrapcheck // wrapped by caller
+	}
+
+	files := make([]string, 0, len(chain))
+
+	for _, c := range chain {
+		files = append(files, c.Filename)
+	}
+
+	return files, nil
+}
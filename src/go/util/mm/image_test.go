@@ -0,0 +1,29 @@
+//nolint:testpackage // testing internals
+package mm
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestParseBackingChain(t *testing.T) {
+	resp := `[
+		{"filename": "/phenix/images/foo/files/vm_snapshot.qc2", "backing-filename": "/phenix/images/base.qc2"},
+		{"filename": "/phenix/images/base.qc2"}
+	]`
+
+	chain, err := parseBackingChain(resp)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := []string{"/phenix/images/foo/files/vm_snapshot.qc2", "/phenix/images/base.qc2"}
+
+	if !slices.Equal(chain, want) {
+		t.Fatalf("expected %v, got %v", want, chain)
+	}
+
+	if _, err := parseBackingChain("qemu-img: Could not open"); err == nil {
+		t.Fatal("expected error parsing non-JSON output")
+	}
+}
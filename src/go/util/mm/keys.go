@@ -0,0 +1,237 @@
+package mm
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"phenix/util/mm/mmcli"
+)
+
+// DefaultKeyDelay is the default delay between key chords sent to a VM.
+const DefaultKeyDelay = 50 * time.Millisecond
+
+var (
+	ErrInvalidKeys = errors.New("invalid keys")
+
+	// qcodes are the QEMU key codes supported when sending keys to a VM.
+	qcodes = map[string]struct{}{} //nolint:gochecknoglobals // populated in init
+
+	// keyAliases maps common key names to QEMU key codes.
+	keyAliases = map[string]string{ //nolint:gochecknoglobals // lookup table
+		"control":   "ctrl",
+		"ctl":       "ctrl",
+		"del":       "delete",
+		"enter":     "ret",
+		"return":    "ret",
+		"space":     "spc",
+		"escape":    "esc",
+		"win":       "meta_l",
+		"super":     "meta_l",
+		"meta":      "meta_l",
+		"bksp":      "backspace",
+		"pageup":    "pgup",
+		"pagedown":  "pgdn",
+		"ins":       "insert",
+		"prtsc":     "print",
+		"capslock":  "caps_lock",
+		"altgr":     "alt_r",
+		"ctrl_l":    "ctrl",
+		"alt_l":     "alt",
+		"shift_l":   "shift",
+		"backtick":  "grave_accent",
+		"period":    "dot",
+		"backquote": "grave_accent",
+	}
+
+	// textKeys maps printable ASCII characters, other than letters and digits,
+	// to the key codes used to type them on a US keyboard layout.
+	textKeys = map[rune][]string{ //nolint:gochecknoglobals // lookup table
+		' ':  {"spc"},
+		'\n': {"ret"},
+		'\t': {"tab"},
+		'-':  {"minus"},
+		'=':  {"equal"},
+		'[':  {"bracket_left"},
+		']':  {"bracket_right"},
+		';':  {"semicolon"},
+		'\'': {"apostrophe"},
+		'`':  {"grave_accent"},
+		'\\': {"backslash"},
+		',':  {"comma"},
+		'.':  {"dot"},
+		'/':  {"slash"},
+		'!':  {"shift", "1"},
+		'@':  {"shift", "2"},
+		'#':  {"shift", "3"},
+		'$':  {"shift", "4"},
+		'%':  {"shift", "5"},
+		'^':  {"shift", "6"},
+		'&':  {"shift", "7"},
+		'*':  {"shift", "8"},
+		'(':  {"shift", "9"},
+		')':  {"shift", "0"},
+		'_':  {"shift", "minus"},
+		'+':  {"shift", "equal"},
+		'{':  {"shift", "bracket_left"},
+		'}':  {"shift", "bracket_right"},
+		':':  {"shift", "semicolon"},
+		'"':  {"shift", "apostrophe"},
+		'~':  {"shift", "grave_accent"},
+		'|':  {"shift", "backslash"},
+		'<':  {"shift", "comma"},
+		'>':  {"shift", "dot"},
+		'?':  {"shift", "slash"},
+	}
+)
+
+func init() { //nolint:gochecknoinits // populate key codes
+	codes := []string{
+		"shift", "shift_r", "alt", "alt_r", "ctrl", "ctrl_r", "meta_l", "meta_r", "menu",
+		"esc", "tab", "ret", "spc", "backspace", "caps_lock", "num_lock", "scroll_lock",
+		"minus", "equal", "bracket_left", "bracket_right", "semicolon", "apostrophe",
+		"grave_accent", "backslash", "comma", "dot", "slash", "less",
+		"home", "end", "pgup", "pgdn", "up", "down", "left", "right", "insert", "delete",
+		"print", "sysrq", "pause",
+		"kp_0", "kp_1", "kp_2", "kp_3", "kp_4", "kp_5", "kp_6", "kp_7", "kp_8", "kp_9",
+		"kp_add", "kp_subtract", "kp_multiply", "kp_divide", "kp_decimal", "kp_enter",
+	}
+
+	for _, code := range codes {
+		qcodes[code] = struct{}{}
+	}
+
+	for c := 'a'; c <= 'z'; c++ {
+		qcodes[string(c)] = struct{}{}
+	}
+
+	for c := '0'; c <= '9'; c++ {
+		qcodes[string(c)] = struct{}{}
+	}
+
+	for i := 1; i <= 12; i++ {
+		qcodes[fmt.Sprintf("f%d", i)] = struct{}{}
+	}
+}
+
+// ParseKeyChords parses the given space-separated key chords, where each chord
+// is one or more keys joined by '+' or '-' and pressed together (e.g.,
+// `ctrl-alt-delete tab ret`). Keys are QEMU key codes (e.g., `ret`, `f2`,
+// `kp_1`) or common aliases for them (e.g., `enter`, `del`, `esc`).
+func ParseKeyChords(keys string) ([][]string, error) {
+	var chords [][]string
+
+	for _, field := range strings.Fields(keys) {
+		var chord []string
+
+		for key := range strings.FieldsFuncSeq(strings.ToLower(field), func(r rune) bool { return r == '+' || r == '-' }) {
+			if alias, ok := keyAliases[key]; ok {
+				key = alias
+			}
+
+			if _, ok := qcodes[key]; !ok {
+				return nil, fmt.Errorf("%w: unknown key '%s' in '%s'", ErrInvalidKeys, key, field)
+			}
+
+			chord = append(chord, key)
+		}
+
+		if len(chord) == 0 {
+			return nil, fmt.Errorf("%w: empty key chord '%s'", ErrInvalidKeys, field)
+		}
+
+		chords = append(chords, chord)
+	}
+
+	return chords, nil
+}
+
+// TextKeyChords returns the key chords needed to type the given text on a US
+// keyboard layout. Only printable ASCII characters, spaces, tabs, and newlines
+// are supported.
+func TextKeyChords(text string) ([][]string, error) {
+	var chords [][]string
+
+	for _, r := range text {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9':
+			chords = append(chords, []string{string(r)})
+		case r >= 'A' && r <= 'Z':
+			chords = append(chords, []string{"shift", strings.ToLower(string(r))})
+		default:
+			chord, ok := textKeys[r]
+			if !ok {
+				return nil, fmt.Errorf("%w: unsupported character %q", ErrInvalidKeys, r)
+			}
+
+			chords = append(chords, chord)
+		}
+	}
+
+	return chords, nil
+}
+
+// SendVMKeys sends the given key chords to the given VM in the given namespace
+// using QMP, waiting the given delay between chords. The keys in each chord are
+// pressed together and then released. Keys are sent as hardware key presses,
+// so no guest agent is needed, but container VMs are not supported.
+func SendVMKeys(ns, vm string, chords [][]string, delay time.Duration) error {
+	if ns == "" {
+		return errors.New("no namespace provided")
+	}
+
+	if vm == "" {
+		return errors.New("no VM name provided")
+	}
+
+	cmd := mmcli.NewNamespacedCommand(ns)
+
+	for i, chord := range chords {
+		if i > 0 && delay > 0 {
+			time.Sleep(delay)
+		}
+
+		cmd.Command = fmt.Sprintf("vm qmp %s '%s'", vm, sendKeyQMP(chord))
+
+		resp, err := mmcli.SingleResponse(mmcli.Run(cmd))
+		if err != nil {
+			return fmt.Errorf("sending keys %s to VM %s: %w", strings.Join(chord, "-"), vm, err)
+		}
+
+		var result struct {
+			Error *struct {
+				Desc string `json:"desc"`
+			} `json:"error"`
+		}
+
+		if json.Unmarshal([]byte(resp), &result) == nil && result.Error != nil {
+			return fmt.Errorf("sending keys %s to VM %s: %s", strings.Join(chord, "-"), vm, result.Error.Desc)
+		}
+	}
+
+	return nil
+}
+
+// sendKeyQMP returns the QMP `send-key` command for the given key chord. Key
+// codes never include quotes, so the command is safe to single quote.
+func sendKeyQMP(chord []string) string {
+	type key struct {
+		Type string `json:"type"`
+		Data string `json:"data"`
+	}
+
+	keys := make([]key, len(chord))
+
+	for i, k := range chord {
+		keys[i] = key{Type: "qcode", Data: k}
+	}
+
+	body, _ := json.Marshal(map[string]any{
+		"execute":   "send-key",
+		"arguments": map[string]any{"keys": keys},
+	})
+
+	return string(body)
+}
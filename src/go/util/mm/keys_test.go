@@ -0,0 +1,54 @@
+//nolint:testpackage // testing internals
+package mm
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestParseKeyChords(t *testing.T) {
+	chords, err := ParseKeyChords("Ctrl-Alt-Del  f2 shift+tab enter")
+	if err != nil {
+		t.Fatalf("parsing key chords: %v", err)
+	}
+
+	expected := [][]string{{"ctrl", "alt", "delete"}, {"f2"}, {"shift", "tab"}, {"ret"}}
+
+	if !reflect.DeepEqual(chords, expected) {
+		t.Fatalf("expected %v, got %v", expected, chords)
+	}
+
+	for _, keys := range []string{"ctrl-foo", "--"} {
+		if _, err := ParseKeyChords(keys); !errors.Is(err, ErrInvalidKeys) {
+			t.Errorf("expected invalid keys error for %q, got %v", keys, err)
+		}
+	}
+}
+
+func TestTextKeyChords(t *testing.T) {
+	chords, err := TextKeyChords("aB1 !\n")
+	if err != nil {
+		t.Fatalf("converting text: %v", err)
+	}
+
+	expected := [][]string{{"a"}, {"shift", "b"}, {"1"}, {"spc"}, {"shift", "1"}, {"ret"}}
+
+	if !reflect.DeepEqual(chords, expected) {
+		t.Fatalf("expected %v, got %v", expected, chords)
+	}
+
+	if _, err := TextKeyChords("é"); !errors.Is(err, ErrInvalidKeys) {
+		t.Fatalf("expected invalid keys error, got %v", err)
+	}
+}
+
+func TestSendKeyQMP(t *testing.T) {
+	qmp := sendKeyQMP([]string{"ctrl", "alt", "delete"})
+	expected := `{"arguments":{"keys":[{"type":"qcode","data":"ctrl"},{"type":"qcode","data":"alt"},` +
+		`{"type":"qcode","data":"delete"}]},"execute":"send-key"}`
+
+	if qmp != expected {
+		t.Fatalf("unexpected QMP command %s", qmp)
+	}
+}
@@ -0,0 +1,163 @@
+package mm
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/sync/errgroup"
+
+	"phenix/util/mm/mmcli"
+	"phenix/util/plog"
+)
+
+// blockStatsWorkers is the maximum number of VMs queried for block device
+// statistics via QMP concurrently.
+const blockStatsWorkers = 8
+
+// VMMetrics is a sample of the resources used by a VM.
+type VMMetrics struct {
+	VM        string    `json:"vm"`
+	Host      string    `json:"host"`
+	Timestamp time.Time `json:"timestamp"`
+
+	// CPU is the percentage of a host CPU used by the VM's process, so it can be
+	// greater than 100 for VMs with multiple vCPUs. VCPU is the percentage used
+	// by the VM's vCPUs only.
+	CPU  float64 `json:"cpu"`
+	VCPU float64 `json:"vcpu"`
+
+	// Memory is the resident memory of the VM's process in megabytes.
+	Memory float64 `json:"memory"`
+
+	// Disk counters are totals for all the VM's disks since the VM was started.
+	DiskReadBytes  int64 `json:"diskReadBytes"`
+	DiskWriteBytes int64 `json:"diskWriteBytes"`
+	DiskReadOps    int64 `json:"diskReadOps"`
+	DiskWriteOps   int64 `json:"diskWriteOps"`
+
+	// Network rates are totals for all the VM's interfaces in megabytes per
+	// second.
+	NetworkRx float64 `json:"networkRx"`
+	NetworkTx float64 `json:"networkTx"`
+
+	// Error is set if the VM's disk usage couldn't be determined, in which case
+	// the disk counters are zero.
+	Error string `json:"error,omitempty"`
+}
+
+// blockStatsResponse is the response to a QMP `query-blockstats` command.
+type blockStatsResponse struct {
+	Return []struct {
+		Device string `json:"device"`
+		Stats  struct {
+			ReadBytes  int64 `json:"rd_bytes"`
+			WriteBytes int64 `json:"wr_bytes"`
+			ReadOps    int64 `json:"rd_operations"`
+			WriteOps   int64 `json:"wr_operations"`
+		} `json:"stats"`
+	} `json:"return"`
+}
+
+// GetVMMetrics returns the current resource usage of each running VM in the
+// given namespace. CPU, memory, and network usage come from minimega's `vm
+// top`, which samples usage over a short period, and disk usage comes from
+// QEMU's block device statistics. Errors getting the disk usage of a VM are
+// recorded in the VM's metrics rather than failing the entire sample.
+func GetVMMetrics(ns string) ([]VMMetrics, error) {
+	if ns == "" {
+		return nil, errors.New("no namespace provided")
+	}
+
+	cmd := mmcli.NewNamespacedCommand(ns)
+	cmd.Command = "vm top"
+
+	var (
+		now     = time.Now().UTC()
+		metrics []VMMetrics
+	)
+
+	for _, row := range mmcli.RunTabular(cmd) {
+		m := VMMetrics{ //nolint:exhaustruct // partial initialization
+			VM:        row["name"],
+			Host:      row["host"],
+			Timestamp: now,
+			CPU:       parseTopFloat(row["cpu"]),
+			VCPU:      parseTopFloat(row["vcpu"]),
+			Memory:    parseTopFloat(row["res"]),
+			NetworkRx: parseTopFloat(row["rx"]),
+			NetworkTx: parseTopFloat(row["tx"]),
+		}
+
+		metrics = append(metrics, m)
+	}
+
+	var g errgroup.Group
+
+	g.SetLimit(blockStatsWorkers)
+
+	for i := range metrics {
+		g.Go(func() error {
+			m := &metrics[i]
+
+			if err := addBlockStats(ns, m); err != nil {
+				plog.Warn(plog.TypeSystem, "unable to get VM disk usage", "ns", ns, "vm", m.VM, "err", err)
+
+				m.Error = err.Error()
+			}
+
+			return nil
+		})
+	}
+
+	_ = g.Wait()
+
+	return metrics, nil
+}
+
+func addBlockStats(ns string, m *VMMetrics) error {
+	cmd := mmcli.NewNamespacedCommand(ns)
+	cmd.Command = fmt.Sprintf(`vm qmp %s '{ "execute": "query-blockstats" }'`, m.VM)
+
+	resp, err := mmcli.SingleResponse(mmcli.Run(cmd))
+	if err != nil {
+		// Container VMs don't support QMP, so they won't have disk stats.
+		return nil //nolint:nilerr // disk stats are optional
+	}
+
+	return parseBlockStats(resp, m)
+}
+
+// parseBlockStats adds the totals of the block device statistics in the given
+// QMP response to the given metrics.
+func parseBlockStats(resp string, m *VMMetrics) error {
+	var stats blockStatsResponse
+
+	if err := json.Unmarshal([]byte(resp), &stats); err != nil {
+		return fmt.Errorf("parsing block stats for VM %s: %w", m.VM, err)
+	}
+
+	for _, dev := range stats.Return {
+		m.DiskReadBytes += dev.Stats.ReadBytes
+		m.DiskWriteBytes += dev.Stats.WriteBytes
+		m.DiskReadOps += dev.Stats.ReadOps
+		m.DiskWriteOps += dev.Stats.WriteOps
+	}
+
+	return nil
+}
+
+// parseTopFloat parses a value from `vm top`, ignoring any trailing units or
+// percent sign. Values that can't be parsed (e.g., rates for container VMs)
+// are returned as zero.
+func parseTopFloat(s string) float64 {
+	s = strings.TrimSpace(s)
+	s = strings.TrimRight(s, "%BKMGbkmg/s")
+
+	v, _ := strconv.ParseFloat(s, 64)
+
+	return v
+}
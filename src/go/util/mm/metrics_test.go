@@ -0,0 +1,41 @@
+//nolint:testpackage // testing internals
+package mm
+
+import "testing"
+
+func TestParseTopFloat(t *testing.T) {
+	tests := map[string]float64{
+		"12.5":   12.5,
+		" 3.0% ": 3,
+		"0.42":   0.42,
+		"N/A":    0,
+		"":       0,
+	}
+
+	for in, expected := range tests {
+		if got := parseTopFloat(in); got != expected {
+			t.Errorf("parseTopFloat(%q) = %v, expected %v", in, got, expected)
+		}
+	}
+}
+
+func TestParseBlockStats(t *testing.T) {
+	resp := `{"return": [
+		{"device": "drive-virtio-disk0", "stats": {"rd_bytes": 1024, "wr_bytes": 2048, "rd_operations": 4, "wr_operations": 8}},
+		{"device": "drive-virtio-disk1", "stats": {"rd_bytes": 1, "wr_bytes": 2, "rd_operations": 3, "wr_operations": 4}}
+	]}`
+
+	var m VMMetrics
+
+	if err := parseBlockStats(resp, &m); err != nil {
+		t.Fatal(err)
+	}
+
+	if m.DiskReadBytes != 1025 || m.DiskWriteBytes != 2050 || m.DiskReadOps != 7 || m.DiskWriteOps != 12 {
+		t.Fatalf("unexpected disk totals %+v", m)
+	}
+
+	if err := parseBlockStats("not json", &m); err == nil {
+		t.Fatal("expected error parsing invalid block stats")
+	}
+}
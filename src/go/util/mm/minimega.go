@@ -10,6 +10,7 @@ import (
 	"os"
 	"path/filepath"
 	"regexp"
+	"slices"
 	"strconv"
 	"strings"
 	"sync"
@@ -173,9 +174,16 @@ func (m Minimega) GetVMInfo(opts ...Option) VMs { //nolint:funlen // complex log
 		cmd.Filters = []string{"name=" + o.vm}
 	}
 
-	var vms VMs
+	var (
+		vms  VMs
+		rows = mmcli.RunTabular(cmd)
 
-	for _, row := range mmcli.RunTabular(cmd) {
+		// Host captures are determined from the VM details already retrieved
+		// rather than via `GetExperimentCaptures`, which gets them again.
+		captures = append(getMinimegaCaptures(o.ns), hostCapturesForVMs(rows)...)
+	)
+
+	for _, row := range rows {
 		vm := VM{ //nolint:exhaustruct // partial initialization
 			UUID:     row["uuid"],
 			Host:     row["host"],
@@ -217,9 +225,13 @@ func (m Minimega) GetVMInfo(opts ...Option) VMs { //nolint:funlen // complex log
 		_ = json.Unmarshal([]byte(s), &tags)
 		vm.Tags = tags
 
-		// Make sure the VM name is set prior to calling `GetVMCaptures`, as the VM
-		// name is not always set when calling `GetVMInfo`.
-		vm.Captures = m.GetVMCaptures(NS(o.ns), VMName(vm.Name))
+		// Use the VM name from the row, as the VM name is not always set when
+		// calling `GetVMInfo`.
+		for _, capture := range captures {
+			if capture.VM == vm.Name {
+				vm.Captures = append(vm.Captures, capture)
+			}
+		}
 
 		uptime, err := time.ParseDuration(row["uptime"])
 		if err == nil {
@@ -730,6 +742,10 @@ func (Minimega) StartVMCapture(opts ...Option) error {
 		}
 	}
 
+	if err := o.capture.Validate(); err != nil {
+		return err
+	}
+
 	if filepath.IsAbs(o.captureFile) {
 		return errors.New("path for capture file should not be absolute")
 	}
@@ -753,6 +769,21 @@ func (Minimega) StartVMCapture(opts ...Option) error {
 		return fmt.Errorf("ensuring experiment files directory exists: %w", err)
 	}
 
+	// minimega captures can't be filtered or bounded, so use tcpdump instead.
+	if !o.capture.Empty() {
+		if err := startHostCapture(o); err != nil {
+			return fmt.Errorf(
+				"starting VM capture for interface %d on VM %s in namespace %s: %w",
+				o.captureIface,
+				o.vm,
+				o.ns,
+				err,
+			)
+		}
+
+		return nil
+	}
+
 	cmd = mmcli.NewNamespacedCommand(o.ns)
 	cmd.Command = fmt.Sprintf("capture pcap vm %s %d %s", o.vm, o.captureIface, o.captureFile)
 
@@ -778,6 +809,14 @@ func (Minimega) StopVMCapture(opts ...Option) error {
 
 	o := NewOptions(opts...)
 
+	if err := stopHostCaptures(o.ns, o.vm, captures); err != nil {
+		return fmt.Errorf("stopping host captures for VM %s in namespace %s: %w", o.vm, o.ns, err)
+	}
+
+	if !slices.ContainsFunc(captures, func(c Capture) bool { return !c.Host }) {
+		return nil
+	}
+
 	cmd := mmcli.NewNamespacedCommand(o.ns)
 	cmd.Command = "capture pcap delete vm " + o.vm
 
@@ -792,7 +831,13 @@ func (Minimega) StopVMCapture(opts ...Option) error {
 func (Minimega) GetExperimentCaptures(opts ...Option) []Capture {
 	o := NewOptions(opts...)
 
-	cmd := mmcli.NewNamespacedCommand(o.ns)
+	return append(getMinimegaCaptures(o.ns), getHostCaptures(o.ns)...)
+}
+
+// getMinimegaCaptures returns the captures minimega is running for VMs in the
+// given namespace.
+func getMinimegaCaptures(ns string) []Capture {
+	cmd := mmcli.NewNamespacedCommand(ns)
 	cmd.Command = "capture"
 	cmd.Columns = []string{"interface", "path"}
 
@@ -832,6 +877,55 @@ func (Minimega) GetExperimentCaptures(opts ...Option) []Capture {
 	return captures
 }
 
+// getHostCaptures returns the tcpdump captures running for VMs in the given
+// namespace. It doesn't use `GetVMInfo` since `GetVMInfo` includes captures.
+func getHostCaptures(ns string) []Capture {
+	cmd := mmcli.NewNamespacedCommand(ns)
+	cmd.Command = vmInfoCmd
+	cmd.Columns = []string{"name", "host", "state", "tap"}
+
+	return hostCapturesForVMs(mmcli.RunTabular(cmd))
+}
+
+// hostCapturesForVMs returns the tcpdump captures running for the VMs in the
+// given `vm info` rows, which must include the name, host, state, and tap
+// columns. Only the hosts running VMs are checked for captures.
+func hostCapturesForVMs(rows []map[string]string) []Capture {
+	var (
+		byHost   = make(map[string]map[string]Capture)
+		captures []Capture
+	)
+
+	for _, row := range rows {
+		if row["state"] != "RUNNING" {
+			continue
+		}
+
+		running, ok := byHost[row["host"]]
+		if !ok {
+			running = hostCaptures(row["host"])
+			byHost[row["host"]] = running
+		}
+
+		if len(running) == 0 {
+			continue
+		}
+
+		taps := strings.TrimSuffix(strings.TrimPrefix(row["tap"], "["), "]")
+
+		for idx, tap := range strings.Split(taps, ", ") {
+			if capture, ok := running[tap]; ok {
+				capture.VM = row["name"]
+				capture.Interface = idx
+
+				captures = append(captures, capture)
+			}
+		}
+	}
+
+	return captures
+}
+
 func (m Minimega) GetVMCaptures(opts ...Option) []Capture {
 	o := NewOptions(opts...)
 
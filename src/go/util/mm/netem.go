@@ -0,0 +1,347 @@
+package mm
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInvalidImpairment = errors.New("invalid link impairment")
+
+	netemRateRegex = regexp.MustCompile(`^(?i)\d+(\.\d+)?([kmgt]?(bit|bps))$`)
+)
+
+// Impairment is network impairment (emulated by netem) applied to traffic sent
+// to a VM interface from its tap. Zero values are not applied.
+type Impairment struct {
+	Delay      time.Duration `json:"delay,omitempty"`
+	Jitter     time.Duration `json:"jitter,omitempty"`
+	Loss       float64       `json:"loss,omitempty"`
+	Corruption float64       `json:"corruption,omitempty"`
+	Reordering float64       `json:"reordering,omitempty"`
+	Rate       string        `json:"rate,omitempty"`
+}
+
+// Empty returns true if no impairment is configured.
+func (i Impairment) Empty() bool {
+	return i == Impairment{} //nolint:exhaustruct // zero value
+}
+
+// Validate returns an error if the impairment is not valid.
+func (i Impairment) Validate() error {
+	if i.Empty() {
+		return fmt.Errorf("%w: no delay, loss, corruption, reordering, or rate provided", ErrInvalidImpairment)
+	}
+
+	if i.Delay < 0 || i.Jitter < 0 {
+		return fmt.Errorf("%w: delay and jitter must not be negative", ErrInvalidImpairment)
+	}
+
+	if i.Jitter > 0 && i.Delay == 0 {
+		return fmt.Errorf("%w: jitter requires delay", ErrInvalidImpairment)
+	}
+
+	// netem reorders packets by sending some immediately while delaying the rest.
+	if i.Reordering > 0 && i.Delay == 0 {
+		return fmt.Errorf("%w: reordering requires delay", ErrInvalidImpairment)
+	}
+
+	for name, pct := range map[string]float64{"loss": i.Loss, "corruption": i.Corruption, "reordering": i.Reordering} {
+		if pct < 0 || pct > 100 {
+			return fmt.Errorf("%w: %s must be between 0 and 100 percent", ErrInvalidImpairment, name)
+		}
+	}
+
+	if i.Rate != "" && !netemRateRegex.MatchString(i.Rate) {
+		return fmt.Errorf("%w: invalid rate %s (e.g., 1mbit)", ErrInvalidImpairment, i.Rate)
+	}
+
+	return nil
+}
+
+func (i Impairment) String() string {
+	var parts []string
+
+	if i.Delay > 0 {
+		parts = append(parts, "delay "+i.Delay.String())
+
+		if i.Jitter > 0 {
+			parts = append(parts, "jitter "+i.Jitter.String())
+		}
+	}
+
+	if i.Loss > 0 {
+		parts = append(parts, "loss "+formatPercent(i.Loss))
+	}
+
+	if i.Corruption > 0 {
+		parts = append(parts, "corruption "+formatPercent(i.Corruption))
+	}
+
+	if i.Reordering > 0 {
+		parts = append(parts, "reordering "+formatPercent(i.Reordering))
+	}
+
+	if i.Rate != "" {
+		parts = append(parts, "rate "+i.Rate)
+	}
+
+	return strings.Join(parts, " ")
+}
+
+// netemArgs returns the arguments for the `tc qdisc ... netem` command to
+// apply the impairment.
+func (i Impairment) netemArgs() string {
+	var args []string
+
+	if i.Delay > 0 {
+		args = append(args, "delay", tcDuration(i.Delay))
+
+		if i.Jitter > 0 {
+			args = append(args, tcDuration(i.Jitter))
+		}
+	}
+
+	if i.Loss > 0 {
+		args = append(args, "loss", formatPercent(i.Loss))
+	}
+
+	if i.Corruption > 0 {
+		args = append(args, "corrupt", formatPercent(i.Corruption))
+	}
+
+	if i.Reordering > 0 {
+		args = append(args, "reorder", formatPercent(i.Reordering))
+	}
+
+	if i.Rate != "" {
+		args = append(args, "rate", strings.ToLower(i.Rate))
+	}
+
+	return strings.Join(args, " ")
+}
+
+// ParsePercent parses the given percentage, with or without a trailing percent
+// sign.
+func ParsePercent(s string) (float64, error) {
+	pct, err := strconv.ParseFloat(strings.TrimSuffix(strings.TrimSpace(s), "%"), 64)
+	if err != nil {
+		return 0, fmt.Errorf("%w: invalid percentage %s", ErrInvalidImpairment, s)
+	}
+
+	return pct, nil
+}
+
+// ImpairVMInterface applies the given impairment to the given interface of the
+// given VM in the given namespace, replacing any impairment already applied to
+// the interface. Impairment is applied to the VM's tap on the cluster host the
+// VM is running on, so it affects traffic sent to the VM via the interface.
+func ImpairVMInterface(ns, vm string, iface int, imp Impairment) error {
+	if err := imp.Validate(); err != nil {
+		return err
+	}
+
+	host, tap, err := vmTap(ns, vm, iface)
+	if err != nil {
+		return err
+	}
+
+	cmd := fmt.Sprintf("tc qdisc replace dev %s root netem %s", tap, imp.netemArgs())
+
+	if err := MeshShell(host, cmd); err != nil {
+		return fmt.Errorf("impairing interface %d for VM %s: %w", iface, vm, err)
+	}
+
+	return nil
+}
+
+// ClearVMInterfaceImpairment removes any impairment applied to the given
+// interface of the given VM in the given namespace. It returns false if the
+// interface was not impaired.
+func ClearVMInterfaceImpairment(ns, vm string, iface int) (bool, error) {
+	host, tap, err := vmTap(ns, vm, iface)
+	if err != nil {
+		return false, err
+	}
+
+	impairments, err := hostImpairments(host)
+	if err != nil {
+		return false, err
+	}
+
+	// Deleting the root qdisc fails if there isn't one, so only clear
+	// impairment that actually exists.
+	if _, ok := impairments[tap]; !ok {
+		return false, nil
+	}
+
+	if err := MeshShell(host, fmt.Sprintf("tc qdisc del dev %s root", tap)); err != nil {
+		return false, fmt.Errorf("clearing impairment for interface %d of VM %s: %w", iface, vm, err)
+	}
+
+	return true, nil
+}
+
+// GetVMImpairments returns the impairment currently applied to each interface
+// of each running VM in the given namespace, keyed by VM name and then
+// interface index. Interfaces without impairment are not included.
+func GetVMImpairments(ns string) (map[string]map[int]Impairment, error) {
+	var (
+		byHost = make(map[string]map[string]Impairment)
+		result = make(map[string]map[int]Impairment)
+	)
+
+	for _, vm := range GetVMInfo(NS(ns)) {
+		if !vm.Running {
+			continue
+		}
+
+		impairments, ok := byHost[vm.Host]
+		if !ok {
+			var err error
+
+			impairments, err = hostImpairments(vm.Host)
+			if err != nil {
+				return nil, err
+			}
+
+			byHost[vm.Host] = impairments
+		}
+
+		for idx, tap := range vm.Taps {
+			imp, ok := impairments[tap]
+			if !ok {
+				continue
+			}
+
+			if result[vm.Name] == nil {
+				result[vm.Name] = make(map[int]Impairment)
+			}
+
+			result[vm.Name][idx] = imp
+		}
+	}
+
+	return result, nil
+}
+
+func vmTap(ns, name string, iface int) (string, string, error) {
+	if ns == "" {
+		return "", "", errors.New("no namespace provided")
+	}
+
+	if name == "" {
+		return "", "", errors.New("no VM name provided")
+	}
+
+	vms := GetVMInfo(NS(ns), VMName(name))
+	if len(vms) == 0 {
+		return "", "", fmt.Errorf("VM %s not found in namespace %s", name, ns)
+	}
+
+	vm := vms[0]
+
+	if iface < 0 || iface >= len(vm.Taps) {
+		return "", "", fmt.Errorf("VM %s does not have interface %d", name, iface)
+	}
+
+	return vm.Host, vm.Taps[iface], nil
+}
+
+// hostImpairments returns the netem impairment applied to each interface on
+// the given cluster host, keyed by interface name.
+func hostImpairments(host string) (map[string]Impairment, error) {
+	resp, err := MeshShellResponse(host, "tc qdisc show")
+	if err != nil {
+		return nil, fmt.Errorf("getting qdiscs on host %s: %w", host, err)
+	}
+
+	impairments := make(map[string]Impairment)
+
+	for line := range strings.SplitSeq(resp, "\n") {
+		if dev, imp, ok := parseNetemQdisc(line); ok {
+			impairments[dev] = imp
+		}
+	}
+
+	return impairments, nil
+}
+
+// parseNetemQdisc parses a line of `tc qdisc show` output, returning the
+// device and impairment if the line is for a root netem qdisc. For example:
+//
+//	qdisc netem 8001: dev mega_tap1 root refcnt 2 limit 1000 delay 50ms  10ms loss 2% rate 1Mbit
+func parseNetemQdisc(line string) (string, Impairment, bool) {
+	var (
+		fields = strings.Fields(line)
+		dev    string
+		root   bool
+		imp    Impairment
+	)
+
+	if len(fields) < 2 || fields[0] != "qdisc" || fields[1] != "netem" {
+		return "", imp, false
+	}
+
+	for i := 2; i < len(fields); i++ {
+		next := func() string {
+			if i+1 < len(fields) {
+				return fields[i+1]
+			}
+
+			return ""
+		}
+
+		switch fields[i] {
+		case "dev":
+			dev = next()
+			i++
+		case "root":
+			root = true
+		case "delay":
+			imp.Delay, _ = time.ParseDuration(next())
+			i++
+
+			if jitter, err := time.ParseDuration(next()); err == nil {
+				imp.Jitter = jitter
+				i++
+			}
+		case "loss":
+			imp.Loss, _ = ParsePercent(next())
+			i++
+		case "corrupt":
+			imp.Corruption, _ = ParsePercent(next())
+			i++
+		case "reorder":
+			imp.Reordering, _ = ParsePercent(next())
+			i++
+		case "rate":
+			imp.Rate = strings.ToLower(next())
+			i++
+		}
+	}
+
+	if dev == "" || !root {
+		return "", imp, false
+	}
+
+	return dev, imp, true
+}
+
+// tcDuration formats the given duration for tc, which doesn't support Go's
+// compound duration format (e.g., 1m30s).
+func tcDuration(d time.Duration) string {
+	if d%time.Millisecond != 0 {
+		return strconv.FormatInt(d.Microseconds(), 10) + "us"
+	}
+
+	return strconv.FormatInt(d.Milliseconds(), 10) + "ms"
+}
+
+func formatPercent(pct float64) string {
+	return strconv.FormatFloat(pct, 'f', -1, 64) + "%"
+}
@@ -0,0 +1,93 @@
+//nolint:testpackage // testing internals
+package mm
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestParseNetemQdisc(t *testing.T) {
+	line := "qdisc netem 8001: dev mega_tap1 root refcnt 2 limit 1000 delay 50ms  10ms loss 2% rate 1Mbit"
+
+	dev, imp, ok := parseNetemQdisc(line)
+	if !ok {
+		t.Fatal("expected netem qdisc to be parsed")
+	}
+
+	expected := Impairment{Delay: 50 * time.Millisecond, Jitter: 10 * time.Millisecond, Loss: 2, Rate: "1mbit"}
+
+	if dev != "mega_tap1" || imp != expected {
+		t.Fatalf("unexpected device %s and impairment %+v", dev, imp)
+	}
+
+	_, imp, ok = parseNetemQdisc("qdisc netem 8003: dev mega_tap3 root refcnt 2 limit 1000 delay 5ms corrupt 0.1% reorder 25%")
+	if !ok || imp.Corruption != 0.1 || imp.Reordering != 25 {
+		t.Fatalf("unexpected impairment %+v", imp)
+	}
+
+	_, imp, ok = parseNetemQdisc("qdisc netem 8002: dev mega_tap2 root refcnt 2 limit 1000 delay 1.5ms")
+	if !ok || imp.Delay != 1500*time.Microsecond || imp.Jitter != 0 {
+		t.Fatalf("unexpected impairment %+v", imp)
+	}
+
+	for _, line := range []string{
+		"qdisc fq_codel 0: dev eth0 root refcnt 2 limit 10240p flows 1024",
+		"qdisc netem 10: dev mega_tap3 parent 1:1 limit 1000 delay 5ms",
+		"",
+	} {
+		if _, _, ok := parseNetemQdisc(line); ok {
+			t.Fatalf("expected line %q to be ignored", line)
+		}
+	}
+}
+
+func TestImpairmentValidate(t *testing.T) {
+	valid := []Impairment{
+		{Delay: 50 * time.Millisecond, Jitter: 10 * time.Millisecond},
+		{Loss: 2.5},
+		{Rate: "1mbit"},
+		{Rate: "512Kbps"},
+	}
+
+	for _, imp := range valid {
+		if err := imp.Validate(); err != nil {
+			t.Fatalf("expected %+v to be valid: %v", imp, err)
+		}
+	}
+
+	invalid := []Impairment{
+		{},
+		{Jitter: 10 * time.Millisecond},
+		{Loss: 101},
+		{Delay: -time.Millisecond},
+		{Rate: "fast"},
+		{Reordering: 25},
+		{Delay: time.Millisecond, Corruption: 200},
+	}
+
+	for _, imp := range invalid {
+		if err := imp.Validate(); !errors.Is(err, ErrInvalidImpairment) {
+			t.Fatalf("expected %+v to be invalid, got %v", imp, err)
+		}
+	}
+}
+
+func TestNetemArgs(t *testing.T) {
+	imp := Impairment{
+		Delay:      1500 * time.Millisecond,
+		Jitter:     250 * time.Microsecond,
+		Loss:       2,
+		Corruption: 0.5,
+		Reordering: 10,
+		Rate:       "1Mbit",
+	}
+
+	if args := imp.netemArgs(); args != "delay 1500ms 250us loss 2% corrupt 0.5% reorder 10% rate 1mbit" {
+		t.Fatalf("unexpected netem args %q", args)
+	}
+
+	if loss, err := ParsePercent("2%"); err != nil || loss != 2 {
+		t.Fatalf("unexpected loss %f: %v", loss, err)
+	}
+}
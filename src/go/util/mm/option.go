@@ -29,6 +29,7 @@ type options struct {
 
 	captureIface int
 	captureFile  string
+	capture      CaptureLimits
 
 	screenshotSize string
 
@@ -132,6 +133,15 @@ func CaptureFile(f string) Option {
 	}
 }
 
+// CaptureWithLimits filters and bounds the capture. Captures with a filter or
+// limits are run using tcpdump on the VM's cluster host since minimega captures
+// don't support them.
+func CaptureWithLimits(l CaptureLimits) Option {
+	return func(o *options) {
+		o.capture = l
+	}
+}
+
 func ScreenshotSize(s string) Option {
 	return func(o *options) {
 		o.screenshotSize = s
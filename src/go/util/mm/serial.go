@@ -0,0 +1,167 @@
+package mm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"phenix/util/common"
+	"phenix/util/mm/mmcli"
+)
+
+const (
+	// SerialBridgePortBase is added to a VM's minimega ID to get the port used
+	// to bridge its serial port to the headnode. VM IDs are unique per cluster
+	// host, so each VM on a host gets its own port.
+	SerialBridgePortBase = 46000
+
+	serialBridgeTimeout = 10 * time.Second
+	serialBridgeRetry   = 250 * time.Millisecond
+)
+
+var ErrNoSerialPort = errors.New("VM has no serial port")
+
+// DialVMSerial connects to the first serial port of the given VM in the given
+// namespace. VMs must have a serial port configured (e.g., via the
+// `serial-ports` advanced config option). QEMU only allows one connection to a
+// serial port at a time.
+//
+// Serial ports are unix sockets on the cluster host the VM is running on. For
+// VMs not running on the headnode, socat is started on the VM's host to bridge
+// the socket to a TCP port for a single connection, so socat must be installed
+// on all cluster hosts. The bridge only listens on the host's mesh address and
+// only accepts connections from the headnode.
+func DialVMSerial(ctx context.Context, ns, vm string) (net.Conn, error) {
+	cmd := mmcli.NewNamespacedCommand(ns)
+	cmd.Command = vmInfoCmd
+	cmd.Columns = []string{"host", "id", "type"}
+	cmd.Filters = []string{"name=" + vm}
+
+	rows := mmcli.RunTabular(cmd)
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("VM %s not found in namespace %s", vm, ns)
+	}
+
+	if rows[0]["type"] != "kvm" {
+		return nil, fmt.Errorf("%w: serial consoles are only supported for KVM VMs", ErrNoSerialPort)
+	}
+
+	var (
+		host = rows[0]["host"]
+		path = filepath.Join(common.MinimegaBase, rows[0]["id"], "serial0")
+	)
+
+	var dialer net.Dialer
+
+	if IsHeadnode(host) {
+		if _, err := os.Stat(path); err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrNoSerialPort, vm)
+		}
+
+		conn, err := dialer.DialContext(ctx, "unix", path)
+		if err != nil {
+			return nil, fmt.Errorf("connecting to serial port for VM %s: %w", vm, err)
+		}
+
+		return conn, nil
+	}
+
+	id, err := strconv.Atoi(rows[0]["id"])
+	if err != nil {
+		return nil, fmt.Errorf("parsing ID for VM %s: %w", vm, err)
+	}
+
+	if _, err := MeshShellResponse(host, "test -S "+path); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrNoSerialPort, vm)
+	}
+
+	port := SerialBridgePortBase + id
+
+	hostIP, headIP, err := serialBridgeAddrs(ctx, host, port)
+	if err != nil {
+		return nil, fmt.Errorf("resolving serial bridge addresses for host %s: %w", host, err)
+	}
+
+	// Only listen on the host's address the headnode reaches it on, and only
+	// accept connections from the headnode, since the bridge gives anyone that
+	// can connect to it root access to the VM's console.
+	bridge := fmt.Sprintf(
+		"background socat TCP-LISTEN:%d,reuseaddr,bind=%s,range=%s UNIX-CONNECT:%s",
+		port, socatAddr(hostIP), socatRange(headIP), path,
+	)
+
+	if err := MeshSend("", host, bridge); err != nil {
+		return nil, fmt.Errorf("starting serial bridge on host %s: %w", host, err)
+	}
+
+	addr := net.JoinHostPort(hostIP.String(), strconv.Itoa(port))
+
+	ctx, cancel := context.WithTimeout(ctx, serialBridgeTimeout)
+	defer cancel()
+
+	// Give socat a chance to start listening.
+	for {
+		conn, err := dialer.DialContext(ctx, "tcp", addr)
+		if err == nil {
+			return conn, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("connecting to serial bridge for VM %s: %w", vm, err)
+		case <-time.After(serialBridgeRetry):
+		}
+	}
+}
+
+// serialBridgeAddrs returns the address of the given cluster host and the
+// headnode's address on the same network (i.e., the source address the
+// headnode uses to reach the host).
+func serialBridgeAddrs(ctx context.Context, host string, port int) (net.IP, net.IP, error) {
+	var dialer net.Dialer
+
+	// Dialing UDP doesn't send anything, but does pick the local address routed
+	// to the host.
+	conn, err := dialer.DialContext(ctx, "udp", net.JoinHostPort(host, strconv.Itoa(port)))
+	if err != nil {
+		return nil, nil, fmt.Errorf("determining route to host: %w", err)
+	}
+
+	defer func() { _ = conn.Close() }()
+
+	var (
+		local, _  = conn.LocalAddr().(*net.UDPAddr)
+		remote, _ = conn.RemoteAddr().(*net.UDPAddr)
+	)
+
+	if local == nil || remote == nil {
+		return nil, nil, errors.New("unexpected address type")
+	}
+
+	return remote.IP, local.IP, nil
+}
+
+// socatAddr formats the given IP for socat's bind and range options, which
+// require IPv6 addresses to be bracketed.
+func socatAddr(ip net.IP) string {
+	if v4 := ip.To4(); v4 != nil {
+		return v4.String()
+	}
+
+	return "[" + ip.String() + "]"
+}
+
+// socatRange formats the given IP as a socat range option matching only that
+// address.
+func socatRange(ip net.IP) string {
+	if v4 := ip.To4(); v4 != nil {
+		return v4.String() + "/32"
+	}
+
+	return socatAddr(ip) + "/128"
+}
@@ -0,0 +1,30 @@
+//nolint:testpackage // testing internals
+package mm
+
+import (
+	"net"
+	"testing"
+)
+
+func TestSocatAddr(t *testing.T) {
+	tests := []struct {
+		ip          string
+		addr, match string
+	}{
+		{"10.0.0.1", "10.0.0.1", "10.0.0.1/32"},
+		{"::ffff:10.0.0.1", "10.0.0.1", "10.0.0.1/32"},
+		{"fd00::1", "[fd00::1]", "[fd00::1]/128"},
+	}
+
+	for _, tc := range tests {
+		ip := net.ParseIP(tc.ip)
+
+		if got := socatAddr(ip); got != tc.addr {
+			t.Errorf("socatAddr(%s): expected %q, got %q", tc.ip, tc.addr, got)
+		}
+
+		if got := socatRange(ip); got != tc.match {
+			t.Errorf("socatRange(%s): expected %q, got %q", tc.ip, tc.match, got)
+		}
+	}
+}
@@ -243,6 +243,10 @@ type VM struct {
 	Metadata    map[string]any `json:"-"`
 	Annotations map[string]any `json:"-"`
 
+	// Used internally for showing link impairment applied to interfaces, keyed
+	// by interface index.
+	Impairments map[int]string `json:"-"`
+
 	// Used internally to check for active CC agent.
 	UUID string `json:"-"`
 }
@@ -279,6 +283,11 @@ type Capture struct {
 	VM        string `json:"vm"`
 	Interface int    `json:"interface"`
 	Filepath  string `json:"filepath"`
+	Filter    string `json:"filter,omitempty"`
+
+	// Host is true if the capture is run using tcpdump on the VM's cluster host
+	// instead of by minimega.
+	Host bool `json:"host,omitempty"`
 }
 
 type BlockDevice struct {
@@ -0,0 +1,262 @@
+package pcap
+
+import (
+	"container/heap"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+const (
+	blockTypeSHB = 0x0a0d0d0a
+	blockTypeIDB = 0x00000001
+	blockTypeEPB = 0x00000006
+
+	byteOrderMagic = 0x1a2b3c4d
+
+	optEndOfOpt      = 0
+	optIfName        = 2
+	optIfDescription = 3
+	optIfTSResol     = 9
+
+	// Timestamps are written with nanosecond resolution (10^-9).
+	tsResolNanoseconds = 9
+)
+
+// Source is a capture to include in a merged capture. Each source is written
+// as its own interface in the merged capture.
+type Source struct {
+	// Name is used as the interface name (e.g., `host1:0`).
+	Name string
+	// Description is used as the interface description.
+	Description string
+	Reader      *Reader
+}
+
+// Window limits merged packets to those captured within it. Zero values are
+// not applied.
+type Window struct {
+	From time.Time
+	To   time.Time
+}
+
+func (w Window) contains(ts time.Time) bool {
+	if !w.From.IsZero() && ts.Before(w.From) {
+		return false
+	}
+
+	if !w.To.IsZero() && ts.After(w.To) {
+		return false
+	}
+
+	return true
+}
+
+// Merge writes the packets from all the given sources to w as a single PCAPNG
+// file, ordered by timestamp. It returns the number of packets written.
+func Merge(w io.Writer, sources []Source, window Window) (int, error) {
+	pw := &pcapngWriter{w: w, err: nil}
+
+	pw.block(blockTypeSHB, shbBody())
+
+	for _, src := range sources {
+		pw.block(blockTypeIDB, idbBody(src))
+	}
+
+	if pw.err != nil {
+		return 0, pw.err
+	}
+
+	var (
+		queue = make(packetQueue, 0, len(sources))
+		count int
+	)
+
+	// push adds the next packet from the given source to the queue, if there is
+	// one.
+	push := func(iface int) error {
+		pkt, ok, err := nextInWindow(sources[iface].Reader, window)
+		if err != nil {
+			return fmt.Errorf("reading %s: %w", sources[iface].Name, err)
+		}
+
+		if ok {
+			heap.Push(&queue, queued{iface: iface, pkt: pkt})
+		}
+
+		return nil
+	}
+
+	for i := range sources {
+		if err := push(i); err != nil {
+			return 0, err
+		}
+	}
+
+	for queue.Len() > 0 {
+		next := heap.Pop(&queue).(queued) //nolint:forcetypeassert // queue only holds queued packets
+
+		pw.block(blockTypeEPB, epbBody(next.iface, next.pkt))
+
+		if pw.err != nil {
+			return count, pw.err
+		}
+
+		count++
+
+		if err := push(next.iface); err != nil {
+			return count, err
+		}
+	}
+
+	return count, nil
+}
+
+type queued struct {
+	iface int
+	pkt   Packet
+}
+
+// packetQueue is a min-heap of the next packet from each source, ordered by
+// timestamp.
+type packetQueue []queued
+
+func (q packetQueue) Len() int { return len(q) }
+
+func (q packetQueue) Less(i, j int) bool {
+	if q[i].pkt.Timestamp.Equal(q[j].pkt.Timestamp) {
+		return q[i].iface < q[j].iface
+	}
+
+	return q[i].pkt.Timestamp.Before(q[j].pkt.Timestamp)
+}
+
+func (q packetQueue) Swap(i, j int) { q[i], q[j] = q[j], q[i] }
+
+func (q *packetQueue) Push(x any) { *q = append(*q, x.(queued)) } //nolint:forcetypeassert // heap interface
+
+func (q *packetQueue) Pop() any {
+	old := *q
+	item := old[len(old)-1]
+	*q = old[:len(old)-1]
+
+	return item
+}
+
+// nextInWindow returns the next packet from the given reader captured within
+// the given window. It returns false if there are no more packets within the
+// window.
+func nextInWindow(r *Reader, window Window) (Packet, bool, error) {
+	for {
+		pkt, err := r.Next()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return pkt, false, nil
+			}
+
+			return pkt, false, err
+		}
+
+		if window.contains(pkt.Timestamp) {
+			return pkt, true, nil
+		}
+
+		// Packets are written in order, so there's nothing left to include once
+		// the end of the window is passed.
+		if !window.To.IsZero() && pkt.Timestamp.After(window.To) {
+			return pkt, false, nil
+		}
+	}
+}
+
+type pcapngWriter struct {
+	w   io.Writer
+	err error
+}
+
+// block writes a PCAPNG block with the given type and body. The body must
+// already be padded to 32 bits. Any error is saved and subsequent blocks are
+// skipped.
+func (p *pcapngWriter) block(typ uint32, body []byte) {
+	if p.err != nil {
+		return
+	}
+
+	length := uint32(len(body) + 12) //nolint:gosec // blocks are bounded by max packet length
+
+	buf := make([]byte, 0, length)
+	buf = binary.LittleEndian.AppendUint32(buf, typ)
+	buf = binary.LittleEndian.AppendUint32(buf, length)
+	buf = append(buf, body...)
+	buf = binary.LittleEndian.AppendUint32(buf, length)
+
+	if _, err := p.w.Write(buf); err != nil {
+		p.err = fmt.Errorf("writing PCAPNG block: %w", err)
+	}
+}
+
+func shbBody() []byte {
+	var buf []byte
+
+	buf = binary.LittleEndian.AppendUint32(buf, byteOrderMagic)
+	buf = binary.LittleEndian.AppendUint16(buf, 1) // major version
+	buf = binary.LittleEndian.AppendUint16(buf, 0) // minor version
+
+	// Section length is unspecified.
+	return binary.LittleEndian.AppendUint64(buf, ^uint64(0))
+}
+
+func idbBody(src Source) []byte {
+	var buf []byte
+
+	buf = binary.LittleEndian.AppendUint16(buf, src.Reader.LinkType)
+	buf = binary.LittleEndian.AppendUint16(buf, 0) // reserved
+	buf = binary.LittleEndian.AppendUint32(buf, src.Reader.SnapLen)
+
+	if src.Name != "" {
+		buf = appendOption(buf, optIfName, []byte(src.Name))
+	}
+
+	if src.Description != "" {
+		buf = appendOption(buf, optIfDescription, []byte(src.Description))
+	}
+
+	buf = appendOption(buf, optIfTSResol, []byte{tsResolNanoseconds})
+
+	return appendOption(buf, optEndOfOpt, nil)
+}
+
+func epbBody(iface int, pkt Packet) []byte {
+	var (
+		buf = make([]byte, 0, 20+len(pkt.Data)+3)
+		ts  = uint64(pkt.Timestamp.UnixNano()) //nolint:gosec // captures are after the epoch
+	)
+
+	buf = binary.LittleEndian.AppendUint32(buf, uint32(iface))         //nolint:gosec // bounded by number of sources
+	buf = binary.LittleEndian.AppendUint32(buf, uint32(ts>>32))        //nolint:gosec // high 32 bits
+	buf = binary.LittleEndian.AppendUint32(buf, uint32(ts))            //nolint:gosec // low 32 bits
+	buf = binary.LittleEndian.AppendUint32(buf, uint32(len(pkt.Data))) //nolint:gosec // bounded by max packet length
+	buf = binary.LittleEndian.AppendUint32(buf, uint32(pkt.Length))    //nolint:gosec // read from 32 bit field
+	buf = append(buf, pkt.Data...)
+
+	return pad(buf)
+}
+
+func appendOption(buf []byte, code uint16, value []byte) []byte {
+	buf = binary.LittleEndian.AppendUint16(buf, code)
+	buf = binary.LittleEndian.AppendUint16(buf, uint16(len(value))) //nolint:gosec // option values are small
+	buf = append(buf, value...)
+
+	return pad(buf)
+}
+
+// pad pads the given buffer with zeros to a 32 bit boundary.
+func pad(buf []byte) []byte {
+	for len(buf)%4 != 0 {
+		buf = append(buf, 0)
+	}
+
+	return buf
+}
@@ -0,0 +1,136 @@
+package pcap_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+	"time"
+
+	"phenix/util/pcap"
+)
+
+// newPCAP returns a classic PCAP file with a packet for each of the given
+// timestamps. Each packet's data is the given prefix followed by its index.
+func newPCAP(t *testing.T, order binary.AppendByteOrder, prefix byte, timestamps ...time.Time) []byte {
+	t.Helper()
+
+	var buf []byte
+
+	buf = order.AppendUint32(buf, 0xa1b2c3d4)
+	buf = order.AppendUint16(buf, 2)
+	buf = order.AppendUint16(buf, 4)
+	buf = order.AppendUint32(buf, 0)
+	buf = order.AppendUint32(buf, 0)
+	buf = order.AppendUint32(buf, 65535)
+	buf = order.AppendUint32(buf, 1) // ethernet
+
+	for i, ts := range timestamps {
+		data := []byte{prefix, byte(i), 0xff}
+
+		buf = order.AppendUint32(buf, uint32(ts.Unix()))            //nolint:gosec // test timestamps
+		buf = order.AppendUint32(buf, uint32(ts.Nanosecond()/1000)) //nolint:gosec // test timestamps
+		buf = order.AppendUint32(buf, uint32(len(data)))            //nolint:gosec // test data
+		buf = order.AppendUint32(buf, uint32(len(data)))            //nolint:gosec // test data
+		buf = append(buf, data...)
+	}
+
+	return buf
+}
+
+type block struct {
+	typ  uint32
+	body []byte
+}
+
+func readBlocks(t *testing.T, data []byte) []block {
+	t.Helper()
+
+	var blocks []block
+
+	for len(data) > 0 {
+		if len(data) < 12 {
+			t.Fatalf("truncated block")
+		}
+
+		length := binary.LittleEndian.Uint32(data[4:8])
+
+		if binary.LittleEndian.Uint32(data[length-4:length]) != length {
+			t.Fatalf("mismatched block lengths")
+		}
+
+		blocks = append(blocks, block{typ: binary.LittleEndian.Uint32(data[0:4]), body: data[8 : length-4]})
+		data = data[length:]
+	}
+
+	return blocks
+}
+
+func TestMerge(t *testing.T) {
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	files := [][]byte{
+		newPCAP(t, binary.LittleEndian, 'a', base, base.Add(2*time.Second), base.Add(4*time.Second)),
+		newPCAP(t, binary.BigEndian, 'b', base.Add(time.Second), base.Add(3*time.Second)),
+	}
+
+	var sources []pcap.Source
+
+	for i, file := range files {
+		r, err := pcap.NewReader(bytes.NewReader(file))
+		if err != nil {
+			t.Fatalf("creating reader: %v", err)
+		}
+
+		sources = append(sources, pcap.Source{Name: string(rune('a' + i)), Description: "", Reader: r})
+	}
+
+	var out bytes.Buffer
+
+	window := pcap.Window{From: base.Add(time.Second), To: base.Add(3 * time.Second)}
+
+	count, err := pcap.Merge(&out, sources, window)
+	if err != nil {
+		t.Fatalf("merging captures: %v", err)
+	}
+
+	if count != 3 {
+		t.Fatalf("expected 3 packets, got %d", count)
+	}
+
+	blocks := readBlocks(t, out.Bytes())
+
+	if len(blocks) != 6 || blocks[0].typ != 0x0a0d0d0a || blocks[1].typ != 1 || blocks[2].typ != 1 {
+		t.Fatalf("unexpected blocks %+v", blocks)
+	}
+
+	var (
+		expected = []string{"b0", "a1", "b1"}
+		last     uint64
+	)
+
+	for i, b := range blocks[3:] {
+		if b.typ != 6 {
+			t.Fatalf("expected enhanced packet block, got %d", b.typ)
+		}
+
+		ts := uint64(binary.LittleEndian.Uint32(b.body[4:8]))<<32 | uint64(binary.LittleEndian.Uint32(b.body[8:12]))
+		if ts < last {
+			t.Fatalf("packets not in time order")
+		}
+
+		last = ts
+
+		if got := string([]byte{b.body[20], '0' + b.body[21]}); got != expected[i] {
+			t.Fatalf("expected packet %s, got %s", expected[i], got)
+		}
+	}
+}
+
+func TestNewReaderUnsupported(t *testing.T) {
+	pcapng := make([]byte, 24)
+	binary.LittleEndian.PutUint32(pcapng, 0x0a0d0d0a)
+
+	if _, err := pcap.NewReader(bytes.NewReader(pcapng)); err == nil {
+		t.Fatal("expected error for unsupported format")
+	}
+}
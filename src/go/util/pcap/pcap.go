@@ -0,0 +1,117 @@
+// Package pcap reads classic PCAP files and merges them into a single
+// time-ordered PCAPNG file.
+package pcap
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+const (
+	magicMicroseconds = 0xa1b2c3d4
+	magicNanoseconds  = 0xa1b23c4d
+
+	globalHeaderLen = 24
+	recordHeaderLen = 16
+
+	// maxPacketLen guards against allocating huge buffers for corrupt records.
+	maxPacketLen = 256 * 1024
+)
+
+var ErrUnsupportedFormat = errors.New("unsupported capture file format")
+
+// Packet is a single captured packet.
+type Packet struct {
+	Timestamp time.Time
+	Length    int // original length of the packet on the wire
+	Data      []byte
+}
+
+// Reader reads packets from a classic PCAP file.
+type Reader struct {
+	r     io.Reader
+	order binary.ByteOrder
+	nanos bool
+
+	LinkType uint16
+	SnapLen  uint32
+}
+
+// NewReader reads the PCAP global header from r and returns a Reader for the
+// packets that follow it.
+func NewReader(r io.Reader) (*Reader, error) {
+	var hdr [globalHeaderLen]byte
+
+	if _, err := io.ReadFull(r, hdr[:]); err != nil {
+		return nil, fmt.Errorf("reading PCAP header: %w", err)
+	}
+
+	reader := &Reader{r: r} //nolint:exhaustruct // set below
+
+	switch {
+	case binary.LittleEndian.Uint32(hdr[0:4]) == magicMicroseconds:
+		reader.order = binary.LittleEndian
+	case binary.LittleEndian.Uint32(hdr[0:4]) == magicNanoseconds:
+		reader.order, reader.nanos = binary.LittleEndian, true
+	case binary.BigEndian.Uint32(hdr[0:4]) == magicMicroseconds:
+		reader.order = binary.BigEndian
+	case binary.BigEndian.Uint32(hdr[0:4]) == magicNanoseconds:
+		reader.order, reader.nanos = binary.BigEndian, true
+	default:
+		return nil, ErrUnsupportedFormat
+	}
+
+	reader.SnapLen = reader.order.Uint32(hdr[16:20])
+	reader.LinkType = uint16(reader.order.Uint32(hdr[20:24])) //nolint:gosec // link types fit in 16 bits
+
+	return reader, nil
+}
+
+// Next returns the next packet, or io.EOF when there are no more packets. A
+// truncated final packet, which is common for captures that were still being
+// written to, is treated as the end of the file.
+func (r *Reader) Next() (Packet, error) {
+	var hdr [recordHeaderLen]byte
+
+	if _, err := io.ReadFull(r.r, hdr[:]); err != nil {
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			return Packet{}, io.EOF //nolint:exhaustruct // no packet
+		}
+
+		return Packet{}, err //nolint:exhaustruct,wrapcheck // no packet; io.EOF must be returned as is
+	}
+
+	var (
+		sec     = int64(r.order.Uint32(hdr[0:4]))
+		frac    = int64(r.order.Uint32(hdr[4:8]))
+		inclLen = r.order.Uint32(hdr[8:12])
+		origLen = r.order.Uint32(hdr[12:16])
+	)
+
+	if inclLen > maxPacketLen {
+		return Packet{}, fmt.Errorf("invalid packet length %d", inclLen) //nolint:exhaustruct // no packet
+	}
+
+	if !r.nanos {
+		frac *= int64(time.Microsecond)
+	}
+
+	pkt := Packet{
+		Timestamp: time.Unix(sec, frac).UTC(),
+		Length:    int(origLen),
+		Data:      make([]byte, inclLen),
+	}
+
+	if _, err := io.ReadFull(r.r, pkt.Data); err != nil {
+		if errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF) {
+			return Packet{}, io.EOF //nolint:exhaustruct // no packet
+		}
+
+		return Packet{}, fmt.Errorf("reading packet: %w", err) //nolint:exhaustruct // no packet
+	}
+
+	return pkt, nil
+}
@@ -110,7 +110,7 @@ func buildMultipleVMTable(table *tablewriter.Table, includeTaps bool, vms ...mm.
 		)
 
 		for idx, nw := range vm.Networks {
-			ifaces = append(ifaces, fmt.Sprintf("ID: %d, IP: %s, VLAN: %s", idx, vm.IPv4[idx], nw))
+			ifaces = append(ifaces, vmInterface(vm, idx, nw))
 		}
 
 		if vm.Running {
@@ -150,7 +150,7 @@ func buildSingleVMTable(table *tablewriter.Table, includeTaps bool, vm mm.VM) {
 	)
 
 	for idx, nw := range vm.Networks {
-		ifaces = append(ifaces, fmt.Sprintf("ID: %d, IP: %s, VLAN: %s", idx, vm.IPv4[idx], nw))
+		ifaces = append(ifaces, vmInterface(vm, idx, nw))
 	}
 
 	if vm.Running {
@@ -183,6 +183,16 @@ func buildSingleVMTable(table *tablewriter.Table, includeTaps bool, vm mm.VM) {
 	table.Append([]string{"Metadata", string(metadata)})
 }
 
+func vmInterface(vm mm.VM, idx int, nw string) string {
+	iface := fmt.Sprintf("ID: %d, IP: %s, VLAN: %s", idx, vm.IPv4[idx], nw)
+
+	if imp, ok := vm.Impairments[idx]; ok {
+		iface += ", Impairment: " + imp
+	}
+
+	return iface
+}
+
 func PrintTableOfImageConfigs(writer io.Writer, optional []string, imgs ...types.Image) {
 	var (
 		table = tablewriter.NewWriter(writer)
@@ -289,6 +299,31 @@ func PrintTableOfSubnetCaptures(writer io.Writer, captures []mm.Capture) {
 	table.Render()
 }
 
+func PrintTableOfVMMetrics(writer io.Writer, metrics []mm.VMMetrics) {
+	table := tablewriter.NewWriter(writer)
+
+	table.SetHeader([]string{
+		"VM", "Host", "CPU (%)", "vCPU (%)", "Memory (MB)", "Disk Read (MB)", "Disk Write (MB)", "Rx (MB/s)", "Tx (MB/s)",
+	})
+	table.SetAutoWrapText(false)
+
+	for _, m := range metrics {
+		table.Append([]string{
+			m.VM,
+			m.Host,
+			fmt.Sprintf("%.1f", m.CPU),
+			fmt.Sprintf("%.1f", m.VCPU),
+			fmt.Sprintf("%.0f", m.Memory),
+			fmt.Sprintf("%.1f", float64(m.DiskReadBytes)/(1<<20)),  //nolint:mnd // bytes to MB
+			fmt.Sprintf("%.1f", float64(m.DiskWriteBytes)/(1<<20)), //nolint:mnd // bytes to MB
+			fmt.Sprintf("%.2f", m.NetworkRx),
+			fmt.Sprintf("%.2f", m.NetworkTx),
+		})
+	}
+
+	table.Render()
+}
+
 func PrintTableOfSettings(writer io.Writer, settings []types.Setting) {
 	var (
 		table = tablewriter.NewWriter(writer)
@@ -0,0 +1,214 @@
+// Package rfb records and plays back VNC (RFB) sessions using the FBS format
+// used by rfbproxy and vncrec. An FBS file is a header followed by blocks of
+// data sent by the VNC server, each with the time it was received relative to
+// the start of the recording.
+package rfb
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// fbsHeader is written at the start of every FBS file.
+const fbsHeader = "FBS 001.000\n"
+
+var ErrInvalidFBS = errors.New("invalid FBS file")
+
+// Recorder writes data received from a VNC server to an FBS file. It
+// implements io.Writer so it can be used with io.TeeReader. It is safe for
+// concurrent use.
+type Recorder struct {
+	mu    sync.Mutex
+	w     io.Writer
+	start time.Time
+	err   error
+}
+
+// NewRecorder writes the FBS header to w and returns a recorder that writes
+// blocks to it, timestamped relative to now.
+func NewRecorder(w io.Writer) (*Recorder, error) {
+	if _, err := io.WriteString(w, fbsHeader); err != nil {
+		return nil, fmt.Errorf("writing FBS header: %w", err)
+	}
+
+	return &Recorder{mu: sync.Mutex{}, w: w, start: time.Now(), err: nil}, nil
+}
+
+// Write writes p to the recording as a single block. Errors writing the
+// recording are saved and returned by Err rather than Write so a failed
+// recording never interrupts the session being recorded.
+func (r *Recorder) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.err != nil || len(p) == 0 {
+		return len(p), nil
+	}
+
+	var (
+		padded = (len(p) + 3) &^ 3 //nolint:mnd // blocks are padded to 32 bits
+		buf    = make([]byte, 0, 8+padded)
+		offset = time.Since(r.start).Milliseconds()
+	)
+
+	buf = binary.BigEndian.AppendUint32(buf, uint32(len(p))) //nolint:gosec // bounded by read buffer size
+	buf = append(buf, p...)
+	buf = append(buf, make([]byte, padded-len(p))...)
+	buf = binary.BigEndian.AppendUint32(buf, uint32(offset)) //nolint:gosec // recordings are shorter than 49 days
+
+	if _, err := r.w.Write(buf); err != nil {
+		r.err = fmt.Errorf("writing FBS block: %w", err)
+	}
+
+	return len(p), nil
+}
+
+// Err returns the first error encountered writing the recording, if any.
+func (r *Recorder) Err() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.err
+}
+
+// Block is a block of data sent by a VNC server and the time it was received
+// relative to the start of the recording.
+type Block struct {
+	Offset time.Duration
+	Data   []byte
+}
+
+// Reader reads blocks from an FBS file.
+type Reader struct {
+	r *bufio.Reader
+}
+
+// NewReader reads the FBS header from r and returns a reader for the blocks
+// that follow it.
+func NewReader(r io.Reader) (*Reader, error) {
+	br := bufio.NewReader(r)
+
+	header := make([]byte, len(fbsHeader))
+
+	if _, err := io.ReadFull(br, header); err != nil {
+		return nil, fmt.Errorf("%w: reading header: %w", ErrInvalidFBS, err)
+	}
+
+	// Only the major version is checked since minor versions are compatible.
+	if string(header[:8]) != fbsHeader[:8] {
+		return nil, fmt.Errorf("%w: unsupported header %q", ErrInvalidFBS, header)
+	}
+
+	return &Reader{r: br}, nil
+}
+
+// Next returns the next block in the recording. It returns io.EOF when there
+// are no more blocks. A truncated final block, as left by a recording that
+// was interrupted, is treated as the end of the recording.
+func (r *Reader) Next() (Block, error) {
+	var length uint32
+
+	if err := binary.Read(r.r, binary.BigEndian, &length); err != nil {
+		return Block{}, eof(err) //nolint:exhaustruct // no block
+	}
+
+	data := make([]byte, (length+3)&^3) //nolint:mnd // blocks are padded to 32 bits
+
+	if _, err := io.ReadFull(r.r, data); err != nil {
+		return Block{}, eof(err) //nolint:exhaustruct // no block
+	}
+
+	var offset uint32
+
+	if err := binary.Read(r.r, binary.BigEndian, &offset); err != nil {
+		return Block{}, eof(err) //nolint:exhaustruct // no block
+	}
+
+	return Block{Offset: time.Duration(offset) * time.Millisecond, Data: data[:length]}, nil
+}
+
+// Duration returns the offset of the last block in the recording read from r,
+// which is the length of the recording.
+func Duration(r io.Reader) (time.Duration, error) {
+	reader, err := NewReader(r)
+	if err != nil {
+		return 0, err
+	}
+
+	var last time.Duration
+
+	for {
+		block, err := reader.Next()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return last, nil
+			}
+
+			return last, err
+		}
+
+		last = block.Offset
+	}
+}
+
+// Play writes the blocks read from r to w, waiting between blocks to match
+// the timing of the recording adjusted by the given speed (e.g., 2 plays the
+// recording twice as fast). Idle periods longer than maxIdle, if greater than
+// zero, are shortened to maxIdle. It returns when the recording ends or the
+// given context is canceled.
+func Play(ctx context.Context, w io.Writer, r *Reader, speed float64, maxIdle time.Duration) error {
+	if speed <= 0 {
+		speed = 1
+	}
+
+	var (
+		start   = time.Now()
+		skipped time.Duration
+		last    time.Duration
+	)
+
+	for {
+		block, err := r.Next()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+
+			return err
+		}
+
+		if gap := block.Offset - last; maxIdle > 0 && gap > maxIdle {
+			skipped += gap - maxIdle
+		}
+
+		last = block.Offset
+
+		due := time.Duration(float64(block.Offset-skipped) / speed)
+
+		if wait := due - time.Since(start); wait > 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(wait):
+			}
+		}
+
+		if _, err := w.Write(block.Data); err != nil {
+			return fmt.Errorf("writing recorded data: %w", err)
+		}
+	}
+}
+
+func eof(err error) error {
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return io.EOF
+	}
+
+	return fmt.Errorf("reading FBS block: %w", err)
+}
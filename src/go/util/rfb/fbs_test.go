@@ -0,0 +1,63 @@
+package rfb_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"phenix/util/rfb"
+)
+
+func TestRecordAndPlay(t *testing.T) {
+	var buf bytes.Buffer
+
+	rec, err := rfb.NewRecorder(&buf)
+	if err != nil {
+		t.Fatalf("creating recorder: %v", err)
+	}
+
+	_, _ = rec.Write([]byte("RFB 003.008\n"))
+	_, _ = rec.Write([]byte{1, 2, 3, 4, 5})
+
+	if err := rec.Err(); err != nil {
+		t.Fatalf("recording: %v", err)
+	}
+
+	// Drop part of the final block to simulate an interrupted recording.
+	recording := buf.Bytes()[:buf.Len()-2]
+
+	r, err := rfb.NewReader(bytes.NewReader(recording))
+	if err != nil {
+		t.Fatalf("creating reader: %v", err)
+	}
+
+	block, err := r.Next()
+	if err != nil || string(block.Data) != "RFB 003.008\n" {
+		t.Fatalf("unexpected first block %q (%v)", block.Data, err)
+	}
+
+	if _, err := r.Next(); !errors.Is(err, io.EOF) {
+		t.Fatalf("expected truncated block to be treated as EOF, got %v", err)
+	}
+
+	r, _ = rfb.NewReader(bytes.NewReader(buf.Bytes()))
+
+	var out bytes.Buffer
+
+	if err := rfb.Play(context.Background(), &out, r, 10, time.Second); err != nil {
+		t.Fatalf("playing recording: %v", err)
+	}
+
+	if !bytes.Equal(out.Bytes(), append([]byte("RFB 003.008\n"), 1, 2, 3, 4, 5)) {
+		t.Fatalf("unexpected playback %q", out.Bytes())
+	}
+}
+
+func TestNewReaderInvalid(t *testing.T) {
+	if _, err := rfb.NewReader(bytes.NewReader([]byte("RFB 003.008\n"))); !errors.Is(err, rfb.ErrInvalidFBS) {
+		t.Fatalf("expected invalid FBS error, got %v", err)
+	}
+}
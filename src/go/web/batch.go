@@ -0,0 +1,194 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/gorilla/mux"
+
+	"phenix/api/experiment"
+	"phenix/api/vm"
+	"phenix/util/plog"
+	"phenix/web/broker"
+	bt "phenix/web/broker/brokertypes"
+	"phenix/web/cache"
+	"phenix/web/middleware"
+	"phenix/web/util"
+	"phenix/web/weberror"
+)
+
+// batchPolicies maps each batch action to the RBAC resource and verb required
+// to apply it to a VM, matching the policies of the single VM endpoints.
+var batchPolicies = map[string][2]string{ //nolint:gochecknoglobals // global constant
+	vm.BatchStart:    {"vms/start", "update"},
+	vm.BatchStop:     {"vms/stop", "update"},
+	vm.BatchRestart:  {"vms/restart", "update"},
+	vm.BatchRedeploy: {"vms/redeploy", "update"},
+	vm.BatchSnapshot: {"vms/snapshots", "create"},
+	vm.BatchShutdown: {"vms/shutdown", "update"},
+	vm.BatchSetTags:  {"vms", "patch"},
+}
+
+// batchLocks maps each batch action to the function used to lock a VM while
+// the action is applied to it. Actions without a lock function don't lock VMs,
+// matching the single VM endpoints.
+var batchLocks = map[string]func(string, string) error{ //nolint:gochecknoglobals // global constant
+	vm.BatchStart:    cache.LockVMForStarting,
+	vm.BatchStop:     cache.LockVMForStopping,
+	vm.BatchRestart:  cache.LockVMForStarting,
+	vm.BatchRedeploy: cache.LockVMForRedeploying,
+	vm.BatchSnapshot: cache.LockVMForSnapshotting,
+	vm.BatchShutdown: cache.LockVMForStopping,
+}
+
+// BatchVMs - POST /experiments/{exp}/vms:batch.
+func BatchVMs(w http.ResponseWriter, r *http.Request) error {
+	plog.Debug(plog.TypeSystem, "HTTP handler called", "handler", "BatchVMs")
+
+	var (
+		ctx  = r.Context()
+		role = middleware.RoleFromContext(ctx)
+		user = middleware.UserFromContext(ctx)
+		exp  = mux.Vars(r)["exp"]
+	)
+
+	var req struct {
+		Action      string            `json:"action"`
+		All         bool              `json:"all"`
+		VMs         []string          `json:"vms"`
+		Labels      []string          `json:"labels"`
+		Parallelism int               `json:"parallelism"`
+		Tags        map[string]string `json:"tags"`
+		AppendTags  bool              `json:"appendTags"`
+		Snapshot    string            `json:"snapshot"`
+		Injects     bool              `json:"injects"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return weberror.NewWebError(err, "unable to parse request body").
+			SetStatus(http.StatusBadRequest)
+	}
+
+	policy, ok := batchPolicies[req.Action]
+	if !ok {
+		return weberror.NewWebError(vm.ErrUnknownBatchAction, "unknown batch action %s", req.Action).
+			SetStatus(http.StatusBadRequest)
+	}
+
+	selection := []vm.BatchOption{vm.BatchWithVMs(req.VMs...), vm.BatchWithLabels(req.Labels...)}
+
+	if req.All {
+		selection = append(selection, vm.BatchWithAllVMs())
+	}
+
+	names, err := vm.BatchTargets(exp, selection...)
+	if err != nil {
+		return weberror.NewWebError(err, "unable to select VMs in experiment %s", exp).
+			SetStatus(http.StatusBadRequest)
+	}
+
+	var (
+		allowed []string
+		results []vm.BatchResult
+	)
+
+	// VMs the user isn't allowed to apply the action to are reported as failed
+	// rather than failing the entire batch.
+	for _, name := range names {
+		if role.Allowed(policy[0], policy[1], fmt.Sprintf("%s/%s", exp, name)) {
+			allowed = append(allowed, name)
+
+			continue
+		}
+
+		plog.Warn(
+			plog.TypeSecurity,
+			"batch vm action not allowed",
+			"user",
+			user,
+			"exp",
+			exp,
+			"vm",
+			name,
+			"action",
+			req.Action,
+		)
+
+		results = append(results, vm.BatchResult{VM: name, Error: "forbidden"})
+	}
+
+	if len(allowed) > 0 {
+		opts := []vm.BatchOption{
+			vm.BatchWithVMs(allowed...),
+			vm.BatchWithParallelism(req.Parallelism),
+			vm.BatchWithTags(req.Tags, req.AppendTags),
+			vm.BatchWithSnapshotName(req.Snapshot),
+			vm.BatchWithCreator(user),
+			vm.BatchWithInjects(req.Injects),
+			vm.BatchWithLock(batchLocker(exp)),
+			vm.BatchWithCallback(func(result vm.BatchResult) {
+				if result.Error == "" {
+					broadcastBatchVM(exp, result.VM, policy)
+				}
+			}),
+		}
+
+		allowedResults, err := vm.Batch(ctx, exp, req.Action, opts...)
+		if err != nil {
+			return weberror.NewWebError(err, "unable to apply %s to VMs in experiment %s", req.Action, exp).
+				SetStatus(http.StatusBadRequest)
+		}
+
+		results = append(results, allowedResults...)
+	}
+
+	plog.Info(plog.TypeAction, "batch vm action applied", "user", user, "exp", exp, "action", req.Action, "vms", allowed)
+
+	body, _ := json.Marshal(util.WithRoot("results", results))
+
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(body) //nolint:gosec // XSS via taint analysis
+
+	return nil
+}
+
+// batchLocker returns a function that locks VMs in the given experiment for
+// the given batch action using the same locks as the single VM endpoints.
+func batchLocker(exp string) func(string, string) (func(), error) {
+	return func(action, name string) (func(), error) {
+		lock, ok := batchLocks[action]
+		if !ok {
+			return func() {}, nil
+		}
+
+		if err := lock(exp, name); err != nil {
+			return nil, err
+		}
+
+		return func() { cache.UnlockVM(exp, name) }, nil
+	}
+}
+
+func broadcastBatchVM(exp, name string, policy [2]string) {
+	e, err := experiment.Get(exp)
+	if err != nil {
+		return
+	}
+
+	v, err := vm.Get(exp, name)
+	if err != nil {
+		return
+	}
+
+	body, err := marshaler.Marshal(util.VMToProtobuf(exp, *v, e.Spec.Topology()))
+	if err != nil {
+		return
+	}
+
+	broker.Broadcast(
+		bt.NewRequestPolicy(policy[0], policy[1], fmt.Sprintf("%s/%s", exp, name)),
+		bt.NewResource("experiment/vm", fmt.Sprintf("%s/%s", exp, name), "update"),
+		body,
+	)
+}
@@ -0,0 +1,218 @@
+package web
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"phenix/api/experiment"
+	"phenix/api/vm"
+	"phenix/util/mm"
+	"phenix/util/plog"
+	"phenix/web/middleware"
+	"phenix/web/weberror"
+)
+
+// captureLimits are the optional capture filter and limits included in the
+// body of capture requests alongside the protobuf request fields.
+type captureLimits struct {
+	Filter      string `json:"filter,omitempty"`
+	MaxFileSize int    `json:"maxFileSize,omitempty"`
+	MaxFiles    int    `json:"maxFiles,omitempty"`
+	MaxDuration string `json:"maxDuration,omitempty"`
+	MaxPackets  int    `json:"maxPackets,omitempty"`
+}
+
+func parseCaptureLimits(body []byte) (mm.CaptureLimits, error) {
+	var req captureLimits
+
+	if err := json.Unmarshal(body, &req); err != nil {
+		return mm.CaptureLimits{}, fmt.Errorf("parsing capture limits: %w", err) //nolint:exhaustruct // empty limits
+	}
+
+	limits := mm.CaptureLimits{ //nolint:exhaustruct // partial initialization
+		Filter:      req.Filter,
+		MaxFileSize: req.MaxFileSize,
+		MaxFiles:    req.MaxFiles,
+		MaxPackets:  req.MaxPackets,
+	}
+
+	if req.MaxDuration != "" {
+		d, err := time.ParseDuration(req.MaxDuration)
+		if err != nil {
+			return limits, fmt.Errorf("invalid max duration %s: %w", req.MaxDuration, err)
+		}
+
+		limits.MaxDuration = d
+	}
+
+	return limits, nil
+}
+
+// flushWriter flushes each write to the client so packets are delivered as
+// they're captured.
+type flushWriter struct {
+	w       http.ResponseWriter
+	f       http.Flusher
+	written bool
+}
+
+func (fw *flushWriter) Write(p []byte) (int, error) {
+	n, err := fw.w.Write(p)
+	if n > 0 {
+		fw.written = true
+	}
+
+	if fw.f != nil {
+		fw.f.Flush()
+	}
+
+	return n, err //nolint:wrapcheck // passthrough
+}
+
+// DownloadVMCapture - GET /experiments/{exp}/vms/{name}/captures/{id}/download.
+func DownloadVMCapture(w http.ResponseWriter, r *http.Request) error {
+	plog.Debug(plog.TypeSystem, "HTTP handler called", "handler", "DownloadVMCapture")
+
+	var (
+		ctx      = r.Context()
+		role     = middleware.RoleFromContext(ctx)
+		user     = middleware.UserFromContext(ctx)
+		vars     = mux.Vars(r)
+		exp      = vars["exp"]
+		name     = vars["name"]
+		fullName = fmt.Sprintf("%s/%s", exp, name)
+	)
+
+	if !role.Allowed("vms/captures", "get", fullName) {
+		plog.Warn(plog.TypeSecurity, "downloading vm capture not allowed", "user", user, "exp", exp, "vm", name)
+		err := weberror.NewWebError(nil, "downloading capture for VM %s not allowed for %s", fullName, user)
+
+		return err.SetStatus(http.StatusForbidden)
+	}
+
+	// Captures are identified by the interface they're capturing on since only
+	// one capture can run per interface.
+	iface, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		return weberror.NewWebError(err, "capture ID must be an interface index").
+			SetStatus(http.StatusBadRequest)
+	}
+
+	var capture *mm.Capture
+
+	for _, c := range mm.GetVMCaptures(mm.NS(exp), mm.VMName(name)) {
+		if c.Interface == iface {
+			capture = &c
+
+			break
+		}
+	}
+
+	if capture == nil {
+		return weberror.NewWebError(vm.ErrNoCaptures, "no capture for interface %d of VM %s", iface, fullName).
+			SetStatus(http.StatusNotFound)
+	}
+
+	plog.Info(plog.TypeAction, "downloading vm capture", "user", user, "exp", exp, "vm", name, "iface", iface)
+
+	w.Header().Set("Content-Type", "application/vnd.tcpdump.pcap")
+	w.Header().Set("Content-Disposition", "attachment; filename="+filepath.Base(capture.Filepath))
+
+	fw := &flushWriter{w: w, f: nil, written: false}
+	fw.f, _ = w.(http.Flusher)
+
+	if err := vm.StreamCapture(ctx, exp, name, iface, fw); err != nil {
+		if !fw.written {
+			if errors.Is(err, vm.ErrNoCaptures) {
+				return weberror.NewWebError(err, "capture for interface %d of VM %s stopped", iface, fullName).
+					SetStatus(http.StatusNotFound)
+			}
+
+			return weberror.NewWebError(err, "unable to download capture for interface %d of VM %s", iface, fullName)
+		}
+
+		// Errors after the response has started can only be logged.
+		plog.Error(plog.TypeSystem, "streaming vm capture", "exp", exp, "vm", name, "iface", iface, "err", err)
+	}
+
+	return nil
+}
+
+// MergeExperimentCaptures - GET /experiments/{name}/captures/merge[?vlan=<alias>][&from=<RFC3339>][&to=<RFC3339>].
+func MergeExperimentCaptures(w http.ResponseWriter, r *http.Request) error {
+	plog.Debug(plog.TypeSystem, "HTTP handler called", "handler", "MergeExperimentCaptures")
+
+	var (
+		ctx   = r.Context()
+		role  = middleware.RoleFromContext(ctx)
+		user  = middleware.UserFromContext(ctx)
+		name  = mux.Vars(r)["name"]
+		query = r.URL.Query()
+		opts  = []experiment.MergeCapturesOption{experiment.MergeCapturesWithVLAN(query.Get("vlan"))}
+	)
+
+	if !role.Allowed("experiments/captures", "get", name) {
+		plog.Warn(plog.TypeSecurity, "merging experiment captures not allowed", "user", user, "exp", name)
+		err := weberror.NewWebError(nil, "merging captures for experiment %s not allowed for %s", name, user)
+
+		return err.SetStatus(http.StatusForbidden)
+	}
+
+	for _, param := range []string{"from", "to"} {
+		s := query.Get(param)
+		if s == "" {
+			continue
+		}
+
+		ts, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			return weberror.NewWebError(err, "invalid %s timestamp %s", param, s).
+				SetStatus(http.StatusBadRequest)
+		}
+
+		if param == "from" {
+			opts = append(opts, experiment.MergeCapturesFrom(ts))
+		} else {
+			opts = append(opts, experiment.MergeCapturesTo(ts))
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/x-pcapng")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%s-captures.pcapng", name))
+
+	// The merged captures aren't flushed as they're written, but the writer is
+	// still used to track whether the response has started.
+	fw := &flushWriter{w: w, f: nil, written: false}
+
+	count, err := experiment.MergeCaptures(name, fw, opts...)
+	if err != nil {
+		if !fw.written {
+			w.Header().Del("Content-Type")
+			w.Header().Del("Content-Disposition")
+
+			if errors.Is(err, experiment.ErrNoCapturesToMerge) {
+				return weberror.NewWebError(err, "no packet captures to merge for experiment %s", name).
+					SetStatus(http.StatusNotFound)
+			}
+
+			return weberror.NewWebError(err, "unable to merge packet captures for experiment %s", name).
+				SetStatus(http.StatusInternalServerError)
+		}
+
+		// Errors after the response has started can only be logged.
+		plog.Error(plog.TypeSystem, "merging experiment captures", "exp", name, "err", err)
+
+		return nil
+	}
+
+	plog.Info(plog.TypeAction, "experiment captures merged", "user", user, "exp", name, "packets", count)
+
+	return nil
+}
@@ -37,7 +37,7 @@ var (
 )
 
 //nolint:funlen // complex logic
-func startExperiment(name string) ([]byte, error) {
+func startExperiment(name string, opts ...experiment.StartOption) ([]byte, error) {
 	err := cache.LockExperimentForStarting(name)
 	if err != nil {
 		err := weberror.NewWebError(err, "unable to lock experiment %s for starting", name)
@@ -71,11 +71,13 @@ func startExperiment(name string) ([]byte, error) {
 
 		ch := make(chan error)
 
-		if err := experiment.Start(
-			ctx,
+		opts = append(
+			opts,
 			experiment.StartWithName(name),
 			experiment.StartWithErrorChannel(ch),
-		); err != nil {
+		)
+
+		if err := experiment.Start(ctx, opts...); err != nil {
 			cancel() // avoid leakage
 			commonMu.Lock()
 			delete(cancelers, name)
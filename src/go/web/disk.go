@@ -2,8 +2,8 @@ package web
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
 	"net/http"
 	"os"
 	"path/filepath"
@@ -14,6 +14,7 @@ import (
 	"github.com/gorilla/mux"
 
 	"phenix/api/disk"
+	v1 "phenix/types/version/v1"
 	"phenix/util/mm"
 	"phenix/util/plog"
 	"phenix/web/middleware"
@@ -91,6 +92,43 @@ func GetDisks(w http.ResponseWriter, r *http.Request) {
 	_, _ = w.Write(body) //nolint:gosec // XSS via taint analysis
 }
 
+// GetDiskGraph - GET /disks/graph
+// Returns the backing-file graph of every disk image in the files dir, including orphaned overlays and snapshots.
+func GetDiskGraph(w http.ResponseWriter, r *http.Request) {
+	plog.Debug(plog.TypeSystem, "HTTP handler called", "handler", "GetDiskGraph")
+
+	role, _ := r.Context().Value(middleware.ContextKeyRole).(rbac.Role)
+
+	if !role.Allowed("disks", "list") {
+		user, _ := r.Context().Value(middleware.ContextKeyUser).(string)
+		plog.Warn(
+			plog.TypeSecurity,
+			"getting disk graph not allowed",
+			"user",
+			user,
+		)
+		http.Error(w, "forbidden", http.StatusForbidden)
+
+		return
+	}
+
+	graph, err := disk.BackingGraph()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+
+		return
+	}
+
+	body, err := json.Marshal(graph)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+
+		return
+	}
+
+	_, _ = w.Write(body) //nolint:gosec // XSS via taint analysis
+}
+
 // CommitDisk - POST /disks/commit?disk={disk}.
 func CommitDisk(w http.ResponseWriter, r *http.Request) {
 	role, _ := r.Context().Value(middleware.ContextKeyRole).(rbac.Role)
@@ -176,7 +214,7 @@ func CommitDisk(w http.ResponseWriter, r *http.Request) {
 func SnapshotDisk(w http.ResponseWriter, r *http.Request) {
 	role, _ := r.Context().Value(middleware.ContextKeyRole).(rbac.Role)
 	path := mux.Vars(r)["disk"]
-	newPath := normalizeDstDisk(path, mux.Vars(r)["new"])
+	newPath := normalizeDstDisk(path, mux.Vars(r)["new"], v1.FormatQcow2)
 
 	if !role.Allowed("disks", "create", filepath.Base(newPath)) {
 		user, _ := r.Context().Value(middleware.ContextKeyUser).(string)
@@ -317,7 +355,7 @@ func ResizeDisk(w http.ResponseWriter, r *http.Request) {
 func CloneDisk(w http.ResponseWriter, r *http.Request) {
 	role, _ := r.Context().Value(middleware.ContextKeyRole).(rbac.Role)
 	path := mux.Vars(r)["disk"]
-	newPath := normalizeDstDisk(path, mux.Vars(r)["new"])
+	newPath := normalizeDstDisk(path, mux.Vars(r)["new"], v1.FormatQcow2)
 
 	if !role.Allowed("disks", "create") {
 		user, _ := r.Context().Value(middleware.ContextKeyUser).(string)
@@ -365,7 +403,7 @@ func CloneDisk(w http.ResponseWriter, r *http.Request) {
 func RenameDisk(w http.ResponseWriter, r *http.Request) {
 	role, _ := r.Context().Value(middleware.ContextKeyRole).(rbac.Role)
 	path := mux.Vars(r)["disk"]
-	newPath := normalizeDstDisk(path, mux.Vars(r)["new"])
+	newPath := normalizeDstDisk(path, mux.Vars(r)["new"], v1.FormatQcow2)
 
 	if !role.Allowed("disks", "update", filepath.Base(path)) {
 		user, _ := r.Context().Value(middleware.ContextKeyUser).(string)
@@ -445,53 +483,138 @@ func DeleteDisk(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 }
 
-// UploadDisk - POST /disks.
-func UploadDisk(w http.ResponseWriter, r *http.Request) {
+// ConvertDisk - POST /disks/convert?disk={disk}&new={new}&format={format}
+// disk may be relative to the files dir, and both disks must be within it
+// new may be absolute, but will be put in same dir as disk if not. Extension will be set to match format.
+func ConvertDisk(w http.ResponseWriter, r *http.Request) {
 	role, _ := r.Context().Value(middleware.ContextKeyRole).(rbac.Role)
-	clientFile, handler, err := r.FormFile("file")
+	format := v1.Format(mux.Vars(r)["format"])
+
+	if disk.FormatExtension(format) == "" {
+		http.Error(w, fmt.Sprintf("unsupported disk format %q", format), http.StatusBadRequest)
+
+		return
+	}
+
+	path, _, err := resolveDiskPath(mux.Vars(r)["disk"])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+
+		return
+	}
 
-	if !role.Allowed("disks", "upload") {
+	newPath, _, err := resolveDiskPath(normalizeDstDisk(path, mux.Vars(r)["new"], format))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+
+		return
+	}
+
+	if !role.Allowed("disks", "create", filepath.Base(newPath)) {
 		user, _ := r.Context().Value(middleware.ContextKeyUser).(string)
 		plog.Warn(
 			plog.TypeSecurity,
-			"uploading disk not allowed",
+			"converting disk not allowed",
 			"user",
 			user,
+			"from_disk",
+			path,
+			"to_disk",
+			newPath,
 		)
 		http.Error(w, "forbidden", http.StatusForbidden)
 
 		return
 	}
 
-	if err != nil {
-		plog.Error(plog.TypeSystem, err.Error())
-		http.Error(w, "Error uploading: "+err.Error(), http.StatusInternalServerError)
+	if _, err := os.Stat(newPath); err == nil {
+		http.Error(w, fmt.Sprintf("disk %s already exists", newPath), http.StatusConflict)
+
+		return
 	}
 
-	defer func() { _ = clientFile.Close() }()
+	if err := disk.ConvertDisk(path, newPath, format); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
 
-	localFile, err := os.OpenFile( //nolint:gosec // Path traversal via taint analysis
-		mm.GetMMFullPath(handler.Filename),
-		os.O_WRONLY|os.O_CREATE,
-		0o600,
+		return
+	}
+
+	user, _ := r.Context().Value(middleware.ContextKeyUser).(string)
+	plog.Info(
+		plog.TypeAction,
+		"converted disk",
+		"user",
+		user,
+		"from_disk",
+		path,
+		"to_disk",
+		newPath,
+		"format",
+		format,
 	)
+	w.WriteHeader(http.StatusOK)
+}
+
+// ImportDisk - POST /disks/import?file={file}[&name={name}&format={format}]
+// file is an OVA, or an OVF with its disks in the same directory, and may be relative to the files dir or absolute.
+// The VM's disks are converted to format (qcow2 by default) and a draft topology node for it is returned.
+func ImportDisk(w http.ResponseWriter, r *http.Request) {
+	role, _ := r.Context().Value(middleware.ContextKeyRole).(rbac.Role)
+	path := mm.GetMMFullPath(mux.Vars(r)["file"])
+	query := r.URL.Query()
+
+	format := v1.Format(query.Get("format"))
+	if format == "" {
+		format = v1.FormatQcow2
+	}
+
+	if !role.Allowed("disks", "create") {
+		user, _ := r.Context().Value(middleware.ContextKeyUser).(string)
+		plog.Warn(
+			plog.TypeSecurity,
+			"importing disk not allowed",
+			"user",
+			user,
+			"file",
+			path,
+		)
+		http.Error(w, "forbidden", http.StatusForbidden)
+
+		return
+	}
+
+	imported, err := disk.ImportOVA(path, query.Get("name"), format)
 	if err != nil {
-		plog.Error(plog.TypeSystem, err.Error())
-		http.Error(w, "Error uploading: "+err.Error(), http.StatusInternalServerError)
+		status := http.StatusInternalServerError
+		if errors.Is(err, disk.ErrInvalidOVF) {
+			status = http.StatusBadRequest
+		}
+
+		http.Error(w, err.Error(), status)
+
+		return
 	}
 
-	defer func() { _ = localFile.Close() }()
+	body, err := json.Marshal(imported)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+
+		return
+	}
 
-	_, _ = io.Copy(localFile, clientFile)
 	user, _ := r.Context().Value(middleware.ContextKeyUser).(string)
 	plog.Info(
 		plog.TypeAction,
-		"uploaded disk",
+		"imported disk",
 		"user",
 		user,
-		"disk",
-		localFile.Name(),
+		"file",
+		path,
+		"disks",
+		imported.Disks,
 	)
+
+	_, _ = w.Write(body) //nolint:gosec // XSS via taint analysis
 }
 
 // DownloadDisk - GET /disks?disk={disk}
@@ -549,14 +672,21 @@ func DownloadDisk(w http.ResponseWriter, r *http.Request) {
 	http.ServeFile(w, r, path)
 }
 
-// for output disk names - makes absolute and adds qcow2 file extension.
-func normalizeDstDisk(src, dst string) string {
+// for output disk names - makes absolute and adds the file extension for the
+// given format.
+func normalizeDstDisk(src, dst string, format v1.Format) string {
 	if !filepath.IsAbs(dst) {
 		dst = filepath.Join(filepath.Dir(src), dst)
 	}
 
-	if !strings.HasSuffix(dst, ".qcow2") && !strings.HasSuffix(dst, ".qc2") {
-		dst += ".qcow2"
+	ext := disk.FormatExtension(format)
+
+	if format == v1.FormatQcow2 && strings.HasSuffix(dst, ".qc2") {
+		return dst
+	}
+
+	if !strings.HasSuffix(dst, ext) {
+		dst += ext
 	}
 
 	return dst
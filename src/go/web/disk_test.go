@@ -0,0 +1,28 @@
+package web
+
+import (
+	"testing"
+
+	v1 "phenix/types/version/v1"
+)
+
+func TestNormalizeDstDisk(t *testing.T) {
+	tests := []struct {
+		dst    string
+		format v1.Format
+		want   string
+	}{
+		{"foo", v1.FormatQcow2, "/images/foo.qcow2"},
+		{"foo.qc2", v1.FormatQcow2, "/images/foo.qc2"},
+		{"foo.qcow2", v1.FormatQcow2, "/images/foo.qcow2"},
+		{"foo", v1.FormatRaw, "/images/foo.raw"},
+		{"foo.vmdk", v1.FormatVmdk, "/images/foo.vmdk"},
+		{"/other/foo", v1.FormatVhdx, "/other/foo.vhdx"},
+	}
+
+	for _, tt := range tests {
+		if got := normalizeDstDisk("/images/base.qc2", tt.dst, tt.format); got != tt.want {
+			t.Errorf("normalizeDstDisk(%s, %s) = %s, expected %s", tt.dst, tt.format, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,230 @@
+package web
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"path"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"phenix/api/vm"
+	"phenix/util/plog"
+	"phenix/web/middleware"
+	"phenix/web/util"
+	"phenix/web/weberror"
+)
+
+type execRequest struct {
+	Command string   `json:"command"`
+	VMs     []string `json:"vms"`
+	Labels  []string `json:"labels"`
+	Timeout string   `json:"timeout"`
+}
+
+func (r execRequest) options() ([]vm.ExecOption, error) {
+	opts := []vm.ExecOption{vm.ExecWithVMs(r.VMs...), vm.ExecWithLabels(r.Labels...)}
+
+	if r.Timeout != "" {
+		timeout, err := time.ParseDuration(r.Timeout)
+		if err != nil {
+			return nil, fmt.Errorf("invalid timeout %s: %w", r.Timeout, err)
+		}
+
+		opts = append(opts, vm.ExecWithTimeout(timeout))
+	}
+
+	return opts, nil
+}
+
+// ExecVMs - POST /experiments/{exp}/vms/exec.
+func ExecVMs(w http.ResponseWriter, r *http.Request) error {
+	plog.Debug(plog.TypeSystem, "HTTP handler called", "handler", "ExecVMs")
+
+	var (
+		ctx  = r.Context()
+		role = middleware.RoleFromContext(ctx)
+		user = middleware.UserFromContext(ctx)
+		exp  = mux.Vars(r)["exp"]
+	)
+
+	var req execRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return weberror.NewWebError(err, "unable to parse request body").
+			SetStatus(http.StatusBadRequest)
+	}
+
+	opts, err := req.options()
+	if err != nil {
+		return weberror.NewWebError(err, "%s", err.Error()).SetStatus(http.StatusBadRequest)
+	}
+
+	vms, err := vm.ExecTargets(exp, opts...)
+	if err != nil {
+		return weberror.NewWebError(err, "unable to select VMs in experiment %s", exp).
+			SetStatus(http.StatusBadRequest)
+	}
+
+	for _, name := range vms {
+		if !role.Allowed("vms/exec", "create", fmt.Sprintf("%s/%s", exp, name)) {
+			plog.Warn(plog.TypeSecurity, "executing command in vm not allowed", "user", user, "exp", exp, "vm", name)
+			err := weberror.NewWebError(nil, "executing command in VM %s/%s not allowed for %s", exp, name, user)
+
+			return err.SetStatus(http.StatusForbidden)
+		}
+	}
+
+	// Limit execution to the VMs the user was authorized for.
+	opts = append(opts, vm.ExecWithVMs(vms...))
+
+	results, err := vm.Exec(ctx, exp, req.Command, opts...)
+	if err != nil {
+		return weberror.NewWebError(err, "unable to execute command in experiment %s", exp)
+	}
+
+	plog.Info(plog.TypeAction, "command executed in vms", "user", user, "exp", exp, "vms", vms)
+
+	body, _ := json.Marshal(util.WithRoot("results", results))
+
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(body) //nolint:gosec // XSS via taint analysis
+
+	return nil
+}
+
+// ExecVM - POST /experiments/{exp}/vms/{name}/exec.
+func ExecVM(w http.ResponseWriter, r *http.Request) error {
+	plog.Debug(plog.TypeSystem, "HTTP handler called", "handler", "ExecVM")
+
+	var (
+		ctx  = r.Context()
+		role = middleware.RoleFromContext(ctx)
+		user = middleware.UserFromContext(ctx)
+		vars = mux.Vars(r)
+		exp  = vars["exp"]
+		name = vars["name"]
+	)
+
+	if !role.Allowed("vms/exec", "create", fmt.Sprintf("%s/%s", exp, name)) {
+		plog.Warn(plog.TypeSecurity, "executing command in vm not allowed", "user", user, "exp", exp, "vm", name)
+		err := weberror.NewWebError(nil, "executing command in VM %s/%s not allowed for %s", exp, name, user)
+
+		return err.SetStatus(http.StatusForbidden)
+	}
+
+	var req execRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return weberror.NewWebError(err, "unable to parse request body").
+			SetStatus(http.StatusBadRequest)
+	}
+
+	// The VM in the path always takes precedence over any selectors in the body.
+	req.VMs, req.Labels = []string{name}, nil
+
+	opts, err := req.options()
+	if err != nil {
+		return weberror.NewWebError(err, "%s", err.Error()).SetStatus(http.StatusBadRequest)
+	}
+
+	results, err := vm.Exec(ctx, exp, req.Command, opts...)
+	if err != nil {
+		if errors.Is(err, vm.ErrNoVMsSelected) {
+			return weberror.NewWebError(err, "VM %s not found in experiment %s", name, exp).
+				SetStatus(http.StatusNotFound)
+		}
+
+		return weberror.NewWebError(err, "unable to execute command in VM %s/%s", exp, name)
+	}
+
+	plog.Info(plog.TypeAction, "command executed in vm", "user", user, "exp", exp, "vm", name)
+
+	body, _ := json.Marshal(results[0])
+
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(body) //nolint:gosec // XSS via taint analysis
+
+	return nil
+}
+
+// CopyFromVM - GET /experiments/{exp}/vms/{name}/cp?path=<path>.
+func CopyFromVM(w http.ResponseWriter, r *http.Request) error {
+	plog.Debug(plog.TypeSystem, "HTTP handler called", "handler", "CopyFromVM")
+
+	var (
+		ctx  = r.Context()
+		role = middleware.RoleFromContext(ctx)
+		user = middleware.UserFromContext(ctx)
+		vars = mux.Vars(r)
+		exp  = vars["exp"]
+		name = vars["name"]
+		src  = r.URL.Query().Get("path")
+	)
+
+	// Copying files from a VM executes a command in the VM, so it's not treated
+	// as a read-only operation.
+	if !role.Allowed("vms/cp", "create", fmt.Sprintf("%s/%s", exp, name)) {
+		plog.Warn(plog.TypeSecurity, "copying file from vm not allowed", "user", user, "exp", exp, "vm", name)
+		err := weberror.NewWebError(nil, "copying file from VM %s/%s not allowed for %s", exp, name, user)
+
+		return err.SetStatus(http.StatusForbidden)
+	}
+
+	if src == "" {
+		return weberror.NewWebError(errors.New("missing path"), "no path provided").
+			SetStatus(http.StatusBadRequest)
+	}
+
+	data, err := vm.CopyFromVM(ctx, exp, name, src)
+	if err != nil {
+		return weberror.NewWebError(err, "unable to copy %s from VM %s/%s", src, exp, name)
+	}
+
+	plog.Info(plog.TypeAction, "file copied from vm", "user", user, "exp", exp, "vm", name, "path", src)
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Disposition", "attachment; filename="+path.Base(src))
+	_, _ = w.Write(data)
+
+	return nil
+}
+
+// CopyToVM - PUT /experiments/{exp}/vms/{name}/cp?path=<path>.
+func CopyToVM(w http.ResponseWriter, r *http.Request) error {
+	plog.Debug(plog.TypeSystem, "HTTP handler called", "handler", "CopyToVM")
+
+	var (
+		ctx  = r.Context()
+		role = middleware.RoleFromContext(ctx)
+		user = middleware.UserFromContext(ctx)
+		vars = mux.Vars(r)
+		exp  = vars["exp"]
+		name = vars["name"]
+		dst  = r.URL.Query().Get("path")
+	)
+
+	if !role.Allowed("vms/cp", "create", fmt.Sprintf("%s/%s", exp, name)) {
+		plog.Warn(plog.TypeSecurity, "copying file to vm not allowed", "user", user, "exp", exp, "vm", name)
+		err := weberror.NewWebError(nil, "copying file to VM %s/%s not allowed for %s", exp, name, user)
+
+		return err.SetStatus(http.StatusForbidden)
+	}
+
+	if dst == "" {
+		return weberror.NewWebError(errors.New("missing path"), "no path provided").
+			SetStatus(http.StatusBadRequest)
+	}
+
+	if err := vm.CopyToVM(ctx, exp, name, r.Body, dst); err != nil {
+		return weberror.NewWebError(err, "unable to copy file to %s in VM %s/%s", dst, exp, name)
+	}
+
+	plog.Info(plog.TypeAction, "file copied to vm", "user", user, "exp", exp, "vm", name, "path", dst)
+
+	w.WriteHeader(http.StatusNoContent)
+
+	return nil
+}
@@ -10,8 +10,6 @@ import (
 	"io"
 	"net/http"
 	"net/http/httptest"
-	"os"
-	"path/filepath"
 	"sort"
 	"time"
 
@@ -20,9 +18,9 @@ import (
 	"phenix/util/plog"
 	"phenix/web/middleware"
 	"phenix/web/rbac"
+	"phenix/web/weberror"
 )
 
-const maxFileServerUploadMemory = 32 << 20
 const fileServerTokenCookie = "phenix_file_server_token"
 
 const fileServerIndexHTML = `<!doctype html>
@@ -48,7 +46,7 @@ const fileServerIndexHTML = `<!doctype html>
   {{if and .AuthEnabled .User}}<p>Signed in as {{.User}}. <a href="/logout">Logout</a></p>{{end}}
   {{if .Message}}<p class="message">{{.Message}}</p>{{end}}
   {{if .Experiments}}
-  <form id="upload-form" action="/upload" method="post" enctype="multipart/form-data">
+  <form id="upload-form">
     <label>Experiment
       <select name="experiment" required>
         {{range .Experiments}}<option value="{{.}}">{{.}}</option>{{end}}
@@ -57,6 +55,7 @@ const fileServerIndexHTML = `<!doctype html>
     <label>Files
       <input type="file" name="files" multiple required>
     </label>
+    <label><input type="checkbox" name="overwrite"> Overwrite existing files</label>
     <button type="submit">Upload</button>
     <div id="upload-progress" class="upload-progress" hidden>
       <progress id="upload-progress-bar" max="100" value="0"></progress>
@@ -71,69 +70,139 @@ const fileServerIndexHTML = `<!doctype html>
     (function() {
       var form = document.getElementById("upload-form");
 
-      if (!form || !window.XMLHttpRequest || !window.FormData) {
+      if (!form || !window.fetch) {
         return;
       }
 
+      // Files are uploaded in chunks using the resumable upload API so a dropped
+      // connection only requires the current chunk to be resent.
+      var chunkSize = 16 * 1024 * 1024;
+      var maxRetries = 10;
+
       var button = form.querySelector("button[type='submit']");
       var progress = document.getElementById("upload-progress");
       var progressBar = document.getElementById("upload-progress-bar");
       var progressText = document.getElementById("upload-progress-text");
       var uploadError = document.getElementById("upload-error");
 
-      form.addEventListener("submit", function(event) {
-        event.preventDefault();
-
-        var request = new XMLHttpRequest();
-        var data = new FormData(form);
+      function request(method, url, options) {
+        return fetch(url, Object.assign({ method: method, credentials: "same-origin" }, options)).then(function(response) {
+          if (response.redirected && new URL(response.url).pathname === "/login") {
+            window.location = "/login";
 
-        uploadError.hidden = true;
-        uploadError.textContent = "";
-        progress.hidden = false;
-        progressBar.value = 0;
-        progressText.textContent = "Preparing upload...";
-        button.disabled = true;
+            throw new Error("Login required.");
+          }
 
-        request.upload.onprogress = function(progressEvent) {
-          if (!progressEvent.lengthComputable) {
-            progressText.textContent = "Uploading...";
+          if (!response.ok) {
+            return response.text().then(function(text) {
+              var err = new Error(text || response.statusText);
+              err.status = response.status;
 
-            return;
+              throw err;
+            });
           }
 
-          var percent = Math.round((progressEvent.loaded / progressEvent.total) * 100);
-          progressBar.value = percent;
-          progressText.textContent = percent >= 100 ? "Finalizing..." : "Uploading " + percent + "%";
-        };
+          return response;
+        });
+      }
+
+      function checksum(chunk) {
+        if (!window.crypto || !window.crypto.subtle) {
+          return Promise.resolve(null);
+        }
 
-        request.onload = function() {
-          if (request.responseURL && new URL(request.responseURL).pathname === "/login") {
-            window.location = "/login";
+        return chunk.arrayBuffer().then(function(buf) {
+          return window.crypto.subtle.digest("SHA-256", buf);
+        }).then(function(digest) {
+          return "sha256 " + btoa(String.fromCharCode.apply(null, new Uint8Array(digest)));
+        });
+      }
+
+      function sleep(ms) {
+        return new Promise(function(resolve) { setTimeout(resolve, ms); });
+      }
+
+      async function uploadFile(experiment, file, overwrite, onProgress) {
+        var response = await request("POST", "/uploads", {
+          headers: { "Content-Type": "application/json" },
+          body: JSON.stringify({ kind: "experiment", experiment: experiment, filename: file.name, size: file.size, overwrite: overwrite })
+        });
 
-            return;
+        var upload = await response.json();
+        var offset = upload.offset;
+        var failures = 0;
+
+        while (offset < file.size) {
+          var chunk = file.slice(offset, offset + chunkSize);
+          var headers = { "Content-Type": "application/offset+octet-stream", "Upload-Offset": String(offset) };
+          var sum = await checksum(chunk);
+
+          if (sum) {
+            headers["Upload-Checksum"] = sum;
           }
 
-          if (request.status >= 200 && request.status < 300) {
-            document.open();
-            document.write(request.responseText);
-            document.close();
+          try {
+            await request("PATCH", "/uploads/" + upload.id, { headers: headers, body: chunk });
+            failures = 0;
+          } catch (err) {
+            if (err.status === 403 || ++failures > maxRetries) {
+              throw err;
+            }
 
-            return;
+            await sleep(2000);
           }
 
-          uploadError.textContent = request.responseText || "Upload failed.";
-          uploadError.hidden = false;
-          button.disabled = false;
-        };
+          // Always get the offset from the server since failed chunks may have
+          // been partially received.
+          try {
+            var status = await request("HEAD", "/uploads/" + upload.id);
+            offset = parseInt(status.headers.get("Upload-Offset"), 10);
+          } catch (err) {
+            if (++failures > maxRetries) {
+              throw err;
+            }
+          }
+
+          onProgress(offset);
+        }
+      }
 
-        request.onerror = function() {
-          uploadError.textContent = "Upload failed.";
+      form.addEventListener("submit", async function(event) {
+        event.preventDefault();
+
+        var experiment = form.elements["experiment"].value;
+        var overwrite = form.elements["overwrite"].checked;
+        var files = Array.prototype.slice.call(form.elements["files"].files);
+        var total = files.reduce(function(sum, file) { return sum + file.size; }, 0);
+        var done = 0;
+
+        uploadError.hidden = true;
+        uploadError.textContent = "";
+        progress.hidden = false;
+        progressBar.value = 0;
+        progressText.textContent = "Preparing upload...";
+        button.disabled = true;
+
+        try {
+          for (var i = 0; i < files.length; i++) {
+            await uploadFile(experiment, files[i], overwrite, function(offset) {
+              var percent = total ? Math.round(((done + offset) / total) * 100) : 100;
+              progressBar.value = percent;
+              progressText.textContent = "Uploading " + files[i].name + " (" + percent + "%)";
+            });
+
+            done += files[i].size;
+          }
+
+          progressBar.value = 100;
+          progressText.textContent = "Uploaded " + files.length + " file(s) to " + experiment + ".";
+          form.reset();
+        } catch (err) {
+          uploadError.textContent = err.message || "Upload failed.";
           uploadError.hidden = false;
-          button.disabled = false;
-        };
+        }
 
-        request.open(form.method, form.action);
-        request.send(data);
+        button.disabled = false;
       });
     })();
   </script>
@@ -212,7 +281,11 @@ func StartFileServer(endpoint string, jwtKey, proxyAuthHeader string) {
 	authEnabled := jwtKey != ""
 	mux.HandleFunc("/", showFileServerIndex(authEnabled))
 	mux.HandleFunc("/login", fileServerLogin(proxyAuthHeader))
-	mux.HandleFunc("/upload", uploadExperimentFiles(authEnabled))
+	mux.Handle("GET /uploads", weberror.ErrorHandler(GetUploads))
+	mux.Handle("POST /uploads", weberror.ErrorHandler(CreateUpload))
+	mux.Handle("GET /uploads/{id}", weberror.ErrorHandler(GetUpload)) // also matches HEAD
+	mux.Handle("PATCH /uploads/{id}", weberror.ErrorHandler(PatchUpload))
+	mux.Handle("DELETE /uploads/{id}", weberror.ErrorHandler(DeleteUpload))
 
 	plog.Info(plog.TypeSystem, "starting file server", "endpoint", endpoint)
 	handler := fileServerCookieAuth(middleware.Auth(jwtKey, proxyAuthHeader)(mux), jwtKey)
@@ -445,99 +518,6 @@ func clearFileServerTokenCookie(w http.ResponseWriter) {
 	})
 }
 
-// uploadExperimentFiles stores posted files under the selected experiment files directory.
-func uploadExperimentFiles(authEnabled bool) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != http.MethodPost {
-			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
-
-			return
-		}
-
-		if err := r.ParseMultipartForm(maxFileServerUploadMemory); err != nil {
-			http.Error(w, "parsing upload: "+err.Error(), http.StatusBadRequest)
-
-			return
-		}
-
-		exp, err := experiment.Get(r.FormValue("experiment"))
-		if err != nil {
-			http.Error(w, "getting experiment: "+err.Error(), http.StatusBadRequest)
-
-			return
-		}
-
-		role := middleware.RoleFromContext(r.Context())
-		if !role.Allowed("experiments/files", "create", exp.Metadata.Name) {
-			http.Error(w, "forbidden", http.StatusForbidden)
-
-			return
-		}
-
-		files := r.MultipartForm.File["files"]
-		if len(files) == 0 {
-			http.Error(w, "no files uploaded", http.StatusBadRequest)
-
-			return
-		}
-
-		dir := exp.FilesDir()
-		if err := os.MkdirAll(dir, 0o750); err != nil {
-			http.Error(w, "creating experiment files directory: "+err.Error(), http.StatusInternalServerError)
-
-			return
-		}
-
-		var uploaded int
-		for _, header := range files {
-			name := filepath.Base(header.Filename)
-			if name == "" || name == "." {
-				http.Error(w, "invalid upload filename", http.StatusBadRequest)
-
-				return
-			}
-
-			clientFile, err := header.Open()
-			if err != nil {
-				http.Error(w, "opening upload: "+err.Error(), http.StatusInternalServerError)
-
-				return
-			}
-
-			target, err := os.OpenFile(filepath.Join(dir, name), os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o600)
-			if err != nil {
-				_ = clientFile.Close()
-				http.Error(w, "creating target file: "+err.Error(), http.StatusInternalServerError)
-
-				return
-			}
-
-			_, copyErr := io.Copy(target, clientFile)
-			closeClientErr := clientFile.Close()
-			closeTargetErr := target.Close()
-			if copyErr != nil {
-				http.Error(w, "saving upload: "+copyErr.Error(), http.StatusInternalServerError)
-
-				return
-			}
-			if closeClientErr != nil {
-				http.Error(w, "closing upload: "+closeClientErr.Error(), http.StatusInternalServerError)
-
-				return
-			}
-			if closeTargetErr != nil {
-				http.Error(w, "closing target file: "+closeTargetErr.Error(), http.StatusInternalServerError)
-
-				return
-			}
-
-			uploaded++
-		}
-
-		renderFileServerIndex(w, r, fmt.Sprintf("Uploaded %d file(s) to %s.", uploaded, exp.Metadata.Name), authEnabled)
-	}
-}
-
 // renderFileServerIndex writes the upload page with current experiment names.
 func renderFileServerIndex(w http.ResponseWriter, r *http.Request, message string, authEnabled bool) {
 	experiments, err := experiment.List()
@@ -30,6 +30,7 @@ import (
 	"phenix/api/experiment"
 	"phenix/api/scenario"
 	"phenix/api/settings"
+	"phenix/api/vlan"
 	"phenix/api/vm"
 	"phenix/app"
 	putil "phenix/util"
@@ -249,11 +250,20 @@ func CreateExperiment(w http.ResponseWriter, r *http.Request) {
 		experiment.CreateWithGREMesh(req.GetUseGreMesh()),
 	}
 
+	annotations := make(map[string]string)
+
+	// The role of the user creating the experiment is used to allocate a VLAN
+	// range from any VLAN pools reserved for the role.
+	if role.Spec != nil {
+		annotations[vlan.RoleAnnotation] = role.Spec.Name
+	}
+
 	if req.GetWorkflowBranch() != "" {
-		annotations := map[string]string{"phenix.workflow/branch": req.GetWorkflowBranch()}
-		opts = append(opts, experiment.CreateWithAnnotations(annotations))
+		annotations["phenix.workflow/branch"] = req.GetWorkflowBranch()
 	}
 
+	opts = append(opts, experiment.CreateWithAnnotations(annotations))
+
 	if err := experiment.Create(ctx, opts...); err != nil {
 		plog.Error(plog.TypeSystem, "creating experiment", "exp", req.GetName(), "err", err)
 		http.Error(w, err.Error(), http.StatusBadRequest)
@@ -586,7 +596,7 @@ func DeleteExperiment(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusNoContent)
 }
 
-// StartExperiment - POST /experiments/{name}/start.
+// StartExperiment - POST /experiments/{name}/start[?skipPreflight=<bool>].
 //
 
 func StartExperiment(w http.ResponseWriter, r *http.Request) error {
@@ -595,6 +605,8 @@ func StartExperiment(w http.ResponseWriter, r *http.Request) error {
 		role = middleware.RoleFromContext(ctx)
 		vars = mux.Vars(r)
 		name = vars["name"]
+
+		skipPreflight, _ = strconv.ParseBool(r.URL.Query().Get("skipPreflight"))
 	)
 
 	if !role.Allowed("experiments/start", "update", name) {
@@ -617,7 +629,7 @@ func StartExperiment(w http.ResponseWriter, r *http.Request) error {
 		return err.SetStatus(http.StatusForbidden)
 	}
 
-	body, err := startExperiment(name)
+	body, err := startExperiment(name, experiment.StartWithSkipPreflight(skipPreflight))
 	if err != nil {
 		return err
 	}
@@ -631,6 +643,8 @@ func StartExperiment(w http.ResponseWriter, r *http.Request) error {
 		user,
 		"exp",
 		name,
+		"skip-preflight",
+		skipPreflight,
 	)
 
 	return nil
@@ -1793,7 +1807,7 @@ func StartVM(w http.ResponseWriter, r *http.Request) {
 		nil,
 	)
 
-	if err := mm.StartVM(mm.NS(expName), mm.VMName(name)); err != nil {
+	if err := vm.Start(expName, name); err != nil {
 		broker.Broadcast(
 			bt.NewRequestPolicy("vms/start", "update", fullName),
 			bt.NewResource("experiment/vm", name, "errorStarting"),
@@ -1919,7 +1933,7 @@ func StopVM(w http.ResponseWriter, r *http.Request) {
 		nil,
 	)
 
-	if err := mm.StopVM(mm.NS(expName), mm.VMName(name)); err != nil {
+	if err := vm.Stop(expName, name); err != nil {
 		broker.Broadcast(
 			bt.NewRequestPolicy("vms/stop", "update", fullName),
 			bt.NewResource("experiment/vm", name, "errorStopping"),
@@ -2598,8 +2612,23 @@ func StartVMCapture(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := vm.StartCapture(exp, name, int(req.GetInterface()), req.GetFilename()); err != nil {
+	limits, err := parseCaptureLimits(body)
+	if err != nil {
+		plog.Error(plog.TypeSystem, "parsing capture limits", "err", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+
+		return
+	}
+
+	if err := vm.StartCapture(exp, name, int(req.GetInterface()), req.GetFilename(), limits); err != nil {
 		plog.Error(plog.TypeSystem, "starting capture for VM", "exp", exp, "vm", name, "err", err)
+
+		if errors.Is(err, mm.ErrInvalidCaptureLimits) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+
+			return
+		}
+
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 
 		return
@@ -2731,9 +2760,24 @@ func StartCaptureSubnet(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	vmCaptures, err := vm.CaptureSubnet(exp, req.GetSubnet(), req.GetVms())
+	limits, err := parseCaptureLimits(body)
+	if err != nil {
+		plog.Error(plog.TypeSystem, "parsing capture limits", "err", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+
+		return
+	}
+
+	vmCaptures, err := vm.CaptureSubnet(exp, req.GetSubnet(), req.GetVms(), limits)
 	if err != nil {
 		plog.Error(plog.TypeSystem, "unable to start subnet capture", "err", err)
+
+		if errors.Is(err, mm.ErrInvalidCaptureLimits) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+
+			return
+		}
+
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 
 		return
@@ -2986,7 +3030,9 @@ func SnapshotVM(w http.ResponseWriter, r *http.Request) {
 
 	cb := func(s string) { status <- s }
 
-	if err := vm.Snapshot(exp, name, req.GetFilename(), cb); err != nil {
+	creator := vm.SnapshotWithCreator(middleware.UserFromContext(ctx))
+
+	if err := vm.Snapshot(exp, name, req.GetFilename(), cb, creator); err != nil {
 		broker.Broadcast(
 			bt.NewRequestPolicy("vms/snapshots", "create", fullName),
 			bt.NewResource("experiment/vm/snapshot", exp+"/"+name, "errorCreating"),
@@ -3981,9 +4027,7 @@ func WsConsole(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		go func() { _, _ = io.Copy(ws, tty) }()
-
-		_, _ = io.Copy(tty, ws)
+		proxyWebSocket(ws, tty)
 
 		plog.Debug(plog.TypeSystem, "killing minimega console", "pid", pid)
 
@@ -3998,6 +4042,19 @@ func WsConsole(w http.ResponseWriter, r *http.Request) {
 	}).ServeHTTP(w, r)
 }
 
+// proxyWebSocket copies data between the given websocket and terminal (a pty
+// or serial console) until either side is closed.
+func proxyWebSocket(ws *websocket.Conn, term io.ReadWriter) {
+	go func() {
+		_, _ = io.Copy(ws, term)
+
+		// The terminal was closed, so disconnect the client.
+		_ = ws.Close()
+	}()
+
+	_, _ = io.Copy(term, ws)
+}
+
 // ChangeOpticalDisc - POST /experiments/{exp}/vms/{name}/cdrom.
 func ChangeOpticalDisc(w http.ResponseWriter, r *http.Request) {
 	var (
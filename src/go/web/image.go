@@ -0,0 +1,227 @@
+package web
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"golang.org/x/net/websocket"
+
+	"phenix/api/image"
+	"phenix/util/plog"
+	"phenix/web/middleware"
+	"phenix/web/util"
+	"phenix/web/weberror"
+)
+
+type buildImageRequest struct {
+	Verbosity int  `json:"verbosity"`
+	Cache     bool `json:"cache"`
+	Overwrite bool `json:"overwrite"`
+}
+
+// BuildImage - POST /images/{name}/build
+// The image is built in the background, saved to the minimega files directory
+// as <name>.qc2 (or with an extension for its format), and the build job is
+// returned. An existing image is only replaced if overwrite is requested.
+func BuildImage(w http.ResponseWriter, r *http.Request) error {
+	plog.Debug(plog.TypeSystem, "HTTP handler called", "handler", "BuildImage")
+
+	var (
+		name = mux.Vars(r)["name"]
+		role = middleware.RoleFromContext(r.Context())
+		user = middleware.UserFromContext(r.Context())
+		req  buildImageRequest
+	)
+
+	if !role.Allowed("images", "build", name) {
+		return imageForbidden(r, "building image", name)
+	}
+
+	// The request body is optional.
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			return weberror.NewWebError(err, "unable to parse request body").SetStatus(http.StatusBadRequest)
+		}
+	}
+
+	img, err := image.Get(name)
+	if err != nil {
+		return weberror.NewWebError(err, "image %s not found", name).SetStatus(http.StatusNotFound)
+	}
+
+	job, err := image.StartBuild(img, user, req.Verbosity, req.Cache, req.Overwrite)
+	if err != nil {
+		if errors.Is(err, image.ErrBuildRunning) || errors.Is(err, image.ErrImageExists) {
+			return weberror.NewWebError(err, "unable to build image %s", name).SetStatus(http.StatusConflict)
+		}
+
+		return weberror.NewWebError(err, "unable to build image %s", name)
+	}
+
+	plog.Info(plog.TypeAction, "image build started", "user", user, "image", name, "job", job.ID)
+
+	w.Header().Set("Location", "/api/v1/images/builds/"+job.ID)
+
+	body, _ := json.Marshal(job)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	_, _ = w.Write(body) //nolint:gosec // XSS via taint analysis
+
+	return nil
+}
+
+// GetImageBuilds - GET /images/builds.
+func GetImageBuilds(w http.ResponseWriter, r *http.Request) error {
+	plog.Debug(plog.TypeSystem, "HTTP handler called", "handler", "GetImageBuilds")
+
+	role := middleware.RoleFromContext(r.Context())
+
+	if !role.Allowed("images/builds", "list") {
+		return imageForbidden(r, "listing image builds", "")
+	}
+
+	var jobs []image.BuildJob
+
+	for _, job := range image.ListBuilds() {
+		if role.Allowed("images/builds", "list", job.Image) {
+			jobs = append(jobs, job)
+		}
+	}
+
+	body, _ := json.Marshal(util.WithRoot("builds", jobs))
+
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(body) //nolint:gosec // XSS via taint analysis
+
+	return nil
+}
+
+// GetImageBuild - GET /images/builds/{id}.
+func GetImageBuild(w http.ResponseWriter, r *http.Request) error {
+	plog.Debug(plog.TypeSystem, "HTTP handler called", "handler", "GetImageBuild")
+
+	job, err := getImageBuild(r)
+	if err != nil {
+		return err
+	}
+
+	role := middleware.RoleFromContext(r.Context())
+
+	if !role.Allowed("images/builds", "get", job.Image) {
+		return imageForbidden(r, "getting image build", job.Image)
+	}
+
+	body, _ := json.Marshal(job)
+
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(body) //nolint:gosec // XSS via taint analysis
+
+	return nil
+}
+
+// GetImageBuildWebSocket - GET /images/builds/{id}/ws
+// The build's output so far is sent, followed by its output as it's written.
+// The websocket is closed when the build finishes.
+func GetImageBuildWebSocket(w http.ResponseWriter, r *http.Request) error {
+	plog.Debug(plog.TypeSystem, "HTTP handler called", "handler", "GetImageBuildWebSocket")
+
+	job, err := getImageBuild(r)
+	if err != nil {
+		return err
+	}
+
+	role := middleware.RoleFromContext(r.Context())
+
+	if !role.Allowed("images/builds", "get", job.Image) {
+		return imageForbidden(r, "getting image build", job.Image)
+	}
+
+	scrollback, output, detach, err := image.AttachBuild(job.ID)
+	if err != nil {
+		return weberror.NewWebError(err, "build job %s not found", job.ID).SetStatus(http.StatusNotFound)
+	}
+
+	websocket.Handler(func(ws *websocket.Conn) {
+		defer detach()
+
+		// Detach when the client disconnects. Input from the client is ignored.
+		go func() {
+			buf := make([]byte, 512) //nolint:mnd // input is discarded
+
+			for {
+				if _, err := ws.Read(buf); err != nil {
+					detach()
+
+					return
+				}
+			}
+		}()
+
+		if _, err := ws.Write(scrollback); err != nil {
+			return
+		}
+
+		for data := range output {
+			if _, err := ws.Write(data); err != nil {
+				break
+			}
+		}
+
+		_ = ws.Close()
+	}).ServeHTTP(w, r)
+
+	return nil
+}
+
+// CancelImageBuild - DELETE /images/builds/{id}.
+func CancelImageBuild(w http.ResponseWriter, r *http.Request) error {
+	plog.Debug(plog.TypeSystem, "HTTP handler called", "handler", "CancelImageBuild")
+
+	job, err := getImageBuild(r)
+	if err != nil {
+		return err
+	}
+
+	role := middleware.RoleFromContext(r.Context())
+
+	if !role.Allowed("images/builds", "delete", job.Image) {
+		return imageForbidden(r, "canceling image build", job.Image)
+	}
+
+	if err := image.CancelBuild(job.ID); err != nil {
+		if errors.Is(err, image.ErrBuildFinished) {
+			return weberror.NewWebError(err, "unable to cancel build job %s", job.ID).SetStatus(http.StatusConflict)
+		}
+
+		return weberror.NewWebError(err, "unable to cancel build job %s", job.ID)
+	}
+
+	plog.Info(plog.TypeAction, "image build canceled", "user", middleware.UserFromContext(r.Context()), "image", job.Image, "job", job.ID)
+
+	w.WriteHeader(http.StatusNoContent)
+
+	return nil
+}
+
+// getImageBuild returns the build job with the ID in the request path.
+func getImageBuild(r *http.Request) (image.BuildJob, error) {
+	id := mux.Vars(r)["id"]
+
+	job, err := image.GetBuild(id)
+	if err != nil {
+		return job, weberror.NewWebError(err, "build job %s not found", id).SetStatus(http.StatusNotFound)
+	}
+
+	return job, nil
+}
+
+func imageForbidden(r *http.Request, action, name string) error {
+	user := middleware.UserFromContext(r.Context())
+
+	plog.Warn(plog.TypeSecurity, action+" not allowed", "user", user, "image", name)
+
+	return weberror.NewWebError(nil, "%s %s not allowed for %s", action, name, user).SetStatus(http.StatusForbidden)
+}
@@ -0,0 +1,233 @@
+package web
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"phenix/api/vm"
+	"phenix/util/mm"
+	"phenix/util/plog"
+	"phenix/web/broker"
+	bt "phenix/web/broker/brokertypes"
+	"phenix/web/middleware"
+	"phenix/web/util"
+	"phenix/web/weberror"
+)
+
+type impairment struct {
+	Interface  int     `json:"interface"`
+	Delay      string  `json:"delay,omitempty"`
+	Jitter     string  `json:"jitter,omitempty"`
+	Loss       float64 `json:"loss,omitempty"`
+	Corruption float64 `json:"corruption,omitempty"`
+	Reordering float64 `json:"reordering,omitempty"`
+	Rate       string  `json:"rate,omitempty"`
+}
+
+func (i impairment) impairment() (mm.Impairment, error) {
+	imp := mm.Impairment{ //nolint:exhaustruct // partial initialization
+		Loss:       i.Loss,
+		Corruption: i.Corruption,
+		Reordering: i.Reordering,
+		Rate:       i.Rate,
+	}
+
+	if i.Delay != "" {
+		delay, err := time.ParseDuration(i.Delay)
+		if err != nil {
+			return imp, fmt.Errorf("invalid delay %s: %w", i.Delay, err)
+		}
+
+		imp.Delay = delay
+	}
+
+	if i.Jitter != "" {
+		jitter, err := time.ParseDuration(i.Jitter)
+		if err != nil {
+			return imp, fmt.Errorf("invalid jitter %s: %w", i.Jitter, err)
+		}
+
+		imp.Jitter = jitter
+	}
+
+	return imp, nil
+}
+
+// GetVMImpairments - GET /experiments/{exp}/vms/{name}/interfaces/impairment.
+func GetVMImpairments(w http.ResponseWriter, r *http.Request) error {
+	plog.Debug(plog.TypeSystem, "HTTP handler called", "handler", "GetVMImpairments")
+
+	var (
+		ctx      = r.Context()
+		role     = middleware.RoleFromContext(ctx)
+		vars     = mux.Vars(r)
+		exp      = vars["exp"]
+		name     = vars["name"]
+		fullName = fmt.Sprintf("%s/%s", exp, name)
+	)
+
+	if !role.Allowed("vms/impair", "get", fullName) {
+		err := weberror.NewWebError(nil, "getting link impairment for VM %s not allowed", fullName)
+
+		return err.SetStatus(http.StatusForbidden)
+	}
+
+	impairments, err := vm.Impairments(exp)
+	if err != nil {
+		return weberror.NewWebError(err, "unable to get link impairment for VM %s", fullName)
+	}
+
+	resp := []impairment{}
+
+	for iface, imp := range impairments[name] {
+		i := impairment{ //nolint:exhaustruct // partial initialization
+			Interface:  iface,
+			Loss:       imp.Loss,
+			Corruption: imp.Corruption,
+			Reordering: imp.Reordering,
+			Rate:       imp.Rate,
+		}
+
+		if imp.Delay > 0 {
+			i.Delay = imp.Delay.String()
+		}
+
+		if imp.Jitter > 0 {
+			i.Jitter = imp.Jitter.String()
+		}
+
+		resp = append(resp, i)
+	}
+
+	body, _ := json.Marshal(util.WithRoot("impairments", resp))
+
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(body) //nolint:gosec // XSS via taint analysis
+
+	return nil
+}
+
+// ImpairVMInterface - PUT /experiments/{exp}/vms/{name}/interfaces/{iface}/impairment.
+func ImpairVMInterface(w http.ResponseWriter, r *http.Request) error {
+	plog.Debug(plog.TypeSystem, "HTTP handler called", "handler", "ImpairVMInterface")
+
+	var (
+		ctx      = r.Context()
+		role     = middleware.RoleFromContext(ctx)
+		user     = middleware.UserFromContext(ctx)
+		vars     = mux.Vars(r)
+		exp      = vars["exp"]
+		name     = vars["name"]
+		fullName = fmt.Sprintf("%s/%s", exp, name)
+	)
+
+	if !role.Allowed("vms/impair", "update", fullName) {
+		plog.Warn(plog.TypeSecurity, "impairing vm interface not allowed", "user", user, "exp", exp, "vm", name)
+		err := weberror.NewWebError(nil, "impairing interface for VM %s not allowed for %s", fullName, user)
+
+		return err.SetStatus(http.StatusForbidden)
+	}
+
+	iface, err := strconv.Atoi(vars["iface"])
+	if err != nil {
+		return weberror.NewWebError(err, "interface index must be an integer").
+			SetStatus(http.StatusBadRequest)
+	}
+
+	var req impairment
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return weberror.NewWebError(err, "unable to parse request body").
+			SetStatus(http.StatusBadRequest)
+	}
+
+	imp, err := req.impairment()
+	if err != nil {
+		return weberror.NewWebError(err, "%s", err.Error()).SetStatus(http.StatusBadRequest)
+	}
+
+	if err := vm.Impair(exp, name, iface, imp); err != nil {
+		if errors.Is(err, mm.ErrInvalidImpairment) {
+			return weberror.NewWebError(err, "%s", err.Error()).SetStatus(http.StatusBadRequest)
+		}
+
+		return weberror.NewWebError(err, "unable to impair interface %d for VM %s", iface, fullName)
+	}
+
+	broadcastImpairment(fullName, iface, "update")
+
+	plog.Info(
+		plog.TypeAction,
+		"vm interface impaired",
+		"user",
+		user,
+		"exp",
+		exp,
+		"vm",
+		name,
+		"iface",
+		iface,
+		"impairment",
+		imp.String(),
+	)
+
+	w.WriteHeader(http.StatusNoContent)
+
+	return nil
+}
+
+// ClearVMInterfaceImpairment - DELETE /experiments/{exp}/vms/{name}/interfaces/{iface}/impairment.
+func ClearVMInterfaceImpairment(w http.ResponseWriter, r *http.Request) error {
+	plog.Debug(plog.TypeSystem, "HTTP handler called", "handler", "ClearVMInterfaceImpairment")
+
+	var (
+		ctx      = r.Context()
+		role     = middleware.RoleFromContext(ctx)
+		user     = middleware.UserFromContext(ctx)
+		vars     = mux.Vars(r)
+		exp      = vars["exp"]
+		name     = vars["name"]
+		fullName = fmt.Sprintf("%s/%s", exp, name)
+	)
+
+	if !role.Allowed("vms/impair", "delete", fullName) {
+		plog.Warn(plog.TypeSecurity, "clearing vm interface impairment not allowed", "user", user, "exp", exp, "vm", name)
+		err := weberror.NewWebError(nil, "clearing interface impairment for VM %s not allowed for %s", fullName, user)
+
+		return err.SetStatus(http.StatusForbidden)
+	}
+
+	iface, err := strconv.Atoi(vars["iface"])
+	if err != nil {
+		return weberror.NewWebError(err, "interface index must be an integer").
+			SetStatus(http.StatusBadRequest)
+	}
+
+	if err := vm.ClearImpairment(exp, name, iface); err != nil {
+		return weberror.NewWebError(err, "unable to clear impairment for interface %d of VM %s", iface, fullName)
+	}
+
+	broadcastImpairment(fullName, iface, "delete")
+
+	plog.Info(plog.TypeAction, "vm interface impairment cleared", "user", user, "exp", exp, "vm", name, "iface", iface)
+
+	w.WriteHeader(http.StatusNoContent)
+
+	return nil
+}
+
+func broadcastImpairment(fullName string, iface int, action string) {
+	body, _ := json.Marshal(map[string]any{"interface": iface})
+
+	broker.Broadcast(
+		bt.NewRequestPolicy("vms/impair", "get", fullName),
+		bt.NewResource("experiment/vm/impairment", fullName, action),
+		body,
+	)
+}
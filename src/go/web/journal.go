@@ -0,0 +1,193 @@
+package web
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"phenix/api/experiment"
+	"phenix/util/plog"
+	"phenix/web/broker"
+	bt "phenix/web/broker/brokertypes"
+	"phenix/web/middleware"
+	"phenix/web/util"
+	"phenix/web/weberror"
+)
+
+// GetExperimentJournal - GET /experiments/{name}/journal[?since=<RFC3339>][&format=json|csv].
+func GetExperimentJournal(w http.ResponseWriter, r *http.Request) error {
+	plog.Debug(plog.TypeSystem, "HTTP handler called", "handler", "GetExperimentJournal")
+
+	var (
+		ctx  = r.Context()
+		role = middleware.RoleFromContext(ctx)
+		name = mux.Vars(r)["name"]
+
+		query  = r.URL.Query()
+		format = strings.ToLower(query.Get("format"))
+	)
+
+	if !role.Allowed("experiments/journal", "list", name) {
+		user := middleware.UserFromContext(ctx)
+		plog.Warn(
+			plog.TypeSecurity,
+			"getting experiment journal not allowed",
+			"user",
+			user,
+			"exp",
+			name,
+		)
+		err := weberror.NewWebError(
+			nil,
+			"getting experiment journal for %s not allowed for %s",
+			name,
+			user,
+		)
+
+		return err.SetStatus(http.StatusForbidden)
+	}
+
+	var since time.Time
+
+	if s := query.Get("since"); s != "" {
+		var err error
+
+		since, err = time.Parse(time.RFC3339, s)
+		if err != nil {
+			return weberror.NewWebError(err, "invalid since timestamp %s", s).
+				SetStatus(http.StatusBadRequest)
+		}
+	}
+
+	entries, err := experiment.Journal(name, since)
+	if err != nil {
+		return weberror.NewWebError(err, "unable to get journal for experiment %s", name)
+	}
+
+	switch format {
+	case "csv":
+		var buf bytes.Buffer
+
+		if err := experiment.ExportJournal(&buf, entries, format); err != nil {
+			return weberror.NewWebError(err, "unable to export journal for experiment %s", name)
+		}
+
+		w.Header().Set("Content-Type", "text/csv")
+		w.Header().Set(
+			"Content-Disposition",
+			fmt.Sprintf("attachment; filename=%s-journal.csv", name),
+		)
+
+		_, _ = w.Write(buf.Bytes())
+	case "", "json":
+		body, err := json.Marshal(util.WithRoot("entries", entries))
+		if err != nil {
+			return weberror.NewWebError(err, "unable to encode journal for experiment %s", name)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(body) //nolint:gosec // XSS via taint analysis
+	default:
+		return weberror.NewWebError(experiment.ErrInvalidJournalFormat, "unsupported journal format %s", format).
+			SetStatus(http.StatusBadRequest)
+	}
+
+	return nil
+}
+
+// AddExperimentJournalEntry - POST /experiments/{name}/journal.
+func AddExperimentJournalEntry(w http.ResponseWriter, r *http.Request) error {
+	plog.Debug(plog.TypeSystem, "HTTP handler called", "handler", "AddExperimentJournalEntry")
+
+	var (
+		ctx  = r.Context()
+		role = middleware.RoleFromContext(ctx)
+		user = middleware.UserFromContext(ctx)
+		name = mux.Vars(r)["name"]
+	)
+
+	if !role.Allowed("experiments/journal", "create", name) {
+		plog.Warn(
+			plog.TypeSecurity,
+			"adding experiment journal entry not allowed",
+			"user",
+			user,
+			"exp",
+			name,
+		)
+		err := weberror.NewWebError(
+			nil,
+			"adding journal entry to experiment %s not allowed for %s",
+			name,
+			user,
+		)
+
+		return err.SetStatus(http.StatusForbidden)
+	}
+
+	var req struct {
+		Source  string         `json:"source"`
+		VM      string         `json:"vm"`
+		Message string         `json:"message"`
+		Data    map[string]any `json:"data"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return weberror.NewWebError(err, "unable to parse request body").
+			SetStatus(http.StatusBadRequest)
+	}
+
+	// Only users and apps can add entries via the API. System entries are
+	// reserved for events recorded by phenix itself.
+	switch req.Source {
+	case "", experiment.JournalSourceUser, experiment.JournalSourceApp:
+	default:
+		err := errors.New("invalid journal entry source")
+
+		return weberror.NewWebError(err, "invalid journal entry source %s", req.Source).
+			SetStatus(http.StatusBadRequest)
+	}
+
+	entry := experiment.JournalEntry{ //nolint:exhaustruct // partial initialization
+		Source:  req.Source,
+		Author:  user,
+		VM:      req.VM,
+		Message: req.Message,
+		Data:    req.Data,
+	}
+
+	entry, err := experiment.AddJournalEntry(name, entry)
+	if err != nil {
+		return weberror.NewWebError(err, "unable to add journal entry to experiment %s", name).
+			SetStatus(http.StatusBadRequest)
+	}
+
+	body, _ := json.Marshal(entry)
+
+	broker.Broadcast(
+		bt.NewRequestPolicy("experiments/journal", "list", name),
+		bt.NewResource("experiment/journal", name, "create"),
+		body,
+	)
+
+	plog.Info(
+		plog.TypeAction,
+		"experiment journal entry added",
+		"user",
+		user,
+		"exp",
+		name,
+	)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_, _ = w.Write(body) //nolint:gosec // XSS via taint analysis
+
+	return nil
+}
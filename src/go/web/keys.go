@@ -0,0 +1,86 @@
+package web
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"phenix/api/vm"
+	"phenix/util/mm"
+	"phenix/util/plog"
+	"phenix/web/middleware"
+	"phenix/web/weberror"
+)
+
+type keysRequest struct {
+	Text  string `json:"text"`
+	Keys  string `json:"keys"`
+	Delay string `json:"delay"`
+}
+
+func (r keysRequest) options() ([]vm.SendKeysOption, error) {
+	opts := []vm.SendKeysOption{vm.SendKeysWithText(r.Text), vm.SendKeysWithChords(r.Keys)}
+
+	if r.Delay != "" {
+		delay, err := time.ParseDuration(r.Delay)
+		if err != nil {
+			return nil, fmt.Errorf("invalid delay %s: %w", r.Delay, err)
+		}
+
+		opts = append(opts, vm.SendKeysWithDelay(delay))
+	}
+
+	return opts, nil
+}
+
+// SendVMKeys - POST /experiments/{exp}/vms/{name}/keys.
+func SendVMKeys(w http.ResponseWriter, r *http.Request) error {
+	plog.Debug(plog.TypeSystem, "HTTP handler called", "handler", "SendVMKeys")
+
+	var (
+		ctx      = r.Context()
+		role     = middleware.RoleFromContext(ctx)
+		user     = middleware.UserFromContext(ctx)
+		vars     = mux.Vars(r)
+		exp      = vars["exp"]
+		name     = vars["name"]
+		fullName = fmt.Sprintf("%s/%s", exp, name)
+	)
+
+	if !role.Allowed("vms/keys", "create", fullName) {
+		plog.Warn(plog.TypeSecurity, "sending keys to vm not allowed", "user", user, "exp", exp, "vm", name)
+		err := weberror.NewWebError(nil, "sending keys to VM %s not allowed for %s", fullName, user)
+
+		return err.SetStatus(http.StatusForbidden)
+	}
+
+	var req keysRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return weberror.NewWebError(err, "unable to parse request body").
+			SetStatus(http.StatusBadRequest)
+	}
+
+	opts, err := req.options()
+	if err != nil {
+		return weberror.NewWebError(err, "%s", err.Error()).SetStatus(http.StatusBadRequest)
+	}
+
+	if err := vm.SendKeys(exp, name, opts...); err != nil {
+		if errors.Is(err, mm.ErrInvalidKeys) {
+			return weberror.NewWebError(err, "%s", err.Error()).SetStatus(http.StatusBadRequest)
+		}
+
+		return weberror.NewWebError(err, "unable to send keys to VM %s", fullName)
+	}
+
+	plog.Info(plog.TypeAction, "keys sent to vm", "user", user, "exp", exp, "vm", name, "keys", req.Keys)
+
+	w.WriteHeader(http.StatusNoContent)
+
+	return nil
+}
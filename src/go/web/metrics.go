@@ -0,0 +1,86 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"phenix/api/vm"
+	"phenix/util/mm"
+	"phenix/util/plog"
+	"phenix/web/broker"
+	bt "phenix/web/broker/brokertypes"
+	"phenix/web/middleware"
+	"phenix/web/util"
+	"phenix/web/weberror"
+)
+
+// GetVMMetrics - GET /experiments/{exp}/vms/{name}/metrics[?since=<RFC3339>].
+func GetVMMetrics(w http.ResponseWriter, r *http.Request) error {
+	plog.Debug(plog.TypeSystem, "HTTP handler called", "handler", "GetVMMetrics")
+
+	var (
+		ctx      = r.Context()
+		role     = middleware.RoleFromContext(ctx)
+		vars     = mux.Vars(r)
+		exp      = vars["exp"]
+		name     = vars["name"]
+		fullName = fmt.Sprintf("%s/%s", exp, name)
+	)
+
+	if !role.Allowed("vms/metrics", "get", fullName) {
+		user := middleware.UserFromContext(ctx)
+		plog.Warn(plog.TypeSecurity, "getting vm metrics not allowed", "user", user, "exp", exp, "vm", name)
+		err := weberror.NewWebError(nil, "getting metrics for VM %s not allowed for %s", fullName, user)
+
+		return err.SetStatus(http.StatusForbidden)
+	}
+
+	var since time.Time
+
+	if s := r.URL.Query().Get("since"); s != "" {
+		var err error
+
+		since, err = time.Parse(time.RFC3339, s)
+		if err != nil {
+			return weberror.NewWebError(err, "invalid since timestamp %s", s).
+				SetStatus(http.StatusBadRequest)
+		}
+	}
+
+	metrics := vm.MetricsHistory(exp, name, since)
+	if metrics == nil {
+		metrics = []mm.VMMetrics{}
+	}
+
+	body, _ := json.Marshal(util.WithRoot("metrics", metrics))
+
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(body) //nolint:gosec // XSS via taint analysis
+
+	return nil
+}
+
+// publishVMMetrics publishes the latest metrics collected for each VM in the
+// given experiment to clients allowed to get them.
+func publishVMMetrics(exp string, metrics []mm.VMMetrics) {
+	for _, m := range metrics {
+		fullName := fmt.Sprintf("%s/%s", exp, m.VM)
+
+		body, err := json.Marshal(m)
+		if err != nil {
+			plog.Error(plog.TypeSystem, "marshaling vm metrics", "exp", exp, "vm", m.VM, "err", err)
+
+			continue
+		}
+
+		broker.Broadcast(
+			bt.NewRequestPolicy("vms/metrics", "get", fullName),
+			bt.NewResource("experiment/vm/metrics", fullName, "update"),
+			body,
+		)
+	}
+}
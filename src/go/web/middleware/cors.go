@@ -6,8 +6,8 @@ import (
 
 const (
 	origins = "*"
-	methods = "GET, POST, PUT, PATCH, DELETE, OPTIONS"
-	headers = "Accept, Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization"
+	methods = "GET, HEAD, POST, PUT, PATCH, DELETE, OPTIONS"
+	headers = "Accept, Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, Upload-Offset, Upload-Checksum"
 )
 
 func AllowCORS(next http.Handler) http.Handler {
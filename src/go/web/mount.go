@@ -16,7 +16,9 @@ import (
 
 	"github.com/gorilla/mux"
 
+	"phenix/api/disk"
 	"phenix/api/experiment"
+	"phenix/api/vm"
 	"phenix/util/file"
 	"phenix/util/mm"
 	"phenix/util/plog"
@@ -34,6 +36,9 @@ const (
 type MountInfo struct {
 	users int
 	lock  *sync.RWMutex
+	// offline mounts are read-only mounts of a disk image on the headnode
+	// rather than mounts of a running VM's filesystem via miniccc.
+	offline bool
 }
 
 var (
@@ -43,7 +48,9 @@ var (
 	activeMountsMu sync.RWMutex //nolint:gochecknoglobals // global lock
 )
 
-// MountVM - POST /experiments/{exp}/vms/{name}/mount.
+// MountVM - POST /experiments/{exp}/vms/{name}/mount[?offline=true&partition={partition}].
+// Offline mounts are read-only mounts of a stopped VM's disk on the headnode and
+// don't require miniccc.
 func MountVM(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	role, _ := r.Context().Value(middleware.ContextKeyRole).(rbac.Role)
@@ -82,6 +89,46 @@ func MountVM(w http.ResponseWriter, r *http.Request) {
 		)
 
 		mountInfo.users += 1
+	} else if offline, _ := strconv.ParseBool(r.URL.Query().Get("offline")); offline {
+		partition, _ := strconv.Atoi(r.URL.Query().Get("partition"))
+
+		path, err := vm.OfflineDisk(vars["exp"], vars["name"])
+		if err != nil {
+			status := http.StatusInternalServerError
+			if errors.Is(err, vm.ErrVMRunning) {
+				status = http.StatusBadRequest
+			}
+
+			plog.Error(plog.TypeSystem, "getting disk for offline mount", "mount", mapKey, "err", err)
+			http.Error(w, fmt.Sprintf("Error mounting: %s", err), status)
+
+			return
+		}
+
+		if err := disk.MountDisk(path, mapKey, partition); err != nil {
+			plog.Error(plog.TypeSystem, "creating offline mount", "mount", mapKey, "disk", path, "err", err)
+			http.Error(w, fmt.Sprintf("Error mounting: %s", err), http.StatusInternalServerError)
+
+			return
+		}
+
+		user, _ := r.Context().Value(middleware.ContextKeyUser).(string)
+		plog.Info(
+			plog.TypeAction,
+			"vm mounted offline",
+			"exp",
+			vars["exp"],
+			"vm",
+			vars["name"],
+			"disk",
+			path,
+			"path",
+			mapKey,
+			"user",
+			user,
+		)
+
+		activeMounts[mapKey] = &MountInfo{users: 1, lock: &sync.RWMutex{}, offline: true}
 	} else {
 		user, _ := r.Context().Value(middleware.ContextKeyUser).(string)
 		plog.Info(
@@ -113,7 +160,7 @@ func MountVM(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		activeMounts[mapKey] = &MountInfo{1, &sync.RWMutex{}}
+		activeMounts[mapKey] = &MountInfo{users: 1, lock: &sync.RWMutex{}, offline: false}
 	}
 
 	w.WriteHeader(http.StatusOK)
@@ -160,13 +207,20 @@ func UnmountVM(w http.ResponseWriter, r *http.Request) {
 
 			mountInfo.lock.Lock()
 
-			_, err := mm.ExecC2Command(
-				mm.C2NS(vars["exp"]),
-				mm.C2VM(vars["name"]),
-				mm.C2Unmount(),
-				mm.C2Timeout(MountTimeout),
-				mm.C2SkipActiveClientCheck(true),
-			)
+			var err error
+
+			if mountInfo.offline {
+				err = disk.UnmountDisk(mapKey)
+			} else {
+				_, err = mm.ExecC2Command(
+					mm.C2NS(vars["exp"]),
+					mm.C2VM(vars["name"]),
+					mm.C2Unmount(),
+					mm.C2Timeout(MountTimeout),
+					mm.C2SkipActiveClientCheck(true),
+				)
+			}
+
 			if err != nil {
 				mountInfo.lock.Unlock()
 
@@ -208,8 +262,6 @@ func UnmountVM(w http.ResponseWriter, r *http.Request) {
 
 // GetMountFiles - GET /experiments/{exp}/vms/{name}/mount/files?path=
 // Note: error may be returned inside json body as Readdir can return an error with entries.
-//
-//nolint:funlen // handler
 func GetMountFiles(w http.ResponseWriter, r *http.Request) {
 	var (
 		vars     = mux.Vars(r)
@@ -232,6 +284,16 @@ func GetMountFiles(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	listMountFiles(w, r, basePath)
+}
+
+// listMountFiles writes the files in the requested directory of the mount at
+// the given base path.
+//
+//nolint:funlen // handler
+func listMountFiles(w http.ResponseWriter, r *http.Request, basePath string) {
+	vars := mux.Vars(r)
+
 	activeMountsMu.RLock()
 
 	mountInfo, exists := activeMounts[basePath]
@@ -383,6 +445,14 @@ func DownloadMountFile(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	downloadMountFile(w, r, basePath)
+}
+
+// downloadMountFile serves the requested file from the mount at the given base
+// path.
+func downloadMountFile(w http.ResponseWriter, r *http.Request, basePath string) {
+	vars := mux.Vars(r)
+
 	activeMountsMu.RLock()
 
 	mountInfo, exists := activeMounts[basePath]
@@ -488,6 +558,12 @@ func UploadMountFile(w http.ResponseWriter, r *http.Request) {
 	mountInfo.lock.RLock()
 	defer mountInfo.lock.RUnlock()
 
+	if mountInfo.offline {
+		http.Error(w, "Offline mount for "+basePath+" is read-only", http.StatusBadRequest)
+
+		return
+	}
+
 	combinedPath := filepath.Join(basePath, vars["path"])
 	//nolint:gosec // Path traversal via taint analysis
 	if _, err := os.Stat(combinedPath); err != nil {
@@ -590,6 +666,12 @@ func CopyExperimentFileToMount(w http.ResponseWriter, r *http.Request) {
 	mountInfo.lock.RLock()
 	defer mountInfo.lock.RUnlock()
 
+	if mountInfo.offline {
+		http.Error(w, "Offline mount for "+basePath+" is read-only", http.StatusBadRequest)
+
+		return
+	}
+
 	query := r.URL.Query()
 	destDir := filepath.Join(basePath, query.Get("path"))
 	if err := validatePathWithin(destDir, basePath); err != nil {
@@ -660,6 +742,177 @@ func CopyExperimentFileToMount(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// MountDiskImage - POST /disks/mount?disk={disk}[&partition={partition}].
+func MountDiskImage(w http.ResponseWriter, r *http.Request) {
+	var (
+		role, _ = r.Context().Value(middleware.ContextKeyRole).(rbac.Role)
+		user, _ = r.Context().Value(middleware.ContextKeyUser).(string)
+	)
+
+	path, rel, err := resolveDiskPath(mux.Vars(r)["disk"])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+
+		return
+	}
+
+	if !role.Allowed("disks/mount", "post", rel) {
+		plog.Warn(plog.TypeSecurity, "mounting disk not allowed", "user", user, "disk", path)
+		http.Error(w, "forbidden", http.StatusForbidden)
+
+		return
+	}
+
+	mapKey := disk.GetLocalMountPath(path)
+
+	activeMountsMu.Lock()
+	defer activeMountsMu.Unlock()
+
+	if mountInfo, exists := activeMounts[mapKey]; exists {
+		mountInfo.users += 1
+	} else {
+		partition, _ := strconv.Atoi(r.URL.Query().Get("partition"))
+
+		if err := disk.MountDisk(path, mapKey, partition); err != nil {
+			plog.Error(plog.TypeSystem, "creating disk mount", "disk", path, "err", err)
+			http.Error(w, fmt.Sprintf("Error mounting: %s", err), http.StatusInternalServerError)
+
+			return
+		}
+
+		activeMounts[mapKey] = &MountInfo{users: 1, lock: &sync.RWMutex{}, offline: true}
+	}
+
+	plog.Info(plog.TypeAction, "disk mounted", "disk", path, "path", mapKey, "user", user)
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// UnmountDiskImage - DELETE /disks/mount?disk={disk}.
+func UnmountDiskImage(w http.ResponseWriter, r *http.Request) {
+	var (
+		role, _ = r.Context().Value(middleware.ContextKeyRole).(rbac.Role)
+		user, _ = r.Context().Value(middleware.ContextKeyUser).(string)
+	)
+
+	path, rel, err := resolveDiskPath(mux.Vars(r)["disk"])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+
+		return
+	}
+
+	if !role.Allowed("disks/mount", "delete", rel) {
+		plog.Warn(plog.TypeSecurity, "unmounting disk not allowed", "user", user, "disk", path)
+		http.Error(w, "forbidden", http.StatusForbidden)
+
+		return
+	}
+
+	mapKey := disk.GetLocalMountPath(path)
+
+	activeMountsMu.Lock()
+	defer activeMountsMu.Unlock()
+
+	mountInfo, exists := activeMounts[mapKey]
+	if !exists {
+		plog.Warn(plog.TypeSystem, "tried to unmount disk that was not mounted", "disk", path)
+		w.WriteHeader(http.StatusOK)
+
+		return
+	}
+
+	mountInfo.users -= 1
+
+	if mountInfo.users == 0 {
+		mountInfo.lock.Lock()
+
+		if err := disk.UnmountDisk(mapKey); err != nil {
+			mountInfo.lock.Unlock()
+
+			plog.Error(plog.TypeSystem, "unmounting disk", "disk", path, "err", err)
+			http.Error(w, fmt.Sprintf("Error unmounting: %s", err), http.StatusInternalServerError)
+
+			return
+		}
+
+		delete(activeMounts, mapKey)
+
+		plog.Info(plog.TypeAction, "disk unmounted", "disk", path, "user", user)
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// GetDiskMountFiles - GET /disks/files?disk={disk}&path={path}.
+func GetDiskMountFiles(w http.ResponseWriter, r *http.Request) {
+	var (
+		role, _ = r.Context().Value(middleware.ContextKeyRole).(rbac.Role)
+	)
+
+	path, rel, err := resolveDiskPath(mux.Vars(r)["disk"])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+
+		return
+	}
+
+	if !role.Allowed("disks/mount", "list", rel) {
+		user, _ := r.Context().Value(middleware.ContextKeyUser).(string)
+		plog.Warn(plog.TypeSecurity, "getting disk mount files not allowed", "user", user, "disk", path)
+		http.Error(w, "forbidden", http.StatusForbidden)
+
+		return
+	}
+
+	listMountFiles(w, r, disk.GetLocalMountPath(path))
+}
+
+// DownloadDiskMountFile - GET /disks/files/download?disk={disk}&path={path}.
+func DownloadDiskMountFile(w http.ResponseWriter, r *http.Request) {
+	var (
+		role, _ = r.Context().Value(middleware.ContextKeyRole).(rbac.Role)
+	)
+
+	path, rel, err := resolveDiskPath(mux.Vars(r)["disk"])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+
+		return
+	}
+
+	if !role.Allowed("disks/mount", "get", rel) {
+		user, _ := r.Context().Value(middleware.ContextKeyUser).(string)
+		plog.Warn(plog.TypeSecurity, "downloading disk mount files not allowed", "user", user, "disk", path)
+		http.Error(w, "forbidden", http.StatusForbidden)
+
+		return
+	}
+
+	downloadMountFile(w, r, disk.GetLocalMountPath(path))
+}
+
+// resolveDiskPath resolves the given disk image against the minimega files
+// directory, returning its full path and its path relative to the files
+// directory. Disk images outside the files directory are rejected.
+func resolveDiskPath(image string) (string, string, error) {
+	if image == "" {
+		return "", "", errors.New("no disk provided")
+	}
+
+	var (
+		base = filepath.Clean(mm.GetMMFilesDirectory())
+		path = filepath.Clean(mm.GetMMFullPath(image))
+	)
+
+	rel, err := filepath.Rel(base, path)
+	if err != nil || rel == "." || validatePathWithin(path, base) != nil {
+		return "", "", fmt.Errorf("disk %s is not within the minimega files directory", image)
+	}
+
+	return path, rel, nil
+}
+
 // getExperimentFilePath returns a source path only when it matches a known experiment file.
 func getExperimentFilePath(expName, source, filesDir string) (string, error) {
 	if source == "" {
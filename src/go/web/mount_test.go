@@ -0,0 +1,48 @@
+package web
+
+import (
+	"path/filepath"
+	"testing"
+
+	"phenix/util/mm"
+)
+
+func TestResolveDiskPath(t *testing.T) {
+	base := mm.GetMMFilesDirectory()
+
+	tests := []struct {
+		name    string
+		disk    string
+		rel     string
+		wantErr bool
+	}{
+		{name: "relative", disk: "base.qc2", rel: "base.qc2"},
+		{name: "nested", disk: "foo/../bar/base.qc2", rel: "bar/base.qc2"},
+		{name: "absolute within", disk: filepath.Join(base, "base.qc2"), rel: "base.qc2"},
+		{name: "empty", disk: "", wantErr: true},
+		{name: "files directory", disk: base, wantErr: true},
+		{name: "parent", disk: "../base.qc2", wantErr: true},
+		{name: "absolute outside", disk: "/etc/shadow", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path, rel, err := resolveDiskPath(tt.disk)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected error, got %s", path)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if rel != tt.rel || path != filepath.Join(base, tt.rel) {
+				t.Fatalf("expected %s, got %s (%s)", tt.rel, rel, path)
+			}
+		})
+	}
+}
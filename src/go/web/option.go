@@ -7,6 +7,7 @@ import (
 	"strings"
 	"time"
 
+	"phenix/api/vm"
 	"phenix/util/common"
 	"phenix/util/plog"
 	"phenix/web/middleware"
@@ -42,6 +43,14 @@ type serverOptions struct {
 	features map[string]bool
 
 	unixSocketGID int
+
+	metricsInterval  time.Duration
+	metricsRetention time.Duration
+
+	vncRecording bool
+
+	serialScrollback int
+	serialLogging    bool
 }
 
 func newServerOptions(opts ...ServerOption) serverOptions {
@@ -51,6 +60,11 @@ func newServerOptions(opts ...ServerOption) serverOptions {
 		basePath:    "/",
 		jwtLifetime: defaultJWTLifetime,
 		features:    make(map[string]bool),
+
+		metricsInterval:  vm.DefaultMetricsInterval,
+		metricsRetention: vm.DefaultMetricsRetention,
+
+		serialScrollback: vm.DefaultSerialScrollback,
 	}
 
 	for _, opt := range opts {
@@ -194,6 +208,39 @@ func ServeWithUnixSocketGID(g int) ServerOption {
 	}
 }
 
+// ServeWithVMMetrics sets how often VM metrics are collected and how long they
+// are kept. Collection is disabled if the interval is not positive.
+func ServeWithVMMetrics(interval, retention time.Duration) ServerOption {
+	return func(o *serverOptions) {
+		o.metricsInterval = interval
+
+		if retention > 0 {
+			o.metricsRetention = retention
+		}
+	}
+}
+
+// ServeWithVNCRecording enables recording of every VNC session to the
+// experiment's files directory.
+func ServeWithVNCRecording(r bool) ServerOption {
+	return func(o *serverOptions) {
+		o.vncRecording = r
+	}
+}
+
+// ServeWithSerialConsoles sets the number of bytes of serial console output kept
+// for each VM and whether serial console output is logged to the experiment's
+// files directory.
+func ServeWithSerialConsoles(scrollback int, logging bool) ServerOption {
+	return func(o *serverOptions) {
+		if scrollback > 0 {
+			o.serialScrollback = scrollback
+		}
+
+		o.serialLogging = logging
+	}
+}
+
 // GetOptions - GET /options.
 func GetOptions(w http.ResponseWriter, r *http.Request) error {
 	var (
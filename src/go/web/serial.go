@@ -0,0 +1,127 @@
+package web
+
+import (
+	"errors"
+	"fmt"
+	"html/template"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"golang.org/x/net/websocket"
+
+	"phenix/api/vm"
+	"phenix/util/mm"
+	"phenix/util/plog"
+	"phenix/web/middleware"
+	"phenix/web/util"
+	"phenix/web/weberror"
+)
+
+// GetVMSerial - GET /experiments/{exp}/vms/{name}/serial.
+func GetVMSerial(w http.ResponseWriter, r *http.Request) error {
+	plog.Debug(plog.TypeSystem, "HTTP handler called", "handler", "GetVMSerial")
+
+	var (
+		ctx      = r.Context()
+		role     = middleware.RoleFromContext(ctx)
+		vars     = mux.Vars(r)
+		fullName = fmt.Sprintf("%s/%s", vars["exp"], vars["name"])
+	)
+
+	if !role.Allowed("vms/serial", "get", fullName) {
+		user := middleware.UserFromContext(ctx)
+		plog.Warn(plog.TypeSecurity, "serial console access not allowed", "user", user, "exp", vars["exp"], "vm", vars["name"])
+		err := weberror.NewWebError(nil, "serial console access for VM %s not allowed for %s", fullName, user)
+
+		return err.SetStatus(http.StatusForbidden)
+	}
+
+	// The terminal page connects to the websocket at the requested path plus
+	// `/ws`, which is what actually opens the serial console.
+	config := struct{ WebSocket string }{WebSocket: "/ws"}
+
+	w.Header().Set("Cache-Control", "no-cache, no-store, must-revalidate")
+
+	if o.unbundled {
+		tmpl := template.Must(template.New("terminal.tmpl").ParseFiles("web/public/terminal.tmpl"))
+		_ = tmpl.Execute(w, config)
+
+		return nil
+	}
+
+	assets, err := GetAssets()
+	if err != nil {
+		return weberror.NewWebError(err, "unable to load terminal page")
+	}
+
+	util.NewBinaryFileSystem(assets).ServeTemplate(w, "terminal.tmpl", config)
+
+	return nil
+}
+
+// GetVMSerialWebSocket - GET /experiments/{exp}/vms/{name}/serial/ws.
+func GetVMSerialWebSocket(w http.ResponseWriter, r *http.Request) error {
+	plog.Debug(plog.TypeSystem, "HTTP handler called", "handler", "GetVMSerialWebSocket")
+
+	console, err := openSerialConsole(r)
+	if err != nil {
+		return err
+	}
+
+	var (
+		vars = mux.Vars(r)
+		user = middleware.UserFromContext(r.Context())
+	)
+
+	plog.Info(plog.TypeAction, "serial console opened", "user", user, "exp", vars["exp"], "vm", vars["name"])
+
+	websocket.Handler(func(ws *websocket.Conn) {
+		ws.PayloadType = websocket.BinaryFrame
+
+		client := console.NewClient()
+		defer func() { _ = client.Close() }()
+
+		proxyWebSocket(ws, client)
+
+		plog.Info(plog.TypeAction, "serial console closed", "user", user, "exp", vars["exp"], "vm", vars["name"])
+	}).ServeHTTP(w, r)
+
+	return nil
+}
+
+// openSerialConsole checks the user is allowed to use the requested VM's serial
+// console and opens it.
+func openSerialConsole(r *http.Request) (*vm.SerialConsole, error) {
+	var (
+		ctx      = r.Context()
+		role     = middleware.RoleFromContext(ctx)
+		vars     = mux.Vars(r)
+		exp      = vars["exp"]
+		name     = vars["name"]
+		fullName = fmt.Sprintf("%s/%s", exp, name)
+	)
+
+	if !role.Allowed("vms/serial", "update", fullName) {
+		user := middleware.UserFromContext(ctx)
+		plog.Warn(plog.TypeSecurity, "serial console access not allowed", "user", user, "exp", exp, "vm", name)
+		err := weberror.NewWebError(nil, "serial console access for VM %s not allowed for %s", fullName, user)
+
+		return nil, err.SetStatus(http.StatusForbidden)
+	}
+
+	console, err := vm.OpenSerialConsole(
+		ctx, exp, name,
+		vm.SerialWithScrollback(o.serialScrollback),
+		vm.SerialWithLog(o.serialLogging),
+	)
+	if err != nil {
+		if errors.Is(err, mm.ErrNoSerialPort) {
+			return nil, weberror.NewWebError(err, "VM %s has no serial port configured", fullName).
+				SetStatus(http.StatusBadRequest)
+		}
+
+		return nil, weberror.NewWebError(err, "unable to open serial console for VM %s", fullName)
+	}
+
+	return console, nil
+}
@@ -10,6 +10,7 @@ import (
 
 	"github.com/gorilla/mux"
 
+	"phenix/api/vm"
 	"phenix/util/common"
 	"phenix/util/plog"
 	"phenix/web/broker"
@@ -209,6 +210,14 @@ func Start(opts ...ServerOption) error {
 	api.Handle("/schemas/{kind}/{version}", weberror.ErrorHandler(GetSchema)).
 		Methods("GET", "OPTIONS")
 
+	api.Handle("/images/builds", weberror.ErrorHandler(GetImageBuilds)).Methods("GET", "OPTIONS")
+	api.Handle("/images/builds/{id}", weberror.ErrorHandler(GetImageBuild)).Methods("GET", "OPTIONS")
+	api.Handle("/images/builds/{id}", weberror.ErrorHandler(CancelImageBuild)).
+		Methods("DELETE", "OPTIONS")
+	api.Handle("/images/builds/{id}/ws", weberror.ErrorHandler(GetImageBuildWebSocket)).
+		Methods("GET", "OPTIONS")
+	api.Handle("/images/{name}/build", weberror.ErrorHandler(BuildImage)).Methods("POST", "OPTIONS")
+
 	api.HandleFunc("/experiments", GetExperiments).Methods("GET", "OPTIONS")
 	api.HandleFunc("/experiments", CreateExperiment).Methods("POST", "OPTIONS")
 	api.Handle("/experiments/builder", weberror.ErrorHandler(CreateExperimentFromBuilder)).
@@ -226,6 +235,14 @@ func Start(opts ...ServerOption) error {
 		Methods("POST", "OPTIONS")
 	api.Handle("/experiments/{name}/stop", weberror.ErrorHandler(StopExperiment)).
 		Methods("POST", "OPTIONS")
+	api.Handle("/experiments/{exp}/snapshots", weberror.ErrorHandler(GetVMSnapshotCatalog)).
+		Methods("GET", "OPTIONS")
+	api.Handle("/experiments/{exp}/snapshots/prune", weberror.ErrorHandler(PruneVMSnapshots)).
+		Methods("POST", "OPTIONS")
+	api.Handle("/experiments/{name}/journal", weberror.ErrorHandler(GetExperimentJournal)).
+		Methods("GET", "OPTIONS")
+	api.Handle("/experiments/{name}/journal", weberror.ErrorHandler(AddExperimentJournalEntry)).
+		Methods("POST", "OPTIONS")
 	api.HandleFunc("/experiments/{exp}/netflow", GetNetflow).Methods("GET", "OPTIONS")
 	api.HandleFunc("/experiments/{exp}/netflow", StartNetflow).Methods("POST", "OPTIONS")
 	api.HandleFunc("/experiments/{exp}/netflow", StopNetflow).Methods("DELETE", "OPTIONS")
@@ -239,6 +256,8 @@ func Start(opts ...ServerOption) error {
 	api.HandleFunc("/experiments/{name}/schedule", GetExperimentSchedule).Methods("GET", "OPTIONS")
 	api.HandleFunc("/experiments/{name}/schedule", ScheduleExperiment).Methods("POST", "OPTIONS")
 	api.HandleFunc("/experiments/{name}/captures", GetExperimentCaptures).Methods("GET", "OPTIONS")
+	api.Handle("/experiments/{name}/captures/merge", weberror.ErrorHandler(MergeExperimentCaptures)).
+		Methods("GET", "OPTIONS")
 	api.HandleFunc("/experiments/{exp}/captureSubnet", StartCaptureSubnet).
 		Methods("POST", "OPTIONS")
 	api.HandleFunc("/experiments/{exp}/stopCaptureSubnet", StopCaptureSubnet).
@@ -271,6 +290,10 @@ func Start(opts ...ServerOption) error {
 	api.HandleFunc("/experiments/{name}/soh", GetExperimentSoH).Methods("GET", "OPTIONS")
 	api.HandleFunc("/experiments/{exp}/vms", GetVMs).Methods("GET", "OPTIONS")
 	api.HandleFunc("/experiments/{exp}/vms", UpdateVMs).Methods("PATCH", "OPTIONS")
+	api.Handle("/experiments/{exp}/vms:batch", weberror.ErrorHandler(BatchVMs)).
+		Methods("POST", "OPTIONS")
+	api.Handle("/experiments/{exp}/vms/exec", weberror.ErrorHandler(ExecVMs)).
+		Methods("POST", "OPTIONS")
 	api.HandleFunc("/experiments/{exp}/vms/{name}", GetVM).Methods("GET", "OPTIONS")
 	api.HandleFunc("/experiments/{exp}/vms/{name}", UpdateVM).Methods("PATCH", "OPTIONS")
 	api.HandleFunc("/experiments/{exp}/vms/{name}", DeleteVM).Methods("DELETE", "OPTIONS")
@@ -289,17 +312,49 @@ func Start(opts ...ServerOption) error {
 	api.HandleFunc("/experiments/{exp}/vms/{name}/vnc", GetVNC).Methods("GET", "OPTIONS")
 	api.HandleFunc("/experiments/{exp}/vms/{name}/vnc/ws", GetVNCWebSocket).
 		Methods("GET", "OPTIONS")
+	api.Handle("/experiments/{exp}/vms/{name}/serial", weberror.ErrorHandler(GetVMSerial)).
+		Methods("GET", "OPTIONS")
+	api.Handle("/experiments/{exp}/vms/{name}/serial/ws", weberror.ErrorHandler(GetVMSerialWebSocket)).
+		Methods("GET", "OPTIONS")
+	api.Handle("/experiments/{exp}/vms/{name}/vnc/recordings", weberror.ErrorHandler(GetVNCRecordings)).
+		Methods("GET", "OPTIONS")
+	api.Handle("/experiments/{exp}/vms/{name}/vnc/recordings/{id}", weberror.ErrorHandler(GetVNCPlayback)).
+		Methods("GET", "OPTIONS")
+	api.Handle("/experiments/{exp}/vms/{name}/vnc/recordings/{id}/ws", weberror.ErrorHandler(GetVNCPlaybackWebSocket)).
+		Methods("GET", "OPTIONS")
+	api.Handle("/experiments/{exp}/vms/{name}/exec", weberror.ErrorHandler(ExecVM)).
+		Methods("POST", "OPTIONS")
+	api.Handle("/experiments/{exp}/vms/{name}/cp", weberror.ErrorHandler(CopyFromVM)).
+		Methods("GET", "OPTIONS")
+	api.Handle("/experiments/{exp}/vms/{name}/cp", weberror.ErrorHandler(CopyToVM)).
+		Methods("PUT", "OPTIONS")
+	api.Handle("/experiments/{exp}/vms/{name}/keys", weberror.ErrorHandler(SendVMKeys)).
+		Methods("POST", "OPTIONS")
+	api.Handle("/experiments/{exp}/vms/{name}/interfaces/impairment", weberror.ErrorHandler(GetVMImpairments)).
+		Methods("GET", "OPTIONS")
+	api.Handle("/experiments/{exp}/vms/{name}/interfaces/{iface}/impairment", weberror.ErrorHandler(ImpairVMInterface)).
+		Methods("PUT", "OPTIONS")
+	api.Handle(
+		"/experiments/{exp}/vms/{name}/interfaces/{iface}/impairment",
+		weberror.ErrorHandler(ClearVMInterfaceImpairment),
+	).Methods("DELETE", "OPTIONS")
+	api.Handle("/experiments/{exp}/vms/{name}/metrics", weberror.ErrorHandler(GetVMMetrics)).
+		Methods("GET", "OPTIONS")
 	api.HandleFunc("/experiments/{exp}/vms/{name}/captures", GetVMCaptures).
 		Methods("GET", "OPTIONS")
 	api.HandleFunc("/experiments/{exp}/vms/{name}/captures", StartVMCapture).
 		Methods("POST", "OPTIONS")
 	api.HandleFunc("/experiments/{exp}/vms/{name}/captures", StopVMCaptures).
 		Methods("DELETE", "OPTIONS")
+	api.Handle("/experiments/{exp}/vms/{name}/captures/{id}/download", weberror.ErrorHandler(DownloadVMCapture)).
+		Methods("GET", "OPTIONS")
 	api.HandleFunc("/experiments/{exp}/vms/{name}/snapshots", GetVMSnapshots).
 		Methods("GET", "OPTIONS")
 	api.HandleFunc("/experiments/{exp}/vms/{name}/snapshots", SnapshotVM).Methods("POST", "OPTIONS")
 	api.HandleFunc("/experiments/{exp}/vms/{name}/snapshots/{snapshot}", RestoreVM).
 		Methods("POST", "OPTIONS")
+	api.Handle("/experiments/{exp}/vms/{name}/snapshots/{snapshot}", weberror.ErrorHandler(DeleteVMSnapshot)).
+		Methods("DELETE", "OPTIONS")
 	api.HandleFunc("/experiments/{exp}/vms/{name}/commit", CommitVM).Methods("POST", "OPTIONS")
 	api.HandleFunc("/experiments/{exp}/vms/{name}/memorySnapshot", CreateVMMemorySnapshot).
 		Methods("POST", "OPTIONS")
@@ -328,6 +383,18 @@ func Start(opts ...ServerOption) error {
 			Queries("path", "{path}")
 		api.HandleFunc("/experiments/{exp}/vms/{name}/files/copy", CopyExperimentFileToMount).
 			Methods("POST", "OPTIONS")
+		api.HandleFunc("/disks/mount", MountDiskImage).
+			Methods("POST", "OPTIONS").
+			Queries("disk", "{disk}")
+		api.HandleFunc("/disks/mount", UnmountDiskImage).
+			Methods("DELETE", "OPTIONS").
+			Queries("disk", "{disk}")
+		api.HandleFunc("/disks/files", GetDiskMountFiles).
+			Methods("GET", "OPTIONS").
+			Queries("disk", "{disk}", "path", "{path}")
+		api.HandleFunc("/disks/files/download", DownloadDiskMountFile).
+			Methods("GET", "OPTIONS").
+			Queries("disk", "{disk}", "path", "{path}")
 	}
 
 	api.HandleFunc("/disks", GetDisks).Methods("GET", "OPTIONS")
@@ -340,6 +407,7 @@ func Start(opts ...ServerOption) error {
 	api.HandleFunc("/disks/resize", ResizeDisk).
 		Methods("POST", "OPTIONS").
 		Queries("disk", "{disk}", "size", "{size}")
+	api.HandleFunc("/disks/graph", GetDiskGraph).Methods("GET", "OPTIONS")
 	api.HandleFunc("/disks/commit", CommitDisk).Methods("POST", "OPTIONS").Queries("disk", "{disk}")
 	api.HandleFunc("/disks/clone", CloneDisk).
 		Methods("POST", "OPTIONS").
@@ -348,11 +416,22 @@ func Start(opts ...ServerOption) error {
 	api.HandleFunc("/disks/rename", RenameDisk).
 		Methods("POST", "OPTIONS").
 		Queries("disk", "{disk}", "new", "{new}")
-	api.HandleFunc("/disks", UploadDisk).Methods("POST", "OPTIONS")
+	api.HandleFunc("/disks/convert", ConvertDisk).
+		Methods("POST", "OPTIONS").
+		Queries("disk", "{disk}", "new", "{new}", "format", "{format}")
+	api.HandleFunc("/disks/import", ImportDisk).
+		Methods("POST", "OPTIONS").
+		Queries("file", "{file}")
 	api.HandleFunc("/disks/download", DownloadDisk).
 		Methods("GET", "OPTIONS").
 		Queries("disk", "{disk}")
 
+	api.Handle("/uploads", weberror.ErrorHandler(GetUploads)).Methods("GET", "OPTIONS")
+	api.Handle("/uploads", weberror.ErrorHandler(CreateUpload)).Methods("POST", "OPTIONS")
+	api.Handle("/uploads/{id}", weberror.ErrorHandler(GetUpload)).Methods("GET", "HEAD", "OPTIONS")
+	api.Handle("/uploads/{id}", weberror.ErrorHandler(PatchUpload)).Methods("PATCH", "OPTIONS")
+	api.Handle("/uploads/{id}", weberror.ErrorHandler(DeleteUpload)).Methods("DELETE", "OPTIONS")
+
 	api.HandleFunc("/vms", GetAllVMs).Methods("GET", "OPTIONS")
 	api.HandleFunc("/applications", GetApplications).Methods("GET", "OPTIONS")
 	api.HandleFunc("/topologies", GetTopologies).Methods("GET", "OPTIONS")
@@ -420,6 +499,12 @@ func Start(opts ...ServerOption) error {
 
 	go scorch.Start(o.basePath)
 
+	if o.metricsInterval > 0 {
+		plog.Info(plog.TypeSystem, "starting VM metrics collector", "interval", o.metricsInterval)
+
+		go vm.CollectMetrics(context.Background(), o.metricsInterval, o.metricsRetention, publishVMMetrics)
+	}
+
 	plog.Info(plog.TypeSystem, "starting log publisher")
 
 	go SyncMinimegaLogs(context.Background(), o.minimegaLogs)
@@ -0,0 +1,183 @@
+package web
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"phenix/api/vm"
+	"phenix/util/plog"
+	"phenix/web/broker"
+	bt "phenix/web/broker/brokertypes"
+	"phenix/web/middleware"
+	"phenix/web/util"
+	"phenix/web/weberror"
+)
+
+// GetVMSnapshotCatalog - GET /experiments/{exp}/snapshots[?vm=<name>].
+func GetVMSnapshotCatalog(w http.ResponseWriter, r *http.Request) error {
+	plog.Debug(plog.TypeSystem, "HTTP handler called", "handler", "GetVMSnapshotCatalog")
+
+	var (
+		ctx  = r.Context()
+		role = middleware.RoleFromContext(ctx)
+		exp  = mux.Vars(r)["exp"]
+		name = r.URL.Query().Get("vm")
+	)
+
+	snapshots, err := vm.SnapshotCatalog(exp, name)
+	if err != nil {
+		return weberror.NewWebError(err, "unable to get snapshot catalog for experiment %s", exp)
+	}
+
+	allowed := []vm.SnapshotInfo{}
+
+	for _, snapshot := range snapshots {
+		if role.Allowed("vms/snapshots", "list", fmt.Sprintf("%s/%s", exp, snapshot.VM)) {
+			allowed = append(allowed, snapshot)
+		}
+	}
+
+	body, _ := json.Marshal(util.WithRoot("snapshots", allowed))
+
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(body) //nolint:gosec // XSS via taint analysis
+
+	return nil
+}
+
+// DeleteVMSnapshot - DELETE /experiments/{exp}/vms/{name}/snapshots/{snapshot}.
+func DeleteVMSnapshot(w http.ResponseWriter, r *http.Request) error {
+	plog.Debug(plog.TypeSystem, "HTTP handler called", "handler", "DeleteVMSnapshot")
+
+	var (
+		ctx      = r.Context()
+		role     = middleware.RoleFromContext(ctx)
+		user     = middleware.UserFromContext(ctx)
+		vars     = mux.Vars(r)
+		exp      = vars["exp"]
+		name     = vars["name"]
+		snapshot = vars["snapshot"]
+		fullName = fmt.Sprintf("%s/%s", exp, name)
+	)
+
+	if !role.Allowed("vms/snapshots", "delete", fullName) {
+		plog.Warn(plog.TypeSecurity, "deleting vm snapshot not allowed", "user", user, "exp", exp, "vm", name)
+		err := weberror.NewWebError(nil, "deleting snapshot for VM %s not allowed for %s", fullName, user)
+
+		return err.SetStatus(http.StatusForbidden)
+	}
+
+	if err := vm.DeleteSnapshot(exp, name, snapshot); err != nil {
+		if errors.Is(err, vm.ErrSnapshotNotFound) {
+			return weberror.NewWebError(err, "snapshot %s not found for VM %s", snapshot, fullName).
+				SetStatus(http.StatusNotFound)
+		}
+
+		return weberror.NewWebError(err, "unable to delete snapshot %s for VM %s", snapshot, fullName)
+	}
+
+	broker.Broadcast(
+		bt.NewRequestPolicy("vms/snapshots", "list", fullName),
+		bt.NewResource("experiment/vm/snapshot", fullName, "delete"),
+		nil,
+	)
+
+	plog.Info(plog.TypeAction, "vm snapshot deleted", "user", user, "exp", exp, "vm", name, "snapshot", snapshot)
+
+	w.WriteHeader(http.StatusNoContent)
+
+	return nil
+}
+
+// PruneVMSnapshots - POST /experiments/{exp}/snapshots/prune.
+func PruneVMSnapshots(w http.ResponseWriter, r *http.Request) error {
+	plog.Debug(plog.TypeSystem, "HTTP handler called", "handler", "PruneVMSnapshots")
+
+	var (
+		ctx  = r.Context()
+		role = middleware.RoleFromContext(ctx)
+		user = middleware.UserFromContext(ctx)
+		exp  = mux.Vars(r)["exp"]
+	)
+
+	var req struct {
+		VM        string `json:"vm"`
+		Keep      *int   `json:"keep"`
+		OlderThan string `json:"olderThan"`
+		DryRun    bool   `json:"dryRun"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return weberror.NewWebError(err, "unable to parse request body").
+			SetStatus(http.StatusBadRequest)
+	}
+
+	if req.VM != "" && !role.Allowed("vms/snapshots", "delete", fmt.Sprintf("%s/%s", exp, req.VM)) {
+		plog.Warn(plog.TypeSecurity, "pruning vm snapshots not allowed", "user", user, "exp", exp, "vm", req.VM)
+		err := weberror.NewWebError(nil, "pruning snapshots for VM %s/%s not allowed for %s", exp, req.VM, user)
+
+		return err.SetStatus(http.StatusForbidden)
+	}
+
+	opts := []vm.PruneOption{
+		vm.PruneDryRun(req.DryRun),
+		// Only snapshots for VMs the user is allowed to delete snapshots for are
+		// considered when pruning all the VMs in the experiment.
+		vm.PruneFilter(func(s vm.SnapshotInfo) bool {
+			return role.Allowed("vms/snapshots", "delete", fmt.Sprintf("%s/%s", exp, s.VM))
+		}),
+	}
+
+	if req.Keep != nil {
+		opts = append(opts, vm.PruneKeep(*req.Keep))
+	}
+
+	if req.OlderThan != "" {
+		olderThan, err := time.ParseDuration(req.OlderThan)
+		if err != nil {
+			return weberror.NewWebError(err, "invalid duration %s", req.OlderThan).
+				SetStatus(http.StatusBadRequest)
+		}
+
+		opts = append(opts, vm.PruneOlderThan(olderThan))
+	}
+
+	pruned, err := vm.PruneSnapshots(exp, req.VM, opts...)
+	if err != nil {
+		if errors.Is(err, vm.ErrNoPruneCriteria) {
+			return weberror.NewWebError(err, "%s", err.Error()).SetStatus(http.StatusBadRequest)
+		}
+
+		return weberror.NewWebError(err, "unable to prune snapshots for experiment %s", exp)
+	}
+
+	if pruned == nil {
+		pruned = []vm.SnapshotInfo{}
+	}
+
+	if !req.DryRun {
+		for _, snapshot := range pruned {
+			fullName := fmt.Sprintf("%s/%s", exp, snapshot.VM)
+
+			broker.Broadcast(
+				bt.NewRequestPolicy("vms/snapshots", "list", fullName),
+				bt.NewResource("experiment/vm/snapshot", fullName, "delete"),
+				nil,
+			)
+		}
+
+		plog.Info(plog.TypeAction, "vm snapshots pruned", "user", user, "exp", exp, "vm", req.VM, "pruned", len(pruned))
+	}
+
+	body, _ := json.Marshal(util.WithRoot("snapshots", pruned))
+
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(body) //nolint:gosec // XSS via taint analysis
+
+	return nil
+}
@@ -0,0 +1,232 @@
+package web
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+
+	"phenix/api/upload"
+	"phenix/util/plog"
+	"phenix/web/middleware"
+	"phenix/web/util"
+	"phenix/web/weberror"
+)
+
+// statusChecksumMismatch is the status the tus checksum extension uses when a
+// chunk doesn't match its checksum.
+const statusChecksumMismatch = 460
+
+type uploadRequest struct {
+	Kind       upload.Kind `json:"kind"`
+	Experiment string      `json:"experiment"`
+	Filename   string      `json:"filename"`
+	Size       int64       `json:"size"`
+	Overwrite  bool        `json:"overwrite"`
+}
+
+type uploadResponse struct {
+	upload.Upload
+
+	Progress float64 `json:"progress"`
+}
+
+// CreateUpload - POST /uploads.
+func CreateUpload(w http.ResponseWriter, r *http.Request) error {
+	plog.Debug(plog.TypeSystem, "HTTP handler called", "handler", "CreateUpload")
+
+	var (
+		ctx  = r.Context()
+		role = middleware.RoleFromContext(ctx)
+		user = middleware.UserFromContext(ctx)
+		req  uploadRequest
+	)
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return weberror.NewWebError(err, "unable to parse request body").
+			SetStatus(http.StatusBadRequest)
+	}
+
+	switch req.Kind {
+	case upload.KindDisk:
+		if !role.Allowed("disks", "upload") {
+			plog.Warn(plog.TypeSecurity, "uploading disk not allowed", "user", user)
+			err := weberror.NewWebError(nil, "uploading disks not allowed for %s", user)
+
+			return err.SetStatus(http.StatusForbidden)
+		}
+	case upload.KindExperimentFile:
+		if !role.Allowed("experiments/files", "create", req.Experiment) {
+			plog.Warn(plog.TypeSecurity, "uploading experiment files not allowed", "user", user, "exp", req.Experiment)
+			err := weberror.NewWebError(nil, "uploading files to experiment %s not allowed for %s", req.Experiment, user)
+
+			return err.SetStatus(http.StatusForbidden)
+		}
+	default:
+		return weberror.NewWebError(nil, "unknown upload kind %q", req.Kind).SetStatus(http.StatusBadRequest)
+	}
+
+	u, err := upload.Create(user, req.Kind, req.Experiment, req.Filename, req.Size, req.Overwrite)
+	if err != nil {
+		if errors.Is(err, upload.ErrFileExists) {
+			return weberror.NewWebError(err, "unable to create upload").SetStatus(http.StatusConflict)
+		}
+
+		return weberror.NewWebError(err, "unable to create upload").SetStatus(http.StatusBadRequest)
+	}
+
+	w.Header().Set("Location", fmt.Sprintf("%s/%s", r.URL.Path, u.ID))
+	writeUpload(w, u, http.StatusCreated)
+
+	return nil
+}
+
+// GetUploads - GET /uploads.
+func GetUploads(w http.ResponseWriter, r *http.Request) error {
+	plog.Debug(plog.TypeSystem, "HTTP handler called", "handler", "GetUploads")
+
+	uploads, err := upload.List(middleware.UserFromContext(r.Context()))
+	if err != nil {
+		return weberror.NewWebError(err, "unable to list uploads")
+	}
+
+	resp := make([]uploadResponse, len(uploads))
+
+	for i, u := range uploads {
+		resp[i] = uploadResponse{Upload: u, Progress: u.Progress()}
+	}
+
+	body, err := json.Marshal(util.WithRoot("uploads", resp))
+	if err != nil {
+		return weberror.NewWebError(err, "unable to marshal uploads")
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(body)
+
+	return nil
+}
+
+// GetUpload - GET|HEAD /uploads/{id}
+// The upload's offset and size are also returned in the Upload-Offset and
+// Upload-Length headers so HEAD can be used to resume an upload.
+func GetUpload(w http.ResponseWriter, r *http.Request) error {
+	plog.Debug(plog.TypeSystem, "HTTP handler called", "handler", "GetUpload")
+
+	u, err := getUserUpload(r)
+	if err != nil {
+		return err
+	}
+
+	writeUpload(w, u, http.StatusOK)
+
+	return nil
+}
+
+// PatchUpload - PATCH /uploads/{id}
+// The request body is the next chunk of the file, written at the offset given
+// in the Upload-Offset header. If the Upload-Checksum header is provided, the
+// chunk is only kept if it matches.
+func PatchUpload(w http.ResponseWriter, r *http.Request) error {
+	plog.Debug(plog.TypeSystem, "HTTP handler called", "handler", "PatchUpload")
+
+	u, err := getUserUpload(r)
+	if err != nil {
+		return err
+	}
+
+	offset, err := strconv.ParseInt(r.Header.Get("Upload-Offset"), 10, 64)
+	if err != nil {
+		return weberror.NewWebError(err, "missing or invalid Upload-Offset header").
+			SetStatus(http.StatusBadRequest)
+	}
+
+	u, err = upload.Write(u.ID, offset, r.Body, r.Header.Get("Upload-Checksum"))
+	if err != nil {
+		w.Header().Set("Upload-Offset", strconv.FormatInt(u.Offset, 10))
+
+		switch {
+		case errors.Is(err, upload.ErrOffsetMismatch), errors.Is(err, upload.ErrUploadBusy),
+			errors.Is(err, upload.ErrUploadComplete), errors.Is(err, upload.ErrFileExists):
+			return weberror.NewWebError(err, "unable to write upload %s", u.ID).SetStatus(http.StatusConflict)
+		case errors.Is(err, upload.ErrChecksumMismatch):
+			return weberror.NewWebError(err, "unable to write upload %s", u.ID).SetStatus(statusChecksumMismatch)
+		case errors.Is(err, upload.ErrUploadTooLarge):
+			return weberror.NewWebError(err, "unable to write upload %s", u.ID).SetStatus(http.StatusRequestEntityTooLarge)
+		case errors.Is(err, upload.ErrUnsupportedChecksum):
+			return weberror.NewWebError(err, "unable to write upload %s", u.ID).SetStatus(http.StatusBadRequest)
+		default:
+			return weberror.NewWebError(err, "unable to write upload %s", u.ID).SetStatus(http.StatusInternalServerError)
+		}
+	}
+
+	if u.Complete() {
+		plog.Info(plog.TypeAction, "uploaded "+string(u.Kind), "user", u.User, "exp", u.Experiment, "file", u.Path)
+	}
+
+	w.Header().Set("Upload-Offset", strconv.FormatInt(u.Offset, 10))
+	w.WriteHeader(http.StatusNoContent)
+
+	return nil
+}
+
+// DeleteUpload - DELETE /uploads/{id}.
+func DeleteUpload(w http.ResponseWriter, r *http.Request) error {
+	plog.Debug(plog.TypeSystem, "HTTP handler called", "handler", "DeleteUpload")
+
+	u, err := getUserUpload(r)
+	if err != nil {
+		return err
+	}
+
+	if err := upload.Delete(u.ID); err != nil {
+		if errors.Is(err, upload.ErrUploadBusy) {
+			return weberror.NewWebError(err, "unable to delete upload %s", u.ID).SetStatus(http.StatusConflict)
+		}
+
+		return weberror.NewWebError(err, "unable to delete upload %s", u.ID)
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+
+	return nil
+}
+
+// getUserUpload returns the upload with the ID in the request path. Uploads
+// can only be accessed by the user that created them.
+func getUserUpload(r *http.Request) (upload.Upload, error) {
+	id := mux.Vars(r)["id"]
+	if id == "" {
+		// Routes registered with net/http's ServeMux (e.g., the file server).
+		id = r.PathValue("id")
+	}
+
+	u, err := upload.Get(id)
+	if err == nil && u.User != middleware.UserFromContext(r.Context()) {
+		err = fmt.Errorf("%w: %s", upload.ErrUploadNotFound, id)
+	}
+
+	if err != nil {
+		if errors.Is(err, upload.ErrUploadNotFound) {
+			return u, weberror.NewWebError(err, "upload %s not found", id).SetStatus(http.StatusNotFound)
+		}
+
+		return u, weberror.NewWebError(err, "unable to get upload %s", id)
+	}
+
+	return u, nil
+}
+
+func writeUpload(w http.ResponseWriter, u upload.Upload, status int) {
+	body, _ := json.Marshal(uploadResponse{Upload: u, Progress: u.Progress()})
+
+	w.Header().Set("Upload-Offset", strconv.FormatInt(u.Offset, 10))
+	w.Header().Set("Upload-Length", strconv.FormatInt(u.Size, 10))
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_, _ = w.Write(body)
+}
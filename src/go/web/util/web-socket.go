@@ -37,3 +37,36 @@ func ConnectWSHandler(endpoint string) func(*websocket.Conn) {
 		plog.Info(plog.TypeSystem, "websocket client disconnected", "endpoint", endpoint)
 	}
 }
+
+// RecordWSHandler is like ConnectWSHandler, but also writes the data received
+// from the remote host to the given recorder. The handler doesn't return until
+// all data received from the remote host has been written to the recorder.
+func RecordWSHandler(endpoint string, recorder io.Writer) func(*websocket.Conn) {
+	return func(ws *websocket.Conn) {
+		ws.PayloadType = websocket.BinaryFrame
+
+		remote, err := (&net.Dialer{}).DialContext(context.Background(), "tcp", endpoint) //nolint:exhaustruct // partial initialization
+		if err != nil {
+			plog.Error(plog.TypeSystem, "dialing websocket", "err", err)
+
+			return
+		}
+
+		plog.Info(plog.TypeSystem, "websocket client connected", "endpoint", endpoint, "recording", true)
+
+		done := make(chan struct{})
+
+		go func() {
+			defer close(done)
+
+			_, _ = io.Copy(ws, io.TeeReader(remote, recorder))
+		}()
+
+		_, _ = io.Copy(remote, ws)
+
+		_ = remote.Close()
+		<-done
+
+		plog.Info(plog.TypeSystem, "websocket client disconnected", "endpoint", endpoint, "recording", true)
+	}
+}
@@ -1,10 +1,16 @@
 package web
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"html/template"
+	"io"
 	"net/http"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/mitchellh/mapstructure"
@@ -13,9 +19,11 @@ import (
 	"phenix/api/vm"
 	"phenix/util/mm"
 	"phenix/util/plog"
+	"phenix/util/rfb"
 	"phenix/web/middleware"
 	"phenix/web/rbac"
 	"phenix/web/util"
+	"phenix/web/weberror"
 )
 
 // GetVNC - GET /experiments/{exp}/vms/{name}/vnc.
@@ -99,6 +107,12 @@ func GetVNC(w http.ResponseWriter, r *http.Request) {
 		name,
 	)
 
+	serveVNCPage(w, config)
+}
+
+// serveVNCPage serves the noVNC page, which connects to the websocket at the
+// requested path plus `/ws`.
+func serveVNCPage(w http.ResponseWriter, config *vncConfig) {
 	if o.unbundled {
 		tmpl := template.Must(template.New("vnc.html").ParseFiles("web/public/vnc.html"))
 		_ = tmpl.Execute(w, config)
@@ -131,9 +145,214 @@ func GetVNCWebSocket(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	websocket.Handler(util.ConnectWSHandler(endpoint)).ServeHTTP(w, r)
+	if !o.vncRecording {
+		websocket.Handler(util.ConnectWSHandler(endpoint)).ServeHTTP(w, r)
+
+		return
+	}
+
+	user := middleware.UserFromContext(r.Context())
+
+	recorder, err := vm.RecordVNC(exp, name, user)
+	if err != nil {
+		// Sessions aren't allowed without a recording when recording is enabled.
+		plog.Error(plog.TypeSystem, "starting VNC recording", "exp", exp, "vm", name, "err", err)
+		http.Error(w, "unable to record VNC session", http.StatusInternalServerError)
+
+		return
+	}
+
+	plog.Info(
+		plog.TypeAction,
+		"vnc session recording started",
+		"user",
+		user,
+		"exp",
+		exp,
+		"vm",
+		name,
+		"recording",
+		recorder.Recording().ID,
+	)
+
+	websocket.Handler(util.RecordWSHandler(endpoint, recorder)).ServeHTTP(w, r)
+
+	if err := recorder.Close(); err != nil {
+		plog.Error(plog.TypeSystem, "closing VNC recording", "exp", exp, "vm", name, "err", err)
+	}
 }
 
+// GetVNCRecordings - GET /experiments/{exp}/vms/{name}/vnc/recordings.
+func GetVNCRecordings(w http.ResponseWriter, r *http.Request) error {
+	plog.Debug(plog.TypeSystem, "HTTP handler called", "handler", "GetVNCRecordings")
+
+	var (
+		ctx      = r.Context()
+		role     = middleware.RoleFromContext(ctx)
+		vars     = mux.Vars(r)
+		exp      = vars["exp"]
+		name     = vars["name"]
+		fullName = fmt.Sprintf("%s/%s", exp, name)
+	)
+
+	if !role.Allowed("vms/recordings", "list", fullName) {
+		user := middleware.UserFromContext(ctx)
+		plog.Warn(plog.TypeSecurity, "listing vnc recordings not allowed", "user", user, "exp", exp, "vm", name)
+		err := weberror.NewWebError(nil, "listing VNC recordings for VM %s not allowed for %s", fullName, user)
+
+		return err.SetStatus(http.StatusForbidden)
+	}
+
+	recordings, err := vm.VNCRecordings(exp, name)
+	if err != nil {
+		return weberror.NewWebError(err, "unable to list VNC recordings for VM %s", fullName)
+	}
+
+	if recordings == nil {
+		recordings = []vm.VNCRecording{}
+	}
+
+	body, _ := json.Marshal(util.WithRoot("recordings", recordings))
+
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(body) //nolint:gosec // XSS via taint analysis
+
+	return nil
+}
+
+// GetVNCPlayback - GET /experiments/{exp}/vms/{name}/vnc/recordings/{id}.
+func GetVNCPlayback(w http.ResponseWriter, r *http.Request) error {
+	plog.Debug(plog.TypeSystem, "HTTP handler called", "handler", "GetVNCPlayback")
+
+	var (
+		ctx      = r.Context()
+		role     = middleware.RoleFromContext(ctx)
+		user     = middleware.UserFromContext(ctx)
+		vars     = mux.Vars(r)
+		exp      = vars["exp"]
+		name     = vars["name"]
+		id       = vars["id"]
+		fullName = fmt.Sprintf("%s/%s", exp, name)
+	)
+
+	if !role.Allowed("vms/recordings", "get", fullName) {
+		plog.Warn(plog.TypeSecurity, "vnc playback not allowed", "user", user, "exp", exp, "vm", name)
+		err := weberror.NewWebError(nil, "playing back VNC recordings for VM %s not allowed for %s", fullName, user)
+
+		return err.SetStatus(http.StatusForbidden)
+	}
+
+	f, recording, err := vm.OpenVNCRecording(exp, name, id)
+	if err != nil {
+		if errors.Is(err, vm.ErrVNCRecordingNotFound) {
+			return weberror.NewWebError(err, "VNC recording %s not found for VM %s", id, fullName).
+				SetStatus(http.StatusNotFound)
+		}
+
+		return weberror.NewWebError(err, "unable to open VNC recording %s for VM %s", id, fullName)
+	}
+
+	_ = f.Close()
+
+	token, _ := ctx.Value(middleware.ContextKeyJWT).(string)
+	config := newVNCBannerConfig(token, exp, name)
+
+	config.finalize(fmt.Sprintf(
+		"PLAYBACK - EXP: %s - VM: %s - USER: %s - %s",
+		exp, name, recording.User, recording.Start.Format(time.RFC3339),
+	))
+
+	w.Header().Set("Cache-Control", "no-cache, no-store, must-revalidate")
+	w.Header().Set("Pragma", "no-cache")
+	w.Header().Set("Expires", "0")
+
+	plog.Info(plog.TypeAction, "vnc playback opened", "user", user, "exp", exp, "vm", name, "recording", id)
+
+	serveVNCPage(w, config)
+
+	return nil
+}
+
+// GetVNCPlaybackWebSocket - GET /experiments/{exp}/vms/{name}/vnc/recordings/{id}/ws[?speed=<n>].
+func GetVNCPlaybackWebSocket(w http.ResponseWriter, r *http.Request) error {
+	plog.Debug(plog.TypeSystem, "HTTP handler called", "handler", "GetVNCPlaybackWebSocket")
+
+	var (
+		ctx      = r.Context()
+		role     = middleware.RoleFromContext(ctx)
+		vars     = mux.Vars(r)
+		exp      = vars["exp"]
+		name     = vars["name"]
+		id       = vars["id"]
+		fullName = fmt.Sprintf("%s/%s", exp, name)
+		speed    = 1.0
+	)
+
+	if !role.Allowed("vms/recordings", "get", fullName) {
+		err := weberror.NewWebError(nil, "playing back VNC recordings for VM %s not allowed", fullName)
+
+		return err.SetStatus(http.StatusForbidden)
+	}
+
+	if s := r.URL.Query().Get("speed"); s != "" {
+		var err error
+
+		speed, err = strconv.ParseFloat(s, 64)
+		if err != nil || speed <= 0 {
+			return weberror.NewWebError(err, "invalid playback speed %s", s).
+				SetStatus(http.StatusBadRequest)
+		}
+	}
+
+	f, _, err := vm.OpenVNCRecording(exp, name, id)
+	if err != nil {
+		if errors.Is(err, vm.ErrVNCRecordingNotFound) {
+			return weberror.NewWebError(err, "VNC recording %s not found for VM %s", id, fullName).
+				SetStatus(http.StatusNotFound)
+		}
+
+		return weberror.NewWebError(err, "unable to open VNC recording %s for VM %s", id, fullName)
+	}
+
+	defer f.Close()
+
+	reader, err := rfb.NewReader(f)
+	if err != nil {
+		return weberror.NewWebError(err, "unable to read VNC recording %s for VM %s", id, fullName)
+	}
+
+	websocket.Handler(func(ws *websocket.Conn) {
+		ws.PayloadType = websocket.BinaryFrame
+
+		ctx, cancel := context.WithCancel(ws.Request().Context())
+		defer cancel()
+
+		// Input from the client is discarded, but it's read to detect when the
+		// client disconnects.
+		go func() {
+			_, _ = io.Copy(io.Discard, ws)
+
+			cancel()
+		}()
+
+		if err := rfb.Play(ctx, ws, reader, speed, vncPlaybackMaxIdle); err != nil && !errors.Is(err, context.Canceled) {
+			plog.Error(plog.TypeSystem, "playing back VNC recording", "exp", exp, "vm", name, "recording", id, "err", err)
+
+			return
+		}
+
+		// Keep the connection open so the client continues to show the final
+		// screen of the recording.
+		<-ctx.Done()
+	}).ServeHTTP(w, r)
+
+	return nil
+}
+
+// vncPlaybackMaxIdle is the longest idle period in a VNC recording that's
+// played back as-is. Longer periods are shortened to it.
+const vncPlaybackMaxIdle = 5 * time.Second
+
 type bannerConfig struct {
 	BannerLines     []string `mapstructure:"banner"`
 	BackgroundColor string   `mapstructure:"backgroundColor"`